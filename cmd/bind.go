@@ -2,209 +2,533 @@ package cmd
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"image"
-	_ "image/png" // Register PNG decoder
+	_ "image/jpeg" // Register JPEG decoder
+	_ "image/png"  // Register PNG decoder
 	"io"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strings"
 
+	"github.com/Beastly713/horcrux/pkg/armor"
 	"github.com/Beastly713/horcrux/pkg/format"
+	"github.com/Beastly713/horcrux/pkg/paper"
 	"github.com/Beastly713/horcrux/pkg/pipeline"
+	"github.com/Beastly713/horcrux/pkg/qr"
 	"github.com/Beastly713/horcrux/pkg/shamir"
 	"github.com/Beastly713/horcrux/pkg/stego"
+	"github.com/Beastly713/horcrux/pkg/wordlist"
 	"github.com/spf13/cobra"
 )
 
 var (
 	outDir    string
 	overwrite bool
+	scanPaths []string
+	keyWords  []string
 )
 
+// loadedHorcrux holds a parsed horcrux and the open file backing its body.
+type loadedHorcrux struct {
+	Path   string
+	Header *format.Header
+	Body   io.Reader
+	File   *os.File // Kept open for standard files, nil for images and armored text
+}
+
 // bindCmd represents the bind command
 var bindCmd = &cobra.Command{
 	Use:   "bind [directory]",
 	Short: "Reconstruct the original file from a set of horcruxes",
-	Long: `Bind looks for .horcrux and .png files in the specified directory 
-(or current directory if not provided), validates them, and attempts to 
-reconstruct the original file.
+	Long: `Bind looks for horcruxes in the specified directory (or current
+directory if not provided), validates them, and attempts to reconstruct the
+original file. Files are recognized by their contents, whatever their names:
+plain horcruxes, and images, recordings, PDF and ZIP files and texts with a
+horcrux hidden or stored in them.
+
+Images and recordings split with --stego-passphrase need the same
+--stego-passphrase here.
+
+Armored horcruxes pasted into any text file are read too, several to a file.
+Pass "-" as the directory to read armored horcruxes from stdin.
+
+Paper backups are restored with --scan, pointing at photos or scans (PNG/JPEG)
+of the QR codes, plus one --words per horcrux with its key fragment words.
 
 You need at least T (threshold) valid horcruxes to succeed.`,
 	Args: cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		// 1. Determine Source Directory
+		// When restoring from paper only, the directory is scanned just if given.
 		sourceDir := "."
 		if len(args) > 0 {
 			sourceDir = args[0]
+		} else if len(scanPaths) > 0 {
+			sourceDir = ""
 		}
 
+		// Ctrl-C stops the bind cleanly, before anything is written
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+		defer stop()
+		out, progress, done := cliProgress()
+		defer done()
+
 		// 2. Gather files
-		files, err := os.ReadDir(sourceDir)
+		groups, err := gatherHorcruxes(ctx, cmd, sourceDir, out, progress)
 		if err != nil {
-			return fmt.Errorf("failed to read directory: %w", err)
+			return err
 		}
 
-		// internal struct to hold open file references
-		type loadedHorcrux struct {
-			Path   string
-			Header *format.Header
-			Body   io.Reader
-			File   *os.File // Kept open for standard files, nil for images
+		// 3. Process Each Group
+		for _, group := range groups {
+			defer closeHorcruxes(group)
+			if err := resurrect(ctx, group, nil, out, progress); err != nil {
+				return err
+			}
 		}
 
-		// Group files by an ID (Filename + Timestamp)
-		groups := make(map[string][]*loadedHorcrux)
+		return nil
+	},
+}
 
-		fmt.Printf("Scanning for horcruxes in %s...\n", sourceDir)
+func init() {
+	rootCmd.AddCommand(bindCmd)
 
-		for _, f := range files {
-			if f.IsDir() {
-				continue
+	bindCmd.Flags().StringVarP(&outDir, "destination", "d", "", "Directory to write the resurrected file")
+	bindCmd.Flags().BoolVar(&overwrite, "overwrite", false, "Overwrite existing file if present")
+	bindCmd.Flags().StringVar(&stegoPassphrase, "stego-passphrase", "", "Passphrase the carrier images were split with")
+	bindCmd.Flags().StringSliceVar(&scanPaths, "scan", nil, "Photos or scans (or directories of them) of paper backup QR codes")
+	bindCmd.Flags().StringArrayVar(&keyWords, "words", nil, "Key fragment words of one paper horcrux (repeat for each)")
+}
+
+// readArmoredHorcruxes decodes every armored block in r and parses each one as
+// a horcrux. Text without armor is skipped silently, since bind also looks at
+// unrelated .txt files. Blocks that are damaged are reported and skipped,
+// keeping the rest.
+func readArmoredHorcruxes(name string, r io.Reader, out io.Writer) []*loadedHorcrux {
+	blocks, err := armor.Decode(r)
+	if errors.Is(err, armor.ErrNoArmor) {
+		return nil
+	}
+	if err != nil {
+		// One line per bad block
+		for _, line := range strings.Split(err.Error(), "\n") {
+			fmt.Fprintf(out, "Skipping invalid armor in %s: %s\n", name, line)
+		}
+	}
+
+	var horcruxes []*loadedHorcrux
+	for _, block := range blocks {
+		reader, err := format.NewReader(bytes.NewReader(block.Data))
+		if err != nil {
+			fmt.Fprintf(out, "Skipping invalid armored block %d in %s: %v\n", block.Number, name, err)
+			continue
+		}
+		horcruxes = append(horcruxes, &loadedHorcrux{
+			Path:   fmt.Sprintf("%s#%d", name, block.Number),
+			Header: reader.Header,
+			Body:   reader.Body,
+		})
+	}
+	return horcruxes
+}
+
+// readPaperHorcruxes decodes the QR codes in the given images (or directories
+// of images), reassembles the shards and attaches the key fragments typed in
+// as word lists.
+func readPaperHorcruxes(paths []string, phrases []string, out io.Writer) []*loadedHorcrux {
+	shards := readPaperShards(paths, out)
+
+	// 3. Attach the key fragments
+	var horcruxes []*loadedHorcrux
+	for _, shard := range shards {
+		attached := false
+		for _, phrase := range phrases {
+			if shard.AttachWords(wordlist.Split(phrase)) == nil {
+				attached = true
+				break
 			}
+		}
+		if !attached {
+			fmt.Fprintf(out, "Skipping horcrux %d of %s: no matching --words for its key fragment\n",
+				shard.Header.Index, shard.Header.OriginalFilename)
+			continue
+		}
 
-			ext := strings.ToLower(filepath.Ext(f.Name()))
-			if ext != ".horcrux" && ext != ".png" {
+		horcruxes = append(horcruxes, &loadedHorcrux{
+			Path:   fmt.Sprintf("paper horcrux %d", shard.Header.Index),
+			Header: shard.Header,
+			Body:   bytes.NewReader(shard.Body),
+		})
+	}
+	return horcruxes
+}
+
+// readPaperShards decodes the QR codes in the given images (or directories of
+// images) and reassembles the shards, still without their key fragments.
+func readPaperShards(paths []string, out io.Writer) []*paper.Shard {
+	// 1. Collect image files
+	var images []string
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			fmt.Fprintf(out, "Skipping %s: %v\n", p, err)
+			continue
+		}
+		if !info.IsDir() {
+			images = append(images, p)
+			continue
+		}
+		entries, err := os.ReadDir(p)
+		if err != nil {
+			fmt.Fprintf(out, "Skipping %s: %v\n", p, err)
+			continue
+		}
+		for _, e := range entries {
+			ext := strings.ToLower(filepath.Ext(e.Name()))
+			if !e.IsDir() && (ext == ".png" || ext == ".jpg" || ext == ".jpeg") {
+				images = append(images, filepath.Join(p, e.Name()))
+			}
+		}
+	}
+
+	// 2. Decode every QR code
+	var payloads [][]byte
+	for _, path := range images {
+		file, err := os.Open(path)
+		if err != nil {
+			fmt.Fprintf(out, "Skipping unreadable image %s: %v\n", path, err)
+			continue
+		}
+		img, _, err := image.Decode(file)
+		file.Close()
+		if err != nil {
+			fmt.Fprintf(out, "Skipping invalid image %s: %v\n", path, err)
+			continue
+		}
+
+		codes, err := qr.Decode(img)
+		if err != nil {
+			fmt.Fprintf(out, "No QR codes found in %s\n", path)
+			continue
+		}
+		fmt.Fprintf(out, "Read %d QR code(s) from %s\n", len(codes), filepath.Base(path))
+		payloads = append(payloads, codes...)
+	}
+
+	shards, err := paper.Assemble(payloads)
+	if err != nil {
+		fmt.Fprintf(out, "Some paper horcruxes are incomplete:\n%v\n", err)
+	}
+	return shards
+}
+
+// gatherHorcruxes reads every horcrux in sourceDir ("-" for armored text on
+// stdin, "" for none) and in the --scan paths, grouped by the file they split.
+// It prints to out, reports each file read to progress, and stops once ctx is
+// done.
+func gatherHorcruxes(ctx context.Context, cmd *cobra.Command, sourceDir string, out io.Writer, progress pipeline.Reporter) (map[string][]*loadedHorcrux, error) {
+	// Group files by split session
+	groups := make(map[string][]*loadedHorcrux)
+	addHorcrux := func(lh *loadedHorcrux) {
+		groupID := sessionID(lh.Header)
+		for _, other := range groups[groupID] {
+			if other.Header.Index == lh.Header.Index {
+				// The same horcrux twice, say pasted into two texts
+				fmt.Fprintf(out, "Skipping %s: horcrux #%d was already found in %s\n", filepath.Base(lh.Path), lh.Header.Index, filepath.Base(other.Path))
+				if lh.File != nil {
+					lh.File.Close()
+				}
+				return
+			}
+		}
+		groups[groupID] = append(groups[groupID], lh)
+	}
+
+	if len(scanPaths) > 0 {
+		for _, lh := range readPaperHorcruxes(scanPaths, keyWords, out) {
+			addHorcrux(lh)
+		}
+	}
+
+	if sourceDir == "-" {
+		// Armored horcruxes pasted on stdin
+		fmt.Fprintln(out, "Reading armored horcruxes from stdin...")
+		for _, lh := range readArmoredHorcruxes("stdin", cmd.InOrStdin(), out) {
+			addHorcrux(lh)
+		}
+	} else if sourceDir != "" {
+		files, err := os.ReadDir(sourceDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read directory: %w", err)
+		}
+
+		fmt.Fprintf(out, "Scanning for horcruxes in %s...\n", sourceDir)
+
+		// Parts of horcruxes spread over several images, by set
+		spread := make(map[[16]byte][]*stego.Part)
+		spreadPaths := make(map[[16]byte][]string)
+
+		for i, f := range files {
+			if err := ctx.Err(); err != nil {
+				return nil, fmt.Errorf("bind cancelled: %w", err)
+			}
+			progress.Report(pipeline.Event{Stage: pipeline.StageExtract, Done: int64(i), Total: int64(len(files))})
+			if f.IsDir() {
 				continue
 			}
 
+			// Files are recognized by their contents, so renamed horcruxes
+			// and carriers are found too
 			path := filepath.Join(sourceDir, f.Name())
-			file, err := os.Open(path)
+			head, err := sniffFile(path)
 			if err != nil {
-				fmt.Printf("Skipping unreadable file %s: %v\n", f.Name(), err)
+				fmt.Fprintf(out, "Skipping unreadable file %s: %v\n", f.Name(), err)
+				continue
+			}
+			if !mayHoldHorcrux(f.Name(), head) {
 				continue
 			}
 
 			var inputReader io.Reader
 			var fileToKeepOpen *os.File
 
-			if ext == ".png" {
-				// --- STEGANOGRAPHY HANDLING ---
-				img, _, err := image.Decode(file)
-				file.Close() // Close image file immediately after decoding
+			if isHorcruxFile(f.Name(), head) {
+				// --- STANDARD HANDLING ---
+				file, err := os.Open(path)
 				if err != nil {
-					fmt.Printf("Skipping invalid image %s: %v\n", f.Name(), err)
+					fmt.Fprintf(out, "Skipping unreadable file %s: %v\n", f.Name(), err)
+					continue
+				}
+				inputReader = file
+				fileToKeepOpen = file
+			} else {
+				// --- CARRIER HANDLING (stego, container or armored text) ---
+				raw, err := os.ReadFile(path)
+				if err != nil {
+					fmt.Fprintf(out, "Skipping unreadable file %s: %v\n", f.Name(), err)
 					continue
 				}
 
-				hiddenData, err := stego.Extract(img)
+				hiddenData, err := extractCarrier(raw)
+				if errors.Is(err, stego.ErrNoHiddenData) {
+					// Ordinary images and documents are expected alongside
+					// horcruxes, but text may have armored ones pasted in
+					if armor.IsArmored(raw) {
+						for _, lh := range readArmoredHorcruxes(path, bytes.NewReader(raw), out) {
+							addHorcrux(lh)
+						}
+					}
+					continue
+				}
 				if err != nil {
-					if err != stego.ErrNoHiddenData {
-						fmt.Printf("Failed to extract data from %s: %v\n", f.Name(), err)
+					fmt.Fprintf(out, "Failed to extract data from %s: %v\n", f.Name(), err)
+					continue
+				}
+				if armor.IsArmored(hiddenData) {
+					// Hidden in text with --carrier-text
+					for _, lh := range readArmoredHorcruxes(path, bytes.NewReader(hiddenData), out) {
+						addHorcrux(lh)
 					}
 					continue
 				}
-
+				if part, ok := stego.ParsePart(hiddenData); ok {
+					// Reassembled once every image has been read
+					spread[part.Set] = append(spread[part.Set], part)
+					spreadPaths[part.Set] = append(spreadPaths[part.Set], path)
+					continue
+				}
 				inputReader = bytes.NewReader(hiddenData)
-				fileToKeepOpen = nil
-
-			} else {
-				// --- STANDARD HANDLING ---
-				inputReader = file
-				fileToKeepOpen = file
 			}
 
-			// 3. Parse Header
+			// Parse Header
 			reader, err := format.NewReader(inputReader)
 			if err != nil {
-				fmt.Printf("Skipping invalid/headerless file %s: %v\n", f.Name(), err)
+				fmt.Fprintf(out, "Skipping invalid/headerless file %s: %v\n", f.Name(), err)
 				if fileToKeepOpen != nil {
 					fileToKeepOpen.Close()
 				}
 				continue
 			}
 
-			groupID := fmt.Sprintf("%s|%d", reader.Header.OriginalFilename, reader.Header.Timestamp)
-
-			lh := &loadedHorcrux{
+			addHorcrux(&loadedHorcrux{
 				Path:   path,
 				Header: reader.Header,
 				Body:   reader.Body,
 				File:   fileToKeepOpen,
-			}
-			groups[groupID] = append(groups[groupID], lh)
+			})
 		}
 
-		if len(groups) == 0 {
-			return fmt.Errorf("no valid horcruxes found in %s", sourceDir)
-		}
+		progress.Report(pipeline.Event{Stage: pipeline.StageExtract, Done: int64(len(files)), Total: int64(len(files))})
 
-		// 4. Process Each Group
-		for _, group := range groups {
-			refHeader := group[0].Header
-			fmt.Printf("\nFound shards for: %s (Threshold: %d/%d)\n", refHeader.OriginalFilename, len(group), refHeader.Threshold)
-
-			defer func(gh []*loadedHorcrux) {
-				for _, h := range gh {
-					if h.File != nil {
-						h.File.Close()
-					}
-				}
-			}(group)
-
-			if len(group) < refHeader.Threshold {
-				fmt.Printf("Not enough horcruxes to restore %s. Need %d, found %d.\n", refHeader.OriginalFilename, refHeader.Threshold, len(group))
+		for set, parts := range spread {
+			paths := spreadPaths[set]
+			data, err := stego.JoinParts(parts)
+			if err != nil {
+				fmt.Fprintf(out, "Skipping horcrux spread over %s and other images: %v\n", strings.Join(baseNames(paths), ", "), err)
 				continue
 			}
-
-			// 5. Reconstruct Key
-			fmt.Println("Reconstructing encryption key...")
-			keyFragments := make([][]byte, 0, len(group))
-			for _, h := range group {
-				keyFragments = append(keyFragments, h.Header.KeyFragment)
-			}
-
-			key, err := shamir.Combine(keyFragments)
+			reader, err := format.NewReader(bytes.NewReader(data))
 			if err != nil {
-				fmt.Printf("Failed to reconstruct key for %s: %v\n", refHeader.OriginalFilename, err)
+				fmt.Fprintf(out, "Skipping invalid/headerless horcrux spread over %s: %v\n", strings.Join(baseNames(paths), ", "), err)
 				continue
 			}
+			addHorcrux(&loadedHorcrux{
+				Path:   paths[0],
+				Header: reader.Header,
+				Body:   reader.Body,
+			})
+		}
+	}
 
-			// 6. Reconstruct Body
-			fmt.Println("Joining shards and decrypting...")
-			shardMap := make(map[int][]byte)
-			for _, h := range group {
-				data, err := io.ReadAll(h.Body)
-				if err != nil {
-					fmt.Printf("Failed to read body of %s: %v\n", h.Path, err)
-					return err
-				}
-				// CRITICAL FIX: Convert 1-based Horcrux Index to 0-based RS Index
-				// Shamir uses 1..N, ReedSolomon uses 0..N-1
-				shardMap[h.Header.Index-1] = data
-			}
+	if len(groups) == 0 {
+		if sourceDir == "" {
+			return nil, fmt.Errorf("no valid horcruxes found in the scanned images")
+		}
+		return nil, fmt.Errorf("no valid horcruxes found in %s", sourceDir)
+	}
+	return groups, nil
+}
 
-			plainText, err := pipeline.JoinPipeline(shardMap, key, refHeader.Total, refHeader.Threshold)
-			if err != nil {
-				fmt.Printf("Reconstruction pipeline failed: %v\n(Did you try to bind corrupted or wrong files?)\n", err)
-				continue
-			}
+// sessionID identifies the split a horcrux came from: the file it split and
+// when.
+func sessionID(h *format.Header) string {
+	return fmt.Sprintf("%s|%d", h.OriginalFilename, h.Timestamp)
+}
 
-			// 7. Write Output
-			finalPath := filepath.Join(outDir, refHeader.OriginalFilename)
-			if outDir == "" {
-				finalPath = refHeader.OriginalFilename
-			}
+// isHorcruxFile reports whether a file, given its name and first bytes, is a
+// plain horcrux. The extension catches those too damaged to recognize, so
+// that bind says why it skips them.
+func isHorcruxFile(name string, head []byte) bool {
+	return format.IsHorcrux(head) || strings.EqualFold(filepath.Ext(name), ".horcrux")
+}
 
-			if _, err := os.Stat(finalPath); err == nil && !overwrite {
-				fmt.Printf("File %s already exists. Use --overwrite to replace it.\n", finalPath)
-				continue
-			}
+// mayHoldHorcrux reports whether a file is a horcrux or in a format a horcrux
+// can be hidden or stored in.
+func mayHoldHorcrux(name string, head []byte) bool {
+	return isHorcruxFile(name, head) || stego.Detect(head) != nil
+}
 
-			if err := os.WriteFile(finalPath, plainText, 0644); err != nil {
-				return fmt.Errorf("failed to write output file: %w", err)
-			}
+// extractCarrier returns the horcrux carried by a file in any registered
+// carrier format, or stego.ErrNoHiddenData.
+func extractCarrier(raw []byte) ([]byte, error) {
+	return stego.ExtractAny(raw, stego.Options{Passphrase: stegoPassphrase})
+}
+
+// baseNames returns the last element of each path.
+func baseNames(paths []string) []string {
+	names := make([]string, len(paths))
+	for i, p := range paths {
+		names[i] = filepath.Base(p)
+	}
+	return names
+}
 
-			fmt.Printf("Successfully resurrected: %s\n", finalPath)
+// closeHorcruxes closes the files still open behind a group.
+func closeHorcruxes(group []*loadedHorcrux) {
+	for _, h := range group {
+		if h.File != nil {
+			h.File.Close()
 		}
+	}
+}
 
-		return nil
-	},
+// combineKey reconstructs the encryption key from the key fragments in the
+// headers of a group.
+func combineKey(group []*loadedHorcrux) ([]byte, error) {
+	keyFragments := make([][]byte, 0, len(group))
+	for _, h := range group {
+		keyFragments = append(keyFragments, h.Header.KeyFragment)
+	}
+	return shamir.Combine(keyFragments)
 }
 
-func init() {
-	rootCmd.AddCommand(bindCmd)
+// resurrect rebuilds and writes the original file of one group, printing to
+// out and reporting its progress. The key is combined from the key fragments
+// unless one is given. Problems specific to the group are reported and
+// skipped; only failures that should stop the whole run are returned,
+// cancellation of ctx among them.
+func resurrect(ctx context.Context, group []*loadedHorcrux, key []byte, out io.Writer, progress pipeline.Reporter) error {
+	refHeader := group[0].Header
+	fmt.Fprintf(out, "\nFound shards for: %s (Threshold: %d/%d)\n", refHeader.OriginalFilename, len(group), refHeader.Threshold)
+
+	if len(group) < refHeader.Threshold {
+		fmt.Fprintf(out, "Not enough horcruxes to restore %s. Need %d, found %d.\n", refHeader.OriginalFilename, refHeader.Threshold, len(group))
+		headers := make([]*format.Header, len(group))
+		for i, h := range group {
+			headers[i] = h.Header
+		}
+		fmt.Fprintf(out, "Missing shards held by: %s\n", strings.Join(format.MissingCustodians(headers), ", "))
+		return nil
+	}
 
-	bindCmd.Flags().StringVarP(&outDir, "destination", "d", "", "Directory to write the resurrected file")
-	bindCmd.Flags().BoolVar(&overwrite, "overwrite", false, "Overwrite existing file if present")
-}
\ No newline at end of file
+	// 1. Reconstruct Key
+	if key == nil {
+		fmt.Fprintln(out, "Reconstructing encryption key...")
+		var err error
+		key, err = combineKey(group)
+		if err != nil {
+			fmt.Fprintf(out, "Failed to reconstruct key for %s: %v\n", refHeader.OriginalFilename, err)
+			return nil
+		}
+	}
+
+	// 2. Reconstruct Body
+	fmt.Fprintln(out, "Joining shards and decrypting...")
+	shardMap := make(map[int][]byte)
+	for _, h := range group {
+		data, err := io.ReadAll(h.Body)
+		if err != nil {
+			fmt.Fprintf(out, "Failed to read body of %s: %v\n", h.Path, err)
+			return err
+		}
+		// CRITICAL FIX: Convert 1-based Horcrux Index to 0-based RS Index
+		// Shamir uses 1..N, ReedSolomon uses 0..N-1
+		shardMap[h.Header.Index-1] = data
+	}
+
+	plainText, err := joinShards(ctx, refHeader, shardMap, key, out, progress)
+	if ctx.Err() != nil {
+		return fmt.Errorf("bind cancelled: %w", ctx.Err())
+	}
+	if err != nil {
+		fmt.Fprintf(out, "Reconstruction pipeline failed: %v\n(Did you try to bind corrupted or wrong files?)\n", err)
+		return nil
+	}
+
+	// 3. Write Output
+	finalPath := filepath.Join(outDir, refHeader.OriginalFilename)
+	if outDir == "" {
+		finalPath = refHeader.OriginalFilename
+	}
+
+	if _, err := os.Stat(finalPath); err == nil && !overwrite {
+		fmt.Fprintf(out, "File %s already exists. Use --overwrite to replace it.\n", finalPath)
+		return nil
+	}
+
+	progress.Report(pipeline.Event{Stage: pipeline.StageWrite, Total: 1})
+	if err := os.WriteFile(finalPath, plainText, 0644); err != nil {
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+	progress.Report(pipeline.Event{Stage: pipeline.StageWrite, Done: 1, Total: 1})
+
+	fmt.Fprintf(out, "Successfully resurrected: %s\n", finalPath)
+	return nil
+}
+
+// joinShards runs the join pipeline matching the horcruxes' format. Legacy
+// horcruxes from the original tool carry no integrity check, so a warning is
+// printed for them.
+func joinShards(ctx context.Context, refHeader *format.Header, shardMap map[int][]byte, key []byte, out io.Writer, progress pipeline.Reporter) ([]byte, error) {
+	if refHeader.Legacy {
+		fmt.Fprintln(out, "Legacy horcruxes (jesseduffield/horcrux): the result cannot be integrity checked.")
+		fmt.Fprintln(out, "Run 'horcrux upgrade' to re-split them in the authenticated format.")
+		return pipeline.JoinLegacy(shardMap, key, refHeader.Total, refHeader.Threshold)
+	}
+	return pipeline.JoinPipelineContext(ctx, shardMap, key, refHeader.Total, refHeader.Threshold, progress)
+}
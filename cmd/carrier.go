@@ -0,0 +1,314 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/Beastly713/horcrux/pkg/armor"
+	"github.com/Beastly713/horcrux/pkg/stego"
+)
+
+// carrierFile is a file shards are hidden in, in whichever registered
+// carrier format its contents turn out to be. Baseline JPEGs stay JPEGs;
+// other images become PNGs. The file is read only when needed, so a large
+// album does not sit in memory.
+type carrierFile struct {
+	path     string
+	raw      []byte
+	carrier  stego.Carrier // sniffed on first read
+	capacity int           // -1 until measured
+	enlarge  bool          // scale up the image when a shard does not fit
+	out      io.Writer     // where to say what happens to it
+}
+
+func newCarrierFile(path string, out io.Writer) *carrierFile {
+	return &carrierFile{path: path, capacity: -1, out: out}
+}
+
+// load reads the file and, the first time, detects its format.
+func (c *carrierFile) load() error {
+	if c.raw != nil {
+		return nil
+	}
+	raw, err := os.ReadFile(c.path)
+	if err != nil {
+		return fmt.Errorf("failed to open carrier: %w", err)
+	}
+	if c.carrier == nil {
+		c.carrier = stego.Detect(raw[:min(len(raw), stego.SniffLen)])
+		if c.carrier == nil || c.carrier == stego.ContainerCarrier {
+			return fmt.Errorf("%s is not an image, WAV recording or text file", filepath.Base(c.path))
+		}
+	}
+	c.raw = raw
+	return nil
+}
+
+// unload drops the file contents until they are needed again.
+func (c *carrierFile) unload() {
+	c.raw = nil
+}
+
+// isImage reports whether the carrier is a PNG or JPEG image.
+func (c *carrierFile) isImage() bool {
+	return c.carrier == stego.ImageCarrier || c.carrier == stego.JPEGCarrier
+}
+
+// isText reports whether the carrier is UTF-8 text, whichever way it hides
+// data.
+func (c *carrierFile) isText() bool {
+	return c.carrier != nil && c.carrier.Name() == stego.TextCarrier.Name()
+}
+
+// fallBackToPNG gives up on embedding into a JPEG as a JPEG.
+func (c *carrierFile) fallBackToPNG(err error) {
+	fmt.Fprintf(c.out, "%s cannot carry data as a JPEG (%v); writing PNG copies instead\n", filepath.Base(c.path), err)
+	c.carrier = stego.ImageCarrier
+}
+
+// measure returns how many bytes the carrier can hide.
+func (c *carrierFile) measure(opts stego.Options) (int, error) {
+	if c.capacity >= 0 {
+		return c.capacity, nil
+	}
+	if err := c.load(); err != nil {
+		return 0, err
+	}
+	n, err := c.carrier.Capacity(c.raw, opts)
+	if errors.Is(err, stego.ErrUnsupportedJPEG) {
+		c.fallBackToPNG(err)
+		n, err = c.carrier.Capacity(c.raw, opts)
+	}
+	if err != nil {
+		return 0, err
+	}
+	c.capacity = n
+	return n, nil
+}
+
+// check reports whether n bytes fit in the carrier, enlarged as far as
+// allowed if c.enlarge is set.
+func (c *carrierFile) check(n int, opts stego.Options) error {
+	capacity, err := c.measure(opts)
+	if err != nil {
+		return err
+	}
+	if c.isText() {
+		n = armoredSize(n)
+	}
+	if capacity >= n {
+		return nil
+	}
+	switch {
+	case c.isText():
+		return fmt.Errorf("%s holds %d bytes, %d short (use a text with more lines, or zero-width characters)", filepath.Base(c.path), capacity, n-capacity)
+	case !c.isImage():
+		return fmt.Errorf("%s holds %d bytes, %d short (use a longer recording)", filepath.Base(c.path), capacity, n-capacity)
+	case c.enlarge:
+		cfg, _, err := image.DecodeConfig(bytes.NewReader(c.raw))
+		if err != nil {
+			return fmt.Errorf("failed to decode carrier image %s: %w", filepath.Base(c.path), err)
+		}
+		if stego.Capacity(image.Rect(0, 0, cfg.Width*stego.MaxEnlargement, cfg.Height*stego.MaxEnlargement), opts) >= n {
+			return nil
+		}
+		return fmt.Errorf("%s is too small for %d bytes even enlarged %d times", filepath.Base(c.path), n, stego.MaxEnlargement)
+	}
+	return fmt.Errorf("%s holds %d bytes, %d short (--auto-scale enlarges it to fit)", filepath.Base(c.path), capacity, n-capacity)
+}
+
+// embed hides data in a copy of the carrier and returns the encoded file and
+// its extension.
+func (c *carrierFile) embed(data []byte, opts stego.Options) ([]byte, string, error) {
+	if err := c.load(); err != nil {
+		return nil, "", err
+	}
+	if c.isText() {
+		// Armored, so the text holds a horcrux that can be pasted back out
+		var armored bytes.Buffer
+		if err := armor.Encode(&armored, data); err != nil {
+			return nil, "", err
+		}
+		data = armored.Bytes()
+	}
+
+	carrier, raw := c.carrier, c.raw
+	if c.enlarge && c.isImage() {
+		capacity, err := c.measure(opts)
+		if err != nil {
+			return nil, "", err
+		}
+		if capacity < len(data) {
+			// The original stays as it is for the next shard
+			if raw, err = c.enlarged(len(data), opts); err != nil {
+				return nil, "", err
+			}
+			carrier = stego.ImageCarrier
+		}
+	}
+
+	encoded, ext, err := carrier.Embed(raw, data, opts)
+	if carrier == stego.JPEGCarrier {
+		// Keep the carrier's format by hiding in its DCT coefficients
+		switch {
+		case errors.Is(err, stego.ErrUnsupportedJPEG):
+			c.fallBackToPNG(err)
+			encoded, ext, err = stego.ImageCarrier.Embed(raw, data, opts)
+		case errors.Is(err, stego.ErrMessageTooLarge):
+			return nil, "", fmt.Errorf("%w (a JPEG holds far less than a PNG of the same size; use a larger photo or a PNG carrier)", err)
+		}
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	if ext == "" {
+		ext = filepath.Ext(c.path)
+	}
+	return encoded, ext, nil
+}
+
+// enlarged returns the carrier image scaled up to hold n bytes, as a PNG.
+func (c *carrierFile) enlarged(n int, opts stego.Options) ([]byte, error) {
+	img, _, err := image.Decode(bytes.NewReader(c.raw))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode carrier image %s: %w", filepath.Base(c.path), err)
+	}
+	big, err := stego.Enlarge(img, n, opts)
+	if err != nil {
+		return nil, err
+	}
+	if b, nb := img.Bounds(), big.Bounds(); nb != b {
+		fmt.Fprintf(c.out, "Enlarged %s from %dx%d to %dx%d to fit\n", filepath.Base(c.path), b.Dx(), b.Dy(), nb.Dx(), nb.Dy())
+	}
+	var buf bytes.Buffer
+	if err := stego.EncodePNG(&buf, big, c.raw); err != nil {
+		return nil, fmt.Errorf("failed to encode png: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// armoredSize is the length of n bytes once armored.
+func armoredSize(n int) int {
+	var buf bytes.Buffer
+	armor.Encode(&buf, make([]byte, n))
+	return buf.Len()
+}
+
+// sniffFile reads the first stego.SniffLen bytes of a file, enough to tell
+// its format.
+func sniffFile(path string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	head := make([]byte, stego.SniffLen)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	return head[:n], nil
+}
+
+// readAlbum lists the images in dir, by name. Files are recognized by their
+// contents, whatever they are called.
+func readAlbum(dir string, out io.Writer) ([]*carrierFile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read carrier directory: %w", err)
+	}
+	var names []string
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		head, err := sniffFile(filepath.Join(dir, e.Name()))
+		if err == nil && stego.ImageCarrier.Detect(head) {
+			names = append(names, e.Name())
+		}
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no PNG or JPEG images in %s", dir)
+	}
+	sort.Strings(names)
+
+	album := make([]*carrierFile, len(names))
+	for i, name := range names {
+		album[i] = newCarrierFile(filepath.Join(dir, name), out)
+	}
+	return album, nil
+}
+
+// spreadOverAlbum hides data in as many album images as it takes, starting
+// at *next and moving on through the album, and writes them to dir as
+// baseName, with a part number when it takes more than one. Each part
+// records its place in the sequence, so bind needs no file names. It returns
+// the names written.
+//
+// An image is never used twice: copies of one photo holding different shards
+// would show where the data is when compared.
+func spreadOverAlbum(album []*carrierFile, next *int, data []byte, opts stego.Options, dir, baseName string) ([]string, error) {
+	// 1. Pick images until they hold the data
+	var chosen []*carrierFile
+	var sizes []int
+	remaining := len(data)
+	for remaining > 0 {
+		if *next == len(album) {
+			return nil, fmt.Errorf("%w: ran out of album images %d bytes short (the album has %d, and each is used once)", stego.ErrMessageTooLarge, remaining, len(album))
+		}
+		c := album[*next]
+		*next++
+
+		capacity, err := c.measure(opts)
+		c.unload()
+		if err != nil {
+			fmt.Fprintf(c.out, "Skipping carrier %s: %v\n", filepath.Base(c.path), err)
+			continue
+		}
+		if len(chosen) == 0 && capacity >= len(data) {
+			// Fits in one image, embedded as usual
+			chosen, sizes, remaining = []*carrierFile{c}, nil, 0
+			break
+		}
+		if room := capacity - stego.PartOverhead; room > 0 {
+			n := min(room, remaining)
+			chosen = append(chosen, c)
+			sizes = append(sizes, n)
+			remaining -= n
+		}
+	}
+
+	payloads := [][]byte{data}
+	if sizes != nil {
+		var err error
+		payloads, err = stego.SplitParts(data, sizes)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// 2. Embed and write each part
+	names := make([]string, len(chosen))
+	for i, c := range chosen {
+		encoded, ext, err := c.embed(payloads[i], opts)
+		c.unload()
+		if err != nil {
+			return nil, fmt.Errorf("failed to embed into %s: %w", filepath.Base(c.path), err)
+		}
+
+		names[i] = baseName + ext
+		if len(chosen) > 1 {
+			names[i] = fmt.Sprintf("%s_part%d%s", baseName, i+1, ext)
+		}
+		if err := os.WriteFile(filepath.Join(dir, names[i]), encoded, 0644); err != nil {
+			return nil, fmt.Errorf("failed to write file %s: %w", names[i], err)
+		}
+	}
+	return names, nil
+}
@@ -0,0 +1,608 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Beastly713/horcrux/pkg/armor"
+	"github.com/Beastly713/horcrux/pkg/crypto/secrets"
+	"github.com/Beastly713/horcrux/pkg/format"
+	"github.com/Beastly713/horcrux/pkg/paper"
+	"github.com/Beastly713/horcrux/pkg/pipeline"
+	"github.com/Beastly713/horcrux/pkg/wordlist"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ceremonyStep is what a quorum ceremony is waiting for.
+type ceremonyStep int
+
+const (
+	ceremonyMenu    ceremonyStep = iota // the custodian to choose how to give a share
+	ceremonyPaste                       // an armored horcrux to be pasted
+	ceremonyWords                       // the key words of a paper horcrux
+	ceremonyMedia                       // the path of a file or directory on media
+	ceremonyLoading                     // media being read
+	ceremonyHandoff                     // the next custodian to take the keyboard
+	ceremonyAbort                       // confirmation of an abort
+)
+
+// ceremonyShare is a share given during a quorum ceremony. Its key fragment
+// and body are held only in memory, and wiped when the ceremony ends.
+type ceremonyShare struct {
+	header *format.Header  // without its key fragment; nil until loaded
+	key    *secrets.Secret // nil until given
+	body   *secrets.Secret // nil until loaded
+}
+
+func (s *ceremonyShare) complete() bool {
+	return s.header != nil && s.key != nil && s.body != nil
+}
+
+// ceremony collects the shares of one split from custodians taking turns at
+// the keyboard, none of them touching the disk, until there are enough to
+// bind.
+type ceremony struct {
+	step      ceremonyStep
+	header    *format.Header // of the split, from the first horcrux given
+	shares    map[int]*ceremonyShare
+	custodian int    // whose turn it is, from 1
+	typed     []byte // pasted or typed so far, wiped once used
+	wordsFor  int    // the share whose words are asked for, 0 for any
+	note      string // what the last custodian gave, for the handoff
+	err       string
+	failed    bool // a bind failed; shares given again replace those held
+}
+
+// ceremonyLoadedMsg carries the horcruxes read from media.
+type ceremonyLoadedMsg struct {
+	horcruxes []*tuiHorcrux
+	paper     []*paper.Shard
+	err       error
+}
+
+func newCeremony() *ceremony {
+	return &ceremony{shares: make(map[int]*ceremonyShare), custodian: 1}
+}
+
+// held counts the shares complete enough to bind with.
+func (c *ceremony) held() int {
+	n := 0
+	for _, s := range c.shares {
+		if s.complete() {
+			n++
+		}
+	}
+	return n
+}
+
+func (c *ceremony) ready() bool {
+	return c.header != nil && c.held() >= c.header.Threshold
+}
+
+// wipe destroys every share and whatever is being typed.
+func (c *ceremony) wipe() {
+	for _, s := range c.shares {
+		if s.key != nil {
+			s.key.Destroy()
+		}
+		if s.body != nil {
+			s.body.Destroy()
+		}
+	}
+	c.shares = make(map[int]*ceremonyShare)
+	c.clearTyped()
+}
+
+func (c *ceremony) clearTyped() {
+	clear(c.typed)
+	c.typed = c.typed[:0]
+}
+
+// share returns the share with the given index, creating it if need be.
+func (c *ceremony) share(index int) *ceremonyShare {
+	s, ok := c.shares[index]
+	if !ok {
+		s = &ceremonyShare{}
+		c.shares[index] = s
+	}
+	return s
+}
+
+// take holds the horcruxes a custodian gave, moving their key fragments and
+// bodies out of reach of anything but the shares. Keyless ones, read off
+// paper, wait for their words.
+func (c *ceremony) take(horcruxes []*tuiHorcrux, shards []*paper.Shard) error {
+	var taken, given, keyless []int
+	var errs []error
+	add := func(h *format.Header, body []byte) error {
+		if c.header != nil && sessionID(h) != sessionID(c.header) {
+			clear(body)
+			clear(h.KeyFragment)
+			return fmt.Errorf("horcrux #%d is of %s, split %s, not of the file this ceremony binds",
+				h.Index, h.OriginalFilename, time.Unix(h.Timestamp, 0).Format("2006-01-02 15:04"))
+		}
+		if c.header == nil {
+			c.header = h
+		}
+		s := c.share(h.Index)
+		if c.failed && s.body != nil {
+			// Given again to replace a share that may be bad
+			s.body.Destroy()
+			s.header, s.body = nil, nil
+			if len(h.KeyFragment) > 0 && s.key != nil {
+				s.key.Destroy()
+				s.key = nil
+			}
+		}
+		if s.complete() || (s.body != nil && len(h.KeyFragment) == 0) {
+			clear(body)
+			clear(h.KeyFragment)
+			given = append(given, h.Index)
+			return nil
+		}
+		if s.body == nil {
+			s.header, s.body = h, secrets.WrapSecret(body)
+		} else {
+			clear(body)
+		}
+		if len(h.KeyFragment) > 0 && s.key == nil {
+			s.key = secrets.WrapSecret(h.KeyFragment)
+		} else {
+			clear(h.KeyFragment)
+		}
+		h.KeyFragment = nil
+		if s.key == nil {
+			keyless = append(keyless, h.Index)
+		}
+		taken = append(taken, h.Index)
+		return nil
+	}
+
+	for _, h := range horcruxes {
+		errs = append(errs, add(h.header, h.body))
+	}
+	for _, shard := range shards {
+		errs = append(errs, add(shard.Header, shard.Body))
+	}
+	err := errors.Join(errs...)
+	if err != nil && len(taken)+len(given) == 0 {
+		return err
+	}
+	if len(taken) == 0 {
+		return fmt.Errorf("%s already given", shareList(c.header, given))
+	}
+
+	c.note = fmt.Sprintf("Took %s.", shareList(c.header, taken))
+	if err != nil {
+		c.note += fmt.Sprintf(" Ignored: %v", err)
+	}
+	if len(keyless) > 0 {
+		sort.Ints(keyless)
+		c.wordsFor = keyless[0]
+		c.step = ceremonyWords
+		return nil
+	}
+	c.step = ceremonyHandoff
+	return nil
+}
+
+// submitPaste takes the armored horcruxes pasted, once the end of a block
+// has arrived. Damaged blocks are noted and the others taken.
+func (c *ceremony) submitPaste() error {
+	defer c.clearTyped()
+	blocks, err := armor.Decode(bytes.NewReader(c.typed))
+	errs := []error{err}
+	var horcruxes []*tuiHorcrux
+	for _, block := range blocks {
+		reader, err := format.NewReader(bytes.NewReader(block.Data))
+		if err != nil {
+			clear(block.Data)
+			errs = append(errs, fmt.Errorf("invalid armored block %d: %w", block.Number, err))
+			continue
+		}
+		body, err := io.ReadAll(reader.Body)
+		clear(block.Data)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to read armored block %d: %w", block.Number, err))
+			continue
+		}
+		horcruxes = append(horcruxes, &tuiHorcrux{header: reader.Header, body: body})
+	}
+	if len(horcruxes) == 0 {
+		return fmt.Errorf("the pasted text is not a whole armored horcrux: %w", errors.Join(errs...))
+	}
+	if err := c.take(horcruxes, nil); err != nil {
+		return err
+	}
+	if err := errors.Join(errs...); err != nil {
+		c.note += fmt.Sprintf(" Ignored: %v", err)
+	}
+	return nil
+}
+
+// errWordsFirst refuses words before any horcrux: they are checked against
+// the split, which a horcrux tells.
+var errWordsFirst = errors.New("words are checked against their split: give a horcrux of it first, pasted or from media")
+
+// submitWords takes the key fragment typed as words, for the share asked for
+// or, when none is, for whichever share it belongs to.
+func (c *ceremony) submitWords() error {
+	defer c.clearTyped()
+	if c.header == nil {
+		return errWordsFirst
+	}
+	fragment, err := paper.DecodeWords(c.header, wordlist.Split(string(c.typed)))
+	if err != nil {
+		return fmt.Errorf("the words do not decode: %w", err)
+	}
+	if len(fragment) == 0 {
+		return errors.New("the words hold no key fragment")
+	}
+	index := int(fragment[len(fragment)-1])
+	switch {
+	case index < 1 || index > c.header.Total:
+		clear(fragment)
+		return fmt.Errorf("these words are for share #%d, which this split does not have", index)
+	case c.wordsFor != 0 && index != c.wordsFor:
+		clear(fragment)
+		return fmt.Errorf("these words are for share #%d, not #%d", index, c.wordsFor)
+	case c.shares[index] != nil && c.shares[index].key != nil && !c.failed:
+		clear(fragment)
+		return fmt.Errorf("%s already given", shareList(c.header, []int{index}))
+	}
+
+	s := c.share(index)
+	if s.key != nil {
+		s.key.Destroy()
+	}
+	s.key = secrets.WrapSecret(fragment)
+	if c.wordsFor == 0 {
+		c.note = fmt.Sprintf("Took the words of %s.", shareList(c.header, []int{index}))
+	} else {
+		c.note += " Its words match."
+	}
+	if s.body == nil {
+		c.note += " Its horcrux is still needed, pasted or from media."
+	}
+	c.wordsFor = 0
+	c.step = ceremonyHandoff
+	return nil
+}
+
+// set is the split as bound from the complete shares. Its horcruxes use the
+// shares' memory, so it is only good until the ceremony is wiped.
+func (c *ceremony) set() *shardSet {
+	set := &shardSet{horcruxes: make(map[int]*tuiHorcrux)}
+	for index, s := range c.shares {
+		if !s.complete() {
+			continue
+		}
+		header := *s.header
+		header.KeyFragment = s.key.Bytes()
+		set.horcruxes[index] = &tuiHorcrux{header: &header, body: s.body.Bytes()}
+		set.header = &header
+	}
+	return set
+}
+
+// loadMedia reads the horcruxes in a file, or in the files of a directory,
+// plugged in by a custodian: plain, hidden or armored ones, and paper ones
+// from photos of their QR codes.
+func loadMedia(path string) tea.Cmd {
+	return func() tea.Msg {
+		info, err := os.Stat(path)
+		if err != nil {
+			return ceremonyLoadedMsg{err: err}
+		}
+		paths := []string{path}
+		if info.IsDir() {
+			entries, err := os.ReadDir(path)
+			if err != nil {
+				return ceremonyLoadedMsg{err: err}
+			}
+			paths = nil
+			for _, e := range entries {
+				if !e.IsDir() {
+					paths = append(paths, filepath.Join(path, e.Name()))
+				}
+			}
+		}
+
+		var candidates, images []string
+		for _, p := range paths {
+			head, err := sniffFile(p)
+			if err != nil || !mayHoldHorcrux(p, head) {
+				continue
+			}
+			candidates = append(candidates, p)
+			switch strings.ToLower(filepath.Ext(p)) {
+			case ".png", ".jpg", ".jpeg":
+				images = append(images, p)
+			}
+		}
+		horcruxes, errs := readTUIHorcruxes(context.Background(), candidates, true, pipeline.Discard)
+		shards := readPaperShards(images, io.Discard)
+		if len(horcruxes)+len(shards) == 0 {
+			if len(errs) > 0 {
+				return ceremonyLoadedMsg{err: errs[0]}
+			}
+			return ceremonyLoadedMsg{err: fmt.Errorf("no horcrux found in %s", path)}
+		}
+		return ceremonyLoadedMsg{horcruxes: horcruxes, paper: shards}
+	}
+}
+
+// updateCeremony handles a key press during a quorum ceremony. Moving on to
+// another custodian clears the screen.
+func (m model) updateCeremony(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	c := m.ceremony
+	if msg.String() == "ctrl+c" {
+		cmd := m.quit()
+		return m, cmd
+	}
+
+	switch c.step {
+	case ceremonyMenu:
+		c.err = ""
+		switch msg.String() {
+		case "a":
+			c.step = ceremonyPaste
+		case "w":
+			if c.header == nil {
+				c.err = errWordsFirst.Error()
+				break
+			}
+			c.step = ceremonyWords
+		case "m":
+			c.step = ceremonyMedia
+			m.textInput.Reset()
+			m.textInput.Placeholder = "/media/usb"
+			m.textInput.Focus()
+		case "esc":
+			if len(c.shares) == 0 {
+				m.ceremony = nil
+				m.status = browseStatus
+				return m, tea.ClearScreen
+			}
+			c.step = ceremonyAbort
+		}
+
+	case ceremonyPaste, ceremonyWords:
+		switch msg.Type {
+		case tea.KeyEsc:
+			c.clearTyped()
+			c.err = ""
+			if c.step == ceremonyWords && c.wordsFor != 0 {
+				c.note += fmt.Sprintf(" Its words were not typed: share #%d cannot be used without them.", c.wordsFor)
+				c.wordsFor = 0
+				c.step = ceremonyHandoff
+				return m, tea.ClearScreen
+			}
+			c.step = ceremonyMenu
+			return m, nil
+		case tea.KeyBackspace:
+			if _, size := utf8.DecodeLastRune(c.typed); size > 0 {
+				clear(c.typed[len(c.typed)-size:])
+				c.typed = c.typed[:len(c.typed)-size]
+			}
+			return m, nil
+		case tea.KeyEnter:
+			if c.step == ceremonyWords {
+				if err := c.submitWords(); err != nil {
+					c.err = err.Error()
+					return m, nil
+				}
+				c.err = ""
+				return m, tea.ClearScreen
+			}
+			c.typed = append(c.typed, '\n')
+		case tea.KeySpace:
+			c.typed = append(c.typed, ' ')
+		case tea.KeyRunes:
+			for _, r := range msg.Runes {
+				if r == '\r' {
+					r = '\n'
+				}
+				c.typed = utf8.AppendRune(c.typed, r)
+			}
+		default:
+			return m, nil
+		}
+		if c.step == ceremonyPaste && bytes.Contains(c.typed, []byte(armor.EndMarker)) {
+			if err := c.submitPaste(); err != nil {
+				c.err = err.Error()
+				return m, nil
+			}
+			c.err = ""
+			return m, tea.ClearScreen
+		}
+
+	case ceremonyMedia:
+		switch msg.String() {
+		case "esc":
+			c.step = ceremonyMenu
+			c.err = ""
+		case "enter":
+			path := strings.TrimSpace(m.textInput.Value())
+			if path == "" {
+				c.err = "give the path of a file or a directory"
+				return m, nil
+			}
+			c.step = ceremonyLoading
+			c.err = ""
+			return m, loadMedia(path)
+		default:
+			var cmd tea.Cmd
+			m.textInput, cmd = m.textInput.Update(msg)
+			return m, cmd
+		}
+
+	case ceremonyHandoff:
+		if msg.String() == "esc" {
+			c.step = ceremonyAbort
+			return m, nil
+		}
+		if msg.String() != "enter" {
+			return m, nil
+		}
+		if c.ready() {
+			m.askWhere(c.header.OriginalFilename, func(ctx context.Context, progress pipeline.Reporter) ([]*shardSet, error) {
+				return []*shardSet{c.set()}, nil
+			})
+			return m, nil
+		}
+		c.custodian++
+		c.note = ""
+		c.step = ceremonyMenu
+		return m, tea.ClearScreen
+
+	case ceremonyAbort:
+		if msg.String() == "y" {
+			c.wipe()
+			m.ceremony = nil
+			m.status = "Ceremony aborted. The shares given were wiped."
+			return m, tea.ClearScreen
+		}
+		c.step = ceremonyMenu
+		if c.note != "" {
+			// Asked at the handoff
+			c.step = ceremonyHandoff
+		}
+	}
+	return m, nil
+}
+
+// settleCeremony ends the ceremony once its bind has written the file, or
+// the user has settled where to. A bind that failed keeps the shares for the
+// next custodian, who may give one again to replace a bad one.
+func (m *model) settleCeremony(err error) {
+	c := m.ceremony
+	if err == nil {
+		c.wipe()
+		m.ceremony = nil
+		return
+	}
+	c.failed = true
+	c.err = fmt.Sprintf("The bind failed: %v", err)
+	c.note = ""
+	c.custodian++
+	c.step = ceremonyMenu
+}
+
+// loaded takes the horcruxes read from media.
+func (c *ceremony) loaded(msg ceremonyLoadedMsg) tea.Cmd {
+	err := msg.err
+	if err == nil {
+		err = c.take(msg.horcruxes, msg.paper)
+	}
+	if err != nil {
+		c.err = err.Error()
+		c.step = ceremonyMedia
+		return nil
+	}
+	c.err = ""
+	return tea.ClearScreen
+}
+
+func (c *ceremony) view(input textinput.Model) string {
+	s := "Quorum ceremony"
+	if c.header != nil {
+		s += fmt.Sprintf(": %s, split %s", c.header.OriginalFilename, time.Unix(c.header.Timestamp, 0).Format("2006-01-02 15:04"))
+	}
+	s += "\n" + dimStyle.Render("Shares are held in memory only, and wiped when the ceremony ends.") + "\n\n"
+	if c.header != nil {
+		s += fmt.Sprintf("Shares held: %d, %d needed\n\n", c.held(), c.header.Threshold)
+	}
+
+	switch c.step {
+	case ceremonyMenu:
+		s += focusedStyle.Render(fmt.Sprintf("Custodian %d", c.custodian)) + ", give your share:\n\n"
+		s += "  'a': Paste an armored horcrux\n"
+		s += "  'w': Type the words of a paper horcrux\n"
+		s += "  'm': Load from media (a file or directory)\n\n"
+		if c.failed {
+			s += dimStyle.Render("A share given again replaces the one held.") + "\n\n"
+		}
+		s += "Esc: end the ceremony\n"
+
+	case ceremonyPaste:
+		s += focusedStyle.Render(fmt.Sprintf("Custodian %d", c.custodian)) + ", paste your armored horcrux.\n"
+		s += "It is taken as soon as its END line arrives, and is not shown.\n\n"
+		s += fmt.Sprintf("%d line(s) received\n\n", bytes.Count(c.typed, []byte("\n")))
+		s += "Esc: back\n"
+
+	case ceremonyWords:
+		if c.wordsFor != 0 {
+			s += c.note + "\n\n"
+			s += focusedStyle.Render(fmt.Sprintf("Custodian %d", c.custodian)) + fmt.Sprintf(", type the words of %s, then Enter.\n\n", shareList(c.header, []int{c.wordsFor}))
+		} else {
+			s += focusedStyle.Render(fmt.Sprintf("Custodian %d", c.custodian)) + ", type the words of your paper horcrux, then Enter.\n\n"
+		}
+		s += cursorStyle.Render(">") + " " + maskWords(c.typed) + "\n\n"
+		s += "Esc: back\n"
+
+	case ceremonyMedia:
+		s += focusedStyle.Render(fmt.Sprintf("Custodian %d", c.custodian)) + ", plug in your media and give the path of your horcrux, or of its directory.\n\n"
+		s += cursorStyle.Render(">") + " " + input.View() + "\n\n"
+		s += "Enter: load | Esc: back\n"
+
+	case ceremonyLoading:
+		s += "Reading the media...\n"
+
+	case ceremonyHandoff:
+		s += c.note + "\n\n"
+		if c.ready() {
+			s += checkedStyle.Render("Threshold reached.") + " Enter: bind | Esc: end the ceremony\n"
+		} else {
+			s += "Pass the keyboard to the next custodian. Enter: next custodian | Esc: end the ceremony\n"
+		}
+
+	case ceremonyAbort:
+		s += fmt.Sprintf("End the ceremony and wipe the %d share(s) given? y/n\n", len(c.shares))
+	}
+
+	if c.err != "" {
+		s += "\n" + errorStyle.Render(c.err) + "\n"
+	}
+	return s
+}
+
+// maskWords shows how much was typed, a dot per letter, without the words.
+func maskWords(typed []byte) string {
+	var b strings.Builder
+	for _, r := range string(typed) {
+		if r == ' ' || r == '\n' {
+			b.WriteRune(' ')
+		} else {
+			b.WriteRune('•')
+		}
+	}
+	return b.String()
+}
+
+// shareList names shares as "#2 (Alice), #4".
+func shareList(h *format.Header, indices []int) string {
+	names := make([]string, len(indices))
+	for i, index := range indices {
+		names[i] = fmt.Sprintf("#%d", index)
+		if h != nil {
+			if custodian, ok := h.CustodianName(index); ok {
+				names[i] += fmt.Sprintf(" (%s)", custodian)
+			}
+		}
+	}
+	if len(names) == 1 {
+		return "share " + names[0]
+	}
+	return "shares " + strings.Join(names, ", ")
+}
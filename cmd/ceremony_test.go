@@ -0,0 +1,259 @@
+package cmd
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Beastly713/horcrux/pkg/paper"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// paperShard is horcrux index of will.pdf read off paper, without its key
+// fragment.
+func paperShard(index int) *paper.Shard {
+	h := testHorcrux("will.pdf", 100, index, "")
+	h.header.KeyFragment = nil
+	return &paper.Shard{Header: h.header, Body: h.body}
+}
+
+// typeWords types the words of a key fragment of the split of will.pdf at
+// timestamp into the ceremony.
+func typeWords(t *testing.T, c *ceremony, timestamp int64, fragment []byte) error {
+	h := testHorcrux("will.pdf", timestamp, 1, "").header
+	h.KeyFragment = fragment
+	words, err := paper.EncodeWords(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.typed = append(c.typed, strings.Join(words, " ")...)
+	return c.submitWords()
+}
+
+func TestCeremonyTake(t *testing.T) {
+	tests := []struct {
+		name      string
+		given     [][]*tuiHorcrux // by custodian
+		wantErr   string          // of the last custodian
+		wantHeld  int
+		wantReady bool
+	}{
+		{
+			name:      "threshold reached",
+			given:     [][]*tuiHorcrux{{testHorcrux("will.pdf", 100, 1, "")}, {testHorcrux("will.pdf", 100, 3, "")}},
+			wantHeld:  2,
+			wantReady: true,
+		},
+		{
+			name:      "several shares at once",
+			given:     [][]*tuiHorcrux{{testHorcrux("will.pdf", 100, 1, ""), testHorcrux("will.pdf", 100, 2, "")}},
+			wantHeld:  2,
+			wantReady: true,
+		},
+		{
+			name:     "duplicate share",
+			given:    [][]*tuiHorcrux{{testHorcrux("will.pdf", 100, 1, "")}, {testHorcrux("will.pdf", 100, 1, "")}},
+			wantErr:  "already given",
+			wantHeld: 1,
+		},
+		{
+			name:     "share of another split",
+			given:    [][]*tuiHorcrux{{testHorcrux("will.pdf", 100, 1, "")}, {testHorcrux("will.pdf", 200, 2, "")}},
+			wantErr:  "not of the file this ceremony binds",
+			wantHeld: 1,
+		},
+		{
+			name:      "another split's share among good ones",
+			given:     [][]*tuiHorcrux{{testHorcrux("will.pdf", 100, 1, "")}, {testHorcrux("will.pdf", 200, 2, ""), testHorcrux("will.pdf", 100, 3, "")}},
+			wantHeld:  2,
+			wantReady: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newCeremony()
+			var err error
+			for _, horcruxes := range tt.given {
+				err = c.take(horcruxes, nil)
+			}
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("Error %v, want %q", err, tt.wantErr)
+			}
+			if c.held() != tt.wantHeld || c.ready() != tt.wantReady {
+				t.Errorf("Held %d, ready %v; want %d, %v", c.held(), c.ready(), tt.wantHeld, tt.wantReady)
+			}
+		})
+	}
+}
+
+func TestCeremonyKeyMovedToShare(t *testing.T) {
+	h := testHorcrux("will.pdf", 100, 1, "")
+	fragment := h.header.KeyFragment
+	c := newCeremony()
+	if err := c.take([]*tuiHorcrux{h}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if h.header.KeyFragment != nil {
+		t.Error("The key fragment was left in the header")
+	}
+	c.wipe()
+	if fragment[0] != 0 || h.body[0] != 0 {
+		t.Error("Wiping left the key fragment or body in memory")
+	}
+}
+
+func TestCeremonySubmitWords(t *testing.T) {
+	tests := []struct {
+		name      string
+		paper     []int // shards loaded first, waiting for words
+		timestamp int64 // of the split the words are for, if not will.pdf's
+		fragment  []byte
+		wantErr   string
+		wantHeld  int
+	}{
+		{
+			name:     "words of the share asked for",
+			paper:    []int{2},
+			fragment: []byte{1, 2},
+			wantHeld: 1,
+		},
+		{
+			name:     "words of the wrong share",
+			paper:    []int{2},
+			fragment: []byte{1, 3},
+			wantErr:  "for share #3, not #2",
+		},
+		{
+			name:      "words of another split",
+			paper:     []int{2},
+			timestamp: 200,
+			fragment:  []byte{1, 2},
+			wantErr:   "another split",
+		},
+		{
+			name:     "words before any horcrux",
+			fragment: []byte{1, 2},
+			wantErr:  "give a horcrux of it first",
+		},
+		{
+			name:     "index out of range",
+			paper:    []int{2},
+			fragment: []byte{1, 4},
+			wantErr:  "this split does not have",
+		},
+		{
+			name:     "index zero",
+			paper:    []int{2},
+			fragment: []byte{1, 0},
+			wantErr:  "this split does not have",
+		},
+		{
+			name:     "no key fragment",
+			paper:    []int{2},
+			fragment: []byte{},
+			wantErr:  "no key fragment",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newCeremony()
+			for _, index := range tt.paper {
+				if err := c.take(nil, []*paper.Shard{paperShard(index)}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			timestamp := tt.timestamp
+			if timestamp == 0 {
+				timestamp = 100
+			}
+			err := typeWords(t, c, timestamp, tt.fragment)
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("Error %v, want %q", err, tt.wantErr)
+			}
+			if c.held() != tt.wantHeld {
+				t.Errorf("Held %d, want %d", c.held(), tt.wantHeld)
+			}
+			if len(c.typed) != 0 {
+				t.Error("The words typed were kept")
+			}
+		})
+	}
+}
+
+func TestCeremonyWordsGivenTwice(t *testing.T) {
+	c := newCeremony()
+	if err := c.take([]*tuiHorcrux{testHorcrux("will.pdf", 100, 2, "")}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := typeWords(t, c, 100, []byte{1, 2}); err == nil || !strings.Contains(err.Error(), "already given") {
+		t.Errorf("Words of a share held: %v", err)
+	}
+	if c.held() != 1 {
+		t.Errorf("Held %d, want 1", c.held())
+	}
+}
+
+func TestCeremonyWordsNeedAHorcrux(t *testing.T) {
+	m := model{ceremony: newCeremony()}
+	final, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("w")})
+	if c := final.(model).ceremony; c.step != ceremonyMenu || c.err == "" {
+		t.Errorf("Asked for words before any horcrux: step %d, error %q", c.step, c.err)
+	}
+}
+
+func TestCeremonyReplaceAfterFailure(t *testing.T) {
+	c := newCeremony()
+	bad := testHorcrux("will.pdf", 100, 1, "")
+	bad.body[0] = 0xFF
+	if err := c.take([]*tuiHorcrux{bad, testHorcrux("will.pdf", 100, 2, "")}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	m := model{ceremony: c}
+	m.settleCeremony(errors.New("decryption failed"))
+	if m.ceremony != c || !c.failed || c.held() != 2 {
+		t.Fatalf("A failed bind should keep the shares: ceremony %v, held %d", m.ceremony, c.held())
+	}
+	if err := c.take([]*tuiHorcrux{testHorcrux("will.pdf", 100, 1, "")}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.shares[1].body.Bytes()[0]; got != 1 || bad.body[0] != 0 {
+		t.Errorf("Share #1 not replaced: body %x, old body %x", got, bad.body[0])
+	}
+
+	m.settleCeremony(nil)
+	if m.ceremony != nil || len(c.shares) != 0 {
+		t.Error("A bind that succeeded should wipe the ceremony")
+	}
+}
+
+func TestCeremonyWipedOnQuit(t *testing.T) {
+	tests := []struct {
+		name  string
+		state func(m *model)
+	}{
+		{"giving shares", func(m *model) {}},
+		{"asking where to bind", func(m *model) { m.asking = &pendingBind{label: "will.pdf"} }},
+		{"asking about a taken name", func(m *model) { m.outcome = &bindOutcome{} }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := testHorcrux("will.pdf", 100, 1, "")
+			body := h.body
+			c := newCeremony()
+			if err := c.take([]*tuiHorcrux{h}, nil); err != nil {
+				t.Fatal(err)
+			}
+			m := model{ceremony: c}
+			tt.state(&m)
+
+			final, cmd := m.Update(tea.KeyMsg{Type: tea.KeyCtrlC})
+			if cmd == nil || final.(model).ceremony != nil {
+				t.Fatal("Ctrl+C should quit and end the ceremony")
+			}
+			if body[0] != 0 || len(c.shares) != 0 {
+				t.Error("Quitting left the shares in memory")
+			}
+		})
+	}
+}
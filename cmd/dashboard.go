@@ -0,0 +1,264 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Beastly713/horcrux/pkg/crypto/secrets"
+	"github.com/Beastly713/horcrux/pkg/format"
+	"github.com/Beastly713/horcrux/pkg/pipeline"
+	"github.com/Beastly713/horcrux/pkg/shamir"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+var paneStyle = lipgloss.NewStyle().
+	Border(lipgloss.NormalBorder(), false, false, false, true).
+	BorderForeground(lipgloss.Color("241")).
+	MarginLeft(2).
+	PaddingLeft(2)
+
+// shardSet is the horcruxes of one split session found by the interactive
+// mode: those of one file, split at one time.
+type shardSet struct {
+	header    *format.Header      // of the first horcrux found
+	horcruxes map[int]*tuiHorcrux // by index
+}
+
+// groupShardSets sorts horcruxes into sets by split session, ordered by file
+// name and then by age. A second copy of an index is left out.
+func groupShardSets(horcruxes []*tuiHorcrux) []*shardSet {
+	byID := make(map[string]*shardSet)
+	var sets []*shardSet
+	for _, h := range horcruxes {
+		id := sessionID(h.header)
+		set, ok := byID[id]
+		if !ok {
+			set = &shardSet{header: h.header, horcruxes: make(map[int]*tuiHorcrux)}
+			byID[id] = set
+			sets = append(sets, set)
+		}
+		if _, dup := set.horcruxes[h.header.Index]; !dup {
+			set.horcruxes[h.header.Index] = h
+		}
+	}
+	sort.Slice(sets, func(i, j int) bool {
+		a, b := sets[i].header, sets[j].header
+		if a.OriginalFilename != b.OriginalFilename {
+			return a.OriginalFilename < b.OriginalFilename
+		}
+		return a.Timestamp < b.Timestamp
+	})
+	return sets
+}
+
+func (s *shardSet) found() int {
+	return len(s.horcruxes)
+}
+
+func (s *shardSet) ready() bool {
+	return s.found() >= s.header.Threshold
+}
+
+// indices returns the indices found, in order.
+func (s *shardSet) indices() []int {
+	indices := make([]int, 0, len(s.horcruxes))
+	for i := range s.horcruxes {
+		indices = append(indices, i)
+	}
+	sort.Ints(indices)
+	return indices
+}
+
+// missing returns the indices not found, in order.
+func (s *shardSet) missing() []int {
+	var missing []int
+	for i := 1; i <= s.header.Total; i++ {
+		if _, ok := s.horcruxes[i]; !ok {
+			missing = append(missing, i)
+		}
+	}
+	return missing
+}
+
+func (s *shardSet) headers() []*format.Header {
+	headers := make([]*format.Header, 0, len(s.horcruxes))
+	for _, i := range s.indices() {
+		headers = append(headers, s.horcruxes[i].header)
+	}
+	return headers
+}
+
+// paths returns the files holding the set, in order.
+func (s *shardSet) paths() []string {
+	var paths []string
+	for _, h := range s.horcruxes {
+		paths = append(paths, h.paths...)
+	}
+	sort.Strings(paths)
+	return slices.Compact(paths)
+}
+
+// contains reports whether path holds one of the set's horcruxes.
+func (s *shardSet) contains(path string) bool {
+	for _, h := range s.horcruxes {
+		for _, p := range h.paths {
+			if p == path {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// summary reads like "3 of 5 found, threshold 3 — ready".
+func (s *shardSet) summary() string {
+	state := checkedStyle.Render("ready")
+	if !s.ready() {
+		state = fmt.Sprintf("%d more needed", s.header.Threshold-s.found())
+	}
+	return fmt.Sprintf("%d of %d found, threshold %d — %s", s.found(), s.header.Total, s.header.Threshold, state)
+}
+
+// join rebuilds the original file from the set, whose bodies must be loaded.
+func (s *shardSet) join(ctx context.Context, progress pipeline.Reporter) ([]byte, error) {
+	keyFragments := make([][]byte, 0, len(s.horcruxes))
+	shardMap := make(map[int][]byte)
+	for _, h := range s.horcruxes {
+		keyFragments = append(keyFragments, h.header.KeyFragment)
+
+		// CRITICAL FIX: Convert 1-based Header Index to 0-based RS Index
+		shardMap[h.header.Index-1] = h.body
+	}
+
+	combined, err := shamir.Combine(keyFragments)
+	if err != nil {
+		return nil, fmt.Errorf("key reconstruction failed: %w", err)
+	}
+	keySecret := secrets.WrapSecret(combined)
+	defer keySecret.Destroy() // Ensure memory is cleared on exit
+
+	var plainText []byte
+	if s.header.Legacy {
+		plainText, err = pipeline.JoinLegacy(shardMap, keySecret.Bytes(), s.header.Total, s.header.Threshold)
+	} else {
+		plainText, err = pipeline.JoinPipelineContext(ctx, shardMap, keySecret.Bytes(), s.header.Total, s.header.Threshold, progress)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("decryption pipeline failed: %w", err)
+	}
+	return plainText, nil
+}
+
+// loadShardSet reads the bodies of a set found by a scan.
+func loadShardSet(ctx context.Context, set *shardSet, progress pipeline.Reporter) ([]*shardSet, error) {
+	horcruxes, errs := readTUIHorcruxes(ctx, set.paths(), true, progress)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if len(errs) > 0 {
+		return nil, errs[0]
+	}
+	for _, loaded := range groupShardSets(horcruxes) {
+		if sessionID(loaded.header) == sessionID(set.header) {
+			return []*shardSet{loaded}, nil
+		}
+	}
+	return nil, errors.New("the horcruxes of this set are gone")
+}
+
+// shardSetsMsg carries the result of a scan of the directory.
+type shardSetsMsg struct {
+	scan int
+	sets []*shardSet
+	errs []error
+}
+
+// scanShardSets reads the headers in the given files in the background.
+func scanShardSets(scan int, paths []string) tea.Cmd {
+	return func() tea.Msg {
+		horcruxes, errs := readTUIHorcruxes(context.Background(), paths, false, pipeline.Discard)
+		return shardSetsMsg{scan: scan, sets: groupShardSets(horcruxes), errs: errs}
+	}
+}
+
+// setsView lists the sets found, with the details of current, the one
+// highlighted.
+func setsView(sets []*shardSet, current *shardSet, focused, scanning bool, skipped int) string {
+	s := "Shard sets\n\n"
+	switch {
+	case scanning:
+		return s + dimStyle.Render("Reading horcruxes...") + "\n"
+	case len(sets) == 0:
+		s += dimStyle.Render("No horcruxes found here") + "\n"
+	}
+
+	for _, set := range sets {
+		cursor := " "
+		if set == current && focused {
+			cursor = cursorStyle.Render(">")
+		}
+		s += fmt.Sprintf("%s %s  %s\n", cursor, set.header.OriginalFilename, set.summary())
+	}
+	if skipped > 0 {
+		s += "\n" + dimStyle.Render(fmt.Sprintf("%d file(s) could not be read", skipped)) + "\n"
+	}
+	if current != nil {
+		s += "\n" + setDetails(current)
+	}
+	return s
+}
+
+// setDetails shows what the headers of a set tell: when it was split, who
+// holds each horcrux and where those found are.
+func setDetails(set *shardSet) string {
+	h := set.header
+	s := focusedStyle.Render(h.OriginalFilename) + "\n"
+	s += fmt.Sprintf("  %-10s %s\n", "Split:", time.Unix(h.Timestamp, 0).Format("2006-01-02 15:04"))
+	if h.Legacy {
+		s += fmt.Sprintf("  %-10s %s\n", "Format:", "legacy (jesseduffield/horcrux)")
+	}
+
+	label := "Found:"
+	for _, i := range set.indices() {
+		found := set.horcruxes[i]
+		where := filepath.Base(found.paths[0])
+		if len(found.paths) > 1 {
+			where += fmt.Sprintf(" +%d images", len(found.paths)-1)
+		}
+		who := strings.TrimSpace(fmt.Sprintf("#%d %s", i, holder(found.header, i)))
+		s += fmt.Sprintf("  %-10s %s  %s\n", label, who, dimStyle.Render(where))
+		label = ""
+	}
+
+	if missing := set.missing(); len(missing) > 0 {
+		held := make([]string, len(missing))
+		for j, i := range missing {
+			held[j] = strings.TrimSpace(fmt.Sprintf("#%d %s", i, holder(h, i)))
+		}
+		s += fmt.Sprintf("  %-10s %s\n", "Missing:", strings.Join(held, ", "))
+	}
+	if h.Instructions != "" {
+		s += fmt.Sprintf("  %-10s %s\n", "Notes:", h.Instructions)
+	}
+	if set.ready() {
+		s += "\n'g': Bind this set\n"
+	}
+	return s
+}
+
+// holder returns the custodian of the horcrux with the given index, or ""
+// when none was recorded.
+func holder(h *format.Header, index int) string {
+	custodian, _ := h.CustodianName(index)
+	return custodian
+}
@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Beastly713/horcrux/pkg/format"
+)
+
+// testHorcrux is horcrux index of a 2 of 3 split of name at timestamp, found
+// in path.
+func testHorcrux(name string, timestamp int64, index int, path string) *tuiHorcrux {
+	return &tuiHorcrux{
+		paths: []string{path},
+		header: &format.Header{
+			OriginalFilename: name,
+			Timestamp:        timestamp,
+			Index:            index,
+			Total:            3,
+			Threshold:        2,
+			KeyFragment:      []byte{1, byte(index)},
+		},
+		body: []byte{byte(index)},
+	}
+}
+
+func TestGroupShardSets(t *testing.T) {
+	type set struct {
+		name      string
+		timestamp int64
+		indices   []int
+	}
+	tests := []struct {
+		name      string
+		horcruxes []*tuiHorcrux
+		want      []set
+	}{
+		{
+			name:      "none",
+			horcruxes: nil,
+			want:      nil,
+		},
+		{
+			name: "one session",
+			horcruxes: []*tuiHorcrux{
+				testHorcrux("will.pdf", 100, 3, "c"),
+				testHorcrux("will.pdf", 100, 1, "a"),
+			},
+			want: []set{{"will.pdf", 100, []int{1, 3}}},
+		},
+		{
+			name: "same file split twice",
+			horcruxes: []*tuiHorcrux{
+				testHorcrux("will.pdf", 200, 1, "new1"),
+				testHorcrux("will.pdf", 100, 1, "old1"),
+				testHorcrux("will.pdf", 100, 2, "old2"),
+			},
+			want: []set{{"will.pdf", 100, []int{1, 2}}, {"will.pdf", 200, []int{1}}},
+		},
+		{
+			name: "by file name, then age",
+			horcruxes: []*tuiHorcrux{
+				testHorcrux("will.pdf", 100, 1, "w"),
+				testHorcrux("deeds.pdf", 300, 2, "d"),
+			},
+			want: []set{{"deeds.pdf", 300, []int{2}}, {"will.pdf", 100, []int{1}}},
+		},
+		{
+			name: "second copy of an index left out",
+			horcruxes: []*tuiHorcrux{
+				testHorcrux("will.pdf", 100, 1, "a"),
+				testHorcrux("will.pdf", 100, 1, "copy of a"),
+			},
+			want: []set{{"will.pdf", 100, []int{1}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []set
+			for _, s := range groupShardSets(tt.horcruxes) {
+				got = append(got, set{s.header.OriginalFilename, s.header.Timestamp, s.indices()})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Sets mismatch.\nGot: %v\nWant: %v", got, tt.want)
+			}
+		})
+	}
+
+	sets := groupShardSets([]*tuiHorcrux{
+		testHorcrux("will.pdf", 100, 1, "a"),
+		testHorcrux("will.pdf", 100, 1, "copy of a"),
+		testHorcrux("will.pdf", 100, 3, "c"),
+	})
+	if !reflect.DeepEqual(sets[0].missing(), []int{2}) || !sets[0].ready() {
+		t.Errorf("Set with #1 and #3 of 3: missing %v, ready %v", sets[0].missing(), sets[0].ready())
+	}
+	if !sets[0].contains("a") || sets[0].contains("copy of a") {
+		t.Error("The set should hold the first copy of #1 only")
+	}
+}
@@ -2,19 +2,19 @@ package cmd
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
-	"image"
-	_ "image/jpeg" // Support JPEG decoding
-	_ "image/png"  // Support PNG decoding
 	"io"
 	"os"
 	"path/filepath"
 	"strings"
 
+	"github.com/Beastly713/horcrux/pkg/armor"
 	"github.com/Beastly713/horcrux/pkg/format"
 	"github.com/Beastly713/horcrux/pkg/pipeline"
-	"github.com/Beastly713/horcrux/pkg/shamir"
 	"github.com/Beastly713/horcrux/pkg/stego"
+	"github.com/charmbracelet/bubbles/progress"
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
@@ -41,26 +41,50 @@ type model struct {
 	files      []fileItem
 	cursor     int
 	status     string
-	textInput  textinput.Model // For naming output if needed, or simple status
+	textInput  textinput.Model // The wizard's answers, where to bind to, new names
 	quitting   bool
 	processing bool
+	picking    bool         // choosing a file to split, among all files
+	wizard     *splitWizard // splitting, when not nil
+	binding    *bindTask    // binding, when not nil
+	outDir     string       // where binds write, the directory started in by default
+	asking     *pendingBind // asking where to bind to, when not nil
+	outcome    *bindOutcome // asking about names taken, when not nil
+	ceremony   *ceremony    // taking shares from custodians, when not nil
+
+	// Shard sets in the directory, read in the background
+	sets        []*shardSet
+	scan        int // counts scans, so that a stale one is ignored
+	scanning    bool
+	skipped     int // files that could not be read
+	setsFocused bool
+	setCursor   int
 }
 
+// browseStatus is the help shown while browsing for horcruxes.
+const browseStatus = "Navigate: ↑/↓ | Enter: Open Dir | Space: Select | 'b': Bind Selected | Tab: Shard Sets | 'g': Bind Set | 's': Split a File | 'c': Quorum Ceremony"
+
 func initialModel() model {
 	cwd, _ := os.Getwd()
 	m := model{
-		path:   cwd,
-		status: "Navigate: ↑/↓ | Enter: Open Dir | Space: Select | 'b': Bind Selected",
+		path:      cwd,
+		outDir:    cwd,
+		status:    browseStatus,
+		textInput: textinput.New(),
 	}
+	m.textInput.Prompt = ""
 	m.loadFiles()
 	return m
 }
 
-func (m *model) loadFiles() {
+// loadFiles lists the directory and, unless picking a file to split, returns
+// the command reading its shard sets.
+func (m *model) loadFiles() tea.Cmd {
+	m.sets, m.setsFocused, m.setCursor = nil, false, 0
 	entries, err := os.ReadDir(m.path)
 	if err != nil {
 		m.status = "Error reading directory"
-		return
+		return nil
 	}
 
 	m.files = []fileItem{}
@@ -69,8 +93,13 @@ func (m *model) loadFiles() {
 
 	for _, e := range entries {
 		name := e.Name()
-		// Simple filter for relevance
-		isRel := e.IsDir() || strings.HasSuffix(name, ".horcrux") || strings.HasSuffix(name, ".png")
+		// Filter for relevance by content, so renamed files show up too;
+		// any file can be split
+		isRel := e.IsDir() || m.picking
+		if !isRel {
+			head, err := sniffFile(filepath.Join(m.path, name))
+			isRel = err == nil && mayHoldHorcrux(name, head)
+		}
 		if isRel {
 			m.files = append(m.files, fileItem{
 				name:  name,
@@ -80,87 +109,486 @@ func (m *model) loadFiles() {
 		}
 	}
 	m.cursor = 0
+
+	if m.picking {
+		m.scanning = false
+		return nil
+	}
+	m.scan++
+	m.scanning = true
+	return m.scanSets()
 }
 
-func (m model) Init() tea.Cmd {
+// scanSets reads the shard sets of the files listed.
+func (m model) scanSets() tea.Cmd {
+	var paths []string
+	for _, f := range m.files {
+		if !f.isDir {
+			paths = append(paths, f.path)
+		}
+	}
+	return scanShardSets(m.scan, paths)
+}
+
+// currentSet is the set highlighted in the side pane: the one picked there,
+// or the one the file under the cursor belongs to.
+func (m model) currentSet() *shardSet {
+	if m.setsFocused {
+		return m.sets[m.setCursor]
+	}
+	if len(m.files) == 0 {
+		return nil
+	}
+	for _, set := range m.sets {
+		if set.contains(m.files[m.cursor].path) {
+			return set
+		}
+	}
 	return nil
 }
 
+func (m model) Init() tea.Cmd {
+	// The first scan, started by loadFiles in initialModel
+	return m.scanSets()
+}
+
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
+		if m.wizard != nil {
+			return m.updateWizard(msg)
+		}
+		if m.asking != nil {
+			return m.updateAsking(msg)
+		}
+		if m.outcome != nil {
+			return m.updateOutcome(msg)
+		}
+		if m.binding == nil && m.ceremony != nil {
+			return m.updateCeremony(msg)
+		}
+		if m.binding != nil {
+			// Only cancelling is possible while binding
+			switch msg.String() {
+			case "esc":
+				m.binding.cancel()
+			case "ctrl+c":
+				m.binding.cancel()
+				m.binding.quitAfter = true
+			}
+			return m, nil
+		}
 		switch msg.String() {
 		case "ctrl+c", "q":
-			m.quitting = true
-			return m, tea.Quit
+			cmd := m.quit()
+			return m, cmd
+
+		case "esc":
+			if m.picking {
+				m.picking = false
+				m.status = browseStatus
+				cmd := m.loadFiles()
+				return m, cmd
+			}
+			m.setsFocused = false
+
+		case "tab":
+			if !m.picking && len(m.sets) > 0 {
+				m.setsFocused = !m.setsFocused
+			}
 
 		case "up", "k":
-			if m.cursor > 0 {
+			if m.setsFocused {
+				if m.setCursor > 0 {
+					m.setCursor--
+				}
+			} else if m.cursor > 0 {
 				m.cursor--
 			}
 
 		case "down", "j":
-			if m.cursor < len(m.files)-1 {
+			if m.setsFocused {
+				if m.setCursor < len(m.sets)-1 {
+					m.setCursor++
+				}
+			} else if m.cursor < len(m.files)-1 {
 				m.cursor++
 			}
 
 		case "enter":
+			if m.setsFocused {
+				cmd := m.bindSet(m.currentSet())
+				return m, cmd
+			}
 			selected := m.files[m.cursor]
 			if selected.isDir {
 				m.path = selected.path
-				m.loadFiles()
+				cmd := m.loadFiles()
+				return m, cmd
+			} else if m.picking {
+				m.picking = false
+				m.wizard = newSplitWizard(selected.path)
+				m.wizard.prompt(&m.textInput)
 			}
 
 		case " ":
-			if !m.files[m.cursor].isDir {
+			if !m.files[m.cursor].isDir && !m.picking && !m.setsFocused {
 				m.files[m.cursor].selected = !m.files[m.cursor].selected
 			}
 
 		case "b":
 			// Trigger Bind logic
-			return m, m.bindSelected()
+			if !m.picking {
+				cmd := m.bindSelected()
+				return m, cmd
+			}
+
+		case "g":
+			if !m.picking {
+				cmd := m.bindSet(m.currentSet())
+				return m, cmd
+			}
+
+		case "c":
+			if !m.picking {
+				m.ceremony = newCeremony()
+				return m, tea.ClearScreen
+			}
+
+		case "s":
+			m.picking = true
+			m.status = "Pick the file to split: Enter: Choose | Esc: Cancel"
+			m.loadFiles()
+		}
+
+	case shardSetsMsg:
+		if msg.scan == m.scan {
+			m.sets, m.skipped, m.scanning = msg.sets, len(msg.errs), false
+		}
+
+	case splitLogMsg, splitProgressMsg, splitDoneMsg:
+		if m.wizard != nil {
+			return m, m.wizard.handle(msg)
 		}
 
-	case statusMsg:
-		m.status = string(msg)
-		if strings.HasPrefix(m.status, "Success") {
-			// Clear selections on success
-			for i := range m.files {
-				m.files[i].selected = false
+	case ceremonyLoadedMsg:
+		if m.ceremony != nil {
+			return m, m.ceremony.loaded(msg)
+		}
+
+	case bindProgressMsg:
+		if m.binding != nil {
+			m.binding.stage = pipeline.Event(msg)
+			return m, m.binding.wait()
+		}
+
+	case bindDoneMsg:
+		if m.binding != nil {
+			quit := m.binding.quitAfter
+			m.binding = nil
+			if quit {
+				cmd := m.quit()
+				return m, cmd
+			}
+		}
+		if m.ceremony != nil && !errors.Is(msg.err, context.Canceled) {
+			switch {
+			case msg.err != nil:
+				m.settleCeremony(msg.err)
+				return m, nil
+			case msg.outcome.done():
+				m.settleCeremony(errors.Join(msg.outcome.notBound...))
 			}
 		}
+		switch {
+		case errors.Is(msg.err, context.Canceled):
+			m.setStatus("Bind cancelled.")
+		case msg.err != nil:
+			m.setStatus(fmt.Sprintf("Error: %v", msg.err))
+		case !msg.outcome.done():
+			m.outcome = msg.outcome
+		default:
+			m.setStatus(msg.outcome.status())
+		}
 	}
 
 	return m, nil
 }
 
-type statusMsg string
+// setStatus shows the end of a bind, clearing selections on success.
+func (m *model) setStatus(status string) {
+	m.status = status
+	if strings.HasPrefix(m.status, "Success") {
+		for i := range m.files {
+			m.files[i].selected = false
+		}
+	}
+}
+
+// bindTask is a bind running in the background. Its progress arrives as
+// messages, one per wait, and a bindDoneMsg once it is over.
+type bindTask struct {
+	label     string
+	events    chan tea.Msg
+	cancel    context.CancelFunc
+	quitAfter bool           // quit once the bind has stopped
+	stage     pipeline.Event // latest progress
+	bar       progress.Model
+}
+
+type bindProgressMsg pipeline.Event
+
+type bindDoneMsg struct {
+	outcome *bindOutcome
+	err     error
+}
+
+// startBind loads the horcruxes and binds them to dir in the background,
+// showing label while it does.
+func (m *model) startBind(label string, load bindLoader, dir string) tea.Cmd {
+	ctx, cancel := context.WithCancel(context.Background())
+	t := &bindTask{label: label, events: make(chan tea.Msg, 16), cancel: cancel, bar: newTUIBar()}
+	m.binding = t
+	go func() {
+		defer cancel()
+		progress := pipeline.ReporterFunc(func(e pipeline.Event) {
+			t.events <- bindProgressMsg(e)
+		})
+		sets, err := load(ctx, progress)
+		if err != nil {
+			t.events <- bindDoneMsg{err: err}
+			return
+		}
+		outcome, err := runInteractiveBind(ctx, sets, dir, progress)
+		t.events <- bindDoneMsg{outcome: outcome, err: err}
+	}()
+	return t.wait()
+}
+
+// askWhere opens the prompt for the directory to bind to.
+func (m *model) askWhere(label string, load bindLoader) {
+	m.asking = &pendingBind{label: label, load: load}
+	m.textInput.Reset()
+	m.textInput.Placeholder = ""
+	m.textInput.SetValue(m.outDir)
+	m.textInput.CursorEnd()
+	m.textInput.Focus()
+}
+
+// quit ends the interactive mode, wiping the shares of a ceremony first.
+func (m *model) quit() tea.Cmd {
+	m.quitting = true
+	if m.ceremony != nil {
+		m.ceremony.wipe()
+		m.ceremony = nil
+	}
+	return tea.Quit
+}
+
+// updateAsking handles a key press while asking where to bind to.
+func (m model) updateAsking(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		cmd := m.quit()
+		return m, cmd
+	case "esc":
+		m.asking = nil
+		m.status = browseStatus
+		return m, nil
+	case "enter":
+		dir := strings.TrimSpace(m.textInput.Value())
+		if dir == "" {
+			dir = m.outDir
+		}
+		if info, err := os.Stat(dir); err == nil && !info.IsDir() {
+			m.asking.err = fmt.Sprintf("%s is not a directory", dir)
+			return m, nil
+		}
+		if abs, err := filepath.Abs(dir); err == nil {
+			dir = abs
+		}
+		a := m.asking
+		m.asking = nil
+		m.outDir = dir
+		cmd := m.startBind(a.label, a.load, dir)
+		return m, cmd
+	}
+	var cmd tea.Cmd
+	m.textInput, cmd = m.textInput.Update(msg)
+	return m, cmd
+}
+
+// updateOutcome handles a key press while asking about a name taken in the
+// output directory.
+func (m model) updateOutcome(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	o := m.outcome
+	if msg.String() == "ctrl+c" {
+		cmd := m.quit()
+		return m, cmd
+	}
+	if o.renaming {
+		switch msg.String() {
+		case "esc":
+			o.renaming, o.err = false, ""
+		case "enter":
+			o.rename(m.textInput.Value())
+		default:
+			var cmd tea.Cmd
+			m.textInput, cmd = m.textInput.Update(msg)
+			return m, cmd
+		}
+	} else {
+		switch msg.String() {
+		case "o":
+			o.overwrite()
+		case "r":
+			o.renaming = true
+			m.textInput.Reset()
+			m.textInput.SetValue(freeName(o.dir, o.conflicts[0].name))
+			m.textInput.CursorEnd()
+			m.textInput.Focus()
+		case "s", "esc":
+			o.skip()
+		}
+	}
+	if o.done() {
+		m.outcome = nil
+		m.setStatus(o.status())
+		if m.ceremony != nil {
+			m.settleCeremony(errors.Join(o.notBound...))
+		}
+	}
+	return m, nil
+}
 
-func (m model) bindSelected() tea.Cmd {
+func (t *bindTask) wait() tea.Cmd {
+	events := t.events
 	return func() tea.Msg {
-		var selectedPaths []string
-		for _, f := range m.files {
-			if f.selected {
-				selectedPaths = append(selectedPaths, f.path)
-			}
+		return <-events
+	}
+}
+
+// updateWizard handles a key press while the split wizard is open.
+func (m model) updateWizard(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	w := m.wizard
+	if msg.String() == "ctrl+c" {
+		if w.step == stepRunning {
+			// Quit once the split has cleaned up
+			w.stop(true)
+			return m, nil
+		}
+		cmd := m.quit()
+		return m, cmd
+	}
+	switch w.step {
+	case stepRunning:
+		if msg.String() == "esc" {
+			w.stop(false)
 		}
+		return m, nil
+	case stepDone:
+		// Back to the files, where the new horcruxes may be
+		m.wizard = nil
+		if errors.Is(w.result, context.Canceled) {
+			m.status = "Split cancelled."
+		} else if w.result != nil {
+			m.status = fmt.Sprintf("Error: %v", w.result)
+		} else {
+			m.status = fmt.Sprintf("Success! %s split into %d horcruxes in %s.", filepath.Base(w.file), w.settings.totalParts, w.settings.destDir)
+		}
+		cmd := m.loadFiles()
+		return m, cmd
+	}
 
-		if len(selectedPaths) == 0 {
-			return statusMsg("No files selected!")
+	switch msg.String() {
+	case "esc":
+		if w.step == stepShards {
+			m.wizard = nil
+			m.status = browseStatus
+			cmd := m.loadFiles()
+			return m, cmd
 		}
+		w.step--
+		w.err = ""
+		w.prompt(&m.textInput)
+		return m, nil
+
+	case "enter":
+		if w.step == stepConfirm {
+			return m, w.start()
+		}
+		w.submit(m.textInput.Value())
+		w.prompt(&m.textInput)
+		return m, nil
+	}
+
+	switch w.step {
+	case stepHeaderless:
+		switch msg.String() {
+		case "y":
+			w.settings.isHeaderless = true
+		case "n":
+			w.settings.isHeaderless = false
+		case " ":
+			w.settings.isHeaderless = !w.settings.isHeaderless
+		}
+		return m, nil
+	case stepConfirm:
+		return m, nil
+	}
+	var cmd tea.Cmd
+	m.textInput, cmd = m.textInput.Update(msg)
+	return m, cmd
+}
 
-		if err := runInteractiveBind(selectedPaths); err != nil {
-			return statusMsg(fmt.Sprintf("Error: %v", err))
+func (m *model) bindSelected() tea.Cmd {
+	var selectedPaths []string
+	for _, f := range m.files {
+		if f.selected {
+			selectedPaths = append(selectedPaths, f.path)
 		}
+	}
 
-		return statusMsg("Success! File resurrected in current directory.")
+	if len(selectedPaths) == 0 {
+		m.status = "No files selected!"
+		return nil
 	}
+
+	m.askWhere("the selected files", func(ctx context.Context, progress pipeline.Reporter) ([]*shardSet, error) {
+		return loadSelection(ctx, selectedPaths, progress)
+	})
+	return nil
+}
+
+// bindSet binds every horcrux of a set at once.
+func (m *model) bindSet(set *shardSet) tea.Cmd {
+	switch {
+	case set == nil:
+		m.status = "Not a horcrux of any set found here"
+		return nil
+	case !set.ready():
+		m.status = fmt.Sprintf("Cannot bind %s yet: %s", set.header.OriginalFilename, set.summary())
+		return nil
+	}
+	m.askWhere(set.header.OriginalFilename, func(ctx context.Context, progress pipeline.Reporter) ([]*shardSet, error) {
+		return loadShardSet(ctx, set, progress)
+	})
+	return nil
 }
 
 func (m model) View() string {
 	if m.quitting {
 		return "Bye!\n"
 	}
+	if m.wizard != nil {
+		return docStyle.Render(m.wizard.view(m.textInput))
+	}
+	if m.ceremony != nil {
+		if m.asking == nil && m.binding == nil {
+			return docStyle.Render(m.ceremony.view(m.textInput))
+		}
+		return docStyle.Render("Quorum ceremony" + m.statusView())
+	}
 
 	s := fmt.Sprintf("Directory: %s\n\n", m.path)
 
@@ -192,124 +620,240 @@ func (m model) View() string {
 		s += " " + line + "\n"
 	}
 
-	s += fmt.Sprintf("\n%s\n", m.status)
-	return docStyle.Render(s)
+	if !m.picking {
+		s = lipgloss.JoinHorizontal(lipgloss.Top, s,
+			paneStyle.Render(setsView(m.sets, m.currentSet(), m.setsFocused, m.scanning, m.skipped)))
+	}
+	return docStyle.Render(s + m.statusView())
+}
+
+// statusView is the bottom of the screen: the status, or the bind being
+// prepared, run or settled.
+func (m model) statusView() string {
+	var s string
+	switch {
+	case m.asking != nil:
+		s += fmt.Sprintf("\n\nBind %s\n\n%s Write to: %s\n", m.asking.label, cursorStyle.Render(">"), m.textInput.View())
+		if m.asking.err != "" {
+			s += errorStyle.Render(m.asking.err) + "\n"
+		}
+		s += "Enter: bind | Esc: cancel\n"
+	case m.outcome != nil:
+		s += "\n\n" + m.outcome.view(m.textInput)
+	case m.binding != nil:
+		b := m.binding
+		s += fmt.Sprintf("\n\nBinding %s...\n%s %s\nEsc: cancel\n", b.label, b.bar.ViewAs(progressFraction(b.stage)), stageProgress(b.stage))
+	default:
+		s += fmt.Sprintf("\n\n%s\n", m.status)
+	}
+	return s
+}
+
+// loadSelection reads the selected files, bodies and all, as shard sets.
+func loadSelection(ctx context.Context, paths []string, progress pipeline.Reporter) ([]*shardSet, error) {
+	horcruxes, errs := readTUIHorcruxes(ctx, paths, true, progress)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if len(errs) > 0 {
+		return nil, errs[0]
+	}
+	sets := groupShardSets(horcruxes)
+	if len(sets) == 0 {
+		return nil, errors.New("no horcruxes in the selection")
+	}
+	return sets, nil
 }
 
 // runInteractiveBind is a simplified version of the core bind logic
-// adapted for the TUI to run on specific selected files.
-func runInteractiveBind(paths []string) error {
-	// Group files by ID
-	type loadedHorcrux struct {
-		Header *format.Header
-		Body   []byte // Load full body for simplicity in TUI
-	}
-	
-	// We only process one group for simplicity in this interactive mode
-	// or we take the first valid group we find from selection.
-	var horcruxes []*loadedHorcrux
-	var refHeader *format.Header
-
-	for _, path := range paths {
-		// 1. Open & Handle Stego/Normal
-		file, err := os.Open(path)
+// adapted for the TUI. Every set with enough horcruxes is bound and written
+// to dir, except where its name is taken and --overwrite is not given: those
+// are left in the outcome's conflicts, for the user to decide on. Sets that
+// cannot be bound are listed in the outcome too; only cancellation and
+// failing to write stop the bind.
+func runInteractiveBind(ctx context.Context, sets []*shardSet, dir string, progress pipeline.Reporter) (*bindOutcome, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	o := &bindOutcome{dir: dir}
+	for _, set := range sets {
+		name := set.header.OriginalFilename
+		if !set.ready() {
+			o.notBound = append(o.notBound, fmt.Errorf("not enough shards for %s. Need %d, selected %d (missing shards held by: %s)",
+				name, set.header.Threshold, set.found(), strings.Join(format.MissingCustodians(set.headers()), ", ")))
+			continue
+		}
+		data, err := set.join(ctx, progress)
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
 		if err != nil {
-			return err
+			o.notBound = append(o.notBound, fmt.Errorf("%s: %w", name, err))
+			continue
 		}
-		defer file.Close()
 
-		var reader io.Reader = file
-		
-		// Check for PNG stego
-		if strings.HasSuffix(strings.ToLower(path), ".png") {
-			img, _, err := image.Decode(file)
-			if err != nil {
-				return fmt.Errorf("failed to decode image %s: %w", filepath.Base(path), err)
-			}
-			hiddenData, err := stego.Extract(img)
-			if err != nil {
-				return fmt.Errorf("stego extraction failed for %s: %w", filepath.Base(path), err)
-			}
-			reader = bytes.NewReader(hiddenData)
+		if info, err := os.Stat(filepath.Join(dir, name)); err == nil && !overwrite {
+			o.conflicts = append(o.conflicts, newBindConflict(dir, name, data, set.header, info))
+			continue
 		}
-
-		// 2. Parse Header
-		hReader, err := format.NewReader(reader)
-		if err != nil {
-			return fmt.Errorf("invalid header in %s: %w", filepath.Base(path), err)
+		progress.Report(pipeline.Event{Stage: pipeline.StageWrite, Total: 1})
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			return nil, err
 		}
+		progress.Report(pipeline.Event{Stage: pipeline.StageWrite, Done: 1, Total: 1})
+		o.written = append(o.written, name)
+	}
+	return o, nil
+}
 
-		// Read Body
-		body, err := io.ReadAll(hReader.Body)
+// tuiHorcrux is a horcrux read by the interactive mode, from one file or
+// from the images it is spread over.
+type tuiHorcrux struct {
+	paths  []string
+	header *format.Header
+	body   []byte // Loaded in full for simplicity, when about to be bound
+}
+
+// readTUIHorcruxes reads the horcruxes in the given files: plain ones, those
+// hidden or stored in carriers, and armored ones, several to a file. Bodies
+// are only loaded if asked for. Files that cannot be read are skipped, with
+// the reason in errs. Each file read is reported to progress, and reading
+// stops once ctx is done.
+func readTUIHorcruxes(ctx context.Context, paths []string, bodies bool, progress pipeline.Reporter) (horcruxes []*tuiHorcrux, errs []error) {
+	add := func(paths []string, r io.Reader) {
+		reader, err := format.NewReader(r)
 		if err != nil {
-			return err
+			errs = append(errs, fmt.Errorf("invalid header in %s: %w", filepath.Base(paths[0]), err))
+			return
 		}
-
-		if refHeader == nil {
-			refHeader = hReader.Header
-		} else {
-			// Basic validation that they belong to same file
-			if hReader.Header.OriginalFilename != refHeader.OriginalFilename {
-				return fmt.Errorf("selection contains mixed files: %s vs %s", refHeader.OriginalFilename, hReader.Header.OriginalFilename)
+		h := &tuiHorcrux{paths: paths, header: reader.Header}
+		if bodies {
+			if h.body, err = io.ReadAll(reader.Body); err != nil {
+				errs = append(errs, fmt.Errorf("failed to read %s: %w", filepath.Base(paths[0]), err))
+				return
 			}
 		}
-
-		horcruxes = append(horcruxes, &loadedHorcrux{
-			Header: hReader.Header,
-			Body:   body,
-		})
+		horcruxes = append(horcruxes, h)
 	}
 
-	if len(horcruxes) < refHeader.Threshold {
-		return fmt.Errorf("not enough shards. Need %d, selected %d", refHeader.Threshold, len(horcruxes))
-	}
+	// Parts of horcruxes spread over several images are joined once all
+	// files are read
+	spread := make(map[[16]byte][]*stego.Part)
+	spreadPaths := make(map[[16]byte][]string)
 
-	// 3. Reconstruct
-	keyFragments := make([][]byte, len(horcruxes))
-	shardMap := make(map[int][]byte)
+	for i, path := range paths {
+		if ctx.Err() != nil {
+			return horcruxes, errs
+		}
+		progress.Report(pipeline.Event{Stage: pipeline.StageExtract, Done: int64(i), Total: int64(len(paths))})
+		err := func() error {
+			// 1. Open & Handle Stego/Normal
+			file, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
 
-	for i, h := range horcruxes {
-		keyFragments[i] = h.Header.KeyFragment
-		
-		// CRITICAL FIX: Convert 1-based Header Index to 0-based RS Index
-		shardMap[h.Header.Index-1] = h.Body
-	}
+			head, err := sniffFile(path)
+			if err != nil {
+				return err
+			}
+			if isHorcruxFile(path, head) {
+				add([]string{path}, file)
+				return nil
+			}
 
-	key, err := shamir.Combine(keyFragments)
-	if err != nil {
-		return fmt.Errorf("key reconstruction failed: %w", err)
+			// 2. Check for carriers: stego images, recordings, container
+			// files and text, which may hold several armored horcruxes or one
+			// hidden in it
+			raw, err := io.ReadAll(file)
+			if err != nil {
+				return err
+			}
+			// What was read holds a key fragment, so it is cleared once
+			// parsed; a part of a horcrux spread over several images is
+			// cleared once joined
+			var hiddenData []byte
+			isPart := false
+			defer func() {
+				if !isPart {
+					clear(raw)
+					clear(hiddenData)
+				}
+			}()
+			hiddenData, err = extractCarrier(raw)
+			if errors.Is(err, stego.ErrNoHiddenData) && armor.IsArmored(raw) {
+				hiddenData, err = raw, nil
+			}
+			if err != nil {
+				return fmt.Errorf("stego extraction failed for %s: %w", filepath.Base(path), err)
+			}
+			if part, ok := stego.ParsePart(hiddenData); ok {
+				spread[part.Set] = append(spread[part.Set], part)
+				spreadPaths[part.Set] = append(spreadPaths[part.Set], path)
+				isPart = true
+				return nil
+			}
+			if !armor.IsArmored(hiddenData) {
+				add([]string{path}, bytes.NewReader(hiddenData))
+				return nil
+			}
+			// Damaged blocks are reported, the others still read
+			blocks, err := armor.Decode(bytes.NewReader(hiddenData))
+			for _, block := range blocks {
+				add([]string{path}, bytes.NewReader(block.Data))
+				clear(block.Data)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to decode armor in %s: %w", filepath.Base(path), err)
+			}
+			return nil
+		}()
+		if err != nil {
+			errs = append(errs, err)
+		}
 	}
 
-	plainText, err := pipeline.JoinPipeline(shardMap, key, refHeader.Total, refHeader.Threshold)
-	if err != nil {
-		return fmt.Errorf("decryption pipeline failed: %w", err)
-	}
+	progress.Report(pipeline.Event{Stage: pipeline.StageExtract, Done: int64(len(paths)), Total: int64(len(paths))})
 
-	// 4. Save
-	// We save to the current working directory of the TUI user
-	cwd, _ := os.Getwd()
-	outPath := filepath.Join(cwd, refHeader.OriginalFilename)
-	
-	if err := os.WriteFile(outPath, plainText, 0644); err != nil {
-		return err
+	for set, parts := range spread {
+		data, err := stego.JoinParts(parts)
+		for _, part := range parts {
+			clear(part.Data)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("a horcrux spread over several images is incomplete (select all of its images): %w", err))
+			continue
+		}
+		add(spreadPaths[set], bytes.NewReader(data))
+		clear(data)
 	}
-
-	return nil
+	return horcruxes, errs
 }
 
+// ceremonyMode starts the interactive mode in a quorum ceremony.
+var ceremonyMode bool
+
 // Cobra command setup
 var interactiveCmd = &cobra.Command{
 	Use:   "interactive",
-	Short: "Interactive terminal UI for binding horcruxes",
+	Short: "Interactive terminal UI for splitting and binding horcruxes",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		p := tea.NewProgram(initialModel())
-		if _, err := p.Run(); err != nil {
-			return err
+		m := initialModel()
+		if ceremonyMode {
+			m.ceremony = newCeremony()
 		}
-		return nil
+		final, err := tea.NewProgram(m).Run()
+		if m, ok := final.(model); ok && m.ceremony != nil {
+			m.ceremony.wipe()
+		}
+		return err
 	},
 }
 
 func init() {
 	rootCmd.AddCommand(interactiveCmd)
+	interactiveCmd.Flags().BoolVar(&ceremonyMode, "ceremony", false, "Start in a quorum ceremony, custodians giving their shares in turn")
+	interactiveCmd.Flags().BoolVar(&overwrite, "overwrite", false, "Overwrite existing files when binding instead of asking")
 }
\ No newline at end of file
@@ -0,0 +1,188 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Beastly713/horcrux/pkg/format"
+	"github.com/Beastly713/horcrux/pkg/pipeline"
+	"github.com/charmbracelet/bubbles/textinput"
+)
+
+// bindLoader reads the horcruxes a bind in the interactive mode is about, as
+// shard sets.
+type bindLoader func(ctx context.Context, progress pipeline.Reporter) ([]*shardSet, error)
+
+// pendingBind is a bind waiting for the directory to write to.
+type pendingBind struct {
+	label string
+	load  bindLoader
+	err   string
+}
+
+// bindConflict is a resurrected file whose name is taken in the output
+// directory, waiting for the user to overwrite, rename or skip it.
+type bindConflict struct {
+	name     string // in the output directory
+	data     []byte
+	header   *format.Header
+	existing os.FileInfo
+	sizes    string // how the sizes, or contents, differ
+}
+
+// newBindConflict compares data with the file taken in dir, reading it once.
+func newBindConflict(dir, name string, data []byte, header *format.Header, existing os.FileInfo) *bindConflict {
+	c := &bindConflict{name: name, data: data, header: header, existing: existing}
+	c.sizes = sizeDifference(filepath.Join(dir, name), c)
+	return c
+}
+
+// bindOutcome is what a bind in the interactive mode wrote, and what it
+// still has to ask about.
+type bindOutcome struct {
+	dir       string
+	written   []string
+	skipped   []string
+	notBound  []error         // sets without enough horcruxes, or that failed
+	conflicts []*bindConflict // the first is being asked about
+	renaming  bool
+	err       string // why the name typed cannot be used
+}
+
+func (o *bindOutcome) done() bool {
+	return len(o.conflicts) == 0
+}
+
+// overwrite writes the first conflict over the existing file.
+func (o *bindOutcome) overwrite() {
+	c := o.conflicts[0]
+	o.write(c.name, c.data)
+}
+
+// rename writes the first conflict under another name, unless that is
+// taken too, in which case it becomes the conflict asked about.
+func (o *bindOutcome) rename(name string) {
+	name = strings.TrimSpace(name)
+	o.err = ""
+	if name == "" || name != filepath.Base(name) || name == "." || name == ".." {
+		o.err = "give a file name, without a directory"
+		return
+	}
+	c := o.conflicts[0]
+	o.renaming = false
+	if info, err := os.Stat(filepath.Join(o.dir, name)); err == nil {
+		o.conflicts[0] = newBindConflict(o.dir, name, c.data, c.header, info)
+		return
+	}
+	o.write(name, c.data)
+}
+
+// skip leaves the first conflict unwritten.
+func (o *bindOutcome) skip() {
+	o.skipped = append(o.skipped, o.conflicts[0].name)
+	o.next()
+}
+
+func (o *bindOutcome) write(name string, data []byte) {
+	if err := os.WriteFile(filepath.Join(o.dir, name), data, 0644); err != nil {
+		o.notBound = append(o.notBound, err)
+	} else {
+		o.written = append(o.written, name)
+	}
+	o.next()
+}
+
+func (o *bindOutcome) next() {
+	o.conflicts = o.conflicts[1:]
+	o.renaming = false
+	o.err = ""
+}
+
+// status sums up the bind once every conflict is settled.
+func (o *bindOutcome) status() string {
+	var parts []string
+	if len(o.written) > 0 {
+		parts = append(parts, fmt.Sprintf("Success! %s resurrected in %s.", strings.Join(o.written, ", "), o.dir))
+	}
+	if len(o.skipped) > 0 {
+		parts = append(parts, fmt.Sprintf("Skipped %s.", strings.Join(o.skipped, ", ")))
+	}
+	if err := errors.Join(o.notBound...); err != nil {
+		if len(o.written) == 0 {
+			return fmt.Sprintf("Error: %v", err)
+		}
+		parts = append(parts, fmt.Sprintf("Not bound: %v", err))
+	}
+	return strings.Join(parts, " ")
+}
+
+// freeName returns name with the first number that is not taken in dir, as
+// in "notes (2).txt".
+func freeName(dir, name string) string {
+	ext := filepath.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	for i := 2; ; i++ {
+		candidate := fmt.Sprintf("%s (%d)%s", base, i, ext)
+		if _, err := os.Stat(filepath.Join(dir, candidate)); errors.Is(err, os.ErrNotExist) {
+			return candidate
+		}
+	}
+}
+
+// view compares the existing file with the one resurrected, by size and by
+// time: its last change against when the horcruxes were split.
+func (o *bindOutcome) view(input textinput.Model) string {
+	c := o.conflicts[0]
+	split := time.Unix(c.header.Timestamp, 0)
+	s := fmt.Sprintf("%s already exists in %s\n\n", c.name, o.dir)
+	s += fmt.Sprintf("  %-12s %-14s %s\n", "", "Size", "Modified")
+	s += fmt.Sprintf("  %-12s %-14s %s\n", "Existing", fmt.Sprintf("%d bytes", c.existing.Size()), c.existing.ModTime().Format("2006-01-02 15:04"))
+	s += fmt.Sprintf("  %-12s %-14s %s (when split)\n", "Resurrected", fmt.Sprintf("%d bytes", len(c.data)), split.Format("2006-01-02 15:04"))
+	s += fmt.Sprintf("  %-12s %-14s %s\n", "Difference", c.sizes, ageDifference(c.existing.ModTime(), split))
+
+	switch {
+	case o.renaming:
+		s += "\n" + cursorStyle.Render(">") + " New name: " + input.View() + "\n"
+		if o.err != "" {
+			s += errorStyle.Render(o.err) + "\n"
+		}
+		s += "Enter: write | Esc: back\n"
+	default:
+		s += "\n'o': Overwrite | 'r': Rename | 's'/Esc: Skip\n"
+	}
+	if more := len(o.conflicts) - 1; more > 0 {
+		s += dimStyle.Render(fmt.Sprintf("%d more file(s) to decide on", more)) + "\n"
+	}
+	return s
+}
+
+// sizeDifference says how much larger the resurrected file is than the one
+// at path, or whether it is the same.
+func sizeDifference(path string, c *bindConflict) string {
+	delta := int64(len(c.data)) - c.existing.Size()
+	if delta != 0 {
+		return fmt.Sprintf("%+d bytes", delta)
+	}
+	if existing, err := os.ReadFile(path); err == nil && bytes.Equal(existing, c.data) {
+		return "identical"
+	}
+	return "same size"
+}
+
+// ageDifference says how much newer or older the existing file is.
+func ageDifference(existing, split time.Time) string {
+	d := existing.Sub(split).Round(time.Minute)
+	switch {
+	case d > 0:
+		return fmt.Sprintf("existing is %s newer", strings.TrimSuffix(d.String(), "0s"))
+	case d < 0:
+		return fmt.Sprintf("existing is %s older", strings.TrimSuffix((-d).String(), "0s"))
+	}
+	return "same time"
+}
@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/Beastly713/horcrux/pkg/format"
+)
+
+func TestFreeName(t *testing.T) {
+	tests := []struct {
+		name  string
+		taken []string
+		want  string
+	}{
+		{"notes.txt", nil, "notes (2).txt"},
+		{"notes.txt", []string{"notes (2).txt"}, "notes (3).txt"},
+		{"notes.txt", []string{"notes (2).txt", "notes (3).txt", "notes (5).txt"}, "notes (4).txt"},
+		{"archive.tar.gz", nil, "archive.tar (2).gz"},
+		{"README", []string{"README (2)"}, "README (3)"},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		for _, name := range tt.taken {
+			if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if got := freeName(dir, tt.name); got != tt.want {
+			t.Errorf("freeName(%q) with %v taken = %q, want %q", tt.name, tt.taken, got, tt.want)
+		}
+	}
+}
+
+func TestBindOutcome(t *testing.T) {
+	header := &format.Header{OriginalFilename: "notes.txt", Timestamp: 1700000000}
+	data := []byte("resurrected")
+
+	tests := []struct {
+		name    string
+		act     func(o *bindOutcome)
+		files   map[string]string // in the directory afterwards
+		written []string
+		skipped []string
+		pending bool // still asking about a conflict
+	}{
+		{
+			name:    "overwrite",
+			act:     func(o *bindOutcome) { o.overwrite() },
+			files:   map[string]string{"notes.txt": "resurrected", "notes (2).txt": "older copy"},
+			written: []string{"notes.txt"},
+		},
+		{
+			name:    "skip",
+			act:     func(o *bindOutcome) { o.skip() },
+			files:   map[string]string{"notes.txt": "existing", "notes (2).txt": "older copy"},
+			skipped: []string{"notes.txt"},
+		},
+		{
+			name:    "rename to a free name",
+			act:     func(o *bindOutcome) { o.rename(freeName(o.dir, "notes.txt")) },
+			files:   map[string]string{"notes.txt": "existing", "notes (2).txt": "older copy", "notes (3).txt": "resurrected"},
+			written: []string{"notes (3).txt"},
+		},
+		{
+			name:    "rename to a taken name",
+			act:     func(o *bindOutcome) { o.rename("notes (2).txt") },
+			files:   map[string]string{"notes.txt": "existing", "notes (2).txt": "older copy"},
+			pending: true,
+		},
+		{
+			name:    "rename to a taken name, then overwrite it",
+			act:     func(o *bindOutcome) { o.rename("notes (2).txt"); o.overwrite() },
+			files:   map[string]string{"notes.txt": "existing", "notes (2).txt": "resurrected"},
+			written: []string{"notes (2).txt"},
+		},
+		{
+			name:    "rename into a directory",
+			act:     func(o *bindOutcome) { o.rename("../notes.txt") },
+			files:   map[string]string{"notes.txt": "existing", "notes (2).txt": "older copy"},
+			pending: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, content := range map[string]string{"notes.txt": "existing", "notes (2).txt": "older copy"} {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			info, err := os.Stat(filepath.Join(dir, "notes.txt"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			o := &bindOutcome{dir: dir, conflicts: []*bindConflict{newBindConflict(dir, "notes.txt", data, header, info)}}
+
+			tt.act(o)
+
+			if o.done() == tt.pending {
+				t.Errorf("Done: %v, want %v", o.done(), !tt.pending)
+			}
+			if !reflect.DeepEqual(o.written, tt.written) || !reflect.DeepEqual(o.skipped, tt.skipped) {
+				t.Errorf("Written %v, skipped %v; want %v, %v", o.written, o.skipped, tt.written, tt.skipped)
+			}
+			entries, _ := os.ReadDir(dir)
+			files := make(map[string]string)
+			for _, e := range entries {
+				content, _ := os.ReadFile(filepath.Join(dir, e.Name()))
+				files[e.Name()] = string(content)
+			}
+			if !reflect.DeepEqual(files, tt.files) {
+				t.Errorf("Files mismatch.\nGot: %v\nWant: %v", files, tt.files)
+			}
+		})
+	}
+}
+
+func TestBindConflictSizes(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		existing, data, want string
+	}{
+		{"same", "same", "identical"},
+		{"abcd", "wxyz", "same size"},
+		{"ab", "abcd", "+2 bytes"},
+		{"abcd", "a", "-3 bytes"},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(dir, "f")
+		if err := os.WriteFile(path, []byte(tt.existing), 0644); err != nil {
+			t.Fatal(err)
+		}
+		info, _ := os.Stat(path)
+		c := newBindConflict(dir, "f", []byte(tt.data), &format.Header{}, info)
+		if c.sizes != tt.want {
+			t.Errorf("%q over %q: %q, want %q", tt.data, tt.existing, c.sizes, tt.want)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/Beastly713/horcrux/pkg/pipeline"
+	"github.com/charmbracelet/bubbles/progress"
+)
+
+// progressWidth is the width of a progress bar, brackets excluded.
+const progressWidth = 30
+
+// progressBar renders done of total as a bar of progressWidth, in plain ASCII
+// for terminals written to line by line.
+func progressBar(done, total int64) string {
+	filled := 0
+	if total > 0 {
+		filled = int(min(progressWidth*done/total, progressWidth))
+	}
+	return "[" + strings.Repeat("#", filled) + strings.Repeat("-", progressWidth-filled) + "]"
+}
+
+// progressFraction is how far along an event's stage is, 0 when unknown.
+func progressFraction(e pipeline.Event) float64 {
+	if e.Total <= 0 {
+		return 0
+	}
+	return min(float64(e.Done)/float64(e.Total), 1)
+}
+
+// stageProgress describes an event: its stage, then how far along it is.
+func stageProgress(e pipeline.Event) string {
+	switch {
+	case e.Stage == pipeline.StageWrite || e.Stage == pipeline.StageExtract:
+		return fmt.Sprintf("%s %d/%d", e.Stage, e.Done, e.Total)
+	case e.Total > 0:
+		return fmt.Sprintf("%s %d%%", e.Stage, 100*e.Done/e.Total)
+	}
+	return fmt.Sprintf("%s %d bytes", e.Stage, e.Done)
+}
+
+// progressLine renders an event for a terminal: a bar, then its stage.
+func progressLine(e pipeline.Event) string {
+	return progressBar(e.Done, e.Total) + " " + stageProgress(e)
+}
+
+// newTUIBar returns a progress bar for the interactive mode, drawn with
+// ViewAs rather than animated.
+func newTUIBar() progress.Model {
+	return progress.New(progress.WithDefaultGradient(), progress.WithWidth(progressWidth), progress.WithoutPercentage())
+}
+
+// terminalProgress keeps a progress bar on the last line of a terminal,
+// under the lines written through it.
+type terminalProgress struct {
+	mu      sync.Mutex
+	out     io.Writer
+	line    string // the bar, "" for none
+	midLine bool   // text written through ends without a newline
+}
+
+// cliProgress returns where a command should print and report its progress,
+// and the function to call when it is done: a bar on stdout when that is a
+// terminal, and plain output otherwise.
+func cliProgress() (io.Writer, pipeline.Reporter, func()) {
+	if info, err := os.Stdout.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return os.Stdout, pipeline.Discard, func() {}
+	}
+	t := &terminalProgress{out: os.Stdout}
+	return t, t, t.clear
+}
+
+func (t *terminalProgress) Report(e pipeline.Event) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	line := progressLine(e)
+	if e.Total > 0 && e.Done >= e.Total {
+		// A finished stage leaves no bar behind
+		line = ""
+	}
+	if line != t.line {
+		if !t.midLine {
+			fmt.Fprint(t.out, "\r\033[K"+line)
+		}
+		t.line = line
+	}
+}
+
+func (t *terminalProgress) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if t.line != "" && !t.midLine {
+		fmt.Fprint(t.out, "\r\033[K")
+	}
+	n, err := t.out.Write(p)
+	t.midLine = p[len(p)-1] != '\n'
+	if t.line != "" && !t.midLine {
+		fmt.Fprint(t.out, t.line)
+	}
+	return n, err
+}
+
+// clear removes the bar.
+func (t *terminalProgress) clear() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.line != "" && !t.midLine {
+		fmt.Fprint(t.out, "\r\033[K")
+	}
+	t.line = ""
+}
@@ -0,0 +1,345 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/Beastly713/horcrux/pkg/pipeline"
+	"github.com/Beastly713/horcrux/pkg/shamir"
+	"github.com/Beastly713/horcrux/pkg/slip39"
+	"github.com/Beastly713/horcrux/pkg/ssss"
+	"github.com/spf13/cobra"
+)
+
+var (
+	sharesFormat      string
+	sharesGroups      []string
+	groupThreshold    int
+	sharesPassphrase  string
+	sharesOutput      string
+	sharesInput       string
+	sharesFile        string
+	iterationExponent int
+	ssssToken         string
+	ssssThreshold     int
+	ssssNoDiffusion   bool
+)
+
+// keySize is the length of the AES-256 key the shares must hold.
+const keySize = 32
+
+// sharesCmd groups the commands that move the key shares to and from other
+// secret sharing tools.
+var sharesCmd = &cobra.Command{
+	Use:   "shares",
+	Short: "Export or import the key shares in other secret sharing formats",
+	Long: `Export the encryption key of a split as shares another tool understands,
+or import such shares to recover the key and bind the horcruxes with it.
+
+Supported formats:
+  slip39   SLIP-0039 mnemonic groups, as used by hardware wallets
+  ssss     "index-hex" shares of ssss-split / ssss-combine
+  vault    base64 unseal keys of HashiCorp Vault, the same shares as the
+           key fragments in the horcrux headers`,
+}
+
+var sharesExportCmd = &cobra.Command{
+	Use:   "export [directory]",
+	Short: "Export the key of a split as shares in another format",
+	Long: `Export combines the key fragments of the horcruxes in the directory (or the
+current directory) and writes the key as shares in the chosen format.
+
+By default the shares mirror the split (T of N). Use --groups to define
+SLIP-39 groups, e.g. --groups 2of3,3of5 --group-threshold 2.
+
+Vault shares are the key fragments themselves, so every horcrux found is
+exported as is, even when fewer than T are present.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sourceDir := "."
+		if len(args) > 0 {
+			sourceDir = args[0]
+		}
+
+		// 1. Load the horcruxes
+		groups, err := gatherHorcruxes(cmd.Context(), cmd, sourceDir, cmd.OutOrStdout(), pipeline.Discard)
+		if err != nil {
+			return err
+		}
+		group, err := selectGroup(groups, sharesFile)
+		if err != nil {
+			return err
+		}
+		defer closeHorcruxes(group)
+		refHeader := group[0].Header
+
+		// 2. Convert the key shares
+		lines, err := exportShares(group)
+		if err != nil {
+			return err
+		}
+
+		// 3. Write them out
+		out := cmd.OutOrStdout()
+		if sharesOutput != "" {
+			f, err := os.OpenFile(sharesOutput, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+			if err != nil {
+				return fmt.Errorf("failed to create output file: %w", err)
+			}
+			defer f.Close()
+			out = f
+		}
+
+		fmt.Fprintf(out, "# %s shares of the key for %s\n", sharesFormat, refHeader.OriginalFilename)
+		for _, line := range lines {
+			fmt.Fprintln(out, line)
+		}
+
+		if sharesOutput != "" {
+			fmt.Printf("Wrote %s shares for %s to %s\n", sharesFormat, refHeader.OriginalFilename, sharesOutput)
+		}
+		return nil
+	},
+}
+
+var sharesImportCmd = &cobra.Command{
+	Use:   "import [directory]",
+	Short: "Recover the key from shares and bind the horcruxes with it",
+	Long: `Import reads shares (one per line, "#" comments allowed) from --input or
+stdin, recovers the encryption key and uses it to bind the horcruxes in the
+directory (or the current directory). The key fragments in their headers are
+not used, but enough horcruxes are still needed for the data.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sourceDir := "."
+		if len(args) > 0 {
+			sourceDir = args[0]
+		}
+
+		// 1. Read the shares
+		in := cmd.InOrStdin()
+		if sharesInput != "" && sharesInput != "-" {
+			f, err := os.Open(sharesInput)
+			if err != nil {
+				return fmt.Errorf("failed to open shares: %w", err)
+			}
+			defer f.Close()
+			in = f
+		}
+		lines, err := readShareLines(in)
+		if err != nil {
+			return err
+		}
+
+		// 2. Recover the key
+		key, err := importShares(lines)
+		if err != nil {
+			return fmt.Errorf("failed to recover key from %s shares: %w", sharesFormat, err)
+		}
+		if len(key) != keySize {
+			return fmt.Errorf("the shares hold a %d-bit secret, not a %d-bit horcrux key", len(key)*8, keySize*8)
+		}
+		fmt.Printf("Recovered key from %d %s share(s)\n", len(lines), sharesFormat)
+
+		// 3. Bind the horcruxes it belongs to
+		groups, err := gatherHorcruxes(cmd.Context(), cmd, sourceDir, cmd.OutOrStdout(), pipeline.Discard)
+		if err != nil {
+			return err
+		}
+		group, err := selectGroup(groups, sharesFile)
+		if err != nil {
+			return err
+		}
+		defer closeHorcruxes(group)
+
+		return resurrect(cmd.Context(), group, key, cmd.OutOrStdout(), pipeline.Discard)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(sharesCmd)
+	sharesCmd.AddCommand(sharesExportCmd, sharesImportCmd)
+
+	for _, c := range []*cobra.Command{sharesExportCmd, sharesImportCmd} {
+		c.Flags().StringVar(&sharesFormat, "format", "slip39", "Share format: slip39, ssss or vault")
+		c.Flags().StringVar(&sharesPassphrase, "passphrase", "", "Passphrase protecting the shares")
+		c.Flags().StringVar(&sharesFile, "file", "", "Original filename, when the directory holds several splits")
+		c.Flags().BoolVar(&ssssNoDiffusion, "no-diffusion", false, "ssss: shares made with ssss-split -D")
+	}
+
+	sharesExportCmd.Flags().StringSliceVar(&sharesGroups, "groups", nil, "Comma-separated groups as TofN (default: the split's threshold and total)")
+	sharesExportCmd.Flags().IntVar(&groupThreshold, "group-threshold", 1, "Number of groups required to recover the key")
+	sharesExportCmd.Flags().IntVar(&iterationExponent, "iteration-exponent", slip39.DefaultIterationExponent, "SLIP-39 passphrase hardening (10000×2^e PBKDF2 rounds)")
+	sharesExportCmd.Flags().StringVar(&ssssToken, "token", "", "ssss: token prefixed to every share")
+	sharesExportCmd.Flags().StringVarP(&sharesOutput, "output", "o", "", "Write the shares to a file instead of stdout")
+
+	sharesImportCmd.Flags().IntVarP(&ssssThreshold, "threshold", "t", 0, "ssss: threshold of the shares (default: all shares given)")
+	sharesImportCmd.Flags().StringVarP(&sharesInput, "input", "f", "", "File holding the shares (default: stdin)")
+	sharesImportCmd.Flags().StringVarP(&outDir, "destination", "d", "", "Directory to write the resurrected file")
+	sharesImportCmd.Flags().BoolVar(&overwrite, "overwrite", false, "Overwrite existing file if present")
+}
+
+// exportShares converts the key shares of a split into lines of the chosen
+// format. Vault uses the same shares as the headers, so they are exported as
+// they are; the other formats need the key itself, and split it anew.
+func exportShares(group []*loadedHorcrux) ([]string, error) {
+	refHeader := group[0].Header
+
+	if sharesFormat == "vault" {
+		sorted := append([]*loadedHorcrux(nil), group...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i].Header.Index < sorted[j].Header.Index })
+		var lines []string
+		for _, h := range sorted {
+			lines = append(lines,
+				fmt.Sprintf("# held by %s", h.Header.CustodianOf(h.Header.Index)),
+				fmt.Sprintf("Unseal Key %d: %s", h.Header.Index, base64.StdEncoding.EncodeToString(h.Header.KeyFragment)))
+		}
+		return lines, nil
+	}
+
+	if len(group) < refHeader.Threshold {
+		return nil, fmt.Errorf("not enough horcruxes to recover the key of %s: need %d, found %d",
+			refHeader.OriginalFilename, refHeader.Threshold, len(group))
+	}
+	key, err := combineKey(group)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reconstruct key: %w", err)
+	}
+
+	switch sharesFormat {
+	case "slip39":
+		specs, err := parseGroupSpecs(sharesGroups, refHeader.Threshold, refHeader.Total)
+		if err != nil {
+			return nil, err
+		}
+		mnemonics, err := slip39.Generate(key, []byte(sharesPassphrase), groupThreshold, specs, iterationExponent, true)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create SLIP-39 shares: %w", err)
+		}
+		lines := []string{fmt.Sprintf("# %d of %d groups are needed", groupThreshold, len(specs))}
+		for i, g := range mnemonics {
+			lines = append(lines, "", fmt.Sprintf("# Group %d: %d of %d shares needed", i+1, specs[i].MemberThreshold, specs[i].MemberCount))
+			lines = append(lines, g...)
+		}
+		return lines, nil
+
+	case "ssss":
+		shares, err := ssss.Split(key, refHeader.Threshold, refHeader.Total, ssssToken, !ssssNoDiffusion)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create ssss shares: %w", err)
+		}
+		lines := []string{fmt.Sprintf("# combine %d of them with: ssss-combine -x -t %d", refHeader.Threshold, refHeader.Threshold)}
+		return append(lines, shares...), nil
+	}
+	return nil, fmt.Errorf("unsupported share format %q", sharesFormat)
+}
+
+// importShares recovers the key from share lines of the chosen format.
+func importShares(lines []string) ([]byte, error) {
+	switch sharesFormat {
+	case "slip39":
+		return slip39.Combine(lines, []byte(sharesPassphrase))
+
+	case "ssss":
+		t := ssssThreshold
+		if t == 0 {
+			t = len(lines)
+		}
+		return ssss.Combine(lines, t, !ssssNoDiffusion)
+
+	case "vault":
+		parts := make([][]byte, len(lines))
+		for i, line := range lines {
+			part, err := parseVaultShare(line)
+			if err != nil {
+				return nil, fmt.Errorf("share %d: %w", i+1, err)
+			}
+			parts[i] = part
+		}
+		return shamir.Combine(parts)
+	}
+	return nil, fmt.Errorf("unsupported share format %q", sharesFormat)
+}
+
+// parseVaultShare reads an unseal key as Vault prints it, base64 or hex, with
+// or without its "Unseal Key N:" label.
+func parseVaultShare(line string) ([]byte, error) {
+	if i := strings.LastIndex(line, ":"); i >= 0 {
+		line = line[i+1:]
+	}
+	line = strings.TrimSpace(line)
+	if part, err := base64.StdEncoding.DecodeString(line); err == nil {
+		return part, nil
+	}
+	if part, err := hex.DecodeString(line); err == nil {
+		return part, nil
+	}
+	return nil, fmt.Errorf("not a base64 or hex unseal key")
+}
+
+// selectGroup picks the split to work on, by original filename when the
+// directory holds more than one.
+func selectGroup(groups map[string][]*loadedHorcrux, filename string) ([]*loadedHorcrux, error) {
+	var names []string
+	for _, group := range groups {
+		name := group[0].Header.OriginalFilename
+		if filename == name {
+			return group, nil
+		}
+		names = append(names, name)
+	}
+	if filename != "" {
+		return nil, fmt.Errorf("no horcruxes of %s found", filename)
+	}
+	if len(groups) > 1 {
+		sort.Strings(names)
+		return nil, fmt.Errorf("horcruxes of several files found (%s); choose one with --file", strings.Join(names, ", "))
+	}
+	for _, group := range groups {
+		return group, nil
+	}
+	return nil, fmt.Errorf("no horcruxes found")
+}
+
+// parseGroupSpecs parses TofN group definitions, defaulting to one group
+// shaped like the split.
+func parseGroupSpecs(specs []string, threshold, total int) ([]slip39.GroupSpec, error) {
+	if len(specs) == 0 {
+		return []slip39.GroupSpec{{MemberThreshold: threshold, MemberCount: total}}, nil
+	}
+	groups := make([]slip39.GroupSpec, 0, len(specs))
+	for _, s := range specs {
+		var g slip39.GroupSpec
+		if _, err := fmt.Sscanf(strings.ToLower(strings.TrimSpace(s)), "%dof%d", &g.MemberThreshold, &g.MemberCount); err != nil {
+			return nil, fmt.Errorf("invalid group %q: expected TofN, e.g. 2of3", s)
+		}
+		groups = append(groups, g)
+	}
+	return groups, nil
+}
+
+// readShareLines returns the non-empty, non-comment lines of r.
+func readShareLines(r io.Reader) ([]string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read shares: %w", err)
+	}
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("no shares given")
+	}
+	return lines, nil
+}
@@ -2,17 +2,24 @@ package cmd
 
 import (
 	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
 	"fmt"
-	"image"
 	_ "image/jpeg" // Register JPEG decoder
-	"image/png"    // Register PNG decoder and encoder
+	_ "image/png"  // Register PNG decoder
+	"io"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strings"
+	"text/template"
 	"time"
 
+	"github.com/Beastly713/horcrux/pkg/armor"
 	"github.com/Beastly713/horcrux/pkg/crypto/secrets"
 	"github.com/Beastly713/horcrux/pkg/format"
+	"github.com/Beastly713/horcrux/pkg/paper"
 	"github.com/Beastly713/horcrux/pkg/pipeline"
 	"github.com/Beastly713/horcrux/pkg/shamir"
 	"github.com/Beastly713/horcrux/pkg/stego"
@@ -20,13 +27,42 @@ import (
 )
 
 var (
-	totalParts   int
-	threshold    int
-	destDir      string
-	carrierImage string
-	isHeaderless bool
+	totalParts    int
+	threshold     int
+	destDir       string
+	carrierImages []string
+	carrierAudio  []string
+	carrierText   []string
+	isHeaderless  bool
+	custodians    []string
+	instructions  string
+	armored       bool
+	paperKit      bool
+
+	// stegoPassphrase keys the stego layout; bind registers it too.
+	stegoPassphrase string
+	lsbMatching     bool
+	containerFile   string
+	carrierDir      string
+	autoScale       bool
+	stegoBits       int
+	stegoAlpha      bool
+	stegoRobust     bool
+	stegoGenerate   bool
+	stegoSeed       uint64
+	textEncoding    string
 )
 
+// instructionData is the value the --instructions template is executed with.
+type instructionData struct {
+	Filename   string
+	Index      int
+	Total      int
+	Threshold  int
+	Custodian  string
+	Custodians []string
+}
+
 var splitCmd = &cobra.Command{
 	Use:   "split [file]",
 	Short: "Split a file into encrypted horcruxes",
@@ -34,165 +70,608 @@ var splitCmd = &cobra.Command{
 You need T fragments to recover the file.
 
 If --carrier-image is provided, shards will be hidden inside copies of that image 
-using steganography and saved as PNG files.
+using steganography. Give one image per shard (repeat the flag or separate them
+with commas) so that no two horcruxes can be compared to find the data; each
+image is checked before anything is written, and --auto-scale enlarges any that
+are too small (the enlarged copies are PNGs). PNG carriers give PNG copies; baseline JPEG carriers give
+JPEG copies, with the shard in the DCT coefficients. A JPEG holds far less
+than a PNG, and progressive JPEGs fall back to PNG copies. Add
+--stego-passphrase to hide them in a passphrase-derived pseudo-random order,
+whitened so they look like noise, and --lsb-matching to change pixels by ±1
+instead of overwriting their lowest bit. Check the result with
+"horcrux stego analyze".
+
+--stego-bits 2 to 4 hides that many bits in each channel and --stego-alpha
+uses the alpha channel too, for over five times the room; bind reads the
+settings from the image. JPEG carriers then give PNG copies. See what fits
+with "horcrux stego capacity".
+
+--stego-robust instead repeats each shard all over its image in small
+checksummed blocks, with parity blocks to stand in for lost ones, so that a
+copy that has been cropped, or partly painted over, still gives the shard
+back as long as enough blocks are intact. It holds far less: a few KB in a
+typical photo. JPEG carriers then give PNG copies, and the copies must stay
+lossless; re-saving one as a JPEG still destroys the data.
+
+--stego-generate needs no carrier at all: each shard goes into an image made
+up for it, a textured scene with the noise and tone curve of a camera photo,
+just large enough to be at most half full. The copies are PNGs and the stego
+options apply. Every image is drawn from a random seed; --stego-seed fixes it
+for tests, but anyone who knows the seed can redraw the clean image and see
+what changed.
+
+--carrier-audio does the same with 16-bit PCM WAV recordings, such as an
+audio memo, hiding each shard in the lowest bit of the samples. A minute of
+CD-quality stereo holds about 650 KB. The stego options apply except
+--stego-bits and --stego-alpha.
+
+--carrier-text hides the armored horcrux in a text file such as a letter or a
+wiki page, as invisible zero-width characters between its words or, with
+--text-encoding whitespace, as spaces and tabs at the ends of its lines. The
+copies are named after the file split, with the carrier's extension (e.g.
+secrets_1_of_3.md); bind finds shards hidden in .txt and .md files.
+
+--carrier-dir takes a folder of photos instead, and spreads each shard over
+as many of them as it needs (e.g. diary_1_of_5_part1.jpg, ..._part2.jpg),
+never using a photo twice. Bind puts the parts back together; every part of a
+shard is needed.
+
+If --container is provided instead, each shard is stored in a copy of that
+PNG, JPEG, PDF or ZIP file where its format allows extra data (a private PNG
+chunk, after the end of a JPEG or PDF, before a ZIP's directory). The file
+still opens normally and there is no size limit, but the data is in plain
+sight for anyone who looks at the bytes.
+
+If --armor is provided, shards are written as ASCII-armored text (.horcrux.asc)
+that survives email, chat and copy/paste.
+
+If --paper is provided, each shard becomes a printable HTML page for offline
+storage: the shard data as QR codes and the key fragment as a word list.
+
+Use --custodians to record who holds each shard, and --instructions to add a
+recovery note to every preamble. The note is a Go template with the fields
+.Filename, .Index, .Total, .Threshold, .Custodian and .Custodians.
 
 Example:
   horcrux split diary.txt -n 5 -t 3
-  horcrux split secrets.pdf -n 3 -t 2 --carrier-image vacation.jpg`,
+  horcrux split secrets.pdf -n 3 -t 2 --carrier-image vacation.jpg
+  horcrux split secrets.pdf -n 3 -t 2 -i beach.png,dog.jpg,cake.png --auto-scale
+  horcrux split keys.txt -n 2 -t 2 --carrier-audio memo1.wav,memo2.wav
+  horcrux split keys.txt -n 3 -t 2 --stego-generate --lsb-matching
+  horcrux split will.pdf -n 3 -t 2 --custodians alice,bob,carol \
+    --instructions "Held by {{.Custodian}}. Call the family lawyer before binding."`,
 	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		filePath := args[0]
+		// Ctrl-C stops the split cleanly, removing the horcruxes written
+		ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+		defer stop()
+		out, progress, done := cliProgress()
+		defer done()
+		return splitFile(ctx, args[0], splitFlags(), out, progress)
+	},
+}
 
-		// 1. Validation
-		if totalParts < 2 {
-			return fmt.Errorf("number of parts (-n) must be at least 2")
+// splitSettings are the choices a split is made with, named after the
+// split command's flags, which fill them in on the command line.
+type splitSettings struct {
+	totalParts      int
+	threshold       int
+	destDir         string
+	carrierImages   []string
+	carrierAudio    []string
+	carrierText     []string
+	isHeaderless    bool
+	custodians      []string
+	instructions    string
+	armored         bool
+	paperKit        bool
+	stegoPassphrase string
+	lsbMatching     bool
+	containerFile   string
+	carrierDir      string
+	autoScale       bool
+	stegoBits       int
+	stegoAlpha      bool
+	stegoRobust     bool
+	stegoGenerate   bool
+	stegoSeed       uint64
+	textEncoding    string
+	noOverwrite     bool // refuse to replace horcruxes already in destDir
+}
+
+// splitFlags returns the settings the split command's flags give.
+func splitFlags() splitSettings {
+	return splitSettings{
+		totalParts:      totalParts,
+		threshold:       threshold,
+		destDir:         destDir,
+		carrierImages:   carrierImages,
+		carrierAudio:    carrierAudio,
+		carrierText:     carrierText,
+		isHeaderless:    isHeaderless,
+		custodians:      custodians,
+		instructions:    instructions,
+		armored:         armored,
+		paperKit:        paperKit,
+		stegoPassphrase: stegoPassphrase,
+		lsbMatching:     lsbMatching,
+		containerFile:   containerFile,
+		carrierDir:      carrierDir,
+		autoScale:       autoScale,
+		stegoBits:       stegoBits,
+		stegoAlpha:      stegoAlpha,
+		stegoRobust:     stegoRobust,
+		stegoGenerate:   stegoGenerate,
+		stegoSeed:       stegoSeed,
+		textEncoding:    textEncoding,
+	}
+}
+
+// splitFile splits the file at filePath with runSplit. The horcruxes go
+// next to it unless s names a destination.
+func splitFile(ctx context.Context, filePath string, s splitSettings, out io.Writer, progress pipeline.Reporter) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	if s.destDir == "" {
+		s.destDir = filepath.Dir(filePath)
+	}
+	return runSplit(ctx, file, filepath.Base(filePath), s, out, progress)
+}
+
+// runSplit splits what r holds into horcruxes for the file originalFilename
+// as s says, printing what it does to out and reporting its progress, by
+// stage and by horcrux written. Once ctx is done it stops, and removes the
+// horcruxes written.
+func runSplit(ctx context.Context, r io.Reader, originalFilename string, s splitSettings, out io.Writer, progress pipeline.Reporter) error {
+
+	// 1. Validation
+	if s.totalParts < 2 {
+		return fmt.Errorf("number of parts (-n) must be at least 2")
+	}
+	if s.threshold < 2 {
+		return fmt.Errorf("threshold (-t) must be at least 2")
+	}
+	if s.threshold > s.totalParts {
+		return fmt.Errorf("threshold cannot be greater than total parts")
+	}
+	if len(s.custodians) > 0 && len(s.custodians) != s.totalParts {
+		return fmt.Errorf("got %d custodians for %d parts", len(s.custodians), s.totalParts)
+	}
+
+	// Images, recordings and texts are hidden in alike
+	var carrierPaths []string
+	for _, paths := range [][]string{s.carrierImages, s.carrierAudio, s.carrierText} {
+		if len(paths) > 0 && len(carrierPaths) > 0 {
+			return fmt.Errorf("--carrier-image, --carrier-audio and --carrier-text cannot be combined")
 		}
-		if threshold < 2 {
-			return fmt.Errorf("threshold (-t) must be at least 2")
+		if len(paths) > 0 {
+			carrierPaths = paths
 		}
-		if threshold > totalParts {
-			return fmt.Errorf("threshold cannot be greater than total parts")
+	}
+	var textMethod stego.TextMethod
+	switch s.textEncoding {
+	case "zero-width":
+		textMethod = stego.ZeroWidth
+	case "whitespace":
+		textMethod = stego.TrailingWhitespace
+	default:
+		return fmt.Errorf("unknown --text-encoding %q (want zero-width or whitespace)", s.textEncoding)
+	}
+	if s.textEncoding != "zero-width" && len(s.carrierText) == 0 {
+		return fmt.Errorf("--text-encoding requires --carrier-text")
+	}
+	if (s.stegoPassphrase != "" || s.lsbMatching) && len(s.carrierText) > 0 {
+		return fmt.Errorf("--stego-passphrase and --lsb-matching do not apply to --carrier-text")
+	}
+
+	if len(carrierPaths) > 1 && len(carrierPaths) != s.totalParts {
+		return fmt.Errorf("got %d carriers for %d parts; give one, or one per part", len(carrierPaths), s.totalParts)
+	}
+	if s.autoScale && len(s.carrierImages) == 0 {
+		return fmt.Errorf("--auto-scale requires --carrier-image")
+	}
+	if s.armored && len(carrierPaths) > 0 {
+		return fmt.Errorf("--armor cannot be combined with --carrier-image, --carrier-audio or --carrier-text (text carriers hold armored horcruxes anyway)")
+	}
+	if len(carrierPaths) > 0 && s.carrierDir != "" {
+		return fmt.Errorf("--carrier-dir cannot be combined with --carrier-image, --carrier-audio or --carrier-text")
+	}
+	if s.armored && s.carrierDir != "" {
+		return fmt.Errorf("--armor cannot be combined with --carrier-dir")
+	}
+	if s.stegoBits < 1 || s.stegoBits > stego.MaxBitsPerChannel {
+		return fmt.Errorf("--stego-bits must be 1 to %d", stego.MaxBitsPerChannel)
+	}
+	if s.stegoGenerate && (len(carrierPaths) > 0 || s.carrierDir != "" || s.containerFile != "" || s.armored || s.paperKit) {
+		return fmt.Errorf("--stego-generate cannot be combined with --carrier-image, --carrier-audio, --carrier-text, --carrier-dir, --container, --armor or --paper")
+	}
+	if s.stegoSeed != 0 && !s.stegoGenerate {
+		return fmt.Errorf("--stego-seed requires --stego-generate")
+	}
+	if (s.stegoPassphrase != "" || s.lsbMatching) && len(carrierPaths) == 0 && s.carrierDir == "" && !s.stegoGenerate {
+		return fmt.Errorf("--stego-passphrase and --lsb-matching require --carrier-image, --carrier-audio, --carrier-dir or --stego-generate")
+	}
+	if (s.stegoBits != 1 || s.stegoAlpha) && len(s.carrierImages) == 0 && s.carrierDir == "" && !s.stegoGenerate {
+		return fmt.Errorf("--stego-bits and --stego-alpha require --carrier-image, --carrier-dir or --stego-generate")
+	}
+	if s.stegoRobust && len(s.carrierImages) == 0 && s.carrierDir == "" && !s.stegoGenerate {
+		return fmt.Errorf("--stego-robust requires --carrier-image, --carrier-dir or --stego-generate")
+	}
+	if s.stegoRobust && (s.stegoBits != 1 || s.stegoAlpha) {
+		return fmt.Errorf("--stego-robust cannot be combined with --stego-bits or --stego-alpha")
+	}
+	if s.paperKit && (s.armored || len(carrierPaths) > 0 || s.carrierDir != "" || s.isHeaderless) {
+		return fmt.Errorf("--paper cannot be combined with --armor, --carrier-image, --carrier-audio, --carrier-text, --carrier-dir or --headerless")
+	}
+	if s.containerFile != "" && (len(carrierPaths) > 0 || s.carrierDir != "" || s.armored || s.paperKit || s.stegoPassphrase != "" || s.lsbMatching) {
+		return fmt.Errorf("--container cannot be combined with --carrier-image, --carrier-audio, --carrier-text, --carrier-dir, --armor, --paper or the stego options")
+	}
+
+	var instructionTmpl *template.Template
+	if s.instructions != "" {
+		var err error
+		instructionTmpl, err = template.New("instructions").Parse(s.instructions)
+		if err != nil {
+			return fmt.Errorf("invalid instructions template: %w", err)
 		}
-
-		// 2. Prepare Output Directory
-		if destDir == "" {
-			destDir = filepath.Dir(filePath)
+	}
+
+	// 2. Prepare Output Directory
+	if err := os.MkdirAll(s.destDir, 0755); err != nil {
+		return fmt.Errorf("failed to create destination directory: %w", err)
+	}
+
+	// 3. Prepare Carriers, Album or Container (if requested)
+	var carriers []*carrierFile
+	for _, path := range carrierPaths {
+		// The file's contents, not its name, tell its format
+		c := newCarrierFile(path, out)
+		if err := c.load(); err != nil {
+			return err
 		}
-		if err := os.MkdirAll(destDir, 0755); err != nil {
-			return fmt.Errorf("failed to create destination directory: %w", err)
+		switch {
+		case len(s.carrierImages) > 0 && !c.isImage():
+			return fmt.Errorf("%s is not a PNG or JPEG image", filepath.Base(path))
+		case len(s.carrierAudio) > 0 && c.carrier != stego.WAVCarrier:
+			return fmt.Errorf("%s is not a WAV file", filepath.Base(path))
+		case len(s.carrierText) > 0 && !c.isText():
+			return fmt.Errorf("%s is not UTF-8 text", filepath.Base(path))
+		}
+		if c.isText() {
+			c.carrier = stego.NewTextCarrier(textMethod)
+		}
+		c.enlarge = s.autoScale
+		if len(carrierPaths) > 1 {
+			c.unload()
+		}
+		carriers = append(carriers, c)
+	}
+	if len(carriers) == 1 {
+		fmt.Fprintln(out, "Warning: every horcrux will be a copy of the same carrier, and comparing any two shows where the data is. Give one carrier per part.")
+	}
+	carrierFor := func(i int) *carrierFile {
+		if len(carriers) == 1 {
+			return carriers[0]
+		}
+		return carriers[i]
+	}
+	var album []*carrierFile
+	albumNext := 0
+	if s.carrierDir != "" {
+		var err error
+		if album, err = readAlbum(s.carrierDir, out); err != nil {
+			return err
+		}
+	}
+	stegoOpts := stego.Options{Passphrase: s.stegoPassphrase, BitsPerChannel: s.stegoBits, Alpha: s.stegoAlpha, Robust: s.stegoRobust}
+	if s.lsbMatching {
+		stegoOpts.Method = stego.LSBMatching
+	}
+	generatorSeed := s.stegoSeed
+	if s.stegoGenerate && generatorSeed == 0 {
+		// Secret, or the clean images could be drawn again to compare
+		var seed [8]byte
+		if _, err := rand.Read(seed[:]); err != nil {
+			return fmt.Errorf("failed to seed carrier generation: %w", err)
+		}
+		generatorSeed = binary.LittleEndian.Uint64(seed[:])
+	}
+	var containerRaw []byte
+	if s.containerFile != "" {
+		var err error
+		containerRaw, err = os.ReadFile(s.containerFile)
+		if err != nil {
+			return fmt.Errorf("failed to open container file: %w", err)
+		}
+		if stego.ContainerFormat(containerRaw) == "" {
+			return fmt.Errorf("%s: %w", filepath.Base(s.containerFile), stego.ErrUnsupportedContainer)
+		}
+	}
+
+	// 4. Generate Encryption Key (Ephemeral)
+	// AES-GCM uses 32-byte keys for AES-256
+	keySecret, err := secrets.NewSecret(32)
+	if err != nil {
+		return fmt.Errorf("failed to generate secure key: %w", err)
+	}
+	defer keySecret.Destroy() // Ensure memory is cleared on exit
+
+	fmt.Fprintln(out, "Generating key and splitting...")
+
+	// 5. Split the Key (Shamir's Secret Sharing)
+	// This returns parts with the X-coordinate embedded in the last byte.
+	keyFragments, err := shamir.Split(keySecret.Bytes(), s.totalParts, s.threshold)
+	if err != nil {
+		return fmt.Errorf("failed to split key: %w", err)
+	}
+
+	// 6. Process the File (Read -> Compress -> Encrypt -> Shard)
+	config := pipeline.PipelineConfig{
+		Total:     s.totalParts,
+		Threshold: s.threshold,
+		Progress:  progress,
+	}
+	switch r := r.(type) {
+	case *os.File:
+		if info, err := r.Stat(); err == nil {
+			config.Size = info.Size()
+		}
+	case *bytes.Reader:
+		config.Size = r.Size()
+	}
+
+	// sharding.Shard is assumed to contain the Data
+	fileShards, err := pipeline.SplitPipelineContext(ctx, r, keySecret.Bytes(), config)
+	if ctx.Err() != nil {
+		return fmt.Errorf("split cancelled: %w", ctx.Err())
+	}
+	if err != nil {
+		return fmt.Errorf("pipeline failed: %w", err)
+	}
+
+	if len(fileShards) != len(keyFragments) {
+		return fmt.Errorf("mismatch between data shards (%d) and key fragments (%d)", len(fileShards), len(keyFragments))
+	}
+
+	// 7. Build Horcruxes
+	timestamp := time.Now().Unix()
+
+	// Helper to strip extension for naming
+	ext := filepath.Ext(originalFilename)
+	nameNoExt := strings.TrimSuffix(originalFilename, ext)
+	standardExt := ".horcrux"
+	if s.isHeaderless {
+		standardExt = ".bin"
+	}
+	if s.armored {
+		standardExt += ".asc"
+	}
+
+	headers := make([]*format.Header, s.totalParts)
+	contents := make([][]byte, s.totalParts)
+	for i := 0; i < s.totalParts; i++ {
+		index := i + 1 // 1-based index for user friendliness and Shamir X-coord
+
+		// Construct the Header
+		header := &format.Header{
+			OriginalFilename: originalFilename,
+			Timestamp:        timestamp,
+			Index:            index,
+			Total:            s.totalParts,
+			Threshold:        s.threshold,
+			KeyFragment:      keyFragments[i],
+			Custodians:       s.custodians,
+		}
+		if len(s.custodians) > 0 {
+			header.Custodian = s.custodians[i]
 		}
 
-		// 3. Prepare Carrier Image (if requested)
-		var carrier image.Image
-		if carrierImage != "" {
-			imgFile, err := os.Open(carrierImage)
-			if err != nil {
-				return fmt.Errorf("failed to open carrier image: %w", err)
-			}
-			defer imgFile.Close()
-
-			carrier, _, err = image.Decode(imgFile)
+		if instructionTmpl != nil {
+			var sb strings.Builder
+			err := instructionTmpl.Execute(&sb, instructionData{
+				Filename:   originalFilename,
+				Index:      index,
+				Total:      s.totalParts,
+				Threshold:  s.threshold,
+				Custodian:  header.Custodian,
+				Custodians: s.custodians,
+			})
 			if err != nil {
-				return fmt.Errorf("failed to decode carrier image: %w", err)
+				return fmt.Errorf("failed to render instructions for horcrux %d: %w", index, err)
 			}
+			header.Instructions = strings.TrimSpace(sb.String())
 		}
-
-		// 4. Generate Encryption Key (Ephemeral)
-		// AES-GCM uses 32-byte keys for AES-256
-		keySecret, err := secrets.NewSecret(32)
-		if err != nil {
-			return fmt.Errorf("failed to generate secure key: %w", err)
+		headers[i] = header
+		if s.paperKit {
+			continue
 		}
-		defer keySecret.Destroy() // Ensure memory is cleared on exit
 
-		fmt.Println("Generating key and splitting...")
+		// Serialize content to memory buffer first
+		var contentBuf bytes.Buffer
+		writer := format.NewWriter(&contentBuf)
 
-		// 5. Split the Key (Shamir's Secret Sharing)
-		// This returns parts with the X-coordinate embedded in the last byte.
-		keyFragments, err := shamir.Split(keySecret.Bytes(), totalParts, threshold)
-		if err != nil {
-			return fmt.Errorf("failed to split key: %w", err)
+		// Write Header + Body to the buffer
+		if err := writer.Write(header, fileShards[i].Data, s.isHeaderless); err != nil {
+			return fmt.Errorf("failed to serialize horcrux %d: %w", index, err)
 		}
-
-		// 6. Process the File (Read -> Compress -> Encrypt -> Shard)
-		file, err := os.Open(filePath)
-		if err != nil {
-			return fmt.Errorf("failed to open file: %w", err)
+		contents[i] = contentBuf.Bytes()
+	}
+
+	// 8. Check every carrier image holds its shard before writing anything
+	if len(carriers) > 0 {
+		var problems []string
+		for i, content := range contents {
+			c := carrierFor(i)
+			if err := c.check(len(content), stegoOpts); err != nil {
+				problems = append(problems, fmt.Sprintf("part %d: %v", i+1, err))
+			}
+			if len(carriers) > 1 {
+				c.unload()
+			}
 		}
-		defer file.Close()
-
-		config := pipeline.PipelineConfig{
-			Total:     totalParts,
-			Threshold: threshold,
+		if len(problems) > 0 {
+			return fmt.Errorf("carriers too small:\n  %s", strings.Join(problems, "\n  "))
+		}
+	}
+	if s.stegoGenerate {
+		for i, content := range contents {
+			if _, _, err := stego.GeneratedSize(len(content), stegoOpts); err != nil {
+				return fmt.Errorf("part %d: %w", i+1, err)
+			}
+		}
+	}
+	// An upgrade does not replace horcruxes already written, unless asked
+	standard := !s.paperKit && s.containerFile == "" && len(carriers) == 0 && !s.stegoGenerate && s.carrierDir == ""
+	if standard && s.noOverwrite {
+		for i := range headers {
+			outName := fmt.Sprintf("%s_%d_of_%d%s", nameNoExt, i+1, s.totalParts, standardExt)
+			if _, err := os.Stat(filepath.Join(s.destDir, outName)); err == nil {
+				return fmt.Errorf("%s already exists in %s. Use --overwrite to replace it", outName, s.destDir)
+			}
+		}
+	}
+
+	// 9. Write Horcruxes
+	// Nothing is left behind if the split is cancelled
+	var created []string
+	finished := false
+	defer func() {
+		if !finished && ctx.Err() != nil {
+			for _, path := range created {
+				os.Remove(path)
+			}
+		}
+	}()
+	for i, header := range headers {
+		index := i + 1
+		progress.Report(pipeline.Event{Stage: pipeline.StageWrite, Done: int64(i), Total: int64(s.totalParts)})
+		if ctx.Err() != nil {
+			return fmt.Errorf("split cancelled: %w", ctx.Err())
 		}
 
-		// sharding.Shard is assumed to contain the Data
-		fileShards, err := pipeline.SplitPipeline(file, keySecret.Bytes(), config)
-		if err != nil {
-			return fmt.Errorf("pipeline failed: %w", err)
+		if s.paperKit {
+			// --- PAPER BACKUP MODE ---
+			var page bytes.Buffer
+			if err := paper.Render(&page, header, fileShards[i].Data); err != nil {
+				return fmt.Errorf("failed to render paper backup %d: %w", index, err)
+			}
+
+			outName := fmt.Sprintf("%s_%d_of_%d.html", nameNoExt, index, s.totalParts)
+			if err := os.WriteFile(filepath.Join(s.destDir, outName), page.Bytes(), 0644); err != nil {
+				return fmt.Errorf("failed to write file %s: %w", outName, err)
+			}
+			created = append(created, filepath.Join(s.destDir, outName))
+			fmt.Fprintf(out, "Created %s (print it, then delete the file)\n", outName)
+			continue
 		}
 
-		if len(fileShards) != len(keyFragments) {
-			return fmt.Errorf("mismatch between data shards (%d) and key fragments (%d)", len(fileShards), len(keyFragments))
-        }
+		contentBytes := contents[i]
+
+		// Determine Output Strategy (Container vs Stego vs Standard)
+		if s.containerFile != "" {
+			// --- CONTAINER MODE ---
+			encoded, err := stego.EmbedContainer(containerRaw, contentBytes)
+			if err != nil {
+				return fmt.Errorf("failed to embed shard %d: %w", index, err)
+			}
 
-		// 7. Write Horcruxes
-		originalFilename := filepath.Base(filePath)
-		timestamp := time.Now().Unix()
-		
-		// Helper to strip extension for naming
-		ext := filepath.Ext(originalFilename)
-		nameNoExt := strings.TrimSuffix(originalFilename, ext)
+			outName := fmt.Sprintf("%s_%d_of_%d%s", nameNoExt, index, s.totalParts, filepath.Ext(s.containerFile))
+			outPath := filepath.Join(s.destDir, outName)
+			if err := os.WriteFile(outPath, encoded, 0644); err != nil {
+				return fmt.Errorf("failed to write file %s: %w", outPath, err)
+			}
+			created = append(created, outPath)
+			fmt.Fprintf(out, "Created %s\n", outName)
 
-		for i := 0; i < totalParts; i++ {
-			index := i + 1 // 1-based index for user friendliness and Shamir X-coord
+		} else if len(carriers) > 0 {
+			// --- STEGANOGRAPHY MODE ---
+			fmt.Fprintf(out, "[%d/%d] Embedding into %s...\n", index, s.totalParts, filepath.Base(carrierFor(i).path))
 
-			// Construct the Header
-			header := &format.Header{
-				OriginalFilename: originalFilename,
-				Timestamp:        timestamp,
-				Index:            index,
-				Total:            totalParts,
-				Threshold:        threshold,
-				KeyFragment:      keyFragments[i],
+			carrier := carrierFor(i)
+			encoded, outExt, err := carrier.embed(contentBytes, stegoOpts)
+			if len(carriers) > 1 {
+				carrier.unload()
+			}
+			if err != nil {
+				return fmt.Errorf("failed to embed shard %d: %w", index, err)
 			}
 
-			// Serialize content to memory buffer first
-			var contentBuf bytes.Buffer
-			writer := format.NewWriter(&contentBuf)
+			outName := fmt.Sprintf("%s_%d_of_%d%s", nameNoExt, index, s.totalParts, outExt)
+			outPath := filepath.Join(s.destDir, outName)
+			if err := os.WriteFile(outPath, encoded, 0644); err != nil {
+				return fmt.Errorf("failed to write file %s: %w", outPath, err)
+			}
+			created = append(created, outPath)
+			fmt.Fprintf(out, "Created %s\n", outName)
 
-			// Write Header + Body to the buffer
-			if err := writer.Write(header, fileShards[i].Data, isHeaderless); err != nil {
-				return fmt.Errorf("failed to serialize horcrux %d: %w", index, err)
+		} else if s.stegoGenerate {
+			// --- GENERATED CARRIER MODE ---
+			img, err := stego.GenerateFor(len(contentBytes), stegoOpts, generatorSeed+uint64(i))
+			if err != nil {
+				return fmt.Errorf("failed to generate a carrier for shard %d: %w", index, err)
 			}
-			contentBytes := contentBuf.Bytes()
+			fmt.Fprintf(out, "[%d/%d] Embedding into a generated %dx%d image...\n", index, s.totalParts, img.Rect.Dx(), img.Rect.Dy())
 
-			// Determine Output Strategy (Stego vs Standard)
-			if carrierImage != "" {
-				// --- STEGANOGRAPHY MODE ---
-				fmt.Printf("[%d/%d] Embedding into image...\n", index, totalParts)
+			stegoImg, err := stego.EmbedWithOptions(img, contentBytes, stegoOpts)
+			if err != nil {
+				return fmt.Errorf("failed to embed shard %d: %w", index, err)
+			}
+			var encoded bytes.Buffer
+			if err := stego.EncodePNG(&encoded, stegoImg, nil); err != nil {
+				return fmt.Errorf("failed to encode png: %w", err)
+			}
 
-				stegoImg, err := stego.Embed(carrier, contentBytes)
-				if err != nil {
-					return fmt.Errorf("failed to embed shard %d: %w", index, err)
-				}
+			outName := fmt.Sprintf("%s_%d_of_%d.png", nameNoExt, index, s.totalParts)
+			outPath := filepath.Join(s.destDir, outName)
+			if err := os.WriteFile(outPath, encoded.Bytes(), 0644); err != nil {
+				return fmt.Errorf("failed to write file %s: %w", outPath, err)
+			}
+			created = append(created, outPath)
+			fmt.Fprintf(out, "Created %s\n", outName)
 
-				outName := fmt.Sprintf("%s_%d_of_%d.png", nameNoExt, index, totalParts)
-				outPath := filepath.Join(destDir, outName)
+		} else if s.carrierDir != "" {
+			// --- ALBUM MODE ---
+			fmt.Fprintf(out, "[%d/%d] Embedding into album images...\n", index, s.totalParts)
 
-				outFile, err := os.Create(outPath)
-				if err != nil {
-					return fmt.Errorf("failed to create output file %s: %w", outPath, err)
-				}
-				
-				// Must encode as PNG to be lossless
-				if err := png.Encode(outFile, stegoImg); err != nil {
-					outFile.Close()
-					return fmt.Errorf("failed to encode png %s: %w", outPath, err)
-				}
-				outFile.Close()
-				fmt.Printf("Created %s\n", outName)
-
-			} else {
-				// --- STANDARD MODE ---
-				fileExt := ".horcrux"
-				if isHeaderless {
-					fileExt = ".bin"
+			baseName := fmt.Sprintf("%s_%d_of_%d", nameNoExt, index, s.totalParts)
+			names, err := spreadOverAlbum(album, &albumNext, contentBytes, stegoOpts, s.destDir, baseName)
+			if err != nil {
+				return fmt.Errorf("failed to embed shard %d: %w", index, err)
+			}
+			for _, name := range names {
+				created = append(created, filepath.Join(s.destDir, name))
+			}
+			fmt.Fprintf(out, "Created %s\n", strings.Join(names, ", "))
+
+		} else {
+			// --- STANDARD MODE ---
+			if s.armored {
+				var armorBuf bytes.Buffer
+				if err := armor.Encode(&armorBuf, contentBytes); err != nil {
+					return fmt.Errorf("failed to armor horcrux %d: %w", index, err)
 				}
+				contentBytes = armorBuf.Bytes()
+			}
 
-				outName := fmt.Sprintf("%s_%d_of_%d%s", nameNoExt, index, totalParts, fileExt)
-				outPath := filepath.Join(destDir, outName)
+			outName := fmt.Sprintf("%s_%d_of_%d%s", nameNoExt, index, s.totalParts, standardExt)
+			outPath := filepath.Join(s.destDir, outName)
 
-				if err := os.WriteFile(outPath, contentBytes, 0644); err != nil {
-					return fmt.Errorf("failed to write file %s: %w", outPath, err)
-				}
-				fmt.Printf("Created %s\n", outName)
+			if err := os.WriteFile(outPath, contentBytes, 0644); err != nil {
+				return fmt.Errorf("failed to write file %s: %w", outPath, err)
 			}
+			created = append(created, outPath)
+			fmt.Fprintf(out, "Created %s\n", outName)
 		}
+	}
+	progress.Report(pipeline.Event{Stage: pipeline.StageWrite, Done: int64(s.totalParts), Total: int64(s.totalParts)})
+	finished = true
 
-		fmt.Println("Done! Keep your horcruxes safe.")
-		return nil
-	},
+	fmt.Fprintln(out, "Done! Keep your horcruxes safe.")
+	return nil
 }
 
 func init() {
@@ -201,9 +680,26 @@ func init() {
 	splitCmd.Flags().IntVarP(&totalParts, "shards", "n", 0, "Total number of horcruxes to make")
 	splitCmd.Flags().IntVarP(&threshold, "threshold", "t", 0, "Number of horcruxes required to resurrect")
 	splitCmd.Flags().StringVarP(&destDir, "destination", "d", "", "Directory to output horcruxes (default: current directory)")
-	splitCmd.Flags().StringVarP(&carrierImage, "carrier-image", "i", "", "Path to an image (jpg/png) to hide the horcruxes inside")
+	splitCmd.Flags().StringSliceVarP(&carrierImages, "carrier-image", "i", nil, "Image (jpg/png) to hide the horcruxes inside, or one per horcrux (repeat or comma-separate)")
+	splitCmd.Flags().StringSliceVar(&carrierAudio, "carrier-audio", nil, "16-bit PCM WAV recording to hide the horcruxes inside, or one per horcrux")
+	splitCmd.Flags().StringSliceVar(&carrierText, "carrier-text", nil, "Text file (e.g. a letter) to hide the armored horcruxes inside, or one per horcrux")
+	splitCmd.Flags().StringVar(&textEncoding, "text-encoding", "zero-width", "How --carrier-text hides data: zero-width (invisible characters) or whitespace (at line ends)")
+	splitCmd.Flags().BoolVar(&autoScale, "auto-scale", false, "Enlarge carrier images too small for their horcrux (written as PNG)")
+	splitCmd.Flags().StringVar(&stegoPassphrase, "stego-passphrase", "", "Scatter and whiten the hidden data with a passphrase (needed again to bind)")
+	splitCmd.Flags().StringVar(&carrierDir, "carrier-dir", "", "Directory of images (jpg/png) to spread the horcruxes over, as many per horcrux as needed")
+	splitCmd.Flags().StringVar(&containerFile, "container", "", "Path to a PNG, JPEG, PDF or ZIP file to append the horcruxes to (no size limit, not hidden)")
+	splitCmd.Flags().IntVar(&stegoBits, "stego-bits", 1, "Bits hidden in each colour channel, 1 to 4 (more holds more, and shows more)")
+	splitCmd.Flags().BoolVar(&stegoAlpha, "stego-alpha", false, "Hide data in the alpha channel too")
+	splitCmd.Flags().BoolVar(&stegoGenerate, "stego-generate", false, "Hide each horcrux in a generated photo-like PNG sized to fit, instead of a carrier")
+	splitCmd.Flags().Uint64Var(&stegoSeed, "stego-seed", 0, "Seed for --stego-generate, for reproducible images (default random; keep it secret)")
+	splitCmd.Flags().BoolVar(&stegoRobust, "stego-robust", false, "Repeat the hidden data across each image so it survives cropping and edits (holds far less)")
+	splitCmd.Flags().BoolVar(&lsbMatching, "lsb-matching", false, "Hide data with ±1 LSB matching, which steganalysis detects far less")
 	splitCmd.Flags().BoolVar(&isHeaderless, "headerless", false, "Paranoiac mode: do not write metadata headers")
+	splitCmd.Flags().StringSliceVar(&custodians, "custodians", nil, "Comma-separated holder of each horcrux, in index order")
+	splitCmd.Flags().StringVar(&instructions, "instructions", "", "Recovery instructions template shown in every horcrux")
+	splitCmd.Flags().BoolVar(&armored, "armor", false, "Write ASCII-armored text horcruxes (.horcrux.asc)")
+	splitCmd.Flags().BoolVar(&paperKit, "paper", false, "Write printable HTML pages with QR codes and key words")
 
 	splitCmd.MarkFlagRequired("shards")
 	splitCmd.MarkFlagRequired("threshold")
-}
\ No newline at end of file
+}
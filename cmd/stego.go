@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/jpeg" // Register JPEG decoder
+	_ "image/png"  // Register PNG decoder
+	"os"
+	"strings"
+
+	"github.com/Beastly713/horcrux/pkg/stego"
+	"github.com/spf13/cobra"
+)
+
+// stegoCmd groups the steganography tools.
+var stegoCmd = &cobra.Command{
+	Use:   "stego",
+	Short: "Steganography tools",
+}
+
+var stegoAnalyzeCmd = &cobra.Command{
+	Use:   "analyze [image...]",
+	Short: "Check images for signs of hidden data",
+	Long: `Analyze runs two classic steganalysis attacks on each image, to measure how
+detectable carriers are before you distribute them:
+
+  chi-square    probability that the LSBs were replaced by random data, for the
+                whole image and its first 10%, 20%, ... (sequential embedding)
+  sample pairs  estimated fraction of pixels carrying payload bits
+
+Both target LSB replacement. Images split with --lsb-matching should look
+like clean images to them. Run it on the original carrier too: a clean image
+that already scores high gives little cover.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out := cmd.OutOrStdout()
+		flagged := 0
+		for _, path := range args {
+			file, err := os.Open(path)
+			if err != nil {
+				return fmt.Errorf("failed to open image: %w", err)
+			}
+			img, _, err := image.Decode(file)
+			file.Close()
+			if err != nil {
+				return fmt.Errorf("failed to decode image %s: %w", path, err)
+			}
+
+			report := stego.Analyze(img)
+
+			profile := make([]string, len(report.ChiSquareProfile))
+			for i, p := range report.ChiSquareProfile {
+				profile[i] = fmt.Sprintf("%.2f", p)
+			}
+			verdict := "no sign of LSB embedding"
+			if report.Suspicious() {
+				verdict = "LIKELY CONTAINS HIDDEN DATA"
+				flagged++
+			}
+
+			fmt.Fprintf(out, "%s\n", path)
+			fmt.Fprintf(out, "  chi-square:   p = %.3f (by 10%% steps: %s)\n", report.ChiSquare, strings.Join(profile, " "))
+			fmt.Fprintf(out, "  sample pairs: %.1f%% of pixels estimated to carry data\n", report.SamplePairs*100)
+			fmt.Fprintf(out, "  verdict:      %s\n", verdict)
+		}
+
+		if len(args) > 1 {
+			fmt.Fprintf(out, "\n%d of %d images flagged\n", flagged, len(args))
+		}
+		return nil
+	},
+}
+
+var stegoCapacityCmd = &cobra.Command{
+	Use:   "capacity [image...]",
+	Short: "Show how much data images can hide",
+	Long: `Capacity reports how many bytes each image can hide at every setting split
+offers: 1 to 4 bits per channel (--stego-bits), in R, G and B or in the alpha
+channel too (--stego-alpha), and in robust mode (--stego-robust). Baseline
+JPEGs also show what their DCT coefficients hold when the copies stay JPEGs.
+
+A horcrux is a little larger than its share of the file: allow a few hundred
+bytes for the header. --stego-passphrase costs 16 bytes more.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out := cmd.OutOrStdout()
+		for _, path := range args {
+			raw, err := os.ReadFile(path)
+			if err != nil {
+				return fmt.Errorf("failed to open image: %w", err)
+			}
+			cfg, _, err := image.DecodeConfig(bytes.NewReader(raw))
+			if err != nil {
+				return fmt.Errorf("failed to decode image %s: %w", path, err)
+			}
+			bounds := image.Rect(0, 0, cfg.Width, cfg.Height)
+
+			fmt.Fprintf(out, "%s (%dx%d)\n", path, cfg.Width, cfg.Height)
+			fmt.Fprintf(out, "  %-6s %12s %12s\n", "bits", "RGB", "RGBA")
+			for bits := 1; bits <= stego.MaxBitsPerChannel; bits++ {
+				opts := stego.Options{Passphrase: stegoPassphrase, BitsPerChannel: bits}
+				rgb := stego.Capacity(bounds, opts)
+				opts.Alpha = true
+				fmt.Fprintf(out, "  %-6d %12d %12d\n", bits, rgb, stego.Capacity(bounds, opts))
+			}
+			fmt.Fprintf(out, "  robust: %d\n", stego.Capacity(bounds, stego.Options{Passphrase: stegoPassphrase, Robust: true}))
+			if stego.IsJPEG(raw) {
+				n, err := stego.CapacityJPEG(raw, stego.Options{Passphrase: stegoPassphrase})
+				if err != nil {
+					fmt.Fprintf(out, "  as a JPEG: %v\n", err)
+				} else {
+					fmt.Fprintf(out, "  as a JPEG: %d\n", n)
+				}
+			}
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(stegoCmd)
+	stegoCmd.AddCommand(stegoAnalyzeCmd)
+	stegoCmd.AddCommand(stegoCapacityCmd)
+
+	stegoCapacityCmd.Flags().StringVar(&stegoPassphrase, "stego-passphrase", "", "Count the room a passphrase takes")
+}
@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+
+	"github.com/Beastly713/horcrux/pkg/pipeline"
+	"github.com/spf13/cobra"
+)
+
+var upgradeDir string
+
+// upgradeCmd re-splits legacy horcruxes in the current format.
+var upgradeCmd = &cobra.Command{
+	Use:   "upgrade [directory]",
+	Short: "Re-split horcruxes made by the original horcrux tool",
+	Long: `Upgrade finds horcruxes made by the original jesseduffield/horcrux tool in the
+directory (or the current directory), resurrects each file in memory and
+splits it again, with the same threshold and total, in this project's
+authenticated format.
+
+The new horcruxes are written to --destination (default: an "upgraded"
+folder inside the directory). The old ones are left untouched; hand out the
+new set, then destroy the old one.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sourceDir := "."
+		if len(args) > 0 {
+			sourceDir = args[0]
+		}
+		dest := upgradeDir
+		if dest == "" {
+			dest = filepath.Join(sourceDir, "upgraded")
+		}
+
+		out := cmd.OutOrStdout()
+
+		// 1. Gather files
+		groups, err := gatherHorcruxes(cmd.Context(), cmd, sourceDir, out, pipeline.Discard)
+		if err != nil {
+			return err
+		}
+
+		upgraded := 0
+		for _, group := range groups {
+			defer closeHorcruxes(group)
+			refHeader := group[0].Header
+
+			if !refHeader.Legacy {
+				fmt.Fprintf(out, "Skipping %s: already in the current format\n", refHeader.OriginalFilename)
+				continue
+			}
+			if len(group) < refHeader.Threshold {
+				fmt.Fprintf(out, "Skipping %s: need %d horcruxes, found %d\n", refHeader.OriginalFilename, refHeader.Threshold, len(group))
+				continue
+			}
+
+			// 2. Resurrect in memory
+			key, err := combineKey(group)
+			if err != nil {
+				return fmt.Errorf("failed to reconstruct key for %s: %w", refHeader.OriginalFilename, err)
+			}
+			shardMap := make(map[int][]byte)
+			for _, h := range group {
+				data, err := io.ReadAll(h.Body)
+				if err != nil {
+					return fmt.Errorf("failed to read body of %s: %w", h.Path, err)
+				}
+				shardMap[h.Header.Index-1] = data
+			}
+			plainText, err := pipeline.JoinLegacy(shardMap, key, refHeader.Total, refHeader.Threshold)
+			if err != nil {
+				return fmt.Errorf("failed to resurrect %s: %w", refHeader.OriginalFilename, err)
+			}
+
+			// 3. Split again
+			fmt.Fprintf(out, "Upgrading %s\n", refHeader.OriginalFilename)
+			settings := splitSettings{
+				totalParts:   refHeader.Total,
+				threshold:    refHeader.Threshold,
+				destDir:      dest,
+				stegoBits:    1,
+				textEncoding: "zero-width",
+				noOverwrite:  !overwrite,
+			}
+			if err := runSplit(cmd.Context(), bytes.NewReader(plainText), refHeader.OriginalFilename, settings, out, pipeline.Discard); err != nil {
+				return fmt.Errorf("failed to upgrade %s: %w", refHeader.OriginalFilename, err)
+			}
+			upgraded++
+		}
+
+		if upgraded == 0 {
+			return fmt.Errorf("no legacy horcruxes could be upgraded in %s", sourceDir)
+		}
+		fmt.Fprintf(out, "The new horcruxes are in %s. Hand them out, then destroy the old ones.\n", dest)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(upgradeCmd)
+
+	upgradeCmd.Flags().StringVarP(&upgradeDir, "destination", "d", "", "Directory for the new horcruxes (default: <directory>/upgraded)")
+	upgradeCmd.Flags().BoolVar(&overwrite, "overwrite", false, "Overwrite existing horcruxes in the destination")
+}
@@ -0,0 +1,308 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/Beastly713/horcrux/pkg/pipeline"
+	"github.com/charmbracelet/bubbles/progress"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	errorStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("196")) // Red
+	dimStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("241"))
+)
+
+// wizardStep is a question the split wizard asks, in order.
+type wizardStep int
+
+const (
+	stepShards wizardStep = iota
+	stepThreshold
+	stepCarriers
+	stepHeaderless
+	stepDestination
+	stepConfirm
+	stepRunning
+	stepDone
+)
+
+// splitWizard asks for the settings of a split in the interactive mode,
+// then runs it with splitFile and shows each horcrux as it is written.
+type splitWizard struct {
+	file     string
+	step     wizardStep
+	settings splitSettings
+	err      string
+
+	// While running
+	events    chan tea.Msg
+	cancel    context.CancelFunc
+	quitAfter bool           // quit once the split has stopped
+	stage     pipeline.Event // latest progress
+	bar       progress.Model
+	written   int
+	log       []string
+	result    error
+}
+
+// Messages from a running split
+type (
+	splitLogMsg      string
+	splitProgressMsg pipeline.Event
+	splitDoneMsg     struct{ err error }
+)
+
+// wizardLogLines is how many of the latest lines of split's output are shown.
+const wizardLogLines = 4
+
+func newSplitWizard(file string) *splitWizard {
+	return &splitWizard{
+		file: file,
+		bar:  newTUIBar(),
+		settings: splitSettings{
+			destDir:      filepath.Dir(file),
+			stegoBits:    1,
+			textEncoding: "zero-width",
+		},
+	}
+}
+
+// prompt sets up input for the current step, filled in with what was given
+// before.
+func (w *splitWizard) prompt(input *textinput.Model) {
+	input.Reset()
+	input.Focus()
+	switch w.step {
+	case stepShards:
+		input.Placeholder = "5"
+		if w.settings.totalParts > 0 {
+			input.SetValue(strconv.Itoa(w.settings.totalParts))
+		}
+	case stepThreshold:
+		input.Placeholder = "3"
+		if w.settings.threshold > 0 {
+			input.SetValue(strconv.Itoa(w.settings.threshold))
+		}
+	case stepCarriers:
+		input.Placeholder = "none; or one image, or one per horcrux, comma-separated"
+		input.SetValue(strings.Join(w.settings.carrierImages, ","))
+	case stepDestination:
+		input.Placeholder = filepath.Dir(w.file)
+		input.SetValue(w.settings.destDir)
+	default:
+		input.Blur()
+	}
+}
+
+// submit checks the answer to the current step and moves on, or records
+// why it cannot.
+func (w *splitWizard) submit(value string) {
+	value = strings.TrimSpace(value)
+	w.err = ""
+	switch w.step {
+	case stepShards:
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 2 || n > 255 {
+			w.err = "the number of horcruxes must be 2 to 255"
+			return
+		}
+		w.settings.totalParts = n
+		if w.settings.threshold > n {
+			w.settings.threshold = 0
+		}
+	case stepThreshold:
+		t, err := strconv.Atoi(value)
+		if err != nil || t < 2 || t > w.settings.totalParts {
+			w.err = fmt.Sprintf("the threshold must be 2 to %d", w.settings.totalParts)
+			return
+		}
+		w.settings.threshold = t
+	case stepCarriers:
+		var paths []string
+		for _, p := range strings.Split(value, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				paths = append(paths, p)
+			}
+		}
+		if len(paths) > 1 && len(paths) != w.settings.totalParts {
+			w.err = fmt.Sprintf("give one image, or one per horcrux (%d)", w.settings.totalParts)
+			return
+		}
+		for _, p := range paths {
+			c := newCarrierFile(p, io.Discard)
+			if err := c.load(); err != nil {
+				w.err = err.Error()
+				return
+			}
+			if !c.isImage() {
+				w.err = fmt.Sprintf("%s is not a PNG or JPEG image", filepath.Base(p))
+				return
+			}
+		}
+		w.settings.carrierImages = paths
+	case stepDestination:
+		if value == "" {
+			value = filepath.Dir(w.file)
+		}
+		if info, err := os.Stat(value); err == nil && !info.IsDir() {
+			w.err = fmt.Sprintf("%s is not a directory", value)
+			return
+		}
+		w.settings.destDir = value
+	}
+	w.step++
+}
+
+// start runs the split in the background. Its output and progress arrive
+// as messages, one per wait.
+func (w *splitWizard) start() tea.Cmd {
+	w.step = stepRunning
+	w.events = make(chan tea.Msg, 16)
+	ctx, cancel := context.WithCancel(context.Background())
+	w.cancel = cancel
+	events, file, settings := w.events, w.file, w.settings
+	go func() {
+		defer cancel()
+		err := splitFile(ctx, file, settings, lineWriter(func(line string) {
+			events <- splitLogMsg(line)
+		}), pipeline.ReporterFunc(func(e pipeline.Event) {
+			events <- splitProgressMsg(e)
+		}))
+		events <- splitDoneMsg{err}
+	}()
+	return w.wait()
+}
+
+// stop cancels the running split, and quits once it has stopped if asked to.
+func (w *splitWizard) stop(quit bool) {
+	w.cancel()
+	w.quitAfter = w.quitAfter || quit
+}
+
+func (w *splitWizard) wait() tea.Cmd {
+	events := w.events
+	return func() tea.Msg {
+		return <-events
+	}
+}
+
+// handle takes a message from the running split, and returns the command to
+// wait for the next.
+func (w *splitWizard) handle(msg tea.Msg) tea.Cmd {
+	switch msg := msg.(type) {
+	case splitLogMsg:
+		w.log = append(w.log, string(msg))
+		if len(w.log) > wizardLogLines {
+			w.log = w.log[len(w.log)-wizardLogLines:]
+		}
+	case splitProgressMsg:
+		w.stage = pipeline.Event(msg)
+		if w.stage.Stage == pipeline.StageWrite {
+			w.written = int(w.stage.Done)
+		}
+	case splitDoneMsg:
+		w.step = stepDone
+		w.result = msg.err
+		if w.quitAfter {
+			return tea.Quit
+		}
+		return nil
+	}
+	return w.wait()
+}
+
+// lineWriter calls a function with each line written to it.
+type lineWriter func(line string)
+
+func (lw lineWriter) Write(p []byte) (int, error) {
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		lw(line)
+	}
+	return len(p), nil
+}
+
+func (w *splitWizard) view(input textinput.Model) string {
+	s := fmt.Sprintf("Split %s\n\n", filepath.Base(w.file))
+
+	carriers := "none"
+	if len(w.settings.carrierImages) > 0 {
+		carriers = strings.Join(w.settings.carrierImages, ", ")
+	}
+	headerless := "no"
+	if w.settings.isHeaderless {
+		headerless = "yes"
+	}
+	fields := []struct {
+		step  wizardStep
+		label string
+		value string
+	}{
+		{stepShards, "Horcruxes", strconv.Itoa(w.settings.totalParts)},
+		{stepThreshold, "Needed to bind", strconv.Itoa(w.settings.threshold)},
+		{stepCarriers, "Carrier images", carriers},
+		{stepHeaderless, "Headerless", headerless},
+		{stepDestination, "Destination", w.settings.destDir},
+	}
+	for _, f := range fields {
+		switch {
+		case f.step == w.step && f.step == stepHeaderless:
+			s += cursorStyle.Render(">") + fmt.Sprintf(" %-15s %s  (y/n)\n", f.label+":", headerless)
+		case f.step == w.step:
+			s += cursorStyle.Render(">") + fmt.Sprintf(" %-15s %s\n", f.label+":", input.View())
+		case f.step < w.step:
+			s += fmt.Sprintf("  %-15s %s\n", f.label+":", f.value)
+		default:
+			s += dimStyle.Render(fmt.Sprintf("  %-15s", f.label+":")) + "\n"
+		}
+	}
+
+	switch w.step {
+	case stepConfirm:
+		s += "\nEnter: split | Esc: back\n"
+	case stepRunning, stepDone:
+		s += "\n" + w.progressView()
+	default:
+		if w.err != "" {
+			s += "\n" + errorStyle.Render(w.err) + "\n"
+		}
+		s += "\nEnter: next | Esc: back\n"
+	}
+	return s
+}
+
+func (w *splitWizard) progressView() string {
+	total := int64(w.settings.totalParts)
+	written := progressFraction(pipeline.Event{Done: int64(w.written), Total: total})
+	s := fmt.Sprintf("%s %d/%d horcruxes\n", w.bar.ViewAs(written), w.written, total)
+	if w.step == stepRunning && w.stage.Stage != "" {
+		s += w.bar.ViewAs(progressFraction(w.stage)) + " " + dimStyle.Render(stageProgress(w.stage)) + "\n"
+	}
+	for _, line := range w.log {
+		s += dimStyle.Render("  "+line) + "\n"
+	}
+
+	if w.step == stepRunning {
+		s += "\nEsc: cancel\n"
+	}
+	if w.step == stepDone {
+		if errors.Is(w.result, context.Canceled) {
+			s += "\n" + errorStyle.Render("Cancelled; the horcruxes written were removed.") + "\n"
+		} else if w.result != nil {
+			s += "\n" + errorStyle.Render(fmt.Sprintf("Error: %v", w.result)) + "\n"
+		} else {
+			s += "\n" + checkedStyle.Render(fmt.Sprintf("Done! The horcruxes are in %s.", w.settings.destDir)) + "\n"
+		}
+		s += "\nPress any key to return to the files\n"
+	}
+	return s
+}
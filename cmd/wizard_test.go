@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWizardSubmit(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(file, []byte("notes"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		step     wizardStep
+		value    string
+		wantStep wizardStep
+		wantErr  bool
+	}{
+		{"shards", stepShards, "5", stepThreshold, false},
+		{"too few shards", stepShards, "1", stepShards, true},
+		{"not a number", stepShards, "five", stepShards, true},
+		{"threshold", stepThreshold, " 3 ", stepCarriers, false},
+		{"threshold above total", stepThreshold, "6", stepThreshold, true},
+		{"no carriers", stepCarriers, "", stepHeaderless, false},
+		{"carrier count", stepCarriers, "a.png,b.png", stepCarriers, true},
+		{"destination", stepDestination, dir, stepConfirm, false},
+		{"destination is a file", stepDestination, file, stepDestination, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := newSplitWizard(file)
+			w.settings.totalParts = 5
+			w.step = tt.step
+			w.submit(tt.value)
+			if w.step != tt.wantStep || (w.err != "") != tt.wantErr {
+				t.Errorf("submit(%q) at step %d: step %d, err %q", tt.value, tt.step, w.step, w.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package armor
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Markers delimiting an armored block, in the style of OpenPGP (RFC 4880).
+const (
+	BeginMarker = "-----BEGIN HORCRUX-----"
+	EndMarker   = "-----END HORCRUX-----"
+
+	// lineLength is the number of base64 characters per body line.
+	lineLength = 64
+)
+
+// ErrNoArmor indicates the input did not contain a single armored block.
+var ErrNoArmor = errors.New("no armored horcrux found")
+
+// ErrChecksum indicates the CRC24 of a decoded block did not match.
+var ErrChecksum = errors.New("armor checksum mismatch")
+
+// Block is a single decoded armored block.
+type Block struct {
+	// Number is the block's position in the input, counting from 1, blocks
+	// that failed to decode included.
+	Number int
+
+	// Headers holds the optional "Key: Value" lines after the BEGIN marker.
+	Headers map[string]string
+
+	// Data is the decoded payload (a complete horcrux).
+	Data []byte
+}
+
+// Encode writes data as an armored block with the given headers.
+// Headers are written in the order given as "Key: Value" pairs.
+func Encode(w io.Writer, data []byte, headers ...string) error {
+	if len(headers)%2 != 0 {
+		return errors.New("armor headers must be key/value pairs")
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(BeginMarker + "\n")
+	for i := 0; i < len(headers); i += 2 {
+		fmt.Fprintf(&buf, "%s: %s\n", headers[i], headers[i+1])
+	}
+	buf.WriteString("\n")
+
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > lineLength {
+		buf.WriteString(encoded[:lineLength] + "\n")
+		encoded = encoded[lineLength:]
+	}
+	if encoded != "" {
+		buf.WriteString(encoded + "\n")
+	}
+
+	var crcBytes [3]byte
+	crc := crc24(data)
+	crcBytes[0], crcBytes[1], crcBytes[2] = byte(crc>>16), byte(crc>>8), byte(crc)
+	buf.WriteString("=" + base64.StdEncoding.EncodeToString(crcBytes[:]) + "\n")
+	buf.WriteString(EndMarker + "\n")
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// Decode scans r for armored blocks and decodes every one of them.
+// Text outside the blocks is ignored, so several blocks may be pasted into one
+// file (or an email) with arbitrary prose around them.
+// A block that fails to decode does not stop the others: the good blocks are
+// returned along with an error joining one per bad block, each naming it.
+// It returns ErrNoArmor if no block was found.
+func Decode(r io.Reader) ([]*Block, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	var blocks []*Block
+	var errs []error
+	number := 0
+	begun := false // a BEGIN marker cut a block short and starts the next
+	for begun || scanner.Scan() {
+		if !begun && strings.TrimSpace(scanner.Text()) != BeginMarker {
+			continue
+		}
+		number++
+		var block *Block
+		var err error
+		block, begun, err = decodeBlock(scanner)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("block %d: %w", number, err))
+			continue
+		}
+		block.Number = number
+		blocks = append(blocks, block)
+	}
+	if err := scanner.Err(); err != nil {
+		errs = append(errs, fmt.Errorf("failed to read armored input: %w", err))
+	}
+
+	if number == 0 && len(errs) == 0 {
+		return nil, ErrNoArmor
+	}
+	return blocks, errors.Join(errs...)
+}
+
+// IsArmored reports whether data contains the start of an armored block.
+func IsArmored(data []byte) bool {
+	return bytes.Contains(data, []byte(BeginMarker))
+}
+
+// decodeBlock consumes lines after a BEGIN marker up to and including the
+// END marker. A block missing its END marker ends at the next BEGIN marker,
+// in which case begun is true.
+func decodeBlock(scanner *bufio.Scanner) (block *Block, begun bool, err error) {
+	block = &Block{Headers: make(map[string]string)}
+
+	// 1. Headers, terminated by a blank line.
+	// Some mail clients drop the blank line when there are no headers, so a
+	// line without a colon is treated as the first body line.
+	var body strings.Builder
+	inHeaders := true
+	var checksum string
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == EndMarker {
+			block, err = finishBlock(block, body.String(), checksum)
+			return block, false, err
+		}
+		if line == BeginMarker {
+			return nil, true, fmt.Errorf("missing %q", EndMarker)
+		}
+
+		if inHeaders {
+			if line == "" {
+				inHeaders = false
+				continue
+			}
+			if key, value, ok := strings.Cut(line, ": "); ok {
+				block.Headers[key] = value
+				continue
+			}
+			inHeaders = false
+		}
+
+		// 2. Body and checksum lines
+		switch {
+		case line == "":
+			continue
+		case strings.HasPrefix(line, "="):
+			checksum = line[1:]
+		default:
+			body.WriteString(line)
+		}
+	}
+
+	return nil, false, fmt.Errorf("missing %q", EndMarker)
+}
+
+func finishBlock(block *Block, body, checksum string) (*Block, error) {
+	data, err := base64.StdEncoding.DecodeString(body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64 body: %w", err)
+	}
+
+	// 3. Verify the CRC24, if present
+	if checksum != "" {
+		crcBytes, err := base64.StdEncoding.DecodeString(checksum)
+		if err != nil || len(crcBytes) != 3 {
+			return nil, fmt.Errorf("invalid checksum line %q", checksum)
+		}
+		want := uint32(crcBytes[0])<<16 | uint32(crcBytes[1])<<8 | uint32(crcBytes[2])
+		if crc24(data) != want {
+			return nil, ErrChecksum
+		}
+	}
+
+	block.Data = data
+	return block, nil
+}
+
+// crc24 computes the OpenPGP CRC-24 checksum (RFC 4880, section 6.1).
+func crc24(data []byte) uint32 {
+	const (
+		crc24Init = 0xB704CE
+		crc24Poly = 0x1864CFB
+	)
+
+	crc := uint32(crc24Init)
+	for _, b := range data {
+		crc ^= uint32(b) << 16
+		for i := 0; i < 8; i++ {
+			crc <<= 1
+			if crc&0x1000000 != 0 {
+				crc ^= crc24Poly
+			}
+		}
+	}
+	return crc & 0xFFFFFF
+}
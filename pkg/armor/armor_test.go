@@ -0,0 +1,119 @@
+package armor
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCRC24(t *testing.T) {
+	// Standard check value for CRC-24/OPENPGP
+	if got := crc24([]byte("123456789")); got != 0x21CF02 {
+		t.Errorf("crc24 check value mismatch: got %06X, want 21CF02", got)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	data := make([]byte, 1000)
+	rand.Read(data)
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, data, "Comment", "horcrux 1 of 3"); err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if len(line) > lineLength {
+			t.Errorf("Line exceeds %d characters: %q", lineLength, line)
+		}
+	}
+
+	blocks, err := Decode(&buf)
+	if err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+	if len(blocks) != 1 {
+		t.Fatalf("Expected 1 block, got %d", len(blocks))
+	}
+	if !bytes.Equal(blocks[0].Data, data) {
+		t.Error("Decoded data does not match original")
+	}
+	if blocks[0].Headers["Comment"] != "horcrux 1 of 3" {
+		t.Errorf("Header mismatch: %v", blocks[0].Headers)
+	}
+}
+
+func TestMultipleBlocksInProse(t *testing.T) {
+	var first, second bytes.Buffer
+	Encode(&first, []byte("first shard"))
+	Encode(&second, []byte("second shard"))
+
+	// Simulate an email with quoting indentation and CRLF line endings
+	pasted := "Hi Bob,\r\n\r\nhere are both of mine:\r\n\r\n" +
+		strings.ReplaceAll(first.String(), "\n", "\r\n") +
+		"\r\nand the other one\r\n  " +
+		strings.ReplaceAll(second.String(), "\n", "\n  ") +
+		"\r\nCheers, Alice\r\n"
+
+	blocks, err := Decode(strings.NewReader(pasted))
+	if err != nil {
+		t.Fatalf("Failed to decode: %v", err)
+	}
+	if len(blocks) != 2 {
+		t.Fatalf("Expected 2 blocks, got %d", len(blocks))
+	}
+	if string(blocks[0].Data) != "first shard" || string(blocks[1].Data) != "second shard" {
+		t.Errorf("Unexpected payloads: %q, %q", blocks[0].Data, blocks[1].Data)
+	}
+}
+
+func TestCorruptedBlock(t *testing.T) {
+	var buf bytes.Buffer
+	Encode(&buf, []byte("The quick brown fox jumps over the lazy dog"))
+
+	// Flip one base64 character in the body
+	corrupted := strings.Replace(buf.String(), "VGhl", "VGhm", 1)
+
+	_, err := Decode(strings.NewReader(corrupted))
+	if !errors.Is(err, ErrChecksum) {
+		t.Errorf("Expected ErrChecksum, got %v", err)
+	}
+
+	_, err = Decode(strings.NewReader("no armor here"))
+	if !errors.Is(err, ErrNoArmor) {
+		t.Errorf("Expected ErrNoArmor, got %v", err)
+	}
+}
+
+func TestBadBlocksAmongGood(t *testing.T) {
+	var good, corrupted, truncated, last bytes.Buffer
+	Encode(&good, []byte("first shard"))
+	Encode(&corrupted, []byte("The quick brown fox jumps over the lazy dog"))
+	Encode(&truncated, []byte("third shard"))
+	Encode(&last, []byte("fourth shard"))
+
+	// The second block is damaged, the third cut off before its END marker
+	pasted := good.String() + "\n" +
+		strings.Replace(corrupted.String(), "VGhl", "VGhm", 1) + "\n" +
+		strings.TrimSuffix(truncated.String(), EndMarker+"\n") +
+		last.String()
+
+	blocks, err := Decode(strings.NewReader(pasted))
+	if !errors.Is(err, ErrChecksum) {
+		t.Errorf("Expected ErrChecksum, got %v", err)
+	}
+	if err == nil || !strings.Contains(err.Error(), "block 2:") || !strings.Contains(err.Error(), "block 3:") {
+		t.Errorf("Expected errors for blocks 2 and 3, got %v", err)
+	}
+	if len(blocks) != 2 {
+		t.Fatalf("Expected 2 good blocks, got %d", len(blocks))
+	}
+	if string(blocks[0].Data) != "first shard" || string(blocks[1].Data) != "fourth shard" {
+		t.Errorf("Unexpected payloads: %q, %q", blocks[0].Data, blocks[1].Data)
+	}
+	if blocks[0].Number != 1 || blocks[1].Number != 4 {
+		t.Errorf("Blocks numbered %d and %d, want 1 and 4", blocks[0].Number, blocks[1].Number)
+	}
+}
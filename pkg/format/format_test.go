@@ -30,6 +30,9 @@ func TestRoundTrip_Standard(t *testing.T) {
 	}
 
 	// 3. Read back from the buffer
+	if !IsHorcrux(buf.Bytes()) {
+		t.Error("IsHorcrux did not recognize a horcrux")
+	}
 	reader, err := NewReader(&buf)
 	if err != nil {
 		t.Fatalf("Failed to create reader: %v", err)
@@ -79,6 +82,9 @@ func TestParanoiacMode(t *testing.T) {
 	if strings.Contains(output, "-- HEADER --") {
 		t.Error("Paranoiac mode failed: Header Marker found in output")
 	}
+	if IsHorcrux(buf.Bytes()) {
+		t.Error("IsHorcrux recognized a headerless file")
+	}
 
 	// 2. Ensure Reader correctly FAILS (It should not recognize this file)
 	_, err = NewReader(&buf)
@@ -101,4 +107,126 @@ payload`
 	if err == nil {
 		t.Error("Reader should have failed on corrupt JSON, but succeeded")
 	}
-}
\ No newline at end of file
+}
+
+func TestCustodianPreamble(t *testing.T) {
+	header := &Header{
+		OriginalFilename: "will.pdf",
+		Timestamp:        1620000000,
+		Index:            1,
+		Total:            3,
+		Threshold:        2,
+		KeyFragment:      []byte("key"),
+		Custodian:        "alice",
+		Custodians:       []string{"alice", "bob", "carol"},
+		Instructions:     "Call the family lawyer.\n-- HEADER --",
+	}
+
+	var buf bytes.Buffer
+	if err := NewWriter(&buf).Write(header, []byte("payload"), false); err != nil {
+		t.Fatalf("Failed to write horcrux: %v", err)
+	}
+
+	output := buf.String()
+	for _, want := range []string{
+		"# THIS HORCRUX IS HELD BY: alice\n",
+		"# THE OTHER HORCRUXES ARE HELD BY: bob, carol\n",
+		"# Call the family lawyer.\n",
+		"# -- HEADER --\n",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Preamble is missing %q:\n%s", want, output)
+		}
+	}
+
+	// Instructions that contain a marker must not confuse the reader
+	reader, err := NewReader(&buf)
+	if err != nil {
+		t.Fatalf("Failed to read horcrux: %v", err)
+	}
+	if !reflect.DeepEqual(reader.Header, header) {
+		t.Errorf("Headers do not match.\nGot: %+v\nWant: %+v", reader.Header, header)
+	}
+}
+
+func TestCustodianLineBreaks(t *testing.T) {
+	for _, h := range []*Header{
+		{Custodian: "alice\n-- HEADER --"},
+		{Custodians: []string{"alice", "bob\r\n# injected", "carol"}},
+	} {
+		h.OriginalFilename, h.Index, h.Total, h.Threshold, h.KeyFragment = "will.pdf", 1, 3, 2, []byte("key")
+		var buf bytes.Buffer
+		if err := NewWriter(&buf).Write(h, []byte("payload"), false); err == nil {
+			t.Errorf("Custodians %q %q were written across lines", h.Custodian, h.Custodians)
+		}
+	}
+}
+
+func TestMissingCustodians(t *testing.T) {
+	custodians := []string{"alice", "bob", "carol", ""}
+	found := []*Header{
+		{Index: 1, Total: 4, Custodians: custodians},
+	}
+
+	got := MissingCustodians(found)
+	want := []string{"bob", "carol", "shard #4"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Missing custodians mismatch.\nGot: %v\nWant: %v", got, want)
+	}
+}
+
+func TestCustodianName(t *testing.T) {
+	h := &Header{Index: 2, Total: 3, Custodian: "bob", Custodians: []string{"alice", "bob", ""}}
+
+	if name, ok := h.CustodianName(1); !ok || name != "alice" {
+		t.Errorf("CustodianName(1) = %q, %v; want alice", name, ok)
+	}
+	if name, ok := h.CustodianName(3); ok || name != "" {
+		t.Errorf("CustodianName(3) = %q, %v; want none", name, ok)
+	}
+
+	// A custodian may be named like the fallback
+	h.Custodians[0] = "shard #1"
+	if name, ok := h.CustodianName(1); !ok || name != "shard #1" {
+		t.Errorf("CustodianName(1) = %q, %v; want shard #1", name, ok)
+	}
+}
+
+func TestLegacyDetection(t *testing.T) {
+	legacy := `# THIS FILE IS A HORCRUX.
+# IT IS ONE OF 2 HORCRUXES THAT EACH CONTAIN PART OF AN ORIGINAL FILE.
+# THIS IS HORCRUX NUMBER 1.
+# IN ORDER TO RESURRECT THIS ORIGINAL FILE YOU MUST FIND THE OTHER 1 HORCRUX(ES) AND THEN BIND THEM USING THE PROGRAM FOUND AT THE FOLLOWING URL
+# https://github.com/jesseduffield/horcrux
+
+-- HEADER --
+{"originalFilename":"diary.txt","timestamp":1582384634,"index":1,"total":2,"threshold":2,"keyFragment":"AQID"}
+-- BODY --
+body`
+
+	reader, err := NewReader(strings.NewReader(legacy))
+	if err != nil {
+		t.Fatalf("Failed to read legacy horcrux: %v", err)
+	}
+	if !reader.Header.Legacy {
+		t.Error("Expected the header to be marked legacy")
+	}
+	if body, _ := io.ReadAll(reader.Body); string(body) != "body" {
+		t.Errorf("Unexpected body %q", body)
+	}
+
+	var buf bytes.Buffer
+	if err := NewWriter(&buf).Write(reader.Header, []byte("x"), false); err != nil {
+		t.Fatalf("Failed to write horcrux: %v", err)
+	}
+	if strings.Contains(buf.String(), "legacy") {
+		t.Error("Legacy flag must not be serialized")
+	}
+	current, err := NewReader(&buf)
+	if err != nil {
+		t.Fatalf("Failed to read horcrux: %v", err)
+	}
+	if current.Header.Legacy {
+		t.Error("Current horcrux marked legacy")
+	}
+}
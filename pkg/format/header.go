@@ -3,6 +3,7 @@ package format
 import (
 	"errors"
 	"fmt"
+	"strings"
 )
 
 // Standard Markers used to delineate sections in the text-friendly format
@@ -15,11 +16,19 @@ const (
 # AND BIND THEM USING THE PROGRAM FOUND AT:
 # https://github.com/Beastly713/horcrux
 `
+	// LegacyURL appears in the preamble of horcruxes made by the original
+	// jesseduffield/horcrux tool, which share the header layout but not the body.
+	LegacyURL = "github.com/jesseduffield/horcrux"
+
 	// HeaderMarker indicates the start of the JSON metadata
 	HeaderMarker = "-- HEADER --"
 
 	// BodyMarker indicates the start of the encrypted/sharded binary content
 	BodyMarker = "-- BODY --"
+
+	// MaxInstructionLines caps the free-text instructions so the preamble
+	// stays within the window the Reader scans for the HeaderMarker.
+	MaxInstructionLines = 30
 )
 
 // Header contains all the metadata required to bind horcruxes together.
@@ -43,6 +52,20 @@ type Header struct {
 	// KeyFragment is the Shamir secret share for this specific shard.
 	// This reconstructs the AES-GCM key.
 	KeyFragment []byte `json:"keyFragment"`
+
+	// Custodian is the label of the person (or place) holding this shard.
+	Custodian string `json:"custodian,omitempty"`
+
+	// Custodians lists the holder of every shard in index order, so whoever
+	// finds one horcrux knows whom to ask for the others.
+	Custodians []string `json:"custodians,omitempty"`
+
+	// Instructions is optional free-text recovery guidance shown in the preamble.
+	Instructions string `json:"instructions,omitempty"`
+
+	// Legacy is set by the Reader for horcruxes made by the original
+	// jesseduffield/horcrux tool, whose body is not in this project's format.
+	Legacy bool `json:"-"`
 }
 
 // Validate checks if the header contains sane values.
@@ -59,5 +82,63 @@ func (h *Header) Validate() error {
 	if h.OriginalFilename == "" {
 		return errors.New("header is missing original filename")
 	}
+	if len(h.Custodians) > 0 && len(h.Custodians) != h.Total {
+		return fmt.Errorf("custodian list has %d entries for total %d", len(h.Custodians), h.Total)
+	}
+	// Custodians are written on single preamble lines
+	if strings.ContainsAny(h.Custodian, "\r\n") {
+		return errors.New("custodian must not contain line breaks")
+	}
+	for i, c := range h.Custodians {
+		if strings.ContainsAny(c, "\r\n") {
+			return fmt.Errorf("custodian of shard #%d must not contain line breaks", i+1)
+		}
+	}
+	if strings.Count(h.Instructions, "\n")+1 > MaxInstructionLines {
+		return fmt.Errorf("instructions exceed %d lines", MaxInstructionLines)
+	}
 	return nil
-}
\ No newline at end of file
+}
+
+// CustodianOf returns the label of whoever holds the shard with the given
+// 1-based index, falling back to a generic "shard #N" when none was recorded.
+func (h *Header) CustodianOf(index int) string {
+	if name, ok := h.CustodianName(index); ok {
+		return name
+	}
+	return fmt.Sprintf("shard #%d", index)
+}
+
+// CustodianName returns the label of whoever holds the shard with the given
+// 1-based index, and whether one was recorded.
+func (h *Header) CustodianName(index int) (string, bool) {
+	if index == h.Index && h.Custodian != "" {
+		return h.Custodian, true
+	}
+	if index >= 1 && index <= len(h.Custodians) && h.Custodians[index-1] != "" {
+		return h.Custodians[index-1], true
+	}
+	return "", false
+}
+
+// MissingCustodians returns the holders of the shards that are absent from
+// the given set. All headers are assumed to belong to the same split.
+func MissingCustodians(headers []*Header) []string {
+	if len(headers) == 0 {
+		return nil
+	}
+
+	present := make(map[int]bool, len(headers))
+	for _, h := range headers {
+		present[h.Index] = true
+	}
+
+	ref := headers[0]
+	var missing []string
+	for i := 1; i <= ref.Total; i++ {
+		if !present[i] {
+			missing = append(missing, ref.CustodianOf(i))
+		}
+	}
+	return missing
+}
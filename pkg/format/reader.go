@@ -9,6 +9,14 @@ import (
 	"strings"
 )
 
+// maxPreambleLines is how far NewReader looks for the HeaderMarker. It leaves
+// room for the MagicHeader, the custodian lines and MaxInstructionLines.
+const maxPreambleLines = 50 + MaxInstructionLines
+
+// legacyURLLine is where the original jesseduffield/horcrux tool names itself
+// in its preamble: the fifth line, followed only by a blank line.
+const legacyURLLine = 4
+
 // Reader is a wrapper around the file stream that separates the
 // metadata header from the binary body.
 type Reader struct {
@@ -28,15 +36,25 @@ func NewReader(r io.Reader) (*Reader, error) {
 	// We read line by line. If we don't find the header marker within a reasonable
 	// amount of lines, we assume this is not a valid formatted horcrux.
 	foundHeader := false
-	for i := 0; i < 50; i++ { // limit scan to 50 lines to prevent infinite loops on garbage files
+	legacy := false
+	for i := 0; i < maxPreambleLines; i++ { // limit scan to prevent infinite loops on garbage files
 		line, err := bufReader.ReadString('\n')
 		if err != nil {
 			return nil, fmt.Errorf("failed to read stream while looking for header: %w", err)
 		}
-		if strings.TrimSpace(line) == HeaderMarker {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == HeaderMarker {
 			foundHeader = true
 			break
 		}
+		switch {
+		case i == legacyURLLine && trimmed == "# https://"+LegacyURL:
+			legacy = true
+		case i > legacyURLLine && trimmed != "":
+			// The original preamble ends with its URL. Anything after it,
+			// such as custodian or instruction lines, is this project's
+			legacy = false
+		}
 	}
 
 	if !foundHeader {
@@ -70,6 +88,7 @@ func NewReader(r io.Reader) (*Reader, error) {
 	if err := json.Unmarshal(jsonBuilder.Bytes(), header); err != nil {
 		return nil, fmt.Errorf("failed to parse header json: %w", err)
 	}
+	header.Legacy = legacy
 
 	// 4. Validate the parsed header
 	if err := header.Validate(); err != nil {
@@ -82,4 +101,12 @@ func NewReader(r io.Reader) (*Reader, error) {
 		// will drain that buffer before reading more from the underlying source.
 		Body: bufReader,
 	}, nil
-}
\ No newline at end of file
+}
+
+// IsHorcrux reports whether data starts like a horcrux with a header, made
+// by this tool or the original one. Headerless horcruxes cannot be told from
+// random bytes.
+func IsHorcrux(data []byte) bool {
+	firstLine, _, _ := strings.Cut(MagicHeader, "\n")
+	return bytes.HasPrefix(data, []byte(firstLine))
+}
@@ -0,0 +1,32 @@
+package format
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestLegacyURLInPreambleText(t *testing.T) {
+	header := &Header{
+		OriginalFilename: "diary.txt",
+		Timestamp:        1700000000,
+		Index:            1,
+		Total:            2,
+		Threshold:        2,
+		KeyFragment:      []byte{1, 2, 3},
+		Custodian:        "https://github.com/jesseduffield/horcrux",
+		Custodians:       []string{"https://github.com/jesseduffield/horcrux", "Not https://github.com/jesseduffield/horcrux"},
+		Instructions:     "Not https://github.com/jesseduffield/horcrux\nhttps://github.com/jesseduffield/horcrux",
+	}
+
+	var buf bytes.Buffer
+	if err := NewWriter(&buf).Write(header, []byte("body"), false); err != nil {
+		t.Fatalf("Failed to write horcrux: %v", err)
+	}
+	reader, err := NewReader(&buf)
+	if err != nil {
+		t.Fatalf("Failed to read horcrux: %v", err)
+	}
+	if reader.Header.Legacy {
+		t.Error("A horcrux naming the original tool in its custodians or instructions was read as legacy")
+	}
+}
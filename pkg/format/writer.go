@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"strings"
 )
 
 // Writer handles the writing of a single horcrux file.
@@ -29,6 +30,7 @@ func (hw *Writer) Write(header *Header, content []byte, headerless bool) error {
 		// We calculate (Threshold - 1) to tell the user exactly how many *more*
 		// files they need to find (assuming they found this one).
 		magicText := fmt.Sprintf(MagicHeader, header.Total, header.Index, header.Threshold-1)
+		magicText += custodianPreamble(header)
 		if _, err := fmt.Fprint(hw.w, magicText); err != nil {
 			return fmt.Errorf("failed to write magic header: %w", err)
 		}
@@ -64,4 +66,34 @@ func (hw *Writer) Write(header *Header, content []byte, headerless bool) error {
 	}
 
 	return nil
-}
\ No newline at end of file
+}
+
+// custodianPreamble renders the optional custodian and instruction lines that
+// follow the MagicHeader. Every line is commented out so that user supplied
+// text can never be mistaken for a section marker.
+func custodianPreamble(header *Header) string {
+	var sb strings.Builder
+
+	if header.Custodian != "" {
+		fmt.Fprintf(&sb, "# THIS HORCRUX IS HELD BY: %s\n", header.Custodian)
+	}
+
+	var others []string
+	for i := 1; i <= len(header.Custodians); i++ {
+		if i != header.Index {
+			others = append(others, header.CustodianOf(i))
+		}
+	}
+	if len(others) > 0 {
+		fmt.Fprintf(&sb, "# THE OTHER HORCRUXES ARE HELD BY: %s\n", strings.Join(others, ", "))
+	}
+
+	if header.Instructions != "" {
+		sb.WriteString("# RECOVERY INSTRUCTIONS:\n")
+		for _, line := range strings.Split(header.Instructions, "\n") {
+			sb.WriteString(strings.TrimRight("# "+line, " ") + "\n")
+		}
+	}
+
+	return sb.String()
+}
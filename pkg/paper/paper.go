@@ -0,0 +1,218 @@
+package paper
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/Beastly713/horcrux/pkg/format"
+	"github.com/Beastly713/horcrux/pkg/qr"
+	"github.com/Beastly713/horcrux/pkg/wordlist"
+)
+
+// A paper horcrux is split into two parts that can be stored apart:
+//
+//   - QR codes holding the header (without the key fragment) and the body.
+//     Each code carries a chunk: [Magic (4) | SetID (4) | Part (1) | Count (1) | Data].
+//   - A checksummed word list holding the key fragment (see pkg/wordlist).
+//     The checksum is tagged with the file name and time of the split, so
+//     words only attach to horcruxes of their own split.
+//
+// A photo of the QR codes alone therefore reveals nothing about the key.
+
+// Magic identifies a QR code produced by this package.
+const Magic = "HXQ1"
+
+const chunkHeaderLen = len(Magic) + 4 + 1 + 1
+
+// QR symbols are capped at version 20 (97x97 modules) with medium error
+// correction, which prints at a size phone cameras resolve comfortably.
+const (
+	qrLevel      = qr.M
+	qrMaxVersion = 20
+)
+
+// MaxChunks is the largest number of QR codes a single shard may span.
+const MaxChunks = 255
+
+// ErrNotPaper indicates a QR payload was not produced by this package.
+var ErrNotPaper = errors.New("not a horcrux paper code")
+
+// Shard is a horcrux read back from paper. Its Header has no KeyFragment
+// until one is attached from the word list.
+type Shard struct {
+	Header *format.Header
+	Body   []byte
+}
+
+// Encode splits a horcrux into QR payloads and a word list for its key
+// fragment.
+func Encode(header *format.Header, body []byte) (chunks [][]byte, words []string, err error) {
+	words, err = EncodeWords(header)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// 1. Serialize header (without the key) and body
+	detached := *header
+	detached.KeyFragment = nil
+	headerJSON, err := json.Marshal(&detached)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to marshal header: %w", err)
+	}
+
+	var payload bytes.Buffer
+	payload.Write(binary.AppendUvarint(nil, uint64(len(headerJSON))))
+	payload.Write(headerJSON)
+	payload.Write(body)
+
+	// 2. Chunk it to fit the QR capacity
+	sum := sha256.Sum256(payload.Bytes())
+	setID := sum[:4]
+
+	chunkData := qr.MaxBytes(qrMaxVersion, qrLevel) - chunkHeaderLen
+	count := (payload.Len() + chunkData - 1) / chunkData
+	if count > MaxChunks {
+		return nil, nil, fmt.Errorf("shard too large for paper: needs %d QR codes, limit is %d", count, MaxChunks)
+	}
+
+	data := payload.Bytes()
+	for part := 0; part < count; part++ {
+		end := min((part+1)*chunkData, len(data))
+		chunk := make([]byte, 0, chunkHeaderLen+end-part*chunkData)
+		chunk = append(chunk, Magic...)
+		chunk = append(chunk, setID...)
+		chunk = append(chunk, byte(part), byte(count))
+		chunk = append(chunk, data[part*chunkData:end]...)
+		chunks = append(chunks, chunk)
+	}
+
+	return chunks, words, nil
+}
+
+// Assemble groups decoded QR payloads by shard and reassembles every shard
+// for which all codes are present. Payloads that are not paper codes are
+// ignored. Incomplete shards are reported in the returned error alongside
+// any shards that did assemble.
+func Assemble(payloads [][]byte) ([]*Shard, error) {
+	type set struct {
+		count int
+		parts map[int][]byte
+	}
+	sets := make(map[string]*set)
+	var order []string
+
+	for _, p := range payloads {
+		if len(p) < chunkHeaderLen || string(p[:len(Magic)]) != Magic {
+			continue
+		}
+		id := string(p[len(Magic) : len(Magic)+4])
+		part, count := int(p[chunkHeaderLen-2]), int(p[chunkHeaderLen-1])
+		if count == 0 || part >= count {
+			continue
+		}
+
+		s, ok := sets[id]
+		if !ok {
+			s = &set{count: count, parts: make(map[int][]byte)}
+			sets[id] = s
+			order = append(order, id)
+		}
+		s.parts[part] = p[chunkHeaderLen:]
+	}
+
+	var shards []*Shard
+	var errs []error
+	for _, id := range order {
+		s := sets[id]
+		if len(s.parts) != s.count {
+			missing := make([]int, 0, s.count-len(s.parts))
+			for i := 0; i < s.count; i++ {
+				if _, ok := s.parts[i]; !ok {
+					missing = append(missing, i+1)
+				}
+			}
+			sort.Ints(missing)
+			errs = append(errs, fmt.Errorf("shard %x is missing QR codes %v of %d", id, missing, s.count))
+			continue
+		}
+
+		var payload []byte
+		for i := 0; i < s.count; i++ {
+			payload = append(payload, s.parts[i]...)
+		}
+		if sum := sha256.Sum256(payload); string(sum[:4]) != id {
+			errs = append(errs, fmt.Errorf("shard %x failed its integrity check", id))
+			continue
+		}
+
+		shard, err := decodePayload(payload)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("shard %x: %w", id, err))
+			continue
+		}
+		shards = append(shards, shard)
+	}
+
+	return shards, errors.Join(errs...)
+}
+
+// EncodeWords returns the word list for the key fragment in header, tied to
+// its split.
+func EncodeWords(header *format.Header) ([]string, error) {
+	words, err := wordlist.EncodeTagged(header.KeyFragment, wordsTag(header))
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode key fragment: %w", err)
+	}
+	return words, nil
+}
+
+// DecodeWords decodes a typed word list made by EncodeWords for a horcrux of
+// the split of header. Words of another split fail like mistyped ones.
+func DecodeWords(header *format.Header, words []string) ([]byte, error) {
+	fragment, err := wordlist.DecodeTagged(words, wordsTag(header))
+	if errors.Is(err, wordlist.ErrChecksum) {
+		return nil, fmt.Errorf("%w: a word is mistyped or out of order, or the words are of another split than %s", err, header.OriginalFilename)
+	}
+	return fragment, err
+}
+
+// wordsTag identifies the split of header, by the same file name and time
+// that group its horcruxes.
+func wordsTag(header *format.Header) []byte {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%d", header.OriginalFilename, header.Timestamp)))
+	return sum[:]
+}
+
+// AttachWords decodes a typed word list and, if it is the key fragment for
+// this shard, stores it in the header and validates the result.
+func (s *Shard) AttachWords(words []string) error {
+	fragment, err := DecodeWords(s.Header, words)
+	if err != nil {
+		return err
+	}
+	if len(fragment) == 0 || int(fragment[len(fragment)-1]) != s.Header.Index {
+		return fmt.Errorf("words are not the key fragment of horcrux %d", s.Header.Index)
+	}
+
+	s.Header.KeyFragment = fragment
+	return s.Header.Validate()
+}
+
+func decodePayload(payload []byte) (*Shard, error) {
+	headerLen, n := binary.Uvarint(payload)
+	if n <= 0 || uint64(len(payload)-n) < headerLen {
+		return nil, ErrNotPaper
+	}
+
+	header := &format.Header{}
+	if err := json.Unmarshal(payload[n:n+int(headerLen)], header); err != nil {
+		return nil, fmt.Errorf("failed to parse header json: %w", err)
+	}
+
+	return &Shard{Header: header, Body: payload[n+int(headerLen):]}, nil
+}
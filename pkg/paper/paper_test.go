@@ -0,0 +1,150 @@
+package paper
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"image"
+	"image/draw"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Beastly713/horcrux/pkg/format"
+	"github.com/Beastly713/horcrux/pkg/qr"
+	"github.com/Beastly713/horcrux/pkg/wordlist"
+)
+
+func testHorcrux(bodySize int) (*format.Header, []byte) {
+	fragment := make([]byte, 33)
+	rand.Read(fragment)
+	fragment[32] = 2 // x-coordinate matches the index
+
+	header := &format.Header{
+		OriginalFilename: "seed.txt",
+		Timestamp:        1620000000,
+		Index:            2,
+		Total:            3,
+		Threshold:        2,
+		KeyFragment:      fragment,
+		Custodian:        "bob",
+		Custodians:       []string{"alice", "bob", "carol"},
+	}
+	body := make([]byte, bodySize)
+	rand.Read(body)
+	return header, body
+}
+
+func TestPaperRoundTrip(t *testing.T) {
+	header, body := testHorcrux(1500) // spans several QR codes
+
+	chunks, words, err := Encode(header, body)
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	if len(chunks) < 2 {
+		t.Fatalf("Expected the body to span several codes, got %d", len(chunks))
+	}
+
+	// Print every code onto one "scanned" page, in reverse order
+	var page []image.Image
+	for i := len(chunks) - 1; i >= 0; i-- {
+		code, err := qr.EncodeVersion(chunks[i], qrLevel, 1, qrMaxVersion)
+		if err != nil {
+			t.Fatalf("QR encode failed: %v", err)
+		}
+		page = append(page, code.Image(3, 4))
+	}
+	scan := sideBySide(page)
+
+	payloads, err := qr.Decode(scan)
+	if err != nil {
+		t.Fatalf("QR decode failed: %v", err)
+	}
+
+	shards, err := Assemble(payloads)
+	if err != nil {
+		t.Fatalf("Assemble failed: %v", err)
+	}
+	if len(shards) != 1 {
+		t.Fatalf("Expected 1 shard, got %d", len(shards))
+	}
+	shard := shards[0]
+	if shard.Header.KeyFragment != nil {
+		t.Error("QR codes must not carry the key fragment")
+	}
+
+	if err := shard.AttachWords(words); err != nil {
+		t.Fatalf("AttachWords failed: %v", err)
+	}
+	if !reflect.DeepEqual(shard.Header, header) {
+		t.Errorf("Header mismatch.\nGot: %+v\nWant: %+v", shard.Header, header)
+	}
+	if !bytes.Equal(shard.Body, body) {
+		t.Error("Body mismatch")
+	}
+}
+
+func TestAttachWordsOfAnotherSplit(t *testing.T) {
+	header, body := testHorcrux(100)
+	words, err := EncodeWords(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The same share of the same file, split again a second later
+	other := *header
+	other.Timestamp++
+	other.KeyFragment = nil
+	shard := &Shard{Header: &other, Body: body}
+	if err := shard.AttachWords(words); !errors.Is(err, wordlist.ErrChecksum) {
+		t.Errorf("Expected ErrChecksum, got %v", err)
+	}
+	if shard.Header.KeyFragment != nil {
+		t.Error("Words of another split were attached")
+	}
+}
+
+func TestAssembleIncomplete(t *testing.T) {
+	header, body := testHorcrux(1500)
+	chunks, _, _ := Encode(header, body)
+
+	shards, err := Assemble(chunks[1:])
+	if len(shards) != 0 || err == nil || !strings.Contains(err.Error(), "missing QR codes [1]") {
+		t.Errorf("Expected a missing code error, got %d shards and %v", len(shards), err)
+	}
+}
+
+func TestRender(t *testing.T) {
+	header, body := testHorcrux(100)
+	header.Instructions = "Ask <alice> first"
+
+	var buf bytes.Buffer
+	if err := Render(&buf, header, body); err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+
+	html := buf.String()
+	_, words, _ := Encode(header, body)
+	for _, want := range append(words, "<svg", "held by <strong>bob</strong>", "Ask &lt;alice&gt; first") {
+		if !strings.Contains(html, want) {
+			t.Errorf("Page is missing %q", want)
+		}
+	}
+}
+
+func sideBySide(imgs []image.Image) image.Image {
+	width, height := 0, 0
+	for _, img := range imgs {
+		width += img.Bounds().Dx()
+		height = max(height, img.Bounds().Dy())
+	}
+	page := image.NewGray(image.Rect(0, 0, width, height))
+	draw.Draw(page, page.Bounds(), image.White, image.Point{}, draw.Src)
+	x := 0
+	for _, img := range imgs {
+		draw.Draw(page, img.Bounds().Add(image.Pt(x, 0)), img, image.Point{}, draw.Src)
+		x += img.Bounds().Dx()
+	}
+	return page
+}
@@ -0,0 +1,107 @@
+package paper
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+
+	"github.com/Beastly713/horcrux/pkg/format"
+	"github.com/Beastly713/horcrux/pkg/qr"
+)
+
+var pageTemplate = template.Must(template.New("page").Funcs(template.FuncMap{
+	"inc": func(i int) int { return i + 1 },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Horcrux {{.Header.Index}} of {{.Header.Total}} – {{.Header.OriginalFilename}}</title>
+<style>
+  @page { size: A4; margin: 15mm; }
+  body { font-family: Georgia, serif; color: #000; max-width: 180mm; margin: 0 auto; }
+  h1 { font-size: 18pt; margin-bottom: 0; }
+  .meta { font-size: 10pt; margin-top: 2mm; }
+  .codes { display: flex; flex-wrap: wrap; gap: 6mm; }
+  figure { margin: 0; width: 80mm; break-inside: avoid; }
+  figure svg { width: 80mm; height: 80mm; }
+  figcaption { font-size: 9pt; text-align: center; }
+  .words { columns: 4; font-family: "Courier New", monospace; font-size: 12pt; break-inside: avoid; }
+  .key { border-top: 1px dashed #000; margin-top: 8mm; padding-top: 4mm; break-before: auto; }
+  .instructions { white-space: pre-wrap; border-left: 3px solid #000; padding-left: 3mm; }
+  code { font-size: 9pt; }
+</style>
+</head>
+<body>
+<h1>Horcrux {{.Header.Index}} of {{.Header.Total}}</h1>
+<p class="meta">
+  Part of <strong>{{.Header.OriginalFilename}}</strong>.
+  Any {{.Header.Threshold}} of the {{.Header.Total}} horcruxes resurrect it.
+  {{- if .Header.Custodian}}<br>This horcrux is held by <strong>{{.Header.Custodian}}</strong>.{{end}}
+  {{- if .Others}}<br>The other horcruxes are held by {{.Others}}.{{end}}
+</p>
+{{- if .Header.Instructions}}
+<p class="instructions">{{.Header.Instructions}}</p>
+{{- end}}
+
+<h2>Shard data</h2>
+<div class="codes">
+{{- range $i, $svg := .Codes}}
+  <figure>{{$svg}}<figcaption>Code {{inc $i}} of {{len $.Codes}}</figcaption></figure>
+{{- end}}
+</div>
+
+<div class="key">
+<h2>Key fragment</h2>
+<p class="meta">Store this part separately from the codes above. The first four letters of each word are enough.</p>
+<ol class="words">
+{{- range .Words}}
+  <li>{{.}}</li>
+{{- end}}
+</ol>
+</div>
+
+<p class="meta">
+  To resurrect: photograph or scan the codes, then run<br>
+  <code>horcrux bind --scan photo.png --words "{{index .Words 0}} {{index .Words 1}} …"</code><br>
+  using the program found at https://github.com/Beastly713/horcrux
+</p>
+</body>
+</html>
+`))
+
+// Render writes a printable HTML page for one horcrux, with the shard data as
+// inline SVG QR codes and the key fragment as a word list.
+func Render(w io.Writer, header *format.Header, body []byte) error {
+	chunks, words, err := Encode(header, body)
+	if err != nil {
+		return err
+	}
+
+	codes := make([]template.HTML, len(chunks))
+	for i, chunk := range chunks {
+		code, err := qr.EncodeVersion(chunk, qrLevel, 1, qrMaxVersion)
+		if err != nil {
+			return fmt.Errorf("failed to encode QR code %d: %w", i+1, err)
+		}
+		// The SVG is generated from module data only, so it is safe to inline
+		codes[i] = template.HTML(code.SVG(4))
+	}
+
+	others := ""
+	for i := 1; i <= len(header.Custodians); i++ {
+		if i == header.Index {
+			continue
+		}
+		if others != "" {
+			others += ", "
+		}
+		others += header.CustodianOf(i)
+	}
+
+	return pageTemplate.Execute(w, struct {
+		Header *format.Header
+		Others string
+		Codes  []template.HTML
+		Words  []string
+	}{header, others, codes, words})
+}
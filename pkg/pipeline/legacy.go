@@ -0,0 +1,60 @@
+package pipeline
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"fmt"
+)
+
+// JoinLegacy resurrects a file split by the original jesseduffield/horcrux
+// tool. That tool encrypted the raw file with AES-256 in OFB mode with a zero
+// IV, without compression or authentication, and either
+//   - wrote the whole ciphertext into every horcrux (threshold < total), or
+//   - dealt it out byte by byte, round-robin in index order (threshold == total).
+//
+// As in JoinPipeline, bodies is keyed by the 0-based horcrux index. Since OFB
+// is not authenticated, a wrong key yields garbage rather than an error.
+func JoinLegacy(bodies map[int][]byte, key []byte, total, threshold int) ([]byte, error) {
+	// 1. Reassemble the ciphertext
+	var cipherText []byte
+	if threshold == total {
+		if len(bodies) < total {
+			return nil, fmt.Errorf("all %d horcruxes are needed, have %d", total, len(bodies))
+		}
+		size := 0
+		for i := 0; i < total; i++ {
+			body, ok := bodies[i]
+			if !ok {
+				return nil, fmt.Errorf("horcrux %d is missing", i+1)
+			}
+			size += len(body)
+		}
+		cipherText = make([]byte, size)
+		for pos := range cipherText {
+			body := bodies[pos%total]
+			if pos/total >= len(body) {
+				return nil, fmt.Errorf("horcrux %d is truncated", pos%total+1)
+			}
+			cipherText[pos] = body[pos/total]
+		}
+	} else {
+		for _, body := range bodies {
+			if cipherText == nil {
+				cipherText = body
+			} else if !bytes.Equal(cipherText, body) {
+				return nil, fmt.Errorf("horcrux bodies differ; they are corrupted or from different splits")
+			}
+		}
+	}
+
+	// 2. Decrypt
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	iv := make([]byte, aes.BlockSize)
+	plainText := make([]byte, len(cipherText))
+	cipher.NewOFB(block, iv).XORKeyStream(plainText, cipherText)
+	return plainText, nil
+}
@@ -1,6 +1,7 @@
 package pipeline
 
 import (
+	"context"
 	"encoding/binary"
 	"fmt"
 	"io"
@@ -14,28 +15,54 @@ import (
 type PipelineConfig struct {
 	Total     int
 	Threshold int
+
+	// Size of the input, if known, for reporting how much is read
+	Size int64
+
+	// Progress receives the progress of every stage (nil for none)
+	Progress Reporter
 }
 
 // SplitPipeline orchestrates the flow: Read -> Compress -> Encrypt -> LengthPrefix -> Shard
 func SplitPipeline(input io.Reader, key []byte, config PipelineConfig) ([]sharding.Shard, error) {
+	return SplitPipelineContext(context.Background(), input, key, config)
+}
+
+// SplitPipelineContext is SplitPipeline, reporting to config.Progress and
+// stopping with ctx.Err() once ctx is done.
+func SplitPipelineContext(ctx context.Context, input io.Reader, key []byte, config PipelineConfig) ([]sharding.Shard, error) {
+	progress := config.Progress
+	if progress == nil {
+		progress = Discard
+	}
+
 	// 1. Read Input
-	plainBytes, err := io.ReadAll(input)
+	plainBytes, err := io.ReadAll(&progressReader{ctx: ctx, r: input, progress: progress, total: config.Size})
 	if err != nil {
 		return nil, fmt.Errorf("failed to read input: %w", err)
 	}
 
 	// 2. Compress
+	done, err := begin(ctx, progress, StageCompress, len(plainBytes))
+	if err != nil {
+		return nil, err
+	}
 	compressor := compression.NewGzipCompressor()
 	compressedBytes, err := compressor.Compress(plainBytes)
 	if err != nil {
 		return nil, fmt.Errorf("compression failed: %w", err)
 	}
+	done()
 
 	// 3. Encrypt (Authenticated AES-GCM)
+	if done, err = begin(ctx, progress, StageEncrypt, len(compressedBytes)); err != nil {
+		return nil, err
+	}
 	cipherText, err := encryptor.Encrypt(compressedBytes, key)
 	if err != nil {
 		return nil, fmt.Errorf("encryption failed: %w", err)
 	}
+	done()
 
 	// 4. Prepend Length (8 bytes)
 	// We must store the exact length of the ciphertext to strip padding after reconstruction.
@@ -46,6 +73,9 @@ func SplitPipeline(input io.Reader, key []byte, config PipelineConfig) ([]shardi
 	payload := append(lengthBuf, cipherText...)
 
 	// 5. Shard (Reed-Solomon)
+	if done, err = begin(ctx, progress, StageShard, len(payload)); err != nil {
+		return nil, err
+	}
 	splitter, err := sharding.NewSplitter(config.Total, config.Threshold)
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize splitter: %w", err)
@@ -55,6 +85,7 @@ func SplitPipeline(input io.Reader, key []byte, config PipelineConfig) ([]shardi
 	if err != nil {
 		return nil, fmt.Errorf("sharding failed: %w", err)
 	}
+	done()
 
 	// Flatten the slice of slice of shards
 	flatShards := make([]sharding.Shard, len(shards))
@@ -67,7 +98,25 @@ func SplitPipeline(input io.Reader, key []byte, config PipelineConfig) ([]shardi
 
 // JoinPipeline orchestrates the reverse: Unshard -> StripPadding -> Decrypt -> Decompress
 func JoinPipeline(shards map[int][]byte, key []byte, total, threshold int) ([]byte, error) {
+	return JoinPipelineContext(context.Background(), shards, key, total, threshold, nil)
+}
+
+// JoinPipelineContext is JoinPipeline, reporting to progress (nil for none)
+// and stopping with ctx.Err() once ctx is done.
+func JoinPipelineContext(ctx context.Context, shards map[int][]byte, key []byte, total, threshold int, progress Reporter) ([]byte, error) {
+	if progress == nil {
+		progress = Discard
+	}
+
 	// 1. Unshard (Reed-Solomon Join)
+	size := 0
+	for _, shard := range shards {
+		size += len(shard)
+	}
+	done, err := begin(ctx, progress, StageJoin, size)
+	if err != nil {
+		return nil, err
+	}
 	splitter, err := sharding.NewSplitter(total, threshold)
 	if err != nil {
 		return nil, err
@@ -78,6 +127,7 @@ func JoinPipeline(shards map[int][]byte, key []byte, total, threshold int) ([]by
 	if err != nil {
 		return nil, fmt.Errorf("reconstruction failed: %w", err)
 	}
+	done()
 
 	// 2. Strip Padding using Prefix Length
 	if len(joinedBytes) < 8 {
@@ -96,17 +146,26 @@ func JoinPipeline(shards map[int][]byte, key []byte, total, threshold int) ([]by
 	cipherText := joinedBytes[8 : 8+originalLen]
 
 	// 3. Decrypt
+	if done, err = begin(ctx, progress, StageDecrypt, len(cipherText)); err != nil {
+		return nil, err
+	}
 	decryptedBytes, err := encryptor.Decrypt(cipherText, key)
 	if err != nil {
 		return nil, fmt.Errorf("decryption failed (integrity check): %w", err)
 	}
 
+	done()
+
 	// 4. Decompress
+	if done, err = begin(ctx, progress, StageDecompress, len(decryptedBytes)); err != nil {
+		return nil, err
+	}
 	compressor := compression.NewGzipCompressor()
 	plainBytes, err := compressor.Decompress(decryptedBytes)
 	if err != nil {
 		return nil, fmt.Errorf("decompression failed: %w", err)
 	}
+	done()
 
 	return plainBytes, nil
 }
\ No newline at end of file
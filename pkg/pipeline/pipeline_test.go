@@ -2,7 +2,12 @@ package pipeline
 
 import (
 	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
 	"crypto/rand"
+	"errors"
+	"slices"
 	"testing"
 )
 
@@ -64,4 +69,139 @@ func TestPipelineRoundTrip(t *testing.T) {
 	if !bytes.Equal(originalData, restoredData) {
 		t.Fatal("Pipeline Round-Trip failed: Data mismatch")
 	}
-}
\ No newline at end of file
+}
+
+// legacySplit makes bodies the way the original jesseduffield/horcrux tool did.
+func legacySplit(t *testing.T, data, key []byte, total, threshold int) map[int][]byte {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cipherText := make([]byte, len(data))
+	cipher.NewOFB(block, make([]byte, aes.BlockSize)).XORKeyStream(cipherText, data)
+
+	bodies := make(map[int][]byte)
+	for i := 0; i < total; i++ {
+		if threshold < total {
+			bodies[i] = cipherText
+			continue
+		}
+		for pos := i; pos < len(cipherText); pos += total {
+			bodies[i] = append(bodies[i], cipherText[pos])
+		}
+	}
+	return bodies
+}
+
+func TestJoinLegacy(t *testing.T) {
+	key := make([]byte, 32)
+	rand.Read(key)
+	data := []byte("Tom Riddle's diary, page 1 of 200")
+
+	for _, scheme := range [][2]int{{4, 2}, {3, 3}, {5, 5}} {
+		total, threshold := scheme[0], scheme[1]
+		bodies := legacySplit(t, data, key, total, threshold)
+
+		if threshold < total {
+			delete(bodies, 0)
+		}
+		got, err := JoinLegacy(bodies, key, total, threshold)
+		if err != nil {
+			t.Fatalf("%d of %d: JoinLegacy failed: %v", threshold, total, err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("%d of %d: got %q", threshold, total, got)
+		}
+	}
+
+	// Round-robin splits need every horcrux
+	bodies := legacySplit(t, data, key, 3, 3)
+	delete(bodies, 1)
+	if _, err := JoinLegacy(bodies, key, 3, 3); err == nil {
+		t.Error("expected an error with a missing horcrux")
+	}
+}
+
+func TestPipelineProgress(t *testing.T) {
+	key := make([]byte, 32)
+	rand.Read(key)
+	data := bytes.Repeat([]byte("Nagini "), 400000) // Over 2 MiB, read in several steps
+
+	// 1. Stages are reported in order, each finishing before the next
+	var events []Event
+	record := ReporterFunc(func(e Event) { events = append(events, e) })
+	shards, err := SplitPipelineContext(context.Background(), bytes.NewReader(data), key, PipelineConfig{
+		Total: 3, Threshold: 2, Size: int64(len(data)), Progress: record,
+	})
+	if err != nil {
+		t.Fatalf("SplitPipelineContext failed: %v", err)
+	}
+	var stages []Stage
+	for _, e := range events {
+		if e.Done > e.Total {
+			t.Errorf("%s: done %d of %d", e.Stage, e.Done, e.Total)
+		}
+		if len(stages) == 0 || stages[len(stages)-1] != e.Stage {
+			stages = append(stages, e.Stage)
+		}
+	}
+	if want := []Stage{StageRead, StageCompress, StageEncrypt, StageShard}; !slices.Equal(stages, want) {
+		t.Errorf("split stages %v, want %v", stages, want)
+	}
+	if last := events[len(events)-1]; last.Done != last.Total {
+		t.Errorf("last split event %+v is not finished", last)
+	}
+	reads := 0
+	for _, e := range events {
+		if e.Stage == StageRead {
+			reads++
+			if e.Total != int64(len(data)) {
+				t.Errorf("read total %d, want %d", e.Total, len(data))
+			}
+		}
+	}
+	if reads < 2 {
+		t.Errorf("got %d read events, want several", reads)
+	}
+
+	events = nil
+	bodies := map[int][]byte{0: shards[0].Data, 2: shards[2].Data}
+	got, err := JoinPipelineContext(context.Background(), bodies, key, 3, 2, record)
+	if err != nil {
+		t.Fatalf("JoinPipelineContext failed: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatal("data mismatch")
+	}
+	stages = nil
+	for _, e := range events {
+		if len(stages) == 0 || stages[len(stages)-1] != e.Stage {
+			stages = append(stages, e.Stage)
+		}
+	}
+	if want := []Stage{StageJoin, StageDecrypt, StageDecompress}; !slices.Equal(stages, want) {
+		t.Errorf("join stages %v, want %v", stages, want)
+	}
+
+	// 2. A cancelled context stops both
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := SplitPipelineContext(ctx, bytes.NewReader(data), key, PipelineConfig{Total: 3, Threshold: 2}); !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled split: got %v", err)
+	}
+	if _, err := JoinPipelineContext(ctx, bodies, key, 3, 2, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled join: got %v", err)
+	}
+
+	// 3. Cancelling midway stops at the next stage
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	stop := ReporterFunc(func(e Event) {
+		if e.Stage == StageEncrypt {
+			cancel()
+		}
+	})
+	if _, err := SplitPipelineContext(ctx, bytes.NewReader(data), key, PipelineConfig{Total: 3, Threshold: 2, Progress: stop}); !errors.Is(err, context.Canceled) {
+		t.Errorf("split cancelled while encrypting: got %v", err)
+	}
+}
@@ -0,0 +1,88 @@
+package pipeline
+
+import (
+	"context"
+	"io"
+)
+
+// Stage is a step of a split or bind whose progress is reported.
+type Stage string
+
+const (
+	// Splitting
+	StageRead     Stage = "read"
+	StageCompress Stage = "compress"
+	StageEncrypt  Stage = "encrypt"
+	StageShard    Stage = "shard"
+
+	// Binding
+	StageExtract    Stage = "extract"
+	StageJoin       Stage = "join"
+	StageDecrypt    Stage = "decrypt"
+	StageDecompress Stage = "decompress"
+
+	// Both
+	StageWrite Stage = "write"
+)
+
+// Event reports that Done of Total units of a stage are finished: bytes in
+// the pipeline, files when extracting and writing. Total is 0 when unknown.
+type Event struct {
+	Stage       Stage
+	Done, Total int64
+}
+
+// Reporter receives the progress of a split or bind. Report is called on the
+// goroutine doing the work, so it should return quickly.
+type Reporter interface {
+	Report(Event)
+}
+
+// ReporterFunc lets an ordinary function be a Reporter.
+type ReporterFunc func(Event)
+
+func (f ReporterFunc) Report(e Event) {
+	f(e)
+}
+
+// Discard is a Reporter that ignores every event.
+var Discard Reporter = ReporterFunc(func(Event) {})
+
+// begin reports the start of a stage over size bytes, unless ctx is done,
+// and returns the function reporting its end.
+func begin(ctx context.Context, progress Reporter, stage Stage, size int) (func(), error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	progress.Report(Event{Stage: stage, Total: int64(size)})
+	return func() {
+		progress.Report(Event{Stage: stage, Done: int64(size), Total: int64(size)})
+	}, nil
+}
+
+// reportStep is how many bytes are read between two events.
+const reportStep = 1 << 20
+
+// progressReader reports the bytes read through it, and fails once its
+// context is done.
+type progressReader struct {
+	ctx      context.Context
+	r        io.Reader
+	progress Reporter
+	done     int64
+	total    int64
+	reported int64
+}
+
+func (pr *progressReader) Read(p []byte) (int, error) {
+	if err := pr.ctx.Err(); err != nil {
+		return 0, err
+	}
+	n, err := pr.r.Read(p)
+	pr.done += int64(n)
+	if pr.done-pr.reported >= reportStep || (err == io.EOF && pr.done > pr.reported) {
+		pr.reported = pr.done
+		pr.progress.Report(Event{Stage: StageRead, Done: pr.done, Total: max(pr.total, pr.done)})
+	}
+	return n, err
+}
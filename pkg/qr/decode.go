@@ -0,0 +1,238 @@
+package qr
+
+import (
+	"errors"
+	"fmt"
+	"math/bits"
+)
+
+// ErrUnreadable indicates a sampled symbol could not be decoded.
+var ErrUnreadable = errors.New("unreadable QR code")
+
+// decodeModules decodes a symbol whose modules have already been sampled.
+// black reports whether the module at column x, row y is dark.
+func decodeModules(size int, black func(x, y int) bool) ([]byte, error) {
+	if size < symbolSize(minVersion) || size > symbolSize(maxVersion) || (size-17)%4 != 0 {
+		return nil, fmt.Errorf("%w: invalid size %d", ErrUnreadable, size)
+	}
+	version := (size - 17) / 4
+
+	// 1. Version information (v7+) must agree with the sampled size
+	if version >= 7 {
+		if v, ok := readVersion(size, black); !ok || v != version {
+			return nil, fmt.Errorf("%w: version information does not match size", ErrUnreadable)
+		}
+	}
+
+	// 2. Format information: level and mask
+	level, mask, ok := readFormat(size, black)
+	if !ok {
+		return nil, fmt.Errorf("%w: bad format information", ErrUnreadable)
+	}
+
+	// 3. Read the codewords from the data modules, removing the mask
+	c := newCode(version, level)
+	rawCodewords := make([]byte, numRawDataModules(version)/8)
+	i := 0
+	c.forEachDataModule(func(x, y int) {
+		if i < len(rawCodewords)*8 {
+			if black(x, y) != maskBit(mask, x, y) {
+				rawCodewords[i>>3] |= 1 << (7 - (i & 7))
+			}
+			i++
+		}
+	})
+
+	// 4. De-interleave and error-correct each block
+	bl := layoutFor(version, level)
+	blocks := make([][]byte, bl.numBlocks)
+	for b := range blocks {
+		blocks[b] = make([]byte, 0, bl.dataLen(b)+bl.eccLen)
+	}
+	pos := 0
+	for k := 0; k <= bl.shortBlockLen-bl.eccLen; k++ {
+		for b := range blocks {
+			if k < bl.dataLen(b) {
+				blocks[b] = append(blocks[b], rawCodewords[pos])
+				pos++
+			}
+		}
+	}
+	for k := 0; k < bl.eccLen; k++ {
+		for b := range blocks {
+			blocks[b] = append(blocks[b], rawCodewords[pos])
+			pos++
+		}
+	}
+
+	var data []byte
+	for b, block := range blocks {
+		if _, err := rsCorrect(block, bl.eccLen); err != nil {
+			return nil, fmt.Errorf("%w: block %d: %v", ErrUnreadable, b, err)
+		}
+		data = append(data, block[:bl.dataLen(b)]...)
+	}
+
+	// 5. Parse the segments
+	return parseSegments(data, version)
+}
+
+// readFormat reads both copies of the format information and returns the
+// closest valid level and mask.
+func readFormat(size int, black func(x, y int) bool) (Level, int, bool) {
+	var copy1, copy2 int
+	for i := 0; i < 15; i++ {
+		x1, y1, x2, y2 := formatPositions(size, i)
+		if black(x1, y1) {
+			copy1 |= 1 << i
+		}
+		if black(x2, y2) {
+			copy2 |= 1 << i
+		}
+	}
+
+	bestDist := 16
+	var bestLevel Level
+	var bestMask int
+	for level := L; level <= H; level++ {
+		for mask := 0; mask < 8; mask++ {
+			want := formatInfo(level, mask)
+			d := min(bits.OnesCount(uint(copy1^want)), bits.OnesCount(uint(copy2^want)))
+			if d < bestDist {
+				bestDist, bestLevel, bestMask = d, level, mask
+			}
+		}
+	}
+	return bestLevel, bestMask, bestDist <= 3
+}
+
+// readVersion reads both copies of the version information.
+func readVersion(size int, black func(x, y int) bool) (int, bool) {
+	var copy1, copy2 int
+	for i := 0; i < 18; i++ {
+		a, b := size-11+i%3, i/3
+		if black(a, b) {
+			copy1 |= 1 << i
+		}
+		if black(b, a) {
+			copy2 |= 1 << i
+		}
+	}
+
+	bestDist, bestVersion := 19, 0
+	for v := 7; v <= maxVersion; v++ {
+		want := versionInfo(v)
+		d := min(bits.OnesCount(uint(copy1^want)), bits.OnesCount(uint(copy2^want)))
+		if d < bestDist {
+			bestDist, bestVersion = d, v
+		}
+	}
+	return bestVersion, bestDist <= 3
+}
+
+// bitReader reads big-endian bit fields from a byte slice.
+type bitReader struct {
+	data []byte
+	pos  int
+}
+
+func (br *bitReader) available() int {
+	return len(br.data)*8 - br.pos
+}
+
+func (br *bitReader) read(n int) int {
+	v := 0
+	for i := 0; i < n; i++ {
+		bit := (br.data[br.pos>>3] >> (7 - (br.pos & 7))) & 1
+		v = v<<1 | int(bit)
+		br.pos++
+	}
+	return v
+}
+
+// parseSegments decodes numeric, alphanumeric and byte segments. ECI
+// designators are skipped; the payload is returned as raw bytes.
+func parseSegments(data []byte, version int) ([]byte, error) {
+	br := &bitReader{data: data}
+	var out []byte
+
+	for br.available() >= 4 {
+		mode := br.read(4)
+		if mode == modeTerminator {
+			break
+		}
+
+		if mode == modeECI {
+			if br.available() < 8 {
+				return nil, fmt.Errorf("%w: truncated ECI", ErrUnreadable)
+			}
+			// 1, 2 or 3 byte designator, signalled by the leading bits
+			first := br.read(8)
+			switch {
+			case first&0x80 == 0:
+			case first&0xC0 == 0x80:
+				br.read(8)
+			default:
+				br.read(16)
+			}
+			continue
+		}
+
+		countBits := charCountBits(mode, version)
+		if countBits == 0 {
+			return nil, fmt.Errorf("%w: unsupported mode %d", ErrUnreadable, mode)
+		}
+		if br.available() < countBits {
+			return nil, fmt.Errorf("%w: truncated segment", ErrUnreadable)
+		}
+		count := br.read(countBits)
+
+		switch mode {
+		case modeByte:
+			if br.available() < count*8 {
+				return nil, fmt.Errorf("%w: truncated byte segment", ErrUnreadable)
+			}
+			for i := 0; i < count; i++ {
+				out = append(out, byte(br.read(8)))
+			}
+
+		case modeNumeric:
+			for count > 0 {
+				digits := min(count, 3)
+				width := []int{0, 4, 7, 10}[digits]
+				if br.available() < width {
+					return nil, fmt.Errorf("%w: truncated numeric segment", ErrUnreadable)
+				}
+				out = append(out, fmt.Sprintf("%0*d", digits, br.read(width))...)
+				count -= digits
+			}
+
+		case modeAlphanumeric:
+			for count > 0 {
+				if count >= 2 {
+					if br.available() < 11 {
+						return nil, fmt.Errorf("%w: truncated alphanumeric segment", ErrUnreadable)
+					}
+					v := br.read(11)
+					if v/45 >= 45 {
+						return nil, fmt.Errorf("%w: invalid alphanumeric value", ErrUnreadable)
+					}
+					out = append(out, alphanumericCharset[v/45], alphanumericCharset[v%45])
+					count -= 2
+				} else {
+					if br.available() < 6 {
+						return nil, fmt.Errorf("%w: truncated alphanumeric segment", ErrUnreadable)
+					}
+					v := br.read(6)
+					if v >= 45 {
+						return nil, fmt.Errorf("%w: invalid alphanumeric value", ErrUnreadable)
+					}
+					out = append(out, alphanumericCharset[v])
+					count--
+				}
+			}
+		}
+	}
+
+	return out, nil
+}
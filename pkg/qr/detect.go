@@ -0,0 +1,671 @@
+package qr
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"math"
+	"sort"
+)
+
+// ErrNotFound indicates no decodable QR symbol was found in an image.
+var ErrNotFound = errors.New("no QR code found")
+
+// Decode locates every QR symbol in img and returns their payloads, ordered
+// top to bottom and then left to right. It copes with scanned or photographed
+// pages: uneven lighting, moderate rotation and perspective distortion.
+func Decode(img image.Image) ([][]byte, error) {
+	bm := binarize(img)
+	finders := findFinderPatterns(bm)
+
+	type result struct {
+		x, y float64
+		data []byte
+	}
+	var results []result
+	used := make([]bool, len(finders))
+
+	for _, t := range groupFinderPatterns(finders) {
+		if used[t.tl] || used[t.tr] || used[t.bl] {
+			continue
+		}
+		tl, tr, bl := finders[t.tl], finders[t.tr], finders[t.bl]
+		data, err := decodeAt(bm, tl, tr, bl)
+		if err != nil {
+			continue
+		}
+		used[t.tl], used[t.tr], used[t.bl] = true, true, true
+		results = append(results, result{x: tl.x, y: tl.y, data: data})
+	}
+
+	if len(results) == 0 {
+		return nil, ErrNotFound
+	}
+
+	// Reading order; symbols whose top-left corners are within a few modules
+	// vertically count as the same row.
+	sort.SliceStable(results, func(i, j int) bool {
+		if math.Abs(results[i].y-results[j].y) > 20 {
+			return results[i].y < results[j].y
+		}
+		return results[i].x < results[j].x
+	})
+
+	payloads := make([][]byte, len(results))
+	for i, r := range results {
+		payloads[i] = r.data
+	}
+	return payloads, nil
+}
+
+// bitmap is a binarized image: true means dark.
+type bitmap struct {
+	width, height int
+	dark          []bool
+}
+
+func (bm *bitmap) at(x, y int) bool {
+	if x < 0 || y < 0 || x >= bm.width || y >= bm.height {
+		return false
+	}
+	return bm.dark[y*bm.width+x]
+}
+
+// binarize converts an image to black and white using a local threshold
+// computed over 8x8 pixel blocks, so that shadows and uneven lighting across
+// a photographed page do not wipe out parts of a symbol.
+func binarize(img image.Image) *bitmap {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	lum := make([]uint8, w*h)
+
+	if gray, ok := img.(*image.Gray); ok {
+		for y := 0; y < h; y++ {
+			copy(lum[y*w:(y+1)*w], gray.Pix[y*gray.Stride:y*gray.Stride+w])
+		}
+	} else {
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				lum[y*w+x] = color.GrayModel.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.Gray).Y
+			}
+		}
+	}
+
+	bm := &bitmap{width: w, height: h, dark: make([]bool, w*h)}
+
+	const blockSize = 8
+	const minDynamicRange = 24
+
+	subW := (w + blockSize - 1) / blockSize
+	subH := (h + blockSize - 1) / blockSize
+	if subW < 5 || subH < 5 {
+		// Too small for local thresholds; use the global mean instead
+		sum := 0
+		for _, v := range lum {
+			sum += int(v)
+		}
+		threshold := uint8(sum / max(len(lum), 1))
+		for i, v := range lum {
+			bm.dark[i] = v < threshold
+		}
+		return bm
+	}
+
+	// 1. Per-block black point
+	blackPoints := make([]int, subW*subH)
+	for by := 0; by < subH; by++ {
+		y0 := min(by*blockSize, h-blockSize)
+		for bx := 0; bx < subW; bx++ {
+			x0 := min(bx*blockSize, w-blockSize)
+			sum, lo, hi := 0, 255, 0
+			for y := y0; y < y0+blockSize; y++ {
+				for x := x0; x < x0+blockSize; x++ {
+					v := int(lum[y*w+x])
+					sum += v
+					lo = min(lo, v)
+					hi = max(hi, v)
+				}
+			}
+
+			average := sum / (blockSize * blockSize)
+			if hi-lo <= minDynamicRange {
+				// A flat block is assumed to be background, unless its
+				// neighbours say it sits inside a dark area.
+				average = lo / 2
+				if by > 0 && bx > 0 {
+					neighbours := (blackPoints[(by-1)*subW+bx] + 2*blackPoints[by*subW+bx-1] +
+						blackPoints[(by-1)*subW+bx-1]) / 4
+					if lo < neighbours {
+						average = neighbours
+					}
+				}
+			}
+			blackPoints[by*subW+bx] = average
+		}
+	}
+
+	// 2. Threshold each block by the mean of its 5x5 neighbourhood
+	for by := 0; by < subH; by++ {
+		y0 := min(by*blockSize, h-blockSize)
+		cy := min(max(by, 2), subH-3)
+		for bx := 0; bx < subW; bx++ {
+			x0 := min(bx*blockSize, w-blockSize)
+			cx := min(max(bx, 2), subW-3)
+
+			sum := 0
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					sum += blackPoints[(cy+dy)*subW+cx+dx]
+				}
+			}
+			threshold := sum / 25
+
+			for y := y0; y < y0+blockSize; y++ {
+				for x := x0; x < x0+blockSize; x++ {
+					bm.dark[y*w+x] = int(lum[y*w+x]) <= threshold
+				}
+			}
+		}
+	}
+
+	return bm
+}
+
+// finderPattern is a candidate finder pattern centre.
+type finderPattern struct {
+	x, y       float64
+	moduleSize float64
+	count      int // number of scan lines that confirmed it
+}
+
+// foundPatternCross reports whether five run lengths match the 1:1:3:1:1
+// ratio of a finder pattern.
+func foundPatternCross(counts [5]int) bool {
+	total := 0
+	for _, c := range counts {
+		if c == 0 {
+			return false
+		}
+		total += c
+	}
+	if total < 7 {
+		return false
+	}
+	moduleSize := float64(total) / 7
+	maxVariance := moduleSize / 1.8
+	return math.Abs(moduleSize-float64(counts[0])) < maxVariance &&
+		math.Abs(moduleSize-float64(counts[1])) < maxVariance &&
+		math.Abs(3*moduleSize-float64(counts[2])) < 3*maxVariance &&
+		math.Abs(moduleSize-float64(counts[3])) < maxVariance &&
+		math.Abs(moduleSize-float64(counts[4])) < maxVariance
+}
+
+func findFinderPatterns(bm *bitmap) []finderPattern {
+	var candidates []finderPattern
+
+	for y := 0; y < bm.height; y++ {
+		var counts [5]int
+		state := 0
+		for x := 0; x <= bm.width; x++ {
+			dark := x < bm.width && bm.at(x, y)
+			if dark {
+				if state&1 == 1 {
+					state++
+				}
+				counts[state]++
+				continue
+			}
+
+			if state&1 == 1 {
+				counts[state]++
+				continue
+			}
+			if state != 4 {
+				state++
+				counts[state]++
+				continue
+			}
+
+			// A full dark-light-dark-light-dark sequence just ended
+			if foundPatternCross(counts) {
+				if fp, ok := checkFinderCenter(bm, counts, x, y); ok {
+					candidates = mergeCandidate(candidates, fp)
+					counts = [5]int{}
+					state = 0
+					continue
+				}
+			}
+			counts = [5]int{counts[2], counts[3], counts[4], 1, 0}
+			state = 3
+		}
+	}
+
+	// Keep patterns confirmed by several scan lines; tiny symbols may only
+	// be hit once, so fall back to everything if that leaves too few.
+	var confirmed []finderPattern
+	for _, c := range candidates {
+		if c.count >= 2 {
+			confirmed = append(confirmed, c)
+		}
+	}
+	if len(confirmed) < 3 {
+		return candidates
+	}
+	return confirmed
+}
+
+func mergeCandidate(candidates []finderPattern, fp finderPattern) []finderPattern {
+	for i, c := range candidates {
+		if math.Abs(c.x-fp.x) <= c.moduleSize && math.Abs(c.y-fp.y) <= c.moduleSize &&
+			math.Abs(c.moduleSize-fp.moduleSize) <= math.Max(1, c.moduleSize/2) {
+			n := float64(c.count)
+			candidates[i] = finderPattern{
+				x:          (c.x*n + fp.x) / (n + 1),
+				y:          (c.y*n + fp.y) / (n + 1),
+				moduleSize: (c.moduleSize*n + fp.moduleSize) / (n + 1),
+				count:      c.count + 1,
+			}
+			return candidates
+		}
+	}
+	return append(candidates, fp)
+}
+
+// checkFinderCenter cross-checks a horizontal finder match vertically and
+// then horizontally again, returning the refined centre.
+func checkFinderCenter(bm *bitmap, counts [5]int, endX, y int) (finderPattern, bool) {
+	total := 0
+	for _, c := range counts {
+		total += c
+	}
+	centerX := float64(endX-counts[4]-counts[3]) - float64(counts[2])/2
+
+	centerY, ok := crossCheck(bm, int(centerX), y, 0, 1, counts[2], total)
+	if !ok {
+		return finderPattern{}, false
+	}
+	centerX, ok = crossCheck(bm, int(centerX), int(centerY), 1, 0, counts[2], total)
+	if !ok {
+		return finderPattern{}, false
+	}
+
+	return finderPattern{x: centerX, y: centerY, moduleSize: float64(total) / 7, count: 1}, true
+}
+
+// crossCheck walks through (x, y) along the direction (dx, dy), measures the
+// five runs of a finder pattern and returns the centre coordinate along that
+// direction.
+func crossCheck(bm *bitmap, x, y, dx, dy, maxCount, originalTotal int) (float64, bool) {
+	if !bm.at(x, y) {
+		return 0, false
+	}
+	var counts [5]int
+
+	// Walk backwards from the centre
+	i := 0
+	for bm.at(x-i*dx, y-i*dy) {
+		counts[2]++
+		i++
+	}
+	inBounds := func(k int) bool {
+		px, py := x+k*dx, y+k*dy
+		return px >= 0 && py >= 0 && px < bm.width && py < bm.height
+	}
+	for inBounds(-i) && !bm.at(x-i*dx, y-i*dy) && counts[1] <= maxCount {
+		counts[1]++
+		i++
+	}
+	if !inBounds(-i) || counts[1] > maxCount {
+		return 0, false
+	}
+	for inBounds(-i) && bm.at(x-i*dx, y-i*dy) && counts[0] <= maxCount {
+		counts[0]++
+		i++
+	}
+	if counts[0] > maxCount {
+		return 0, false
+	}
+
+	// Walk forwards from the centre
+	i = 1
+	for bm.at(x+i*dx, y+i*dy) {
+		counts[2]++
+		i++
+	}
+	for inBounds(i) && !bm.at(x+i*dx, y+i*dy) && counts[3] <= maxCount {
+		counts[3]++
+		i++
+	}
+	if !inBounds(i) || counts[3] > maxCount {
+		return 0, false
+	}
+	for inBounds(i) && bm.at(x+i*dx, y+i*dy) && counts[4] <= maxCount {
+		counts[4]++
+		i++
+	}
+	if counts[4] > maxCount {
+		return 0, false
+	}
+
+	total := 0
+	for _, c := range counts {
+		total += c
+	}
+	if 5*abs(total-originalTotal) >= 2*originalTotal || !foundPatternCross(counts) {
+		return 0, false
+	}
+
+	end := float64(i)
+	pos := float64(x*dx + y*dy)
+	return pos + end - float64(counts[4]+counts[3]) - float64(counts[2])/2, true
+}
+
+// finderTriple indexes the three finder patterns of one candidate symbol.
+type finderTriple struct {
+	tl, tr, bl int
+	score      float64
+}
+
+// groupFinderPatterns returns plausible (top-left, top-right, bottom-left)
+// triples, best first. A triple must form a right isosceles triangle with
+// similar module sizes at each corner.
+func groupFinderPatterns(fps []finderPattern) []finderTriple {
+	if len(fps) > 60 {
+		// Keep the best-confirmed candidates to bound the O(n³) search.
+		// This sorts the caller's slice in place so the indices stay valid.
+		sort.Slice(fps, func(i, j int) bool { return fps[i].count > fps[j].count })
+		fps = fps[:60]
+	}
+
+	dist := func(a, b finderPattern) float64 { return math.Hypot(a.x-b.x, a.y-b.y) }
+
+	var triples []finderTriple
+	for i := 0; i < len(fps); i++ {
+		for j := i + 1; j < len(fps); j++ {
+			for k := j + 1; k < len(fps); k++ {
+				idx := [3]int{i, j, k}
+				a, b, c := fps[i], fps[j], fps[k]
+
+				minMS := math.Min(a.moduleSize, math.Min(b.moduleSize, c.moduleSize))
+				maxMS := math.Max(a.moduleSize, math.Max(b.moduleSize, c.moduleSize))
+				if maxMS > 1.5*minMS {
+					continue
+				}
+
+				// The top-left corner is opposite the longest side
+				d := [3]float64{dist(b, c), dist(a, c), dist(a, b)}
+				corner := 0
+				for n := 1; n < 3; n++ {
+					if d[n] > d[corner] {
+						corner = n
+					}
+				}
+				hyp := d[corner]
+				leg1, leg2 := d[(corner+1)%3], d[(corner+2)%3]
+
+				legError := math.Abs(leg1-leg2) / math.Max(leg1, leg2)
+				hypError := math.Abs(hyp-math.Hypot(leg1, leg2)) / hyp
+				if legError > 0.25 || hypError > 0.15 {
+					continue
+				}
+				// Rows and columns cut rotated finders obliquely, which
+				// inflates their module size by up to √2.
+				moduleSize := (a.moduleSize + b.moduleSize + c.moduleSize) / 3
+				if modules := (leg1 + leg2) / 2 / moduleSize; modules < 12/math.Sqrt2 || modules > 180*math.Sqrt2 {
+					continue
+				}
+
+				tl := idx[corner]
+				p, q := idx[(corner+1)%3], idx[(corner+2)%3]
+				// With y pointing down, top-right × bottom-left is positive
+				cross := (fps[p].x-fps[tl].x)*(fps[q].y-fps[tl].y) - (fps[p].y-fps[tl].y)*(fps[q].x-fps[tl].x)
+				if cross < 0 {
+					p, q = q, p
+				}
+				triples = append(triples, finderTriple{tl: tl, tr: p, bl: q, score: legError + hypError})
+			}
+		}
+	}
+
+	sort.Slice(triples, func(i, j int) bool { return triples[i].score < triples[j].score })
+	return triples
+}
+
+// decodeAt samples and decodes the symbol anchored at three finder patterns.
+func decodeAt(bm *bitmap, tl, tr, bl finderPattern) ([]byte, error) {
+	moduleSize := axisModuleSize(bm, tl, tr, bl)
+	span := (math.Hypot(tr.x-tl.x, tr.y-tl.y) + math.Hypot(bl.x-tl.x, bl.y-tl.y)) / 2
+	estimate := int(math.Round(span/moduleSize)) + 7
+	estimate = 17 + 4*int(math.Round(float64(estimate-17)/4))
+
+	var lastErr error = ErrUnreadable
+	for _, size := range []int{estimate, estimate - 4, estimate + 4, estimate - 8, estimate + 8} {
+		if size < symbolSize(minVersion) || size > symbolSize(maxVersion) {
+			continue
+		}
+
+		for _, useAlignment := range []bool{true, false} {
+			transform, ok := symbolTransform(bm, tl, tr, bl, size, moduleSize, useAlignment)
+			if !ok {
+				continue
+			}
+			data, err := decodeModules(size, func(x, y int) bool {
+				px, py := transform.apply(float64(x)+0.5, float64(y)+0.5)
+				return bm.at(int(math.Floor(px)), int(math.Floor(py)))
+			})
+			if err == nil {
+				return data, nil
+			}
+			lastErr = err
+			if size < symbolSize(2) {
+				break // version 1 has no alignment pattern
+			}
+		}
+	}
+	return nil, lastErr
+}
+
+// axisModuleSize measures the module size along the symbol's own axes by
+// walking from each finder centre towards its neighbours, which stays
+// accurate when the symbol is rotated. It falls back to the row-based
+// estimate from the finder search.
+func axisModuleSize(bm *bitmap, tl, tr, bl finderPattern) float64 {
+	var sum float64
+	n := 0
+	for _, pair := range [][2]finderPattern{{tl, tr}, {tr, tl}, {tl, bl}, {bl, tl}} {
+		if size, ok := finderWidth(bm, pair[0], pair[1]); ok {
+			sum += size
+			n++
+		}
+	}
+	if n == 0 {
+		return (tl.moduleSize + tr.moduleSize + bl.moduleSize) / 3
+	}
+	return sum / float64(n)
+}
+
+// finderWidth walks from the centre of fp towards target and back through the
+// dark-light-dark rings of the finder, returning the measured module size.
+// Each direction covers 3.5 modules.
+func finderWidth(bm *bitmap, fp, target finderPattern) (float64, bool) {
+	dx, dy := target.x-fp.x, target.y-fp.y
+	length := math.Hypot(dx, dy)
+	dx, dy = dx/length, dy/length
+
+	limit := int(fp.moduleSize * 8)
+	total := 0.0
+	for _, sign := range []float64{1, -1} {
+		state := 0 // 0: centre dark, 1: light ring, 2: outer dark ring
+		step := 0
+		for ; step < limit; step++ {
+			dark := bm.at(int(math.Floor(fp.x+sign*dx*float64(step))), int(math.Floor(fp.y+sign*dy*float64(step))))
+			if dark == (state%2 == 1) {
+				state++
+				if state == 3 {
+					break
+				}
+			}
+		}
+		if state != 3 {
+			return 0, false
+		}
+		total += float64(step)
+	}
+	return total / 7, true
+}
+
+// homography maps module coordinates to image coordinates.
+type homography [8]float64
+
+func (h homography) apply(u, v float64) (float64, float64) {
+	den := h[6]*u + h[7]*v + 1
+	return (h[0]*u + h[1]*v + h[2]) / den, (h[3]*u + h[4]*v + h[5]) / den
+}
+
+// symbolTransform builds the module-to-image mapping for a symbol of the
+// given size. With useAlignment it locates the bottom-right alignment pattern
+// to correct for perspective; otherwise it assumes an affine mapping.
+func symbolTransform(bm *bitmap, tl, tr, bl finderPattern, size int, moduleSize float64, useAlignment bool) (homography, bool) {
+	s := float64(size)
+	src := [][2]float64{{3.5, 3.5}, {s - 3.5, 3.5}, {3.5, s - 3.5}}
+	dst := [][2]float64{{tl.x, tl.y}, {tr.x, tr.y}, {bl.x, bl.y}}
+
+	// Parallelogram estimate for the fourth corner
+	brX, brY := tr.x+bl.x-tl.x, tr.y+bl.y-tl.y
+
+	if useAlignment && size >= symbolSize(2) {
+		// The bottom-right alignment pattern centre sits 3 modules further in
+		frac := (s - 10) / (s - 7)
+		estX := tl.x + frac*(brX-tl.x)
+		estY := tl.y + frac*(brY-tl.y)
+		ax, ay, ok := findAlignmentPattern(bm, estX, estY, moduleSize)
+		if !ok {
+			return homography{}, false
+		}
+		src = append(src, [2]float64{s - 6.5, s - 6.5})
+		dst = append(dst, [2]float64{ax, ay})
+	} else {
+		src = append(src, [2]float64{s - 3.5, s - 3.5})
+		dst = append(dst, [2]float64{brX, brY})
+	}
+
+	return solveHomography(src, dst)
+}
+
+// findAlignmentPattern searches around an estimated position for the dark
+// centre module of an alignment pattern (a 1:1:1 light-dark-light run in both
+// directions) and returns the candidate closest to the estimate.
+func findAlignmentPattern(bm *bitmap, estX, estY, moduleSize float64) (float64, float64, bool) {
+	for _, allowance := range []float64{4, 8, 16} {
+		radius := int(allowance * moduleSize)
+		x0, x1 := max(int(estX)-radius, 0), min(int(estX)+radius, bm.width-1)
+		y0, y1 := max(int(estY)-radius, 0), min(int(estY)+radius, bm.height-1)
+
+		bestDist := math.Inf(1)
+		var bestX, bestY float64
+		for y := y0; y <= y1; y++ {
+			for x := x0; x <= x1; x++ {
+				// Start of a dark run
+				if !bm.at(x, y) || bm.at(x-1, y) {
+					continue
+				}
+				cx, ok := alignmentRun(bm, x, y, 1, 0, moduleSize)
+				if !ok {
+					continue
+				}
+				cy, ok := alignmentCenter(bm, int(cx), y, 0, 1, moduleSize)
+				if !ok {
+					continue
+				}
+				cx, ok = alignmentCenter(bm, int(cx), int(cy), 1, 0, moduleSize)
+				if !ok {
+					continue
+				}
+				if d := math.Hypot(cx-estX, cy-estY); d < bestDist {
+					bestDist, bestX, bestY = d, cx, cy
+				}
+			}
+		}
+		if !math.IsInf(bestDist, 1) {
+			return bestX, bestY, true
+		}
+	}
+	return 0, 0, false
+}
+
+// alignmentRun checks that the dark run starting at (x, y) is about one
+// module long and sits between light runs of about one module.
+func alignmentRun(bm *bitmap, x, y, dx, dy int, moduleSize float64) (float64, bool) {
+	dark := 0
+	for bm.at(x+dark*dx, y+dark*dy) {
+		dark++
+	}
+	before := 0
+	for before < int(2*moduleSize) && !bm.at(x-(before+1)*dx, y-(before+1)*dy) {
+		before++
+	}
+	after := 0
+	for after < int(2*moduleSize) && !bm.at(x+(dark+after)*dx, y+(dark+after)*dy) {
+		after++
+	}
+	if !closeTo(dark, moduleSize) || !closeTo(before, moduleSize) || !closeTo(after, moduleSize) {
+		return 0, false
+	}
+	return float64(x*dx+y*dy) + float64(dark)/2, true
+}
+
+// alignmentCenter measures the dark run through (x, y) along (dx, dy) and
+// validates it with alignmentRun, returning its centre.
+func alignmentCenter(bm *bitmap, x, y, dx, dy int, moduleSize float64) (float64, bool) {
+	if !bm.at(x, y) {
+		return 0, false
+	}
+	for bm.at(x-dx, y-dy) {
+		x, y = x-dx, y-dy
+	}
+	return alignmentRun(bm, x, y, dx, dy, moduleSize)
+}
+
+func closeTo(run int, moduleSize float64) bool {
+	return math.Abs(float64(run)-moduleSize) < moduleSize/2+0.5
+}
+
+// solveHomography computes the projective transform mapping the four src
+// points onto the four dst points by Gaussian elimination.
+func solveHomography(src, dst [][2]float64) (homography, bool) {
+	var m [8][9]float64
+	for i := 0; i < 4; i++ {
+		u, v := src[i][0], src[i][1]
+		x, y := dst[i][0], dst[i][1]
+		m[2*i] = [9]float64{u, v, 1, 0, 0, 0, -u * x, -v * x, x}
+		m[2*i+1] = [9]float64{0, 0, 0, u, v, 1, -u * y, -v * y, y}
+	}
+
+	for col := 0; col < 8; col++ {
+		pivot := col
+		for row := col + 1; row < 8; row++ {
+			if math.Abs(m[row][col]) > math.Abs(m[pivot][col]) {
+				pivot = row
+			}
+		}
+		if math.Abs(m[pivot][col]) < 1e-12 {
+			return homography{}, false
+		}
+		m[col], m[pivot] = m[pivot], m[col]
+		for row := 0; row < 8; row++ {
+			if row == col {
+				continue
+			}
+			f := m[row][col] / m[col][col]
+			for k := col; k < 9; k++ {
+				m[row][k] -= f * m[col][k]
+			}
+		}
+	}
+
+	var h homography
+	for i := 0; i < 8; i++ {
+		h[i] = m[i][8] / m[i][i]
+	}
+	return h, true
+}
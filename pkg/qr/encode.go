@@ -0,0 +1,484 @@
+package qr
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"strings"
+)
+
+// ErrDataTooLong indicates the payload does not fit in any allowed version.
+var ErrDataTooLong = errors.New("data too long for a QR code")
+
+// Code is a QR symbol: a square grid of dark and light modules.
+type Code struct {
+	// Version is the symbol version (1-40).
+	Version int
+
+	// Level is the error correction level.
+	Level Level
+
+	// Size is the number of modules per side.
+	Size int
+
+	modules    []bool // true = dark, row-major
+	isFunction []bool // true = finder/timing/alignment/format/version module
+}
+
+// Black reports whether the module at column x, row y is dark.
+// Coordinates outside the symbol (the quiet zone) are light.
+func (c *Code) Black(x, y int) bool {
+	if x < 0 || y < 0 || x >= c.Size || y >= c.Size {
+		return false
+	}
+	return c.modules[y*c.Size+x]
+}
+
+// MaxBytes returns how many bytes of binary data a symbol of the given
+// version and level can hold.
+func MaxBytes(version int, level Level) int {
+	bits := numDataCodewords(version, level)*8 - 4 - charCountBits(modeByte, version)
+	return bits / 8
+}
+
+// Encode returns the smallest QR symbol (byte mode) holding data at the
+// requested error correction level.
+func Encode(data []byte, level Level) (*Code, error) {
+	return EncodeVersion(data, level, minVersion, maxVersion)
+}
+
+// EncodeVersion is like Encode but restricts the version range, e.g. to keep
+// printed symbols at a size phone cameras and scanners handle well.
+func EncodeVersion(data []byte, level Level, minVer, maxVer int) (*Code, error) {
+	if minVer < minVersion || maxVer > maxVersion || minVer > maxVer {
+		return nil, fmt.Errorf("invalid version range %d-%d", minVer, maxVer)
+	}
+
+	version := 0
+	for v := minVer; v <= maxVer; v++ {
+		if len(data) <= MaxBytes(v, level) {
+			version = v
+			break
+		}
+	}
+	if version == 0 {
+		return nil, fmt.Errorf("%w: %d bytes exceeds %d", ErrDataTooLong, len(data), MaxBytes(maxVer, level))
+	}
+
+	// 1. Build the data bit stream: mode, count, payload, terminator, padding
+	var bb bitBuffer
+	bb.append(modeByte, 4)
+	bb.append(len(data), charCountBits(modeByte, version))
+	for _, b := range data {
+		bb.append(int(b), 8)
+	}
+
+	capacityBits := numDataCodewords(version, level) * 8
+	bb.append(0, min(4, capacityBits-len(bb)))
+	bb.append(0, (8-len(bb)%8)%8)
+	for pad := 0xEC; len(bb) < capacityBits; pad ^= 0xEC ^ 0x11 {
+		bb.append(pad, 8)
+	}
+
+	codewords := make([]byte, len(bb)/8)
+	for i, bit := range bb {
+		if bit {
+			codewords[i/8] |= 1 << (7 - i%8)
+		}
+	}
+
+	// 2. Add error correction and interleave
+	allCodewords := addECCAndInterleave(codewords, version, level)
+
+	// 3. Draw function patterns and data, then pick the best mask
+	c := newCode(version, level)
+	c.drawCodewords(allCodewords)
+
+	bestMask, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		c.applyMask(mask)
+		c.drawFormatBits(mask)
+		penalty := c.penalty()
+		if bestPenalty < 0 || penalty < bestPenalty {
+			bestMask, bestPenalty = mask, penalty
+		}
+		c.applyMask(mask) // XOR again to undo
+	}
+	c.applyMask(bestMask)
+	c.drawFormatBits(bestMask)
+
+	return c, nil
+}
+
+// Image renders the symbol with the given pixels per module and a quiet zone
+// of border modules on every side.
+func (c *Code) Image(scale, border int) *image.Gray {
+	side := (c.Size + 2*border) * scale
+	img := image.NewGray(image.Rect(0, 0, side, side))
+	for y := 0; y < side; y++ {
+		for x := 0; x < side; x++ {
+			v := uint8(255)
+			if c.Black(x/scale-border, y/scale-border) {
+				v = 0
+			}
+			img.Pix[y*img.Stride+x] = v
+		}
+	}
+	return img
+}
+
+// SVG renders the symbol as a standalone SVG element using one path, with a
+// quiet zone of border modules. The viewBox is in module units so the caller
+// can size it freely with width/height attributes or CSS.
+func (c *Code) SVG(border int) string {
+	side := c.Size + 2*border
+
+	var path strings.Builder
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if c.Black(x, y) {
+				fmt.Fprintf(&path, "M%d,%dh1v1h-1z", x+border, y+border)
+			}
+		}
+	}
+
+	return fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" shape-rendering="crispEdges">`+
+		`<rect width="100%%" height="100%%" fill="#fff"/><path d="%s" fill="#000"/></svg>`, side, side, path.String())
+}
+
+// bitBuffer is an append-only sequence of bits.
+type bitBuffer []bool
+
+func (bb *bitBuffer) append(val, length int) {
+	for i := length - 1; i >= 0; i-- {
+		*bb = append(*bb, (val>>i)&1 != 0)
+	}
+}
+
+// blockLayout describes how codewords are split into Reed-Solomon blocks.
+type blockLayout struct {
+	numBlocks      int
+	numShortBlocks int
+	shortBlockLen  int // total codewords (data + ECC) in a short block
+	eccLen         int
+}
+
+func layoutFor(version int, level Level) blockLayout {
+	numBlocks := numErrorCorrectionBlocks[level][version]
+	rawCodewords := numRawDataModules(version) / 8
+	return blockLayout{
+		numBlocks:      numBlocks,
+		numShortBlocks: numBlocks - rawCodewords%numBlocks,
+		shortBlockLen:  rawCodewords / numBlocks,
+		eccLen:         eccCodewordsPerBlock[level][version],
+	}
+}
+
+// dataLen returns the number of data codewords in block i.
+func (bl blockLayout) dataLen(i int) int {
+	n := bl.shortBlockLen - bl.eccLen
+	if i >= bl.numShortBlocks {
+		n++
+	}
+	return n
+}
+
+func addECCAndInterleave(data []byte, version int, level Level) []byte {
+	bl := layoutFor(version, level)
+	generator := rsGenerator(bl.eccLen)
+
+	dataBlocks := make([][]byte, bl.numBlocks)
+	eccBlocks := make([][]byte, bl.numBlocks)
+	offset := 0
+	for i := range dataBlocks {
+		n := bl.dataLen(i)
+		dataBlocks[i] = data[offset : offset+n]
+		eccBlocks[i] = rsEncode(dataBlocks[i], generator)
+		offset += n
+	}
+
+	result := make([]byte, 0, numRawDataModules(version)/8)
+	for i := 0; i <= bl.shortBlockLen-bl.eccLen; i++ {
+		for _, block := range dataBlocks {
+			if i < len(block) {
+				result = append(result, block[i])
+			}
+		}
+	}
+	for i := 0; i < bl.eccLen; i++ {
+		for _, block := range eccBlocks {
+			result = append(result, block[i])
+		}
+	}
+	return result
+}
+
+// newCode returns a symbol with all function patterns drawn and the format
+// area reserved.
+func newCode(version int, level Level) *Code {
+	size := symbolSize(version)
+	c := &Code{
+		Version:    version,
+		Level:      level,
+		Size:       size,
+		modules:    make([]bool, size*size),
+		isFunction: make([]bool, size*size),
+	}
+
+	// Timing patterns
+	for i := 0; i < size; i++ {
+		c.setFunction(6, i, i%2 == 0)
+		c.setFunction(i, 6, i%2 == 0)
+	}
+
+	// Finder patterns with separators
+	c.drawFinder(3, 3)
+	c.drawFinder(size-4, 3)
+	c.drawFinder(3, size-4)
+
+	// Alignment patterns, skipping the three that overlap finders
+	positions := alignmentPositions(version)
+	last := len(positions) - 1
+	for i, y := range positions {
+		for j, x := range positions {
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					c.setFunction(x+dx, y+dy, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+
+	// Reserve the format area (the real bits are drawn after masking)
+	c.drawFormatBits(0)
+
+	// Version information
+	if version >= 7 {
+		bits := versionInfo(version)
+		for i := 0; i < 18; i++ {
+			bit := (bits>>i)&1 != 0
+			a, b := size-11+i%3, i/3
+			c.setFunction(a, b, bit)
+			c.setFunction(b, a, bit)
+		}
+	}
+
+	return c
+}
+
+func (c *Code) setFunction(x, y int, dark bool) {
+	c.modules[y*c.Size+x] = dark
+	c.isFunction[y*c.Size+x] = true
+}
+
+func (c *Code) drawFinder(cx, cy int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			x, y := cx+dx, cy+dy
+			if x < 0 || y < 0 || x >= c.Size || y >= c.Size {
+				continue
+			}
+			dist := max(abs(dx), abs(dy))
+			c.setFunction(x, y, dist != 2 && dist != 4)
+		}
+	}
+}
+
+// formatPositions returns the module coordinates of bit i of both copies of
+// the format information.
+func formatPositions(size, i int) (x1, y1, x2, y2 int) {
+	switch {
+	case i < 6:
+		x1, y1 = 8, i
+	case i < 8:
+		x1, y1 = 8, i+1
+	case i == 8:
+		x1, y1 = 7, 8
+	default:
+		x1, y1 = 14-i, 8
+	}
+	if i < 8 {
+		x2, y2 = size-1-i, 8
+	} else {
+		x2, y2 = 8, size-15+i
+	}
+	return
+}
+
+func (c *Code) drawFormatBits(mask int) {
+	bits := formatInfo(c.Level, mask)
+	for i := 0; i < 15; i++ {
+		bit := (bits>>i)&1 != 0
+		x1, y1, x2, y2 := formatPositions(c.Size, i)
+		c.setFunction(x1, y1, bit)
+		c.setFunction(x2, y2, bit)
+	}
+	c.setFunction(8, c.Size-8, true) // the always-dark module
+}
+
+// forEachDataModule visits the non-function modules in codeword placement
+// order: two-column strips zig-zagging up and down from the right edge.
+func (c *Code) forEachDataModule(fn func(x, y int)) {
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5 // skip the vertical timing pattern
+		}
+		upward := (right+1)&2 == 0
+		for vert := 0; vert < c.Size; vert++ {
+			y := vert
+			if upward {
+				y = c.Size - 1 - vert
+			}
+			for j := 0; j < 2; j++ {
+				x := right - j
+				if !c.isFunction[y*c.Size+x] {
+					fn(x, y)
+				}
+			}
+		}
+	}
+}
+
+func (c *Code) drawCodewords(data []byte) {
+	i := 0
+	c.forEachDataModule(func(x, y int) {
+		// Remainder bits beyond the last codeword stay light
+		if i < len(data)*8 {
+			c.modules[y*c.Size+x] = (data[i>>3]>>(7-(i&7)))&1 != 0
+			i++
+		}
+	})
+}
+
+// maskBit reports whether mask pattern m inverts the module at (x, y).
+func maskBit(m, x, y int) bool {
+	switch m {
+	case 0:
+		return (x+y)%2 == 0
+	case 1:
+		return y%2 == 0
+	case 2:
+		return x%3 == 0
+	case 3:
+		return (x+y)%3 == 0
+	case 4:
+		return (x/3+y/2)%2 == 0
+	case 5:
+		return x*y%2+x*y%3 == 0
+	case 6:
+		return (x*y%2+x*y%3)%2 == 0
+	default:
+		return ((x+y)%2+x*y%3)%2 == 0
+	}
+}
+
+// applyMask XORs the mask pattern onto every data module. Applying the same
+// mask twice restores the original.
+func (c *Code) applyMask(m int) {
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if !c.isFunction[y*c.Size+x] && maskBit(m, x, y) {
+				c.modules[y*c.Size+x] = !c.modules[y*c.Size+x]
+			}
+		}
+	}
+}
+
+// penalty scores the symbol with the four rules of ISO/IEC 18004 section
+// 7.8.3. Lower is better.
+func (c *Code) penalty() int {
+	const n1, n2, n3, n4 = 3, 3, 40, 10
+	size := c.Size
+	result := 0
+
+	// Rule 1: runs of five or more same-coloured modules in a row or column
+	for pass := 0; pass < 2; pass++ {
+		for a := 0; a < size; a++ {
+			run := 0
+			var prev bool
+			for b := 0; b < size; b++ {
+				cur := c.Black(b, a)
+				if pass == 1 {
+					cur = c.Black(a, b)
+				}
+				if b > 0 && cur == prev {
+					run++
+					if run == 5 {
+						result += n1
+					} else if run > 5 {
+						result++
+					}
+				} else {
+					run = 1
+				}
+				prev = cur
+			}
+		}
+	}
+
+	// Rule 2: 2x2 blocks of the same colour
+	for y := 0; y < size-1; y++ {
+		for x := 0; x < size-1; x++ {
+			cur := c.Black(x, y)
+			if cur == c.Black(x+1, y) && cur == c.Black(x, y+1) && cur == c.Black(x+1, y+1) {
+				result += n2
+			}
+		}
+	}
+
+	// Rule 3: finder-like patterns 1011101 with four light modules either side
+	pattern := []bool{true, false, true, true, true, false, true}
+	for pass := 0; pass < 2; pass++ {
+		for a := 0; a < size; a++ {
+			for b := -4; b < size; b++ {
+				at := func(k int) bool {
+					if pass == 0 {
+						return c.Black(k, a)
+					}
+					return c.Black(a, k)
+				}
+				match := true
+				for k, p := range pattern {
+					if at(b+k) != p {
+						match = false
+						break
+					}
+				}
+				if !match {
+					continue
+				}
+				before, after := true, true
+				for k := 1; k <= 4; k++ {
+					before = before && !at(b-k)
+					after = after && !at(b+6+k)
+				}
+				if before || after {
+					result += n3
+				}
+			}
+		}
+	}
+
+	// Rule 4: balance of dark and light modules
+	dark := 0
+	for _, m := range c.modules {
+		if m {
+			dark++
+		}
+	}
+	total := size * size
+	k := (abs(dark*20-total*10)+total-1)/total - 1
+	result += max(k, 0) * n4
+
+	return result
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
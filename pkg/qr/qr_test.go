@@ -0,0 +1,204 @@
+package qr
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/draw"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestCapacity(t *testing.T) {
+	// Published byte-mode capacities (ISO/IEC 18004 table 7)
+	cases := []struct {
+		version int
+		level   Level
+		want    int
+	}{
+		{1, L, 17}, {1, M, 14}, {1, Q, 11}, {1, H, 7},
+		{10, M, 213}, {27, L, 1465}, {40, L, 2953}, {40, H, 1273},
+	}
+	for _, tc := range cases {
+		if got := MaxBytes(tc.version, tc.level); got != tc.want {
+			t.Errorf("MaxBytes(%d, %d) = %d, want %d", tc.version, tc.level, got, tc.want)
+		}
+	}
+}
+
+func TestEncodeDecodeModules(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, level := range []Level{L, M, Q, H} {
+		for _, version := range []int{1, 2, 6, 7, 10, 20, 27, 40} {
+			data := make([]byte, MaxBytes(version, level))
+			rng.Read(data)
+
+			code, err := EncodeVersion(data, level, version, version)
+			if err != nil {
+				t.Fatalf("Encode v%d level %d: %v", version, level, err)
+			}
+
+			got, err := decodeModules(code.Size, code.Black)
+			if err != nil {
+				t.Fatalf("Decode v%d level %d: %v", version, level, err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Fatalf("Round trip mismatch for v%d level %d", version, level)
+			}
+		}
+	}
+}
+
+func TestErrorCorrection(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	for _, eccLen := range []int{7, 10, 16, 22, 30} {
+		data := make([]byte, 40)
+		rng.Read(data)
+		block := append(append([]byte{}, data...), rsEncode(data, rsGenerator(eccLen))...)
+
+		// Corrupt as many codewords as the code can correct
+		for _, pos := range rng.Perm(len(block))[:eccLen/2] {
+			block[pos] ^= byte(rng.Intn(255) + 1)
+		}
+
+		n, err := rsCorrect(block, eccLen)
+		if err != nil {
+			t.Fatalf("eccLen %d: %v", eccLen, err)
+		}
+		if n != eccLen/2 || !bytes.Equal(block[:len(data)], data) {
+			t.Errorf("eccLen %d: corrected %d errors, data intact: %v", eccLen, n, bytes.Equal(block[:len(data)], data))
+		}
+	}
+}
+
+// photograph simulates a photo of a printed page: the symbols are drawn on a
+// larger canvas through a perspective transform, under uneven lighting and
+// with sensor noise.
+func photograph(t *testing.T, codes []*Code, rng *rand.Rand) image.Image {
+	t.Helper()
+
+	const scale, border = 6, 4
+	var page []*image.Gray
+	pageW, pageH := 0, 0
+	for _, c := range codes {
+		img := c.Image(scale, border)
+		page = append(page, img)
+		pageW += img.Bounds().Dx()
+		pageH = max(pageH, img.Bounds().Dy())
+	}
+
+	// Lay the symbols out side by side on one sheet
+	sheet := image.NewGray(image.Rect(0, 0, pageW, pageH))
+	for i := range sheet.Pix {
+		sheet.Pix[i] = 255
+	}
+	x := 0
+	for _, img := range page {
+		draw.Draw(sheet, img.Bounds().Add(image.Pt(x, 0)), img, image.Point{}, draw.Src)
+		x += img.Bounds().Dx()
+	}
+
+	// Map the sheet corners onto a tilted, rotated quadrilateral
+	w, h := float64(pageW), float64(pageH)
+	outW, outH := int(w*1.3)+40, int(h*1.5)+40
+	corners := [][2]float64{{30, 40}, {w * 1.15, 10}, {60, h * 1.3}, {w * 1.25, h * 1.45}}
+	toSheet, ok := solveHomography(corners, [][2]float64{{0, 0}, {w, 0}, {0, h}, {w, h}})
+	if !ok {
+		t.Fatal("degenerate test transform")
+	}
+
+	photo := image.NewGray(image.Rect(0, 0, outW, outH))
+	for y := 0; y < outH; y++ {
+		for x := 0; x < outW; x++ {
+			sx, sy := toSheet.apply(float64(x), float64(y))
+			v := 200.0 // table surface
+			if sx >= 0 && sy >= 0 && sx < w && sy < h {
+				v = float64(sheet.Pix[int(sy)*sheet.Stride+int(sx)])
+			}
+			// Lighting falls off towards the right, plus noise
+			light := 1 - 0.45*float64(x)/float64(outW)
+			v = v*light + rng.NormFloat64()*8
+			photo.Pix[y*photo.Stride+x] = uint8(max(0, min(255, v)))
+		}
+	}
+	return photo
+}
+
+func TestDecodePhotographedSymbols(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+
+	var payloads [][]byte
+	var codes []*Code
+	for _, n := range []int{40, 300} {
+		data := make([]byte, n)
+		rng.Read(data)
+		code, err := Encode(data, M)
+		if err != nil {
+			t.Fatalf("Encode: %v", err)
+		}
+		payloads = append(payloads, data)
+		codes = append(codes, code)
+	}
+
+	got, err := Decode(photograph(t, codes, rng))
+	if err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if len(got) != len(payloads) {
+		t.Fatalf("Expected %d symbols, found %d", len(payloads), len(got))
+	}
+	for i := range payloads {
+		if !bytes.Equal(got[i], payloads[i]) {
+			t.Errorf("Symbol %d payload mismatch", i)
+		}
+	}
+}
+
+func TestDecodeRotated(t *testing.T) {
+	rng := rand.New(rand.NewSource(4))
+	data := make([]byte, 500)
+	rng.Read(data)
+	code, err := Encode(data, M)
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	src := code.Image(4, 4)
+
+	for _, degrees := range []float64{30, 45, 90, 200} {
+		angle := degrees * math.Pi / 180
+		side := float64(src.Bounds().Dx())
+		out := int(side * 1.5)
+		center := float64(out) / 2
+
+		img := image.NewGray(image.Rect(0, 0, out, out))
+		for y := 0; y < out; y++ {
+			for x := 0; x < out; x++ {
+				dx, dy := float64(x)-center, float64(y)-center
+				sx := math.Cos(angle)*dx + math.Sin(angle)*dy + side/2
+				sy := -math.Sin(angle)*dx + math.Cos(angle)*dy + side/2
+				v := uint8(255)
+				if sx >= 0 && sy >= 0 && sx < side && sy < side {
+					v = src.Pix[int(sy)*src.Stride+int(sx)]
+				}
+				img.Pix[y*img.Stride+x] = v
+			}
+		}
+
+		got, err := Decode(img)
+		if err != nil {
+			t.Errorf("Rotation %v°: %v", degrees, err)
+			continue
+		}
+		if !bytes.Equal(got[0], data) {
+			t.Errorf("Rotation %v°: payload mismatch", degrees)
+		}
+	}
+}
+
+func TestDecodeNoSymbol(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 200, 200))
+	if _, err := Decode(img); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
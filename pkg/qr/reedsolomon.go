@@ -0,0 +1,200 @@
+package qr
+
+import "errors"
+
+// QR codes use Reed-Solomon over GF(2^8) with the primitive polynomial
+// x^8 + x^4 + x^3 + x^2 + 1 (0x11D) and generator roots α^0 … α^(n-1).
+// This is a different field polynomial from pkg/shamir, so the tables are
+// kept separately.
+
+var (
+	gfExp [512]byte
+	gfLog [256]int
+)
+
+func init() {
+	x := 1
+	for i := 0; i < 255; i++ {
+		gfExp[i] = byte(x)
+		gfLog[x] = i
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= 0x11D
+		}
+	}
+	for i := 255; i < len(gfExp); i++ {
+		gfExp[i] = gfExp[i-255]
+	}
+}
+
+// errTooManyErrors indicates a block has more errors than its ECC can fix.
+var errTooManyErrors = errors.New("too many errors to correct")
+
+func gfMul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return gfExp[gfLog[a]+gfLog[b]]
+}
+
+func gfDiv(a, b byte) byte {
+	if b == 0 {
+		panic("divide by zero")
+	}
+	if a == 0 {
+		return 0
+	}
+	return gfExp[(gfLog[a]+255-gfLog[b])%255]
+}
+
+func gfInverse(a byte) byte {
+	return gfExp[255-gfLog[a]]
+}
+
+// rsGenerator returns the generator polynomial of the given degree,
+// highest-order coefficient first (the leading 1 is omitted).
+func rsGenerator(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1 // start with the monomial x^0
+
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		// Multiply the current product by (x - root)
+		for j := 0; j < degree; j++ {
+			result[j] = gfMul(result[j], root)
+			if j+1 < degree {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMul(root, 0x02)
+	}
+	return result
+}
+
+// rsEncode returns the error correction codewords for data.
+func rsEncode(data []byte, generator []byte) []byte {
+	result := make([]byte, len(generator))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i, g := range generator {
+			result[i] ^= gfMul(g, factor)
+		}
+	}
+	return result
+}
+
+// rsCorrect corrects errors in place in a block of data codewords followed by
+// numECC error correction codewords. It returns the number of corrected errors.
+func rsCorrect(block []byte, numECC int) (int, error) {
+	n := len(block)
+
+	// 1. Syndromes S_i = r(α^i)
+	syndromes := make([]byte, numECC)
+	clean := true
+	for i := range syndromes {
+		var s byte
+		for _, c := range block {
+			s = gfMul(s, gfExp[i]) ^ c
+		}
+		syndromes[i] = s
+		if s != 0 {
+			clean = false
+		}
+	}
+	if clean {
+		return 0, nil
+	}
+
+	// 2. Berlekamp-Massey: error locator polynomial Λ (lowest order first)
+	locator := []byte{1}
+	prev := []byte{1}
+	length, shift := 0, 1
+	prevDiscrepancy := byte(1)
+	for k := 0; k < numECC; k++ {
+		discrepancy := syndromes[k]
+		for i := 1; i <= length && i < len(locator); i++ {
+			discrepancy ^= gfMul(locator[i], syndromes[k-i])
+		}
+		if discrepancy == 0 {
+			shift++
+			continue
+		}
+
+		scale := gfDiv(discrepancy, prevDiscrepancy)
+		next := make([]byte, max(len(locator), len(prev)+shift))
+		copy(next, locator)
+		for i, p := range prev {
+			next[i+shift] ^= gfMul(scale, p)
+		}
+
+		if 2*length <= k {
+			prev = locator
+			length = k + 1 - length
+			prevDiscrepancy = discrepancy
+			shift = 1
+		} else {
+			shift++
+		}
+		locator = next
+	}
+	for len(locator) > 1 && locator[len(locator)-1] == 0 {
+		locator = locator[:len(locator)-1]
+	}
+	numErrors := len(locator) - 1
+	if numErrors == 0 || 2*numErrors > numECC {
+		return 0, errTooManyErrors
+	}
+
+	// 3. Chien search: error at position j has locator X = α^(n-1-j)
+	var positions []int
+	for j := 0; j < n; j++ {
+		xInv := gfExp[(255-(n-1-j)%255)%255]
+		var v byte
+		for i := len(locator) - 1; i >= 0; i-- {
+			v = gfMul(v, xInv) ^ locator[i]
+		}
+		if v == 0 {
+			positions = append(positions, j)
+		}
+	}
+	if len(positions) != numErrors {
+		return 0, errTooManyErrors
+	}
+
+	// 4. Forney: Ω(x) = S(x)Λ(x) mod x^numECC, e = X·Ω(X⁻¹)/Λ'(X⁻¹)
+	omega := make([]byte, numECC)
+	for i := 0; i < numECC; i++ {
+		for j := 0; j <= i && j < len(locator); j++ {
+			omega[i] ^= gfMul(locator[j], syndromes[i-j])
+		}
+	}
+	for _, j := range positions {
+		power := (n - 1 - j) % 255
+		x := gfExp[power]
+		xInv := gfInverse(x)
+
+		var num byte
+		for i := len(omega) - 1; i >= 0; i-- {
+			num = gfMul(num, xInv) ^ omega[i]
+		}
+		// Formal derivative keeps only the odd powers in characteristic 2
+		var den byte
+		for i := len(locator) - 1; i >= 1; i-- {
+			if i%2 == 1 {
+				term := locator[i]
+				for k := 0; k < i-1; k++ {
+					term = gfMul(term, xInv)
+				}
+				den ^= term
+			}
+		}
+		if den == 0 {
+			return 0, errTooManyErrors
+		}
+		block[j] ^= gfMul(x, gfDiv(num, den))
+	}
+
+	return numErrors, nil
+}
@@ -161,12 +161,19 @@ func Combine(parts [][]byte) ([]byte, error) {
 	xSamples := make([]uint8, len(parts))
 	ySamples := make([]uint8, len(parts))
 
-	// Collect X coordinates from the last byte of each part
+	// Collect X coordinates from the last byte of each part. A repeated one
+	// would divide by zero in the interpolation.
+	seen := make(map[uint8]bool, len(parts))
 	for i, part := range parts {
 		if len(part) != firstLen {
 			return nil, fmt.Errorf("parts length mismatch")
 		}
-		xSamples[i] = part[firstLen-1]
+		x := part[firstLen-1]
+		if seen[x] {
+			return nil, fmt.Errorf("duplicate part detected")
+		}
+		seen[x] = true
+		xSamples[i] = x
 	}
 
 	// Interpolate for each byte index
@@ -179,4 +186,34 @@ func Combine(parts [][]byte) ([]byte, error) {
 	}
 
 	return secret, nil
-}
\ No newline at end of file
+}
+
+// Interpolate evaluates, byte by byte, the polynomials passing through the
+// points (xs[i], ys[i]) at x. It is the building block for share schemes that
+// use the same field but their own share layout, such as SLIP-0039.
+func Interpolate(xs []uint8, ys [][]byte, x uint8) ([]byte, error) {
+	if len(xs) == 0 || len(xs) != len(ys) {
+		return nil, fmt.Errorf("need the same, non-zero number of x and y values")
+	}
+
+	seen := make(map[uint8]bool, len(xs))
+	for i, xi := range xs {
+		if seen[xi] {
+			return nil, fmt.Errorf("duplicate x coordinate %d", xi)
+		}
+		seen[xi] = true
+		if len(ys[i]) != len(ys[0]) {
+			return nil, fmt.Errorf("parts length mismatch")
+		}
+	}
+
+	out := make([]byte, len(ys[0]))
+	ySamples := make([]uint8, len(ys))
+	for idx := range out {
+		for i, y := range ys {
+			ySamples[i] = y[idx]
+		}
+		out[idx] = interpolatePolynomial(xs, ySamples, x)
+	}
+	return out, nil
+}
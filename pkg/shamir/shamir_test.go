@@ -44,4 +44,43 @@ func TestSplitAndCombine(t *testing.T) {
 	if bytes.Equal(secret, wrongResult) {
 		t.Error("Security failure: Reconstructed secret with less than threshold shares")
 	}
-}
\ No newline at end of file
+
+	// 5. Fail with a Repeated Share
+	if _, err := Combine([][]byte{shares[0], shares[1], shares[1]}); err == nil {
+		t.Error("Expected an error combining the same share twice")
+	}
+}
+
+func TestInterpolate(t *testing.T) {
+	secret := []byte("Nitwit! Blubber!")
+	shares, err := Split(secret, 4, 3)
+	if err != nil {
+		t.Fatalf("Failed to split: %v", err)
+	}
+
+	xs := make([]uint8, 3)
+	ys := make([][]byte, 3)
+	for i, share := range shares[1:] {
+		xs[i] = share[len(share)-1]
+		ys[i] = share[:len(share)-1]
+	}
+
+	// The polynomial through any three shares passes through the fourth...
+	got, err := Interpolate(xs, ys, shares[0][len(shares[0])-1])
+	if err != nil {
+		t.Fatalf("Failed to interpolate: %v", err)
+	}
+	if !bytes.Equal(got, shares[0][:len(secret)]) {
+		t.Error("Interpolated share does not match the original")
+	}
+
+	// ...and through the secret at zero
+	got, _ = Interpolate(xs, ys, 0)
+	if !bytes.Equal(got, secret) {
+		t.Error("Interpolated secret does not match")
+	}
+
+	if _, err := Interpolate([]uint8{1, 1}, [][]byte{{1}, {2}}, 0); err == nil {
+		t.Error("Expected an error for duplicate x coordinates")
+	}
+}
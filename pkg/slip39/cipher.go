@@ -0,0 +1,146 @@
+package slip39
+
+import (
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/Beastly713/horcrux/pkg/shamir"
+)
+
+const (
+	baseIterationCount = 10000
+	roundCount         = 4
+
+	digestLen   = 4
+	digestIndex = 254
+	secretIndex = 255
+)
+
+// encrypt runs the spec's four round Feistel network over the master secret,
+// with PBKDF2-HMAC-SHA256 of the passphrase as the round function.
+func encrypt(masterSecret, passphrase []byte, iterationExponent int, id uint16, extendable bool) []byte {
+	half := len(masterSecret) / 2
+	l := append([]byte(nil), masterSecret[:half]...)
+	r := append([]byte(nil), masterSecret[half:]...)
+	salt := feistelSalt(id, extendable)
+	for i := 0; i < roundCount; i++ {
+		l, r = r, xorBytes(l, roundFunction(i, passphrase, iterationExponent, salt, r))
+	}
+	return append(r, l...)
+}
+
+// decrypt reverses encrypt by running the rounds backwards.
+func decrypt(ems, passphrase []byte, iterationExponent int, id uint16, extendable bool) []byte {
+	half := len(ems) / 2
+	l := append([]byte(nil), ems[:half]...)
+	r := append([]byte(nil), ems[half:]...)
+	salt := feistelSalt(id, extendable)
+	for i := roundCount - 1; i >= 0; i-- {
+		l, r = r, xorBytes(l, roundFunction(i, passphrase, iterationExponent, salt, r))
+	}
+	return append(r, l...)
+}
+
+func roundFunction(i int, passphrase []byte, iterationExponent int, salt, r []byte) []byte {
+	password := append([]byte{byte(i)}, passphrase...)
+	iterations := (baseIterationCount << iterationExponent) / roundCount
+	out, err := pbkdf2.Key(sha256.New, string(password), append(append([]byte(nil), salt...), r...), iterations, len(r))
+	if err != nil {
+		// Only reachable for FIPS-restricted parameters, which these are not.
+		panic(err)
+	}
+	return out
+}
+
+// feistelSalt binds non-extendable backups to their identifier. Extendable
+// backups leave it out so that new shares can be made for the same secret.
+func feistelSalt(id uint16, extendable bool) []byte {
+	if extendable {
+		return nil
+	}
+	salt := []byte(customization)
+	return binary.BigEndian.AppendUint16(salt, id)
+}
+
+func xorBytes(a, b []byte) []byte {
+	out := make([]byte, len(a))
+	for i := range a {
+		out[i] = a[i] ^ b[i]
+	}
+	return out
+}
+
+// splitSecret splits secret into count shares, threshold of which recover it.
+// Besides the secret at x=255, the polynomial passes through a digest of the
+// secret at x=254, so that recovery can tell a wrong set of shares.
+func splitSecret(threshold, count int, secret []byte) ([][]byte, error) {
+	if threshold == 1 {
+		shares := make([][]byte, count)
+		for i := range shares {
+			shares[i] = append([]byte(nil), secret...)
+		}
+		return shares, nil
+	}
+
+	// 1. threshold-2 random shares, plus the digest and the secret
+	randomCount := threshold - 2
+	xs := make([]uint8, 0, threshold)
+	ys := make([][]byte, 0, threshold)
+	for i := 0; i < randomCount; i++ {
+		y := make([]byte, len(secret))
+		if _, err := rand.Read(y); err != nil {
+			return nil, fmt.Errorf("failed to generate share: %w", err)
+		}
+		xs = append(xs, uint8(i))
+		ys = append(ys, y)
+	}
+
+	randomPart := make([]byte, len(secret)-digestLen)
+	if _, err := rand.Read(randomPart); err != nil {
+		return nil, fmt.Errorf("failed to generate share: %w", err)
+	}
+	xs = append(xs, digestIndex, secretIndex)
+	ys = append(ys, append(createDigest(randomPart, secret), randomPart...), secret)
+
+	// 2. The remaining shares are points on the same polynomial
+	shares := append([][]byte(nil), ys[:randomCount]...)
+	for i := randomCount; i < count; i++ {
+		y, err := shamir.Interpolate(xs, ys, uint8(i))
+		if err != nil {
+			return nil, err
+		}
+		shares = append(shares, y)
+	}
+	return shares, nil
+}
+
+// recoverSecret interpolates the secret from threshold shares and checks its
+// digest.
+func recoverSecret(threshold int, xs []uint8, ys [][]byte) ([]byte, error) {
+	if threshold == 1 {
+		return ys[0], nil
+	}
+
+	secret, err := shamir.Interpolate(xs, ys, secretIndex)
+	if err != nil {
+		return nil, err
+	}
+	digestShare, err := shamir.Interpolate(xs, ys, digestIndex)
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal(digestShare[:digestLen], createDigest(digestShare[digestLen:], secret)) {
+		return nil, ErrDigest
+	}
+	return secret, nil
+}
+
+func createDigest(randomPart, secret []byte) []byte {
+	mac := hmac.New(sha256.New, randomPart)
+	mac.Write(secret)
+	return mac.Sum(nil)[:digestLen]
+}
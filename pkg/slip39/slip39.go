@@ -0,0 +1,409 @@
+// Package slip39 implements SLIP-0039 "Shamir's Secret-Sharing for Mnemonic
+// Codes", the share format used by hardware wallets. A master secret is
+// encrypted with a passphrase, split into groups and then into members, and
+// every share is written as a list of words with an RS1024 checksum.
+//
+// See https://github.com/satoshilabs/slips/blob/master/slip-0039.md
+package slip39
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Beastly713/horcrux/pkg/wordlist"
+)
+
+const (
+	// MinSecretLen is the shortest master secret the spec allows (128 bits).
+	MinSecretLen = 16
+
+	// MaxShareCount is the largest number of groups, or members of a group.
+	MaxShareCount = 16
+
+	// DefaultIterationExponent matches the reference implementation.
+	DefaultIterationExponent = 1
+
+	radixBits       = 10
+	idBits          = 15
+	iterExpBits     = 4
+	checksumWords   = 3
+	metadataWords   = 4 + checksumWords // id/ext/exp, group and member fields, checksum
+	minMnemonicLen  = metadataWords + (MinSecretLen*8+radixBits-1)/radixBits
+	customization   = "shamir"
+	customizationEx = "shamir_extendable"
+)
+
+var (
+	// ErrChecksum indicates a mnemonic whose RS1024 checksum does not match,
+	// usually a mistyped or swapped word.
+	ErrChecksum = errors.New("invalid mnemonic checksum")
+
+	// ErrDigest indicates the shares combined, but not to the secret they were
+	// split from: they belong to different secrets or one was altered.
+	ErrDigest = errors.New("share digest mismatch")
+)
+
+// GroupSpec describes one group: MemberThreshold of its MemberCount shares are
+// needed to recover the group's share of the secret.
+type GroupSpec struct {
+	MemberThreshold int
+	MemberCount     int
+}
+
+// share is a single decoded mnemonic.
+type share struct {
+	Identifier        uint16
+	Extendable        bool
+	IterationExponent int
+	GroupIndex        int
+	GroupThreshold    int
+	GroupCount        int
+	MemberIndex       int
+	MemberThreshold   int
+	Value             []byte
+}
+
+// Generate encrypts masterSecret with passphrase and splits it into mnemonic
+// shares. groupThreshold of the groups must be recovered, each from
+// MemberThreshold of its members. The result holds one list of mnemonics per
+// group, each mnemonic a space separated string of words.
+func Generate(masterSecret, passphrase []byte, groupThreshold int, groups []GroupSpec, iterationExponent int, extendable bool) ([][]string, error) {
+	// 1. Validate parameters
+	if len(masterSecret) < MinSecretLen || len(masterSecret)%2 != 0 {
+		return nil, fmt.Errorf("master secret must be an even number of bytes, at least %d", MinSecretLen)
+	}
+	if err := checkPassphrase(passphrase); err != nil {
+		return nil, err
+	}
+	if iterationExponent < 0 || iterationExponent >= 1<<iterExpBits {
+		return nil, fmt.Errorf("iteration exponent must be between 0 and %d", 1<<iterExpBits-1)
+	}
+	if len(groups) == 0 || len(groups) > MaxShareCount {
+		return nil, fmt.Errorf("group count must be between 1 and %d", MaxShareCount)
+	}
+	if groupThreshold < 1 || groupThreshold > len(groups) {
+		return nil, fmt.Errorf("group threshold must be between 1 and the group count (%d)", len(groups))
+	}
+	for i, g := range groups {
+		if g.MemberCount < 1 || g.MemberCount > MaxShareCount {
+			return nil, fmt.Errorf("group %d: member count must be between 1 and %d", i+1, MaxShareCount)
+		}
+		if g.MemberThreshold < 1 || g.MemberThreshold > g.MemberCount {
+			return nil, fmt.Errorf("group %d: member threshold must be between 1 and the member count", i+1)
+		}
+		if g.MemberThreshold == 1 && g.MemberCount > 1 {
+			return nil, fmt.Errorf("group %d: use 1-of-1 rather than 1-of-%d, which only duplicates the share", i+1, g.MemberCount)
+		}
+	}
+
+	// 2. Encrypt the master secret under a random identifier
+	var idBuf [2]byte
+	if _, err := rand.Read(idBuf[:]); err != nil {
+		return nil, fmt.Errorf("failed to generate identifier: %w", err)
+	}
+	id := binary.BigEndian.Uint16(idBuf[:]) & (1<<idBits - 1)
+	ems := encrypt(masterSecret, passphrase, iterationExponent, id, extendable)
+
+	// 3. Split into groups, then members
+	groupShares, err := splitSecret(groupThreshold, len(groups), ems)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([][]string, len(groups))
+	for gi, g := range groups {
+		memberShares, err := splitSecret(g.MemberThreshold, g.MemberCount, groupShares[gi])
+		if err != nil {
+			return nil, err
+		}
+		for mi, value := range memberShares {
+			s := &share{
+				Identifier:        id,
+				Extendable:        extendable,
+				IterationExponent: iterationExponent,
+				GroupIndex:        gi,
+				GroupThreshold:    groupThreshold,
+				GroupCount:        len(groups),
+				MemberIndex:       mi,
+				MemberThreshold:   g.MemberThreshold,
+				Value:             value,
+			}
+			result[gi] = append(result[gi], strings.Join(s.words(), " "))
+		}
+	}
+	return result, nil
+}
+
+// Combine recovers the master secret from enough mnemonics and decrypts it
+// with passphrase. Mnemonics beyond a group's threshold are ignored. A wrong
+// passphrase is not detected; it yields a different, valid-looking secret.
+func Combine(mnemonics []string, passphrase []byte) ([]byte, error) {
+	if len(mnemonics) == 0 {
+		return nil, errors.New("no mnemonics given")
+	}
+	if err := checkPassphrase(passphrase); err != nil {
+		return nil, err
+	}
+
+	// 1. Decode and check that every share belongs to the same secret
+	groups := make(map[int][]*share)
+	var first *share
+	for i, m := range mnemonics {
+		s, err := decodeMnemonic(m)
+		if err != nil {
+			return nil, fmt.Errorf("mnemonic %d: %w", i+1, err)
+		}
+		if first == nil {
+			first = s
+		} else if s.Identifier != first.Identifier || s.Extendable != first.Extendable ||
+			s.IterationExponent != first.IterationExponent ||
+			s.GroupThreshold != first.GroupThreshold || s.GroupCount != first.GroupCount ||
+			len(s.Value) != len(first.Value) {
+			return nil, fmt.Errorf("mnemonic %d belongs to a different secret", i+1)
+		}
+		members := groups[s.GroupIndex]
+		if len(members) > 0 && members[0].MemberThreshold != s.MemberThreshold {
+			return nil, fmt.Errorf("mnemonic %d: member threshold differs within group %d", i+1, s.GroupIndex+1)
+		}
+		duplicate := false
+		for _, m := range members {
+			if m.MemberIndex == s.MemberIndex {
+				duplicate = true
+			}
+		}
+		if !duplicate {
+			groups[s.GroupIndex] = append(members, s)
+		}
+	}
+
+	// 2. Recover each complete group
+	var groupIndexes []uint8
+	var groupValues [][]byte
+	var incomplete []string
+	for gi := 0; gi < first.GroupCount; gi++ {
+		members, ok := groups[gi]
+		if !ok {
+			continue
+		}
+		threshold := members[0].MemberThreshold
+		if len(members) < threshold {
+			incomplete = append(incomplete, fmt.Sprintf("group %d has %d of %d shares", gi+1, len(members), threshold))
+			continue
+		}
+		members = members[:threshold]
+		xs := make([]uint8, len(members))
+		ys := make([][]byte, len(members))
+		for i, m := range members {
+			xs[i] = uint8(m.MemberIndex)
+			ys[i] = m.Value
+		}
+		value, err := recoverSecret(threshold, xs, ys)
+		if err != nil {
+			return nil, fmt.Errorf("group %d: %w", gi+1, err)
+		}
+		groupIndexes = append(groupIndexes, uint8(gi))
+		groupValues = append(groupValues, value)
+	}
+
+	if len(groupValues) < first.GroupThreshold {
+		msg := fmt.Sprintf("need %d complete groups, have %d", first.GroupThreshold, len(groupValues))
+		if len(incomplete) > 0 {
+			msg += " (" + strings.Join(incomplete, "; ") + ")"
+		}
+		return nil, errors.New(msg)
+	}
+
+	// 3. Recover and decrypt the master secret
+	n := first.GroupThreshold
+	ems, err := recoverSecret(n, groupIndexes[:n], groupValues[:n])
+	if err != nil {
+		return nil, err
+	}
+	return decrypt(ems, passphrase, first.IterationExponent, first.Identifier, first.Extendable), nil
+}
+
+// checkPassphrase enforces the spec's printable ASCII passphrases.
+func checkPassphrase(passphrase []byte) error {
+	for _, c := range passphrase {
+		if c < 32 || c > 126 {
+			return errors.New("passphrase must contain only printable ASCII characters")
+		}
+	}
+	return nil
+}
+
+// words encodes the share as a mnemonic, including the checksum.
+func (s *share) words() []string {
+	// 1. Metadata: id(15) ext(1) exp(4) | GI(4) Gt(4) g(4) I(4) t(4)
+	prefix := uint32(s.Identifier)<<5 | uint32(s.IterationExponent)
+	if s.Extendable {
+		prefix |= 1 << iterExpBits
+	}
+	fields := uint32(s.GroupIndex)<<16 | uint32(s.GroupThreshold-1)<<12 |
+		uint32(s.GroupCount-1)<<8 | uint32(s.MemberIndex)<<4 | uint32(s.MemberThreshold-1)
+
+	data := []int{
+		int(prefix >> 10), int(prefix & 1023),
+		int(fields >> 10), int(fields & 1023),
+	}
+
+	// 2. Value, left-padded with zero bits to whole words
+	data = append(data, bytesToWords(s.Value)...)
+
+	// 3. Checksum
+	data = append(data, rs1024CreateChecksum(checksumCustomization(s.Extendable), data)...)
+
+	out := make([]string, len(data))
+	for i, v := range data {
+		out[i] = wordlist.Word(v)
+	}
+	return out
+}
+
+// decodeMnemonic parses and checks a mnemonic.
+func decodeMnemonic(mnemonic string) (*share, error) {
+	list := strings.Fields(mnemonic)
+	if len(list) < minMnemonicLen {
+		return nil, fmt.Errorf("mnemonic too short: %d words, need at least %d", len(list), minMnemonicLen)
+	}
+	if (radixBits*(len(list)-metadataWords))%16 > 8 {
+		return nil, fmt.Errorf("invalid mnemonic length: %d words", len(list))
+	}
+
+	data := make([]int, len(list))
+	for i, w := range list {
+		v, ok := wordlist.Index(w)
+		if !ok {
+			return nil, fmt.Errorf("unknown word %q", w)
+		}
+		data[i] = v
+	}
+
+	// 1. Checksum, whose customization depends on the extendable flag
+	extendable := (data[1]>>iterExpBits)&1 == 1
+	if !rs1024VerifyChecksum(checksumCustomization(extendable), data) {
+		return nil, ErrChecksum
+	}
+
+	// 2. Metadata
+	prefix := data[0]<<10 | data[1]
+	fields := data[2]<<10 | data[3]
+	s := &share{
+		Identifier:        uint16(prefix >> 5),
+		Extendable:        extendable,
+		IterationExponent: prefix & (1<<iterExpBits - 1),
+		GroupIndex:        fields >> 16,
+		GroupThreshold:    (fields>>12)&15 + 1,
+		GroupCount:        (fields>>8)&15 + 1,
+		MemberIndex:       (fields >> 4) & 15,
+		MemberThreshold:   fields&15 + 1,
+	}
+	if s.GroupThreshold > s.GroupCount {
+		return nil, errors.New("group threshold exceeds group count")
+	}
+	if s.GroupIndex >= s.GroupCount {
+		return nil, errors.New("group index exceeds group count")
+	}
+
+	// 3. Value
+	value, err := wordsToBytes(data[4 : len(data)-checksumWords])
+	if err != nil {
+		return nil, err
+	}
+	s.Value = value
+	return s, nil
+}
+
+// bytesToWords packs b into 10-bit values, padding with zero bits on the left.
+func bytesToWords(b []byte) []int {
+	totalBits := len(b) * 8
+	numWords := (totalBits + radixBits - 1) / radixBits
+	padding := numWords*radixBits - totalBits
+
+	out := make([]int, numWords)
+	for w := range out {
+		v := 0
+		for i := 0; i < radixBits; i++ {
+			v <<= 1
+			bit := w*radixBits + i - padding
+			if bit >= 0 && b[bit/8]&(0x80>>(bit%8)) != 0 {
+				v |= 1
+			}
+		}
+		out[w] = v
+	}
+	return out
+}
+
+// wordsToBytes reverses bytesToWords, rejecting non-zero padding.
+func wordsToBytes(data []int) ([]byte, error) {
+	totalBits := len(data) * radixBits
+	// Secrets are a whole number of 16-bit units, so the padding is what is
+	// left over, and never a whole byte.
+	padding := totalBits % 16
+	if padding > 8 {
+		return nil, fmt.Errorf("invalid mnemonic length: %d value words", len(data))
+	}
+	out := make([]byte, (totalBits-padding)/8)
+	for i := 0; i < totalBits; i++ {
+		if data[i/radixBits]&(1<<(radixBits-1-i%radixBits)) == 0 {
+			continue
+		}
+		if i < padding {
+			return nil, errors.New("invalid mnemonic padding")
+		}
+		bit := i - padding
+		out[bit/8] |= 0x80 >> (bit % 8)
+	}
+	return out, nil
+}
+
+// checksumCustomization returns the string mixed into the RS1024 checksum.
+func checksumCustomization(extendable bool) string {
+	if extendable {
+		return customizationEx
+	}
+	return customization
+}
+
+// rs1024Polymod is the checksum function from the spec: a Reed-Solomon code
+// over GF(1024) that detects up to 3 errors.
+func rs1024Polymod(values []int) uint32 {
+	gen := [10]uint32{
+		0xE0E040, 0x1C1C080, 0x3838100, 0x7070200, 0xE0E0009,
+		0x1C0C2412, 0x38086C24, 0x3090FC48, 0x21B1F890, 0x3F3F120,
+	}
+	chk := uint32(1)
+	for _, v := range values {
+		b := chk >> 20
+		chk = (chk&0xFFFFF)<<10 ^ uint32(v)
+		for i := 0; i < 10; i++ {
+			if (b>>i)&1 == 1 {
+				chk ^= gen[i]
+			}
+		}
+	}
+	return chk
+}
+
+func rs1024VerifyChecksum(cs string, data []int) bool {
+	values := make([]int, 0, len(cs)+len(data))
+	for _, c := range []byte(cs) {
+		values = append(values, int(c))
+	}
+	return rs1024Polymod(append(values, data...)) == 1
+}
+
+func rs1024CreateChecksum(cs string, data []int) []int {
+	values := make([]int, 0, len(cs)+len(data)+checksumWords)
+	for _, c := range []byte(cs) {
+		values = append(values, int(c))
+	}
+	values = append(values, data...)
+	values = append(values, 0, 0, 0)
+	polymod := rs1024Polymod(values) ^ 1
+	return []int{int(polymod>>20) & 1023, int(polymod>>10) & 1023, int(polymod) & 1023}
+}
@@ -0,0 +1,290 @@
+package slip39
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Beastly713/horcrux/pkg/wordlist"
+)
+
+// Vectors from the official SLIP-0039 test suite, passphrase "TREZOR".
+var vectors = []struct {
+	name      string
+	mnemonics []string
+	secret    string
+}{
+	{
+		name: "128 bits without sharing",
+		mnemonics: []string{
+			"duckling enlarge academic academic agency result length solution fridge kidney coal piece deal husband erode duke ajar critical decision keyboard",
+		},
+		secret: "bb54aac4b89dc868ba37d9cc21b2cece",
+	},
+	{
+		name: "128 bits 2-of-3",
+		mnemonics: []string{
+			"shadow pistol academic always adequate wildlife fancy gross oasis cylinder mustang wrist rescue view short owner flip making coding armed",
+			"shadow pistol academic acid actress prayer class unknown daughter sweater depict flip twice unkind craft early superior advocate guest smoking",
+		},
+		secret: "b43ceb7e57a0ea8766221624d01b0864",
+	},
+	{
+		name: "256 bits without sharing",
+		mnemonics: []string{
+			"theory painting academic academic armed sweater year military elder discuss acne wildlife boring employer fused large satoshi bundle carbon diagnose anatomy hamster leaves tracks paces beyond phantom capital marvel lips brave detect luck",
+		},
+		secret: "989baf9dcaad5b10ca33dfd8cc75e42477025dce88ae83e75a230086a0e00e92",
+	},
+}
+
+func TestVectors(t *testing.T) {
+	for _, v := range vectors {
+		t.Run(v.name, func(t *testing.T) {
+			secret, err := Combine(v.mnemonics, []byte("TREZOR"))
+			if err != nil {
+				t.Fatalf("Combine failed: %v", err)
+			}
+			if got := hex.EncodeToString(secret); got != v.secret {
+				t.Errorf("secret = %s, want %s", got, v.secret)
+			}
+		})
+	}
+}
+
+// TestOfficialVectors runs the full test suite of the reference
+// implementation, vectors.json from
+// https://github.com/trezor/python-shamir-mnemonic, when it is placed in
+// testdata. Each entry is a description, the mnemonics, the master secret in
+// hex, empty when the mnemonics must be rejected, and an xprv not used here.
+func TestOfficialVectors(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "vectors.json"))
+	if errors.Is(err, fs.ErrNotExist) {
+		t.Skip("testdata/vectors.json not present; it is vectors.json from https://github.com/trezor/python-shamir-mnemonic")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entries [][]json.RawMessage
+	if err := json.Unmarshal(data, &entries); err != nil {
+		t.Fatalf("Failed to parse vectors.json: %v", err)
+	}
+	for _, e := range entries {
+		var name, secret string
+		var mnemonics []string
+		if len(e) < 3 || json.Unmarshal(e[0], &name) != nil || json.Unmarshal(e[1], &mnemonics) != nil || json.Unmarshal(e[2], &secret) != nil {
+			t.Fatalf("Unexpected entry: %s", e)
+		}
+		t.Run(name, func(t *testing.T) {
+			got, err := Combine(mnemonics, []byte("TREZOR"))
+			switch {
+			case secret == "" && err == nil:
+				t.Errorf("Expected an error, recovered %x", got)
+			case secret != "" && err != nil:
+				t.Errorf("Combine failed: %v", err)
+			case secret != "" && hex.EncodeToString(got) != secret:
+				t.Errorf("secret = %x, want %s", got, secret)
+			}
+		})
+	}
+}
+
+func TestMnemonicRoundTrip(t *testing.T) {
+	for _, v := range vectors {
+		for _, m := range v.mnemonics {
+			s, err := decodeMnemonic(m)
+			if err != nil {
+				t.Fatalf("decode failed: %v", err)
+			}
+			if got := strings.Join(s.words(), " "); got != m {
+				t.Errorf("re-encoded mnemonic differs:\n got %s\nwant %s", got, m)
+			}
+		}
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	words := strings.Fields(vectors[0].mnemonics[0])
+	words[5] = "academic"
+	_, err := Combine([]string{strings.Join(words, " ")}, nil)
+	if !errors.Is(err, ErrChecksum) {
+		t.Errorf("expected ErrChecksum, got %v", err)
+	}
+}
+
+func TestGenerateAndCombineGroups(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	passphrase := []byte("correct horse")
+	groups := []GroupSpec{{1, 1}, {2, 3}, {3, 5}}
+
+	for _, extendable := range []bool{false, true} {
+		mnemonics, err := Generate(secret, passphrase, 2, groups, 0, extendable)
+		if err != nil {
+			t.Fatalf("Generate failed: %v", err)
+		}
+		if len(mnemonics) != 3 || len(mnemonics[1]) != 3 || len(mnemonics[2]) != 5 {
+			t.Fatalf("unexpected share layout: %d groups", len(mnemonics))
+		}
+
+		// Group 2 (2 of 3) and group 3 (3 of 5), with members out of order
+		set := []string{mnemonics[1][2], mnemonics[2][4], mnemonics[1][0], mnemonics[2][1], mnemonics[2][3]}
+		got, err := Combine(set, passphrase)
+		if err != nil {
+			t.Fatalf("Combine failed: %v", err)
+		}
+		if !bytes.Equal(got, secret) {
+			t.Errorf("recovered %x, want %x", got, secret)
+		}
+
+		// One complete group is not enough
+		if _, err := Combine([]string{mnemonics[0][0], mnemonics[2][0], mnemonics[2][1]}, passphrase); err == nil {
+			t.Error("expected an error with one complete group")
+		}
+
+		// A wrong passphrase decrypts to a different secret
+		got, err = Combine([]string{mnemonics[0][0], mnemonics[1][0], mnemonics[1][1]}, []byte("wrong"))
+		if err != nil {
+			t.Fatalf("Combine failed: %v", err)
+		}
+		if bytes.Equal(got, secret) {
+			t.Error("wrong passphrase recovered the secret")
+		}
+	}
+}
+
+func TestMixedSharesDetected(t *testing.T) {
+	secret := bytes.Repeat([]byte{7}, 16)
+	a, err := Generate(secret, nil, 1, []GroupSpec{{2, 3}}, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := Generate(secret, nil, 1, []GroupSpec{{2, 3}}, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Combine([]string{a[0][0], b[0][1]}, nil); err == nil {
+		t.Error("expected shares of different secrets to be rejected")
+	}
+}
+
+// encodeData writes word values as a mnemonic, with a valid checksum.
+func encodeData(data []int, extendable bool) string {
+	data = append(data[:len(data):len(data)], rs1024CreateChecksum(checksumCustomization(extendable), data)...)
+	words := make([]string, len(data))
+	for i, v := range data {
+		words[i] = wordlist.Word(v)
+	}
+	return strings.Join(words, " ")
+}
+
+// TestInvalidShares checks the kinds of invalid mnemonics the official suite
+// has, made from valid shares with the change made after the checksum, or
+// before it so that only the change is wrong.
+func TestInvalidShares(t *testing.T) {
+	groups := []GroupSpec{{2, 3}, {1, 1}}
+	mnemonics, err := Generate(bytes.Repeat([]byte{7}, 16), nil, 2, groups, 0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Combine([]string{mnemonics[0][0], mnemonics[0][1], mnemonics[1][0]}, nil); err != nil {
+		t.Fatalf("Combine failed on the unchanged shares: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		share func(s *share)         // before encoding
+		data  func(data []int) []int // word values, checksum excluded
+		words func(m string) string  // after the checksum
+		group int                    // the group whose first member is changed
+		want  string
+	}{
+		{
+			name: "invalid checksum",
+			words: func(m string) string {
+				w := strings.Fields(m)
+				v, _ := wordlist.Index(w[len(w)-1])
+				w[len(w)-1] = wordlist.Word((v + 1) % 1024)
+				return strings.Join(w, " ")
+			},
+			want: "checksum",
+		},
+		{
+			name: "invalid padding",
+			data: func(data []int) []int { data[4] |= 1 << (radixBits - 1); return data },
+			want: "padding",
+		},
+		{
+			name: "value a word short",
+			data: func(data []int) []int { return append(data[:4], data[5:]...) },
+			want: "too short",
+		},
+		{
+			name:  "group threshold exceeds group count",
+			share: func(s *share) { s.GroupThreshold = 3 },
+			want:  "group threshold exceeds group count",
+		},
+		{
+			name:  "group index exceeds group count",
+			share: func(s *share) { s.GroupIndex = 2 },
+			want:  "group index exceeds group count",
+		},
+		{
+			name:  "member threshold differs within a group",
+			share: func(s *share) { s.MemberThreshold = 3 },
+			want:  "member threshold differs",
+		},
+		{
+			name:  "group threshold differs between groups",
+			share: func(s *share) { s.GroupThreshold = 1 },
+			group: 1,
+			want:  "different secret",
+		},
+		{
+			name: "extendable flag without its checksum",
+			data: func(data []int) []int { data[1] |= 1 << iterExpBits; return data },
+			want: "checksum",
+		},
+		{
+			name:  "extendable share among others",
+			share: func(s *share) { s.Extendable = true },
+			want:  "different secret",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set := []string{mnemonics[0][0], mnemonics[0][1], mnemonics[1][0]}
+			i := map[int]int{0: 0, 1: 2}[tt.group]
+			s, err := decodeMnemonic(set[i])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.share != nil {
+				tt.share(s)
+			}
+			words := s.words()
+			data := make([]int, len(words)-checksumWords)
+			for j := range data {
+				data[j], _ = wordlist.Index(words[j])
+			}
+			if tt.data != nil {
+				data = tt.data(data)
+			}
+			set[i] = encodeData(data, s.Extendable)
+			if tt.words != nil {
+				set[i] = tt.words(set[i])
+			}
+
+			_, err = Combine(set, nil)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Error %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package ssss
+
+import (
+	"encoding/binary"
+	"math/big"
+)
+
+// field is GF(2^bits), with elements as big.Int bit vectors.
+type field struct {
+	bits int
+	poly *big.Int
+}
+
+// newField returns the field ssss uses for a security level: the reduction
+// polynomial is x^bits + x^k1 + x^k2 + x^k3 + 1 from irredCoeff.
+func newField(bits int) *field {
+	k := irredCoeff[bits/8-1]
+	poly := new(big.Int)
+	for _, b := range []int{bits, int(k[0]), int(k[1]), int(k[2]), 0} {
+		poly.SetBit(poly, b, 1)
+	}
+	return &field{bits: bits, poly: poly}
+}
+
+func (f *field) mul(x, y *big.Int) *big.Int {
+	b := new(big.Int).Set(x)
+	z := new(big.Int)
+	if y.Bit(0) == 1 {
+		z.Set(b)
+	}
+	for i := 1; i < f.bits; i++ {
+		b.Lsh(b, 1)
+		if b.Bit(f.bits) == 1 {
+			b.Xor(b, f.poly)
+		}
+		if y.Bit(i) == 1 {
+			z.Xor(z, b)
+		}
+	}
+	return z
+}
+
+// inv returns x^(2^bits - 2), the inverse of a non-zero x.
+func (f *field) inv(x *big.Int) *big.Int {
+	result := big.NewInt(1)
+	square := new(big.Int).Set(x)
+	for i := 1; i < f.bits; i++ {
+		square = f.mul(square, square)
+		result = f.mul(result, square)
+	}
+	return result
+}
+
+// horner evaluates x^t + coeff[t-1]x^(t-1) + ... + coeff[0] at x, where t is
+// len(coeff). ssss's polynomials are monic.
+func (f *field) horner(coeff []*big.Int, x *big.Int) *big.Int {
+	y := new(big.Int).Set(x)
+	for i := len(coeff) - 1; i > 0; i-- {
+		y.Xor(y, coeff[i])
+		y = f.mul(y, x)
+	}
+	return y.Xor(y, coeff[0])
+}
+
+// diffuse runs (or with encode false, undoes) ssss's diffusion layer: 40
+// passes per byte of zero-keyed XTEA over overlapping 8-byte windows of the
+// secret, so that every share depends on every bit of it.
+//
+// The byte order copies the reference, which exports the number as 16-bit
+// big-endian words, least significant word first.
+func diffuse(x *big.Int, bits int, encode bool) *big.Int {
+	n := bits / 8
+	words := (bits + 8) / 16
+	v := make([]byte, words*2)
+	raw := x.FillBytes(make([]byte, words*2))
+	for w := 0; w < words; w++ {
+		copy(v[2*w:2*w+2], raw[len(raw)-2*w-2:len(raw)-2*w])
+	}
+	if bits%16 == 8 {
+		v[n-1] = v[n]
+	}
+
+	if encode {
+		for i := 0; i < 40*n; i += 2 {
+			xteaSlice(v, i, n, xteaEncipher)
+		}
+	} else {
+		for i := 40*n - 2; i >= 0; i -= 2 {
+			xteaSlice(v, i, n, xteaDecipher)
+		}
+	}
+
+	if bits%16 == 8 {
+		v[n] = v[n-1]
+		v[n-1] = 0
+	}
+	for w := 0; w < words; w++ {
+		copy(raw[len(raw)-2*w-2:len(raw)-2*w], v[2*w:2*w+2])
+	}
+	return new(big.Int).SetBytes(raw)
+}
+
+// xteaSlice applies block to the 8 bytes of data starting at idx, wrapping
+// around its first n bytes.
+func xteaSlice(data []byte, idx, n int, block func(v *[2]uint32)) {
+	var buf [8]byte
+	for i := range buf {
+		buf[i] = data[(idx+i)%n]
+	}
+	v := [2]uint32{binary.BigEndian.Uint32(buf[:4]), binary.BigEndian.Uint32(buf[4:])}
+	block(&v)
+	binary.BigEndian.PutUint32(buf[:4], v[0])
+	binary.BigEndian.PutUint32(buf[4:], v[1])
+	for i := range buf {
+		data[(idx+i)%n] = buf[i]
+	}
+}
+
+const xteaDelta = 0x9E3779B9
+
+func xteaEncipher(v *[2]uint32) {
+	var sum uint32
+	for i := 0; i < 32; i++ {
+		v[0] += ((v[1]<<4 ^ v[1]>>5) + v[1]) ^ sum
+		sum += xteaDelta
+		v[1] += ((v[0]<<4 ^ v[0]>>5) + v[0]) ^ sum
+	}
+}
+
+func xteaDecipher(v *[2]uint32) {
+	sum := uint32(0xC6EF3720) // xteaDelta * 32
+	for i := 0; i < 32; i++ {
+		v[1] -= ((v[0]<<4 ^ v[0]>>5) + v[0]) ^ sum
+		sum -= xteaDelta
+		v[0] -= ((v[1]<<4 ^ v[1]>>5) + v[1]) ^ sum
+	}
+}
@@ -0,0 +1,189 @@
+// Package ssss reads and writes shares in the format of B. Poettering's
+// ssss-split and ssss-combine tools.
+//
+// Unlike pkg/shamir, ssss works in GF(2^n) with n the bit length of the
+// secret, uses a monic polynomial whose constant term is the secret, and by
+// default diffuses secrets of 64 bits or more with XTEA before splitting.
+// Shares are written as "[token-]index-hexvalue".
+package ssss
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+const (
+	// MinBits and MaxBits bound the security level (the field size).
+	MinBits = 8
+	MaxBits = 1024
+
+	// MaxShares is the most shares ssss-split writes.
+	MaxShares = 255
+
+	// diffusionMinBits is the smallest field ssss diffuses secrets in.
+	diffusionMinBits = 64
+)
+
+// ErrSyntax indicates a line that is not an ssss share.
+var ErrSyntax = errors.New("invalid ssss share syntax")
+
+// Share is one parsed "[token-]index-hexvalue" line.
+type Share struct {
+	Token string
+	Index int
+	Value *big.Int
+	Bits  int
+}
+
+// Split splits secret into count shares, threshold of which recover it, the
+// way "ssss-split -x" does with a hex secret of the same length. token is
+// prefixed to every share when not empty. diffusion mirrors ssss-split's
+// default; pass false for shares made with -D.
+func Split(secret []byte, threshold, count int, token string, diffusion bool) ([]string, error) {
+	bits := len(secret) * 8
+	if bits < MinBits || bits > MaxBits {
+		return nil, fmt.Errorf("secret must be between %d and %d bytes", MinBits/8, MaxBits/8)
+	}
+	if threshold < 2 || threshold > count || count > MaxShares {
+		return nil, fmt.Errorf("invalid scheme %d of %d: need 2 <= threshold <= shares <= %d", threshold, count, MaxShares)
+	}
+	if strings.Contains(token, "-") {
+		return nil, errors.New("token must not contain '-'")
+	}
+
+	f := newField(bits)
+
+	// 1. Coefficients: the (diffused) secret, then random ones
+	coeff := make([]*big.Int, threshold)
+	coeff[0] = new(big.Int).SetBytes(secret)
+	if diffusion && bits >= diffusionMinBits {
+		coeff[0] = diffuse(coeff[0], bits, true)
+	}
+	buf := make([]byte, len(secret))
+	for i := 1; i < threshold; i++ {
+		if _, err := rand.Read(buf); err != nil {
+			return nil, fmt.Errorf("failed to generate coefficients: %w", err)
+		}
+		coeff[i] = new(big.Int).SetBytes(buf)
+	}
+
+	// 2. Evaluate at x = 1..count
+	width := len(strconv.Itoa(count))
+	shares := make([]string, count)
+	for i := 1; i <= count; i++ {
+		y := f.horner(coeff, big.NewInt(int64(i)))
+		s := fmt.Sprintf("%0*d-%0*x", width, i, bits/4, y)
+		if token != "" {
+			s = token + "-" + s
+		}
+		shares[i-1] = s
+	}
+	return shares, nil
+}
+
+// Combine recovers the secret from threshold shares, like ssss-combine -x.
+// Extra shares are ignored; ssss shares do not record the threshold, so it
+// must be given.
+func Combine(lines []string, threshold int, diffusion bool) ([]byte, error) {
+	if threshold < 2 {
+		return nil, errors.New("threshold must be at least 2")
+	}
+	if len(lines) < threshold {
+		return nil, fmt.Errorf("need %d shares, have %d", threshold, len(lines))
+	}
+
+	// 1. Parse, and check the shares fit together
+	shares := make([]*Share, 0, threshold)
+	seen := make(map[int]bool)
+	for _, line := range lines {
+		s, err := Parse(line)
+		if err != nil {
+			return nil, err
+		}
+		if len(shares) > 0 && s.Bits != shares[0].Bits {
+			return nil, errors.New("shares have different security levels")
+		}
+		if seen[s.Index] {
+			continue
+		}
+		seen[s.Index] = true
+		shares = append(shares, s)
+		if len(shares) == threshold {
+			break
+		}
+	}
+	if len(shares) < threshold {
+		return nil, fmt.Errorf("need %d distinct shares, have %d", threshold, len(shares))
+	}
+
+	bits := shares[0].Bits
+	f := newField(bits)
+
+	// 2. Remove the monic x^t term, then interpolate the rest at zero
+	xs := make([]*big.Int, threshold)
+	ys := make([]*big.Int, threshold)
+	for i, s := range shares {
+		xs[i] = big.NewInt(int64(s.Index))
+		xt := big.NewInt(1)
+		for j := 0; j < threshold; j++ {
+			xt = f.mul(xt, xs[i])
+		}
+		ys[i] = new(big.Int).Xor(s.Value, xt)
+	}
+
+	secret := new(big.Int)
+	for i := range xs {
+		num, den := big.NewInt(1), big.NewInt(1)
+		for j := range xs {
+			if i == j {
+				continue
+			}
+			num = f.mul(num, xs[j])
+			den = f.mul(den, new(big.Int).Xor(xs[i], xs[j]))
+		}
+		term := f.mul(ys[i], f.mul(num, f.inv(den)))
+		secret.Xor(secret, term)
+	}
+
+	// 3. Undo the diffusion
+	if diffusion && bits >= diffusionMinBits {
+		secret = diffuse(secret, bits, false)
+	}
+	return secret.FillBytes(make([]byte, bits/8)), nil
+}
+
+// Parse reads one "[token-]index-hexvalue" share.
+func Parse(line string) (*Share, error) {
+	parts := strings.Split(strings.TrimSpace(line), "-")
+	s := &Share{}
+	switch len(parts) {
+	case 2:
+	case 3:
+		s.Token = parts[0]
+		parts = parts[1:]
+	default:
+		return nil, ErrSyntax
+	}
+
+	index, err := strconv.Atoi(parts[0])
+	if err != nil || index < 1 || index > MaxShares {
+		return nil, fmt.Errorf("%w: bad index %q", ErrSyntax, parts[0])
+	}
+	s.Index = index
+
+	s.Bits = len(parts[1]) * 4
+	if s.Bits < MinBits || s.Bits > MaxBits || s.Bits%8 != 0 {
+		return nil, fmt.Errorf("%w: share has illegal length", ErrSyntax)
+	}
+	raw, err := hex.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSyntax, err)
+	}
+	s.Value = new(big.Int).SetBytes(raw)
+	return s, nil
+}
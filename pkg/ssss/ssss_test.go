@@ -0,0 +1,76 @@
+package ssss
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// The example from the ssss documentation: "ssss-split -t 3 -n 5" of the
+// secret "my secret root password", at a 184 bit security level.
+var docShares = []string{
+	"1-1c41ef496eccfbeba439714085df8437236298da8dd824",
+	"2-fbc74a03a50e14ab406c225afb5f45c40ae11976d2b665",
+	"3-fa1c3a9c6df8af0779c36de6c33f6e36e989d0e0b91309",
+	"4-468de7d6eb36674c9cf008c8e8fc8c566537ad6301eb9e",
+	"5-4756974923c0dce0a55f4774d09ca7a4865f64f56a4ee0",
+}
+
+func TestCombineDocumentationExample(t *testing.T) {
+	for _, set := range [][]string{docShares[:3], docShares[2:], {docShares[4], docShares[0], docShares[2]}} {
+		secret, err := Combine(set, 3, true)
+		if err != nil {
+			t.Fatalf("Combine failed: %v", err)
+		}
+		if string(secret) != "my secret root password" {
+			t.Errorf("secret = %q", secret)
+		}
+	}
+
+	// Too few shares, or the wrong threshold, do not recover it
+	if _, err := Combine(docShares[:2], 3, true); err == nil {
+		t.Error("expected an error with two shares")
+	}
+	if secret, _ := Combine(docShares[:2], 2, true); string(secret) == "my secret root password" {
+		t.Error("recovered the secret below the threshold")
+	}
+}
+
+func TestSplitCombine(t *testing.T) {
+	for _, size := range []int{1, 7, 8, 23, 32, 128} {
+		secret := bytes.Repeat([]byte{0xA5, 0x01, 0xFF}, size)[:size]
+		for _, diffusion := range []bool{true, false} {
+			shares, err := Split(secret, 3, 12, "key", diffusion)
+			if err != nil {
+				t.Fatalf("Split failed: %v", err)
+			}
+			if shares[0][:7] != "key-01-" {
+				t.Errorf("unexpected share prefix in %q", shares[0])
+			}
+
+			got, err := Combine([]string{shares[11], shares[3], shares[7]}, 3, diffusion)
+			if err != nil {
+				t.Fatalf("Combine failed: %v", err)
+			}
+			if !bytes.Equal(got, secret) {
+				t.Errorf("%d bytes, diffusion %v: got %x, want %x", size, diffusion, got, secret)
+			}
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	s, err := Parse("vault-03-00ff")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if s.Token != "vault" || s.Index != 3 || s.Bits != 16 || s.Value.Int64() != 0xff {
+		t.Errorf("unexpected share %+v", s)
+	}
+
+	for _, bad := range []string{"nonsense", "1-abc", "0-00ff", "a-b-c-d", "1-zz"} {
+		if _, err := Parse(bad); !errors.Is(err, ErrSyntax) {
+			t.Errorf("Parse(%q): expected ErrSyntax, got %v", bad, err)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package stego
+
+import (
+	"image"
+	"math"
+)
+
+// Thresholds above which Report.Suspicious flags an image.
+const (
+	// ChiSquareThreshold is the chi-square embedding probability considered
+	// suspicious.
+	ChiSquareThreshold = 0.95
+
+	// SamplePairsThreshold is the estimated embedding rate considered
+	// suspicious. Clean photos usually estimate well below it.
+	SamplePairsThreshold = 0.05
+)
+
+// profileSteps is the number of prefixes the chi-square profile covers.
+const profileSteps = 10
+
+// Report holds the results of the steganalysis run by Analyze.
+type Report struct {
+	// ChiSquare is the probability, by Westfeld and Pfitzmann's chi-square
+	// attack, that the LSBs of the whole image were replaced by random data.
+	// LSB replacement evens out the counts of each pair of values 2k and
+	// 2k+1, which natural images do not.
+	ChiSquare float64
+
+	// ChiSquareProfile is the same probability over the first 10%, 20%, ...,
+	// 100% of the pixels. Sequential embedding shows as values near 1 that
+	// fall off where the payload ends.
+	ChiSquareProfile []float64
+
+	// SamplePairs is the fraction of pixels estimated to carry payload bits,
+	// by Dumitrescu, Wu and Wang's sample pair analysis of adjacent pixels.
+	SamplePairs float64
+}
+
+// Suspicious reports whether either detector exceeds its threshold.
+func (r Report) Suspicious() bool {
+	return r.ChiSquare > ChiSquareThreshold || r.SamplePairs > SamplePairsThreshold
+}
+
+// Analyze runs the chi-square attack and sample pair analysis on the R, G
+// and B channels of img. Both are aimed at LSB replacement; LSB matching is
+// much harder for them to see.
+func Analyze(img image.Image) Report {
+	bounds := img.Bounds()
+	nrgba := toNRGBA(img)
+
+	pixels := bounds.Dx() * bounds.Dy()
+	r := Report{ChiSquareProfile: make([]float64, profileSteps)}
+
+	// 1. Chi-square over growing prefixes of the pixels
+	var hist [256]int
+	next := 0
+	for step := 1; step <= profileSteps; step++ {
+		end := pixels * step / profileSteps
+		for ; next < end; next++ {
+			for c := 0; c < channelsPerPixel; c++ {
+				hist[nrgba.Pix[next*4+c]]++
+			}
+		}
+		r.ChiSquareProfile[step-1] = chiSquareProbability(&hist)
+	}
+	r.ChiSquare = r.ChiSquareProfile[profileSteps-1]
+
+	// 2. Sample pairs
+	r.SamplePairs = samplePairs(nrgba)
+	return r
+}
+
+// chiSquareProbability compares the count of each value 2k with the mean of
+// 2k and 2k+1, and returns 1 - CDF of the chi-square statistic: near 1 when
+// the pairs are as even as fully random LSBs would make them.
+func chiSquareProbability(hist *[256]int) float64 {
+	var chi float64
+	categories := 0
+	for k := 0; k < 128; k++ {
+		expected := float64(hist[2*k]+hist[2*k+1]) / 2
+		// Sparse categories make the statistic unreliable
+		if expected < 5 {
+			continue
+		}
+		d := float64(hist[2*k]) - expected
+		chi += d * d / expected
+		categories++
+	}
+	if categories < 2 {
+		return 0
+	}
+	return 1 - regularizedGammaP(float64(categories-1)/2, chi/2)
+}
+
+// samplePairs estimates the embedding rate from horizontally adjacent pairs
+// (u, v) of the same channel. With X, Y, Z and W the pair classes of the
+// method and P the number of pairs, the rate p is the smaller root of
+//
+//	(W+Z)/2 · p² + (2X − P) · p + (Y − X) = 0
+func samplePairs(img *image.NRGBA) float64 {
+	var x, y, z, w, pairs float64
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+	for row := 0; row < height; row++ {
+		line := img.Pix[row*img.Stride : row*img.Stride+width*4]
+		for col := 0; col+1 < width; col++ {
+			for c := 0; c < channelsPerPixel; c++ {
+				u, v := int(line[col*4+c]), int(line[col*4+4+c])
+				pairs++
+				if u == v {
+					z++
+				}
+				if u>>1 == v>>1 && u != v {
+					w++
+				}
+				if (v%2 == 0 && u < v) || (v%2 == 1 && u > v) {
+					x++
+				}
+				if (v%2 == 0 && u > v) || (v%2 == 1 && u < v) {
+					y++
+				}
+			}
+		}
+	}
+	if pairs == 0 {
+		return 0
+	}
+
+	a := (w + z) / 2
+	b := 2*x - pairs
+	c := y - x
+	var p float64
+	disc := b*b - 4*a*c
+	switch {
+	case a == 0 || disc < 0:
+		if b == 0 {
+			return 0
+		}
+		p = -c / b
+	default:
+		r1 := (-b + math.Sqrt(disc)) / (2 * a)
+		r2 := (-b - math.Sqrt(disc)) / (2 * a)
+		p = r1
+		if math.Abs(r2) < math.Abs(r1) {
+			p = r2
+		}
+	}
+	return math.Max(0, math.Min(1, p))
+}
+
+// regularizedGammaP is the lower regularized incomplete gamma function
+// P(s, x), the CDF of a chi-square with 2s degrees of freedom at 2x.
+func regularizedGammaP(s, x float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	lg, _ := math.Lgamma(s)
+	if x < s+1 {
+		// Series expansion
+		sum, term := 1/s, 1/s
+		for n := 1; n < 1000; n++ {
+			term *= x / (s + float64(n))
+			sum += term
+			if term < sum*1e-15 {
+				break
+			}
+		}
+		return sum * math.Exp(-x+s*math.Log(x)-lg)
+	}
+
+	// Continued fraction for Q(s, x), by the modified Lentz method
+	const tiny = 1e-300
+	b := x + 1 - s
+	c := 1 / tiny
+	d := 1 / b
+	h := d
+	for i := 1; i < 1000; i++ {
+		an := -float64(i) * (float64(i) - s)
+		b += 2
+		d = an*d + b
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = b + an/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		delta := d * c
+		h *= delta
+		if math.Abs(delta-1) < 1e-15 {
+			break
+		}
+	}
+	return 1 - math.Exp(-x+s*math.Log(x)-lg)*h
+}
@@ -0,0 +1,299 @@
+package stego
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg" // Register JPEG decoder
+	_ "image/png"  // Register PNG decoder
+	"sync"
+	"unicode/utf8"
+)
+
+// SniffLen is how much of the start of a file Carrier.Detect is given.
+const SniffLen = 512
+
+// Carrier is a file format that can hide data. The built-in carriers are
+// registered in the order Detect tries them: WAVCarrier, JPEGCarrier,
+// ImageCarrier, ContainerCarrier (which claims PDFs, even ASCII ones) and
+// TextCarrier (any other text). Others can be added with Register.
+type Carrier interface {
+	// Name is a short lower-case name for the format, unique among carriers.
+	Name() string
+
+	// Detect reports whether a file starting with head is in this format.
+	// head is the first SniffLen bytes of the file, or all of a shorter one.
+	Detect(head []byte) bool
+
+	// Capacity returns the most bytes Embed can hide in file.
+	Capacity(file []byte, opts Options) (int, error)
+
+	// Embed hides data in a copy of file and returns it, with the extension
+	// to write it under, or "" to keep the original's.
+	Embed(file, data []byte, opts Options) ([]byte, string, error)
+
+	// Extract retrieves data hidden by Embed with the same options, or
+	// returns ErrNoHiddenData.
+	Extract(file []byte, opts Options) ([]byte, error)
+}
+
+// The built-in carriers.
+var (
+	// WAVCarrier hides data in the samples of 16-bit PCM WAV files, as
+	// EmbedWAV does.
+	WAVCarrier Carrier = wavCarrier{}
+
+	// JPEGCarrier hides data in the DCT coefficients of baseline JPEGs, as
+	// EmbedJPEG does, so they stay JPEGs.
+	JPEGCarrier Carrier = jpegCarrier{}
+
+	// ImageCarrier hides data in the pixels of any decodable image, as
+	// EmbedWithOptions does, and writes the copy as a PNG.
+	ImageCarrier Carrier = imageCarrier{}
+
+	// ContainerCarrier stores data where PNG, JPEG, PDF and ZIP files allow
+	// extra bytes, as EmbedContainer does. It holds any amount.
+	ContainerCarrier Carrier = containerCarrier{}
+
+	// TextCarrier hides data in UTF-8 text, as EmbedText does with
+	// ZeroWidth; NewTextCarrier picks another method. The options are not
+	// used.
+	TextCarrier Carrier = textCarrier{}
+)
+
+// NewTextCarrier returns a TextCarrier that hides data with method. What it
+// hides is extracted by any text carrier, whichever method it uses.
+func NewTextCarrier(method TextMethod) Carrier {
+	return textCarrier{method: method}
+}
+
+var registry struct {
+	sync.RWMutex
+	carriers []Carrier
+}
+
+func init() {
+	for _, c := range []Carrier{WAVCarrier, JPEGCarrier, ImageCarrier, ContainerCarrier, TextCarrier} {
+		Register(c)
+	}
+}
+
+// Register adds a carrier after those already registered. It panics if a
+// carrier of the same name is registered, as two formats claiming one name
+// is a programming error.
+func Register(c Carrier) {
+	registry.Lock()
+	defer registry.Unlock()
+	for _, other := range registry.carriers {
+		if other.Name() == c.Name() {
+			panic(fmt.Sprintf("stego: carrier %q registered twice", c.Name()))
+		}
+	}
+	registry.carriers = append(registry.carriers, c)
+}
+
+// Carriers returns the registered carriers, in the order Detect tries them.
+func Carriers() []Carrier {
+	registry.RLock()
+	defer registry.RUnlock()
+	return append([]Carrier(nil), registry.carriers...)
+}
+
+// Detect returns the first registered carrier that recognizes a file
+// starting with head, or nil if none does.
+func Detect(head []byte) Carrier {
+	for _, c := range Carriers() {
+		if c.Detect(head) {
+			return c
+		}
+	}
+	return nil
+}
+
+// ExtractAny retrieves data hidden in file by any carrier recognizing it,
+// trying them in order. Carriers that find nothing or cannot hold data in
+// this particular file are passed over; if none finds anything, the first
+// other error is returned, else ErrNoHiddenData.
+func ExtractAny(file []byte, opts Options) ([]byte, error) {
+	head := file[:min(len(file), SniffLen)]
+	var firstErr error
+	for _, c := range Carriers() {
+		if !c.Detect(head) {
+			continue
+		}
+		data, err := c.Extract(file, opts)
+		if err == nil {
+			return data, nil
+		}
+		if firstErr == nil && !isNothingHidden(err) {
+			firstErr = fmt.Errorf("%s: %w", c.Name(), err)
+		}
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return nil, ErrNoHiddenData
+}
+
+// isNothingHidden reports whether an extraction error only means there was
+// nothing to find.
+func isNothingHidden(err error) bool {
+	for _, target := range []error{ErrNoHiddenData, ErrUnsupportedJPEG, ErrUnsupportedWAV, ErrUnsupportedContainer} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+type wavCarrier struct{}
+
+func (wavCarrier) Name() string            { return "wav" }
+func (wavCarrier) Detect(head []byte) bool { return IsWAV(head) }
+
+func (wavCarrier) Capacity(file []byte, opts Options) (int, error) {
+	return CapacityWAV(file, opts)
+}
+
+func (wavCarrier) Embed(file, data []byte, opts Options) ([]byte, string, error) {
+	out, err := EmbedWAV(file, data, opts)
+	return out, "", err
+}
+
+func (wavCarrier) Extract(file []byte, opts Options) ([]byte, error) {
+	return ExtractWAV(file, opts)
+}
+
+type jpegCarrier struct{}
+
+func (jpegCarrier) Name() string            { return "jpeg" }
+func (jpegCarrier) Detect(head []byte) bool { return IsJPEG(head) }
+
+func (jpegCarrier) Capacity(file []byte, opts Options) (int, error) {
+	return CapacityJPEG(file, opts)
+}
+
+func (jpegCarrier) Embed(file, data []byte, opts Options) ([]byte, string, error) {
+	out, err := EmbedJPEG(file, data, opts)
+	return out, "", err
+}
+
+func (jpegCarrier) Extract(file []byte, opts Options) ([]byte, error) {
+	return ExtractJPEG(file, opts)
+}
+
+type imageCarrier struct{}
+
+func (imageCarrier) Name() string { return "image" }
+
+// Detect accepts JPEGs too, whose metadata can push the size past head, so
+// that progressive ones can be written as PNGs instead.
+func (imageCarrier) Detect(head []byte) bool {
+	if IsJPEG(head) {
+		return true
+	}
+	_, _, err := image.DecodeConfig(bytes.NewReader(head))
+	return err == nil
+}
+
+func (imageCarrier) Capacity(file []byte, opts Options) (int, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(file))
+	if err != nil {
+		return 0, fmt.Errorf("invalid image: %w", err)
+	}
+	return Capacity(image.Rect(0, 0, cfg.Width, cfg.Height), opts), nil
+}
+
+func (imageCarrier) Embed(file, data []byte, opts Options) ([]byte, string, error) {
+	img, _, err := image.Decode(bytes.NewReader(file))
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid image: %w", err)
+	}
+	stegoImg, err := EmbedWithOptions(img, data, opts)
+	if err != nil {
+		return nil, "", err
+	}
+	// Must encode as PNG to be lossless; a PNG carrier's metadata is kept
+	var out bytes.Buffer
+	if err := EncodePNG(&out, stegoImg, file); err != nil {
+		return nil, "", fmt.Errorf("failed to encode png: %w", err)
+	}
+	return out.Bytes(), ".png", nil
+}
+
+func (imageCarrier) Extract(file []byte, opts Options) ([]byte, error) {
+	img, _, err := image.Decode(bytes.NewReader(file))
+	if err != nil {
+		return nil, fmt.Errorf("invalid image: %w", err)
+	}
+	return ExtractWithOptions(img, opts)
+}
+
+type textCarrier struct {
+	method TextMethod
+}
+
+func (textCarrier) Name() string { return "text" }
+
+// Detect accepts UTF-8 without control characters other than tabs, line
+// breaks and form feeds. A rune cut off at the end of head is allowed.
+func (textCarrier) Detect(head []byte) bool {
+	if len(head) == 0 {
+		return false
+	}
+	for i := len(head) - 1; i >= max(0, len(head)-utf8.UTFMax+1); i-- {
+		if utf8.RuneStart(head[i]) {
+			if !utf8.FullRune(head[i:]) {
+				head = head[:i]
+			}
+			break
+		}
+	}
+	if !utf8.Valid(head) {
+		return false
+	}
+	for _, b := range head {
+		if b < 0x20 && b != '\t' && b != '\n' && b != '\r' && b != '\f' || b == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+func (c textCarrier) Capacity(file []byte, _ Options) (int, error) {
+	if !utf8.Valid(file) {
+		return 0, errors.New("carrier is not UTF-8 text")
+	}
+	return CapacityText(file, c.method), nil
+}
+
+func (c textCarrier) Embed(file, data []byte, _ Options) ([]byte, string, error) {
+	out, err := EmbedText(file, data, c.method)
+	return out, "", err
+}
+
+func (textCarrier) Extract(file []byte, _ Options) ([]byte, error) {
+	return ExtractText(file)
+}
+
+type containerCarrier struct{}
+
+func (containerCarrier) Name() string            { return "container" }
+func (containerCarrier) Detect(head []byte) bool { return ContainerFormat(head) != "" }
+
+func (containerCarrier) Capacity(file []byte, _ Options) (int, error) {
+	if ContainerFormat(file) == "" {
+		return 0, ErrUnsupportedContainer
+	}
+	return 1<<31 - 1, nil
+}
+
+func (containerCarrier) Embed(file, data []byte, _ Options) ([]byte, string, error) {
+	out, err := EmbedContainer(file, data)
+	return out, "", err
+}
+
+func (containerCarrier) Extract(file []byte, _ Options) ([]byte, error) {
+	return ExtractContainer(file)
+}
@@ -0,0 +1,217 @@
+package stego
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+// ErrUnsupportedContainer indicates a carrier file EmbedContainer cannot hold
+// data in.
+var ErrUnsupportedContainer = errors.New("unsupported container format (want PNG, JPEG, PDF or ZIP)")
+
+const (
+	// containerChunk is the private PNG chunk holding the data: ancillary,
+	// private and safe to copy, so viewers and editors leave it alone.
+	containerChunk = "hoRx"
+
+	// containerMagic ends a container frame: [data] [length (64-bit)] [magic].
+	containerMagic = "HORCRUX\x00"
+	frameTrailer   = 8 + len(containerMagic)
+
+	// tailSearch is how far from the end of a file a frame is looked for.
+	// PDF readers look for %%EOF within the last 1024 bytes too.
+	tailSearch = 1024
+)
+
+// Container formats, as named by ContainerFormat.
+const (
+	FormatPNG  = "png"
+	FormatJPEG = "jpeg"
+	FormatPDF  = "pdf"
+	FormatZIP  = "zip"
+)
+
+// startxrefPattern finds the last cross-reference pointer of a PDF.
+var startxrefPattern = regexp.MustCompile(`startxref\s+(\d+)\s+%%EOF\s*$`)
+
+// ContainerFormat names the format of a file from its first bytes, or
+// returns "" if EmbedContainer does not support it.
+func ContainerFormat(head []byte) string {
+	switch {
+	case bytes.HasPrefix(head, []byte(pngSignature)):
+		return FormatPNG
+	case IsJPEG(head):
+		return FormatJPEG
+	case bytes.HasPrefix(head, []byte("%PDF-")):
+		return FormatPDF
+	case bytes.HasPrefix(head, []byte("PK\x03\x04")), bytes.HasPrefix(head, []byte("PK\x05\x06")):
+		return FormatZIP
+	}
+	return ""
+}
+
+// EmbedContainer stores data in a copy of the carrier file where its format
+// allows extra bytes that readers skip, and returns the copy. Unlike pixel
+// embedding there is no size limit and the content is untouched, but anyone
+// inspecting the bytes of the file will find the data.
+//
+//	PNG   a private "hoRx" chunk before IEND
+//	JPEG  appended after the EOI marker
+//	PDF   appended after %%EOF, followed by a copy of the final startxref
+//	ZIP   inserted before the central directory, whose offset is updated
+func EmbedContainer(carrier []byte, data []byte) ([]byte, error) {
+	switch ContainerFormat(carrier) {
+	case FormatPNG:
+		chunks, err := readPNGChunks(carrier)
+		if err != nil {
+			return nil, err
+		}
+		iend := chunks[len(chunks)-1]
+		if iend.typ != "IEND" {
+			return nil, errors.New("corrupt PNG file: no IEND chunk")
+		}
+		var out bytes.Buffer
+		out.Write(carrier[:iend.off])
+		writePNGChunk(&out, containerChunk, data)
+		out.Write(carrier[iend.off:])
+		return out.Bytes(), nil
+
+	case FormatJPEG:
+		return appendFrame(bytes.Clone(carrier), data), nil
+
+	case FormatPDF:
+		m := startxrefPattern.FindSubmatch(carrier[max(0, len(carrier)-tailSearch):])
+		if m == nil {
+			return nil, errors.New("corrupt PDF file: no startxref")
+		}
+		out := append(bytes.Clone(carrier), '\n')
+		out = appendFrame(out, data)
+		return fmt.Appendf(out, "\nstartxref\n%s\n%%%%EOF\n", m[1]), nil
+
+	case FormatZIP:
+		return embedZIP(carrier, data)
+	}
+	return nil, ErrUnsupportedContainer
+}
+
+// ExtractContainer retrieves data stored by EmbedContainer.
+func ExtractContainer(file []byte) ([]byte, error) {
+	switch ContainerFormat(file) {
+	case FormatPNG:
+		chunks, err := readPNGChunks(file)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range chunks {
+			if c.typ == containerChunk {
+				return c.data, nil
+			}
+		}
+		return nil, ErrNoHiddenData
+
+	case FormatJPEG, FormatPDF:
+		tail := max(0, len(file)-tailSearch)
+		i := bytes.LastIndex(file[tail:], []byte(containerMagic))
+		if i < 0 {
+			return nil, ErrNoHiddenData
+		}
+		return frameBefore(file, tail+i+len(containerMagic))
+
+	case FormatZIP:
+		eocd, err := findZIPDirectory(file)
+		if err != nil {
+			return nil, err
+		}
+		return frameBefore(file, int(eocd.cdOffset))
+	}
+	return nil, ErrUnsupportedContainer
+}
+
+func appendFrame(dst, data []byte) []byte {
+	dst = append(dst, data...)
+	dst = binary.BigEndian.AppendUint64(dst, uint64(len(data)))
+	return append(dst, containerMagic...)
+}
+
+// frameBefore returns the data of the frame ending at end.
+func frameBefore(file []byte, end int) ([]byte, error) {
+	if end < frameTrailer || end > len(file) || string(file[end-len(containerMagic):end]) != containerMagic {
+		return nil, ErrNoHiddenData
+	}
+	n := binary.BigEndian.Uint64(file[end-frameTrailer:])
+	if n == 0 || n > uint64(end-frameTrailer) {
+		return nil, ErrNoHiddenData
+	}
+	start := end - frameTrailer - int(n)
+	return file[start : end-frameTrailer], nil
+}
+
+// zipDirectory locates the central directory of a ZIP file and the records
+// pointing at it.
+type zipDirectory struct {
+	eocd     int    // end of central directory record
+	zip64    int    // ZIP64 end of central directory record, or -1
+	locator  int    // ZIP64 end of central directory locator, or -1
+	cdOffset uint64 // start of the central directory
+}
+
+func findZIPDirectory(file []byte) (*zipDirectory, error) {
+	// The record is 22 bytes, followed by a comment of up to 64 KiB
+	d := &zipDirectory{eocd: -1, zip64: -1, locator: -1}
+	for i := len(file) - 22; i >= 0 && i >= len(file)-22-0xFFFF; i-- {
+		if string(file[i:i+4]) == "PK\x05\x06" && i+22+int(binary.LittleEndian.Uint16(file[i+20:])) == len(file) {
+			d.eocd = i
+			break
+		}
+	}
+	if d.eocd < 0 {
+		return nil, errors.New("corrupt ZIP file: no end of central directory")
+	}
+	d.cdOffset = uint64(binary.LittleEndian.Uint32(file[d.eocd+16:]))
+
+	if l := d.eocd - 20; l >= 0 && string(file[l:l+4]) == "PK\x06\x07" {
+		d.locator = l
+		d.zip64 = int(binary.LittleEndian.Uint64(file[l+8:]))
+		if d.zip64 < 0 || d.zip64+56 > len(file) || string(file[d.zip64:d.zip64+4]) != "PK\x06\x06" {
+			return nil, errors.New("corrupt ZIP file: bad ZIP64 end of central directory")
+		}
+		d.cdOffset = binary.LittleEndian.Uint64(file[d.zip64+48:])
+	}
+	if d.cdOffset > uint64(d.eocd) {
+		return nil, errors.New("corrupt ZIP file: bad central directory offset")
+	}
+	return d, nil
+}
+
+// embedZIP inserts a frame between the last entry and the central directory.
+// Entries are found through the central directory, so only the pointers to it
+// need moving.
+func embedZIP(carrier, data []byte) ([]byte, error) {
+	d, err := findZIPDirectory(carrier)
+	if err != nil {
+		return nil, err
+	}
+	frame := appendFrame(nil, data)
+	shift := uint64(len(frame))
+	if d.zip64 < 0 && d.cdOffset+shift >= 0xFFFFFFFF {
+		return nil, errors.New("data too large for a ZIP file without ZIP64 records")
+	}
+
+	out := make([]byte, 0, len(carrier)+len(frame))
+	out = append(out, carrier[:d.cdOffset]...)
+	out = append(out, frame...)
+	out = append(out, carrier[d.cdOffset:]...)
+
+	at := func(offset int) int { return offset + len(frame) }
+	if d.zip64 >= 0 {
+		binary.LittleEndian.PutUint64(out[at(d.zip64)+48:], d.cdOffset+shift)
+		binary.LittleEndian.PutUint64(out[at(d.locator)+8:], uint64(d.zip64)+shift)
+	}
+	if offset := binary.LittleEndian.Uint32(carrier[d.eocd+16:]); offset != 0xFFFFFFFF {
+		binary.LittleEndian.PutUint32(out[at(d.eocd)+16:], offset+uint32(shift))
+	}
+	return out, nil
+}
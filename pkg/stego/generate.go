@@ -0,0 +1,210 @@
+package stego
+
+import (
+	"fmt"
+	"image"
+	"math"
+	mrand "math/rand/v2"
+)
+
+// Generated carriers are 4:3, at least minGeneratedWidth wide, and sized so
+// the payload fills at most 1/generatedHeadroom of their capacity: a cover
+// that is only partly used is far harder to tell from a clean photo. In
+// smaller images the detectors in Analyze are too noisy to pass reliably,
+// clean or not.
+const (
+	minGeneratedWidth = 640
+	maxGeneratedWidth = 8192
+	generatedHeadroom = 2
+)
+
+// noiseOctaves is how many octaves of value noise make up a texture, each
+// twice the frequency and half the amplitude of the last.
+const noiseOctaves = 6
+
+// Generate returns a width x height image of a natural-looking texture: a
+// lit gradient, fractal noise warped into organic shapes, and the sensor
+// noise and tone curve of a camera, so that it passes for a photo and its
+// low bits behave like one's. The same seed gives the same image.
+func Generate(width, height int, seed uint64) *image.NRGBA {
+	rng := mrand.New(mrand.NewPCG(seed, seed^0x686f72637275780a))
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+
+	// 1. A palette around one hue: two ends of the gradient and a texture
+	// colour, muted as most scenes are
+	hue := rng.Float64()
+	light := hsvToLinear(hue, 0.15+0.3*rng.Float64(), 0.6+0.3*rng.Float64())
+	dark := hsvToLinear(hue+0.1*(rng.Float64()-0.5), 0.2+0.3*rng.Float64(), 0.25+0.25*rng.Float64())
+	texture := hsvToLinear(hue+0.3*(rng.Float64()-0.5), 0.1+0.4*rng.Float64(), 0.3+0.5*rng.Float64())
+	angle := 2 * math.Pi * rng.Float64()
+	dx, dy := math.Cos(angle), math.Sin(angle)
+
+	// 2. Noise fields: the shapes, the warp that bends them, and fine detail
+	shapes, warpX, warpY, detail := newFractalNoise(rng), newFractalNoise(rng), newFractalNoise(rng), newFractalNoise(rng)
+	scale := float64(max(width, height)) / (2 + 3*rng.Float64())
+	tone := cameraToneCurve(rng)
+
+	// 3. Each pixel, as a sensor would record it. Every row has noise of its
+	// own, so that bands of rows can be made in parallel alike.
+	parallel(height, max(minParallelPixels/max(width, 1), 1), func(lo, hi int) {
+		for y := lo; y < hi; y++ {
+			rng := mrand.New(mrand.NewPCG(seed, uint64(y)))
+			v := float64(y)/float64(height) - 0.5
+			for x := 0; x < width; x++ {
+				u := float64(x)/float64(width) - 0.5
+				px, py := float64(x)/scale, float64(y)/scale
+
+				// Lighting across the frame, darker towards the corners
+				t := math.Min(math.Max(0.5+u*dx+v*dy, 0), 1)
+				shade := 1 - 0.35*(u*u+v*v)
+				wx, wy := warpX.at(px, py), warpY.at(px+5.2, py+1.3)
+				f := smoothstep(0.35, 0.65, shapes.at(px+1.5*wx, py+1.5*wy))
+				d := 0.8 + 0.4*detail.at(px*8, py*8)
+
+				// Stray light keeps the shadows off zero. Shot noise grows
+				// with the signal, read noise does not; some of it is shared
+				// by the channels, as after demosaicing
+				shared := rng.NormFloat64()
+				i := img.PixOffset(x, y)
+				for c := 0; c < 3; c++ {
+					lin := 0.02 + 0.9*((dark[c]*(1-t)+light[c]*t)*(1-f)+texture[c]*f)*d*shade
+					sensor := lin*255 + (math.Sqrt(lin*255)*0.35)*(0.6*shared+0.8*rng.NormFloat64()) + 0.8*rng.NormFloat64()
+					img.Pix[i+c] = tone[min(max(int(sensor*toneSteps), 0), len(tone)-1)]
+				}
+				img.Pix[i+3] = 0xff
+			}
+		}
+	})
+	return img
+}
+
+// GenerateFor returns a generated image of GeneratedSize for n bytes. The
+// same seed gives the same image.
+func GenerateFor(n int, opts Options, seed uint64) (*image.NRGBA, error) {
+	width, height, err := GeneratedSize(n, opts)
+	if err != nil {
+		return nil, err
+	}
+	return Generate(width, height, seed), nil
+}
+
+// GeneratedSize returns the smallest 4:3 size, at least minGeneratedWidth
+// wide, that holds n bytes with opts at most half full.
+func GeneratedSize(n int, opts Options) (width, height int, err error) {
+	if err := opts.validate(); err != nil {
+		return 0, 0, err
+	}
+	fits := func(w int) bool {
+		return Capacity(image.Rect(0, 0, w, w*3/4), opts) >= n*generatedHeadroom
+	}
+	if !fits(maxGeneratedWidth) {
+		return 0, 0, fmt.Errorf("%w: a generated image would have to be wider than %d pixels", ErrMessageTooLarge, maxGeneratedWidth)
+	}
+	// Capacity grows with the width, so search for the narrowest that fits
+	lo, hi := minGeneratedWidth, maxGeneratedWidth
+	for lo < hi {
+		if mid := (lo + hi) / 2; fits(mid) {
+			hi = mid
+		} else {
+			lo = mid + 1
+		}
+	}
+	return lo, lo * 3 / 4, nil
+}
+
+// fractalNoise is value noise summed over octaves, in [0, 1).
+type fractalNoise struct {
+	lattice [noiseOctaves][noiseLattice * noiseLattice]float64
+	offsets [noiseOctaves][2]float64
+}
+
+// noiseLattice is the period of each octave's lattice of random values.
+const noiseLattice = 64
+
+func newFractalNoise(rng *mrand.Rand) *fractalNoise {
+	n := new(fractalNoise)
+	for o := range n.lattice {
+		for i := range n.lattice[o] {
+			n.lattice[o][i] = rng.Float64()
+		}
+		n.offsets[o] = [2]float64{rng.Float64() * noiseLattice, rng.Float64() * noiseLattice}
+	}
+	return n
+}
+
+func (n *fractalNoise) at(x, y float64) float64 {
+	sum, amp, total := 0.0, 1.0, 0.0
+	for o := range n.lattice {
+		freq := float64(int(1) << o)
+		sum += amp * n.octave(o, x*freq+n.offsets[o][0], y*freq+n.offsets[o][1])
+		total += amp
+		amp /= 2
+	}
+	return sum / total
+}
+
+// octave interpolates octave o's lattice smoothly between its points.
+func (n *fractalNoise) octave(o int, x, y float64) float64 {
+	fx, fy := math.Floor(x), math.Floor(y)
+	x0, y0 := int(fx)&(noiseLattice-1), int(fy)&(noiseLattice-1)
+	x1, y1 := (x0+1)&(noiseLattice-1), (y0+1)&(noiseLattice-1)
+	sx, sy := fade(x-fx), fade(y-fy)
+	l := &n.lattice[o]
+	top := l[y0*noiseLattice+x0]*(1-sx) + l[y0*noiseLattice+x1]*sx
+	bottom := l[y1*noiseLattice+x0]*(1-sx) + l[y1*noiseLattice+x1]*sx
+	return top*(1-sy) + bottom*sy
+}
+
+func smoothstep(edge0, edge1, x float64) float64 {
+	return fade(min(max((x-edge0)/(edge1-edge0), 0), 1))
+}
+
+// fade eases t in [0, 1] in and out.
+func fade(t float64) float64 {
+	return t * t * (3 - 2*t)
+}
+
+// toneSteps is how many steps of each unit of sensor value cameraToneCurve
+// tabulates.
+const toneSteps = 16
+
+// cameraToneCurve tabulates how a camera maps linear sensor values to output
+// values: between a black and a white point, then through sRGB gamma. The
+// gamma stretches the shadows, which leaves the uneven counts of each value
+// pair real photos have and the chi-square attack expects.
+func cameraToneCurve(rng *mrand.Rand) *[256 * toneSteps]uint8 {
+	black := 2 + 6*rng.Float64()
+	white := 235 + 20*rng.Float64()
+	lut := new([256 * toneSteps]uint8)
+	for i := range lut {
+		v := math.Min(math.Max((float64(i)/toneSteps-black)/(white-black), 0), 1)
+		lut[i] = uint8(math.Round(255 * math.Pow(v, 1/2.2)))
+	}
+	return lut
+}
+
+// hsvToLinear converts a colour to linear-light RGB in [0, 1].
+func hsvToLinear(h, s, v float64) [3]float64 {
+	h = (h - math.Floor(h)) * 6
+	c := v * s
+	x := c * (1 - math.Abs(math.Mod(h, 2)-1))
+	var rgb [3]float64
+	switch int(h) {
+	case 0:
+		rgb = [3]float64{c, x, 0}
+	case 1:
+		rgb = [3]float64{x, c, 0}
+	case 2:
+		rgb = [3]float64{0, c, x}
+	case 3:
+		rgb = [3]float64{0, x, c}
+	case 4:
+		rgb = [3]float64{x, 0, c}
+	default:
+		rgb = [3]float64{c, 0, x}
+	}
+	for i := range rgb {
+		rgb[i] = math.Pow(rgb[i]+v-c, 2.2)
+	}
+	return rgb
+}
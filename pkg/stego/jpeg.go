@@ -0,0 +1,786 @@
+package stego
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/bits"
+)
+
+// JPEG markers used by the coefficient codec.
+const (
+	markerSOF0 = 0xC0 // baseline
+	markerSOF1 = 0xC1 // extended sequential, Huffman
+	markerDHT  = 0xC4
+	markerRST0 = 0xD0
+	markerSOI  = 0xD8
+	markerEOI  = 0xD9
+	markerSOS  = 0xDA
+	markerDRI  = 0xDD
+)
+
+// errMissingCode means a Huffman table has no code for a symbol the new
+// coefficients need, so optimized tables must be written instead.
+var errMissingCode = errors.New("symbol missing from Huffman table")
+
+// jpegFile is a sequential Huffman JPEG held as its quantized DCT
+// coefficients, with everything else kept as it was read so it can be
+// written back unchanged apart from them. Nothing is ever inverse
+// transformed, so no quality is lost.
+type jpegFile struct {
+	segments []jpegSegment // from after SOI up to EOI, in file order
+	trailer  []byte        // anything after EOI
+
+	width, height int
+	hMax, vMax    int
+	mcusX, mcusY  int
+	comps         []jpegComponent
+
+	// coefs holds every component's blocks back to back, 64 coefficients
+	// per block in zigzag order. Blocks never coded stay zero.
+	coefs []int16
+}
+
+type jpegSegment struct {
+	marker byte
+	data   []byte    // payload, without the length
+	scan   *jpegScan // set for SOS
+}
+
+type jpegComponent struct {
+	id     byte
+	h, v   int
+	stride int // blocks per row in coefs
+	base   int // offset of the first block in coefs
+}
+
+type jpegScan struct {
+	comps   []scanComponent
+	restart int
+	dc, ac  [4]*huffTable // tables in force when the scan starts
+}
+
+type scanComponent struct {
+	comp   int // index into jpegFile.comps
+	td, ta int // table selectors
+}
+
+// block returns the coefficients of component c's block at (bx, by).
+func (f *jpegFile) block(c, bx, by int) []int16 {
+	comp := &f.comps[c]
+	off := comp.base + (by*comp.stride+bx)*64
+	return f.coefs[off : off+64]
+}
+
+// parseJPEG reads a JPEG file and decodes its scans into coefficients.
+func parseJPEG(data []byte) (*jpegFile, error) {
+	if !IsJPEG(data) {
+		return nil, fmt.Errorf("%w: not a JPEG file", ErrUnsupportedJPEG)
+	}
+	f := &jpegFile{}
+	var dc, ac [4]*huffTable
+	restart := 0
+
+	pos := 2
+	for {
+		// 1. Next marker, skipping fill bytes
+		if pos >= len(data) || data[pos] != 0xFF {
+			return nil, fmt.Errorf("corrupt JPEG: expected a marker at offset %d", pos)
+		}
+		for pos < len(data) && data[pos] == 0xFF {
+			pos++
+		}
+		if pos >= len(data) {
+			return nil, errors.New("corrupt JPEG: missing end of image")
+		}
+		marker := data[pos]
+		pos++
+		if marker == markerEOI {
+			f.trailer = data[pos:]
+			break
+		}
+		if marker == markerSOI || marker == 0x01 || (marker >= markerRST0 && marker <= markerRST0+7) {
+			return nil, fmt.Errorf("corrupt JPEG: unexpected marker %#02x", marker)
+		}
+
+		// 2. Segment payload
+		if pos+2 > len(data) {
+			return nil, errors.New("corrupt JPEG: truncated segment")
+		}
+		length := int(binary.BigEndian.Uint16(data[pos:]))
+		if length < 2 || pos+length > len(data) {
+			return nil, errors.New("corrupt JPEG: truncated segment")
+		}
+		seg := jpegSegment{marker: marker, data: data[pos+2 : pos+length]}
+		pos += length
+
+		var err error
+		switch {
+		case marker == markerSOF0 || marker == markerSOF1:
+			err = f.parseFrame(seg.data)
+		case marker >= 0xC2 && marker <= 0xCF && marker != markerDHT && marker != 0xC8 && marker != 0xCC:
+			return nil, fmt.Errorf("%w: progressive, lossless or arithmetic-coded", ErrUnsupportedJPEG)
+		case marker == markerDHT:
+			err = parseDHT(seg.data, &dc, &ac)
+		case marker == markerDRI:
+			if len(seg.data) != 2 {
+				return nil, errors.New("corrupt JPEG: bad DRI segment")
+			}
+			restart = int(binary.BigEndian.Uint16(seg.data))
+		case marker == markerSOS:
+			if f.comps == nil {
+				return nil, errors.New("corrupt JPEG: scan before frame header")
+			}
+			seg.scan, err = f.parseScanHeader(seg.data, dc, ac, restart)
+			if err != nil {
+				return nil, err
+			}
+			end := entropyEnd(data, pos)
+			err = f.decodeScan(seg.scan, data[pos:end])
+			pos = end
+		}
+		if err != nil {
+			return nil, err
+		}
+		f.segments = append(f.segments, seg)
+	}
+
+	if f.comps == nil {
+		return nil, errors.New("corrupt JPEG: no frame header")
+	}
+	return f, nil
+}
+
+// parseFrame reads an SOF segment and allocates the coefficient blocks.
+func (f *jpegFile) parseFrame(d []byte) error {
+	if f.comps != nil {
+		return errors.New("corrupt JPEG: more than one frame")
+	}
+	if len(d) < 6 {
+		return errors.New("corrupt JPEG: bad frame header")
+	}
+	if d[0] != 8 {
+		return fmt.Errorf("%w: %d-bit precision", ErrUnsupportedJPEG, d[0])
+	}
+	f.height = int(binary.BigEndian.Uint16(d[1:]))
+	f.width = int(binary.BigEndian.Uint16(d[3:]))
+	n := int(d[5])
+	if f.height == 0 || f.width == 0 {
+		return fmt.Errorf("%w: image height defined later by DNL", ErrUnsupportedJPEG)
+	}
+	if n == 0 || n > 4 || len(d) != 6+3*n {
+		return errors.New("corrupt JPEG: bad frame header")
+	}
+
+	f.hMax, f.vMax = 1, 1
+	f.comps = make([]jpegComponent, n)
+	for i := range f.comps {
+		c := d[6+3*i:]
+		h, v := int(c[1]>>4), int(c[1]&15)
+		if h < 1 || h > 4 || v < 1 || v > 4 {
+			return errors.New("corrupt JPEG: bad sampling factors")
+		}
+		f.comps[i] = jpegComponent{id: c[0], h: h, v: v}
+		f.hMax, f.vMax = max(f.hMax, h), max(f.vMax, v)
+	}
+
+	f.mcusX = (f.width + 8*f.hMax - 1) / (8 * f.hMax)
+	f.mcusY = (f.height + 8*f.vMax - 1) / (8 * f.vMax)
+	total := 0
+	for i := range f.comps {
+		c := &f.comps[i]
+		c.stride = f.mcusX * c.h
+		c.base = total
+		total += c.stride * f.mcusY * c.v * 64
+	}
+	f.coefs = make([]int16, total)
+	return nil
+}
+
+// blocksWide and blocksHigh give the blocks a scan of component c alone
+// covers: only those holding image samples, not the MCU padding.
+func (f *jpegFile) blocksWide(c int) int {
+	w := (f.width*f.comps[c].h + f.hMax - 1) / f.hMax
+	return (w + 7) / 8
+}
+
+func (f *jpegFile) blocksHigh(c int) int {
+	h := (f.height*f.comps[c].v + f.vMax - 1) / f.vMax
+	return (h + 7) / 8
+}
+
+func (f *jpegFile) parseScanHeader(d []byte, dc, ac [4]*huffTable, restart int) (*jpegScan, error) {
+	if len(d) < 1 {
+		return nil, errors.New("corrupt JPEG: bad scan header")
+	}
+	n := int(d[0])
+	if n < 1 || n > 4 || len(d) != 4+2*n {
+		return nil, errors.New("corrupt JPEG: bad scan header")
+	}
+	if ss, se, a := d[1+2*n], d[2+2*n], d[3+2*n]; ss != 0 || se != 63 || a != 0 {
+		return nil, fmt.Errorf("%w: not a sequential scan", ErrUnsupportedJPEG)
+	}
+
+	s := &jpegScan{restart: restart, dc: dc, ac: ac}
+	for i := 0; i < n; i++ {
+		id, sel := d[1+2*i], d[2+2*i]
+		sc := scanComponent{comp: -1, td: int(sel >> 4), ta: int(sel & 15)}
+		for ci, c := range f.comps {
+			if c.id == id {
+				sc.comp = ci
+			}
+		}
+		if sc.comp < 0 || sc.td > 3 || sc.ta > 3 {
+			return nil, errors.New("corrupt JPEG: bad scan component")
+		}
+		if dc[sc.td] == nil || ac[sc.ta] == nil {
+			return nil, errors.New("corrupt JPEG: scan uses an undefined Huffman table")
+		}
+		s.comps = append(s.comps, sc)
+	}
+	return s, nil
+}
+
+// entropyEnd returns the offset of the marker ending the entropy-coded data
+// that starts at pos. Stuffed zero bytes and restart markers belong to the
+// data.
+func entropyEnd(data []byte, pos int) int {
+	for ; pos+1 < len(data); pos++ {
+		if data[pos] != 0xFF {
+			continue
+		}
+		next := data[pos+1]
+		if next != 0 && (next < markerRST0 || next > markerRST0+7) {
+			return pos
+		}
+		pos++
+	}
+	return len(data)
+}
+
+// walkScan calls fn for every block of the scan in coding order, with the
+// index of the MCU it belongs to.
+func (f *jpegFile) walkScan(s *jpegScan, fn func(mcu int, sc *scanComponent, blk []int16) error) error {
+	if len(s.comps) == 1 {
+		// Non-interleaved: one block per MCU
+		sc := &s.comps[0]
+		w, h := f.blocksWide(sc.comp), f.blocksHigh(sc.comp)
+		for by := 0; by < h; by++ {
+			for bx := 0; bx < w; bx++ {
+				if err := fn(by*w+bx, sc, f.block(sc.comp, bx, by)); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	for my := 0; my < f.mcusY; my++ {
+		for mx := 0; mx < f.mcusX; mx++ {
+			for i := range s.comps {
+				sc := &s.comps[i]
+				c := &f.comps[sc.comp]
+				for y := 0; y < c.v; y++ {
+					for x := 0; x < c.h; x++ {
+						if err := fn(my*f.mcusX+mx, sc, f.block(sc.comp, mx*c.h+x, my*c.v+y)); err != nil {
+							return err
+						}
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (f *jpegFile) decodeScan(s *jpegScan, data []byte) error {
+	r := &bitReader{data: data}
+	preds := make([]int16, len(f.comps))
+	lastMCU := -1
+	return f.walkScan(s, func(mcu int, sc *scanComponent, blk []int16) error {
+		if mcu != lastMCU {
+			if s.restart > 0 && mcu > 0 && mcu%s.restart == 0 {
+				if err := r.restart(); err != nil {
+					return err
+				}
+				clear(preds)
+			}
+			lastMCU = mcu
+		}
+
+		// DC difference
+		t, err := s.dc[sc.td].decode(r)
+		if err != nil {
+			return err
+		}
+		if t > 11 {
+			return errors.New("corrupt JPEG: bad DC coefficient")
+		}
+		diff, err := r.receiveExtend(t)
+		if err != nil {
+			return err
+		}
+		preds[sc.comp] += int16(diff)
+		blk[0] = preds[sc.comp]
+
+		// AC run lengths
+		for k := 1; k < 64; k++ {
+			rs, err := s.ac[sc.ta].decode(r)
+			if err != nil {
+				return err
+			}
+			run, size := int(rs>>4), rs&15
+			if size == 0 {
+				if run != 15 {
+					break // end of block
+				}
+				k += 15
+				continue
+			}
+			k += run
+			if k > 63 {
+				return errors.New("corrupt JPEG: AC coefficients past the end of a block")
+			}
+			v, err := r.receiveExtend(size)
+			if err != nil {
+				return err
+			}
+			blk[k] = int16(v)
+		}
+		return nil
+	})
+}
+
+// encode writes the file back with its current coefficients. It keeps the
+// original Huffman tables when they cover every symbol, and otherwise writes
+// optimized tables before each scan.
+func (f *jpegFile) encode() ([]byte, error) {
+	out, err := f.encodeWith(false)
+	if errors.Is(err, errMissingCode) {
+		return f.encodeWith(true)
+	}
+	return out, err
+}
+
+func (f *jpegFile) encodeWith(optimize bool) ([]byte, error) {
+	out := []byte{0xFF, markerSOI}
+	writeSegment := func(marker byte, data []byte) {
+		out = append(out, 0xFF, marker)
+		out = binary.BigEndian.AppendUint16(out, uint16(len(data)+2))
+		out = append(out, data...)
+	}
+
+	for _, seg := range f.segments {
+		if optimize && seg.marker == markerDHT {
+			continue
+		}
+		if seg.scan == nil {
+			writeSegment(seg.marker, seg.data)
+			continue
+		}
+
+		s := seg.scan
+		if optimize {
+			dht := f.optimizeTables(s)
+			writeSegment(markerDHT, dht)
+		}
+		writeSegment(seg.marker, seg.data)
+		data, err := f.encodeScan(s)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, data...)
+	}
+
+	out = append(out, 0xFF, markerEOI)
+	return append(out, f.trailer...), nil
+}
+
+func (f *jpegFile) encodeScan(s *jpegScan) ([]byte, error) {
+	w := &bitWriter{}
+	preds := make([]int16, len(f.comps))
+	lastMCU := -1
+	restarts := 0
+	err := f.walkScan(s, func(mcu int, sc *scanComponent, blk []int16) error {
+		if mcu != lastMCU {
+			if s.restart > 0 && mcu > 0 && mcu%s.restart == 0 {
+				w.flush()
+				w.buf = append(w.buf, 0xFF, markerRST0+byte(restarts&7))
+				restarts++
+				clear(preds)
+			}
+			lastMCU = mcu
+		}
+
+		diff := int32(blk[0]) - int32(preds[sc.comp])
+		preds[sc.comp] = blk[0]
+		if err := w.symbol(s.dc[sc.td], magnitudeCategory(diff)); err != nil {
+			return err
+		}
+		w.extend(diff)
+
+		run := 0
+		for k := 1; k < 64; k++ {
+			if blk[k] == 0 {
+				run++
+				continue
+			}
+			for ; run > 15; run -= 16 {
+				if err := w.symbol(s.ac[sc.ta], 0xF0); err != nil {
+					return err
+				}
+			}
+			v := int32(blk[k])
+			if err := w.symbol(s.ac[sc.ta], byte(run<<4)|magnitudeCategory(v)); err != nil {
+				return err
+			}
+			w.extend(v)
+			run = 0
+		}
+		if run > 0 {
+			return w.symbol(s.ac[sc.ta], 0x00)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	w.flush()
+	return w.buf, nil
+}
+
+// optimizeTables replaces the scan's tables with ones built from its own
+// symbol counts, and returns the DHT segment defining them.
+func (f *jpegFile) optimizeTables(s *jpegScan) []byte {
+	var dcFreq, acFreq [4][257]int64
+	preds := make([]int16, len(f.comps))
+	lastMCU := -1
+	f.walkScan(s, func(mcu int, sc *scanComponent, blk []int16) error {
+		if mcu != lastMCU {
+			if s.restart > 0 && mcu > 0 && mcu%s.restart == 0 {
+				clear(preds)
+			}
+			lastMCU = mcu
+		}
+		dcFreq[sc.td][magnitudeCategory(int32(blk[0])-int32(preds[sc.comp]))]++
+		preds[sc.comp] = blk[0]
+
+		run := 0
+		for k := 1; k < 64; k++ {
+			if blk[k] == 0 {
+				run++
+				continue
+			}
+			for ; run > 15; run -= 16 {
+				acFreq[sc.ta][0xF0]++
+			}
+			acFreq[sc.ta][run<<4|int(magnitudeCategory(int32(blk[k])))]++
+			run = 0
+		}
+		if run > 0 {
+			acFreq[sc.ta][0x00]++
+		}
+		return nil
+	})
+
+	var dht []byte
+	var doneDC, doneAC [4]bool
+	for _, sc := range s.comps {
+		if !doneDC[sc.td] {
+			s.dc[sc.td] = optimalTable(&dcFreq[sc.td])
+			dht = s.dc[sc.td].appendDHT(dht, 0, sc.td)
+			doneDC[sc.td] = true
+		}
+		if !doneAC[sc.ta] {
+			s.ac[sc.ta] = optimalTable(&acFreq[sc.ta])
+			dht = s.ac[sc.ta].appendDHT(dht, 1, sc.ta)
+			doneAC[sc.ta] = true
+		}
+	}
+	return dht
+}
+
+// magnitudeCategory is the number of bits needed for |v|.
+func magnitudeCategory(v int32) byte {
+	if v < 0 {
+		v = -v
+	}
+	return byte(bits.Len32(uint32(v)))
+}
+
+// huffTable is a canonical Huffman table, for decoding and encoding.
+type huffTable struct {
+	counts [16]byte
+	values []byte
+
+	minCode, maxCode, valPtr [17]int32
+
+	code [256]uint16
+	size [256]byte
+}
+
+func newHuffTable(counts [16]byte, values []byte) (*huffTable, error) {
+	t := &huffTable{counts: counts, values: values}
+	code, k := int32(0), int32(0)
+	for l := 1; l <= 16; l++ {
+		n := int32(counts[l-1])
+		t.valPtr[l] = k
+		t.minCode[l] = code
+		t.maxCode[l] = -1
+		if n > 0 {
+			t.maxCode[l] = code + n - 1
+		}
+		for i := int32(0); i < n; i++ {
+			t.code[values[k+i]] = uint16(code + i)
+			t.size[values[k+i]] = byte(l)
+		}
+		code += n
+		k += n
+		if code > 1<<l {
+			return nil, errors.New("corrupt JPEG: bad Huffman table")
+		}
+		code <<= 1
+	}
+	return t, nil
+}
+
+func parseDHT(d []byte, dc, ac *[4]*huffTable) error {
+	for len(d) > 0 {
+		if len(d) < 17 {
+			return errors.New("corrupt JPEG: bad Huffman table")
+		}
+		class, id := d[0]>>4, int(d[0]&15)
+		if class > 1 || id > 3 {
+			return errors.New("corrupt JPEG: bad Huffman table")
+		}
+		var counts [16]byte
+		copy(counts[:], d[1:17])
+		n := 0
+		for _, c := range counts {
+			n += int(c)
+		}
+		if n > 256 || len(d) < 17+n {
+			return errors.New("corrupt JPEG: bad Huffman table")
+		}
+		t, err := newHuffTable(counts, d[17:17+n])
+		if err != nil {
+			return err
+		}
+		if class == 0 {
+			dc[id] = t
+		} else {
+			ac[id] = t
+		}
+		d = d[17+n:]
+	}
+	return nil
+}
+
+func (t *huffTable) decode(r *bitReader) (byte, error) {
+	code := int32(0)
+	for l := 1; l <= 16; l++ {
+		b, err := r.bit()
+		if err != nil {
+			return 0, err
+		}
+		code = code<<1 | int32(b)
+		if code <= t.maxCode[l] {
+			return t.values[t.valPtr[l]+code-t.minCode[l]], nil
+		}
+	}
+	return 0, errors.New("corrupt JPEG: bad Huffman code")
+}
+
+func (t *huffTable) appendDHT(dst []byte, class byte, id int) []byte {
+	dst = append(dst, class<<4|byte(id))
+	dst = append(dst, t.counts[:]...)
+	return append(dst, t.values...)
+}
+
+// optimalTable builds a length-limited Huffman table for the symbol counts,
+// as in Annex K.2 of the JPEG standard. freq[256] reserves the all-ones
+// code, which JPEG forbids.
+func optimalTable(counts *[257]int64) *huffTable {
+	freq := *counts
+	freq[256] = 1
+	var codeSize [257]int
+	var others [257]int
+	for i := range others {
+		others[i] = -1
+	}
+
+	for {
+		// The two least frequent trees, the larger value first on ties
+		c1, c2 := -1, -1
+		for i := 0; i <= 256; i++ {
+			if freq[i] > 0 && (c1 < 0 || freq[i] <= freq[c1]) {
+				c1 = i
+			}
+		}
+		for i := 0; i <= 256; i++ {
+			if freq[i] > 0 && i != c1 && (c2 < 0 || freq[i] <= freq[c2]) {
+				c2 = i
+			}
+		}
+		if c2 < 0 {
+			break
+		}
+
+		freq[c1] += freq[c2]
+		freq[c2] = 0
+		codeSize[c1]++
+		for others[c1] >= 0 {
+			c1 = others[c1]
+			codeSize[c1]++
+		}
+		others[c1] = c2
+		codeSize[c2]++
+		for others[c2] >= 0 {
+			c2 = others[c2]
+			codeSize[c2]++
+		}
+	}
+
+	var lengths [33]int
+	for _, size := range codeSize {
+		if size > 0 {
+			lengths[size]++
+		}
+	}
+	// Limit codes to 16 bits
+	for i := 32; i > 16; i-- {
+		for lengths[i] > 0 {
+			j := i - 2
+			for lengths[j] == 0 {
+				j--
+			}
+			lengths[i] -= 2
+			lengths[i-1]++
+			lengths[j+1] += 2
+			lengths[j]--
+		}
+	}
+	// Drop the reserved code from the longest length
+	i := 16
+	for i > 0 && lengths[i] == 0 {
+		i--
+	}
+	if i > 0 {
+		lengths[i]--
+	}
+
+	var tableCounts [16]byte
+	for l := 1; l <= 16; l++ {
+		tableCounts[l-1] = byte(lengths[l])
+	}
+	var values []byte
+	for size := 1; size <= 32; size++ {
+		for v := 0; v < 256; v++ {
+			if codeSize[v] == size {
+				values = append(values, byte(v))
+			}
+		}
+	}
+	t, err := newHuffTable(tableCounts, values)
+	if err != nil {
+		panic(err) // the construction always yields a valid table
+	}
+	return t
+}
+
+// bitReader reads entropy-coded data, removing byte stuffing.
+type bitReader struct {
+	data []byte
+	pos  int
+	acc  byte
+	n    uint
+}
+
+func (r *bitReader) bit() (byte, error) {
+	if r.n == 0 {
+		if r.pos >= len(r.data) {
+			return 0, errors.New("corrupt JPEG: truncated scan")
+		}
+		b := r.data[r.pos]
+		if b == 0xFF {
+			if r.pos+1 >= len(r.data) || r.data[r.pos+1] != 0 {
+				return 0, errors.New("corrupt JPEG: unexpected marker in scan")
+			}
+			r.pos++
+		}
+		r.pos++
+		r.acc, r.n = b, 8
+	}
+	r.n--
+	return (r.acc >> r.n) & 1, nil
+}
+
+func (r *bitReader) receiveExtend(size byte) (int32, error) {
+	v := int32(0)
+	for i := byte(0); i < size; i++ {
+		b, err := r.bit()
+		if err != nil {
+			return 0, err
+		}
+		v = v<<1 | int32(b)
+	}
+	if size > 0 && v < 1<<(size-1) {
+		v += -(1 << size) + 1
+	}
+	return v, nil
+}
+
+// restart drops the padding bits and consumes an RST marker.
+func (r *bitReader) restart() error {
+	r.n = 0
+	if r.pos+1 >= len(r.data) || r.data[r.pos] != 0xFF || r.data[r.pos+1] < markerRST0 || r.data[r.pos+1] > markerRST0+7 {
+		return errors.New("corrupt JPEG: missing restart marker")
+	}
+	r.pos += 2
+	return nil
+}
+
+// bitWriter writes entropy-coded data with byte stuffing.
+type bitWriter struct {
+	buf []byte
+	acc uint32
+	n   uint
+}
+
+func (w *bitWriter) write(code uint32, size uint) {
+	w.acc = w.acc<<size | code&(1<<size-1)
+	w.n += size
+	for w.n >= 8 {
+		b := byte(w.acc >> (w.n - 8))
+		w.buf = append(w.buf, b)
+		if b == 0xFF {
+			w.buf = append(w.buf, 0)
+		}
+		w.n -= 8
+	}
+	w.acc &= 1<<w.n - 1
+}
+
+func (w *bitWriter) symbol(t *huffTable, sym byte) error {
+	if t.size[sym] == 0 {
+		return errMissingCode
+	}
+	w.write(uint32(t.code[sym]), uint(t.size[sym]))
+	return nil
+}
+
+// extend writes the extra bits of a value after its category.
+func (w *bitWriter) extend(v int32) {
+	size := magnitudeCategory(v)
+	if v < 0 {
+		v--
+	}
+	w.write(uint32(v), uint(size))
+}
+
+// flush pads the last byte with one bits.
+func (w *bitWriter) flush() {
+	if w.n > 0 {
+		w.write(1<<(8-w.n)-1, 8-w.n)
+	}
+}
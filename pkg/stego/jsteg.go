@@ -0,0 +1,139 @@
+package stego
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// ErrUnsupportedJPEG indicates a JPEG whose coefficients cannot be read:
+// only sequential Huffman-coded JPEGs with 8-bit samples (baseline, as most
+// cameras and phones write) can carry data.
+var ErrUnsupportedJPEG = errors.New("unsupported JPEG")
+
+var errPixelOptions = fmt.Errorf("%w: coefficients carry one bit each, without alpha", ErrUnsupportedJPEG)
+
+var errRobustJPEG = fmt.Errorf("%w: robust mode needs pixels", ErrUnsupportedJPEG)
+
+// IsJPEG reports whether data starts like a JPEG file.
+func IsJPEG(data []byte) bool {
+	return bytes.HasPrefix(data, []byte{0xFF, markerSOI, 0xFF})
+}
+
+// EmbedJPEG hides data in the quantized DCT coefficients of a JPEG file, in
+// the manner of JSteg, and returns the new file. The pixels are never
+// decoded and compressed again, so the result keeps the carrier's quality,
+// metadata and size to within the payload.
+//
+// Only AC coefficients of magnitude 2 or more carry bits, in the LSB of the
+// magnitude. Zeros and ±1s make up most of a JPEG, and changing them shows
+// clearly; keeping clear of them also means embedding never changes which
+// coefficients carry bits. LSBMatching moves magnitudes by ±1 instead,
+// always upwards from 2. A coefficient carries one bit only, so options using
+// more bits or the alpha channel give ErrUnsupportedJPEG.
+func EmbedJPEG(carrier []byte, data []byte, opts Options) ([]byte, error) {
+	if opts.prefixed() {
+		return nil, errPixelOptions
+	}
+	if opts.Robust {
+		return nil, errRobustJPEG
+	}
+	f, err := parseJPEG(carrier)
+	if err != nil {
+		return nil, err
+	}
+
+	l := newJPEGLayout(f, opts)
+	fullPayload, err := l.frame(data)
+	if err != nil {
+		return nil, err
+	}
+
+	totalBitsRequired := len(fullPayload) * 8
+	if totalBitsRequired > l.slots {
+		return nil, fmt.Errorf("%w: need %d usable coefficients, have %d", ErrMessageTooLarge, totalBitsRequired, l.slots)
+	}
+
+	rng, err := matchingRNG(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	locs := l.locations(totalBitsRequired)
+	for bitIndex := 0; bitIndex < totalBitsRequired; bitIndex++ {
+		bit := int16(fullPayload[bitIndex/8]>>(7-bitIndex%8)) & 1
+		off := locs[bitIndex]
+		c := f.coefs[off]
+		mag, sign := c, int16(1)
+		if c < 0 {
+			mag, sign = -c, -1
+		}
+		switch {
+		case mag&1 == bit:
+			continue
+		case rng != nil:
+			// Never down to 1, which would stop the coefficient carrying a bit
+			if mag == 2 || rng.IntN(2) == 0 {
+				mag++
+			} else {
+				mag--
+			}
+		default:
+			mag = mag&^1 | bit
+		}
+		f.coefs[off] = sign * mag
+	}
+
+	return f.encode()
+}
+
+// ExtractJPEG retrieves data hidden by EmbedJPEG with the same options.
+func ExtractJPEG(jpegData []byte, opts Options) ([]byte, error) {
+	f, err := parseJPEG(jpegData)
+	if err != nil {
+		return nil, err
+	}
+	return newJPEGLayout(f, opts).unframe(func(off int) byte {
+		c := f.coefs[off]
+		if c < 0 {
+			c = -c
+		}
+		return byte(c & 1)
+	}, nil)
+}
+
+// CapacityJPEG returns the most bytes EmbedJPEG can hide in a JPEG file.
+func CapacityJPEG(carrier []byte, opts Options) (int, error) {
+	if opts.prefixed() {
+		return 0, errPixelOptions
+	}
+	if opts.Robust {
+		return 0, errRobustJPEG
+	}
+	f, err := parseJPEG(carrier)
+	if err != nil {
+		return 0, err
+	}
+	return frameCapacity(len(usableCoefficients(f)), opts), nil
+}
+
+// newJPEGLayout numbers the AC coefficients of magnitude 2 or more in file
+// order and locates them in f.coefs.
+func newJPEGLayout(f *jpegFile, opts Options) *layout {
+	usable := usableCoefficients(f)
+	return newLayout(len(usable), func(slot int) int {
+		return int(usable[slot])
+	}, opts)
+}
+
+// usableCoefficients lists the offsets in f.coefs of the AC coefficients of
+// magnitude 2 or more.
+func usableCoefficients(f *jpegFile) []int32 {
+	var usable []int32
+	for i, c := range f.coefs {
+		if i%64 != 0 && (c >= 2 || c <= -2) {
+			usable = append(usable, int32(i))
+		}
+	}
+	return usable
+}
@@ -0,0 +1,326 @@
+package stego
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+	"sync"
+)
+
+const (
+	// nonceSize is the random prefix that makes every keyed embedding's
+	// keystream unique, even for the same passphrase.
+	nonceSize = 16
+
+	// kdfSalt separates these keys from any other use of the passphrase. It is
+	// fixed because Extract has nothing else to go on.
+	kdfSalt       = "horcrux-stego-v1"
+	kdfIterations = 100000
+
+	feistelRounds = 6
+
+	// settingsMagic starts the settings prefix, which is followed by a byte
+	// holding the bits per channel less one (bits 0-1) and alpha use (bit 2).
+	// With a passphrase the prefix is whitened like the payload.
+	settingsMagic = "hx"
+	settingsBits  = 24
+
+	// settingsPixels hold the prefix, one bit in each of R, G and B, ahead
+	// of the payload's slots.
+	settingsPixels = settingsBits / channelsPerPixel
+)
+
+// settingsNonce whitens the settings prefix. Payload nonces are random, so
+// they never meet it in practice.
+var settingsNonce = make([]byte, nonceSize)
+
+// layout maps the n-th payload bit to one of a carrier's slots, the values
+// that each carry one bit, and locates that slot in the carrier.
+type layout struct {
+	slots  int
+	locate func(slot int) int
+
+	perm   *permutation // nil for the sequential layout
+	whiteK []byte
+}
+
+func newLayout(slots int, locate func(slot int) int, opts Options) *layout {
+	l := &layout{slots: slots, locate: locate}
+	if opts.Passphrase != "" {
+		permKey, whiteKey := deriveKeys(opts.Passphrase)
+		l.perm = newPermutation(permKey, uint64(l.slots))
+		l.whiteK = whiteKey
+	}
+	return l
+}
+
+// newImageLayout numbers the slots of a width x height image pixel by pixel,
+// R then G then B (then A), from the lowest bit up in each, after any
+// settings prefix. It locates them as an offset in the Pix slice of an NRGBA
+// copy of the image and a bit plane, combined by splitLocation's inverse.
+func newImageLayout(width, height int, opts Options) *layout {
+	first := 0
+	if opts.prefixed() {
+		first = settingsPixels
+	}
+	depth := opts.depth()
+	perPixel := depth * opts.channels()
+	pixels := max(0, width*height-first)
+	return newLayout(pixels*perPixel, func(slot int) int {
+		p := first + slot/perPixel
+		c, plane := slot%perPixel/depth, slot%depth
+		return (p*4+c)*MaxBitsPerChannel + plane
+	}, opts)
+}
+
+// splitLocation splits a location from newImageLayout into the offset of a
+// channel value in Pix and the bit within it.
+func splitLocation(loc int) (off int, plane uint) {
+	return loc / MaxBitsPerChannel, uint(loc % MaxBitsPerChannel)
+}
+
+// settingsLocation locates bit i of the settings prefix, in the lowest bit
+// of the first pixels' R, G and B.
+func settingsLocation(i int) int {
+	p, c := i/channelsPerPixel, i%channelsPerPixel
+	return (p*4 + c) * MaxBitsPerChannel
+}
+
+// settingsPrefix encodes the bits per channel and alpha use of opts.
+func settingsPrefix(opts Options) []byte {
+	settings := byte(opts.depth() - 1)
+	if opts.Alpha {
+		settings |= 1 << 2
+	}
+	prefix := append([]byte(settingsMagic), settings)
+	if opts.Passphrase != "" {
+		_, whiteKey := deriveKeys(opts.Passphrase)
+		(&layout{whiteK: whiteKey}).whiten(settingsNonce, prefix)
+	}
+	return prefix
+}
+
+// readSettings reads the settings prefix of an image with the given number
+// of pixels into a copy of opts, getting channel values from value, or
+// reports false if it has none.
+func readSettings(value func(off int) byte, pixels int, opts Options) (Options, bool) {
+	if pixels < settingsPixels {
+		return opts, false
+	}
+	prefix := make([]byte, settingsBits/8)
+	for i := 0; i < settingsBits; i++ {
+		off, _ := splitLocation(settingsLocation(i))
+		prefix[i/8] |= (value(off) & 1) << (7 - i%8)
+	}
+	if opts.Passphrase != "" {
+		_, whiteKey := deriveKeys(opts.Passphrase)
+		(&layout{whiteK: whiteKey}).whiten(settingsNonce, prefix)
+	}
+
+	settings := prefix[len(settingsMagic)]
+	if string(prefix[:len(settingsMagic)]) != settingsMagic || settings>>3 != 0 {
+		return opts, false
+	}
+	opts.BitsPerChannel = int(settings&3) + 1
+	opts.Alpha = settings&(1<<2) != 0
+	return opts, opts.prefixed()
+}
+
+func (l *layout) keyed() bool {
+	return l.perm != nil
+}
+
+// eachLocation calls fn with every bit index in [from, to) and the location
+// of the slot carrying it, on as many goroutines as are worth it. fn must be
+// safe to call concurrently; the ranges each goroutine gets start a multiple
+// of 8 bits after from, so it may write the bytes the bits belong to.
+func (l *layout) eachLocation(from, to int, fn func(i, loc int)) {
+	parallel(to-from, minParallelBits, func(lo, hi int) {
+		var buf permutationBuffer
+		for i := from + lo; i < from+hi; i++ {
+			slot := i
+			if l.perm != nil {
+				slot = int(l.perm.at(uint64(i), &buf))
+			}
+			fn(i, l.locate(slot))
+		}
+	})
+}
+
+// locations returns the location of the slot carrying each bit in [0, n).
+func (l *layout) locations(n int) []int {
+	locs := make([]int, n)
+	l.eachLocation(0, n, func(i, loc int) {
+		locs[i] = loc
+	})
+	return locs
+}
+
+// frame prepares [Length (32-bit)] + [Data] for embedding; when keyed, it is
+// whitened and preceded by a fresh nonce.
+func (l *layout) frame(data []byte) ([]byte, error) {
+	lengthBuf := make([]byte, 4)
+	binary.BigEndian.PutUint32(lengthBuf, uint32(len(data)))
+	payload := append(lengthBuf, data...)
+
+	if l.keyed() {
+		nonce := make([]byte, nonceSize)
+		if _, err := rand.Read(nonce); err != nil {
+			return nil, fmt.Errorf("failed to generate nonce: %w", err)
+		}
+		l.whiten(nonce, payload)
+		payload = append(nonce, payload...)
+	}
+	return payload, nil
+}
+
+// frameCapacity is the data a layout with the given number of slots holds
+// once framed.
+func frameCapacity(slots int, opts Options) int {
+	n := slots/8 - 4
+	if opts.Passphrase != "" {
+		n -= nonceSize
+	}
+	return max(0, n)
+}
+
+// unframe reads a frame back, getting the bit at each location from bit.
+// Only the nonce and length are read before the length is checked; load,
+// if set, is called after that, before the data is read in parallel, so a
+// carrier holding nothing costs next to nothing to rule out.
+func (l *layout) unframe(bit func(loc int) byte, load func()) ([]byte, error) {
+	bitIndex := 0
+	readBytes := func(n int) []byte {
+		out := make([]byte, n)
+		from := bitIndex
+		l.eachLocation(from, from+n*8, func(i, loc int) {
+			out[(i-from)/8] |= bit(loc) << (7 - (i-from)%8)
+		})
+		bitIndex += n * 8
+		return out
+	}
+
+	// 1. Nonce, when keyed
+	var nonce []byte
+	headerBits := 32
+	if l.keyed() {
+		headerBits += nonceSize * 8
+	}
+	if l.slots < headerBits {
+		return nil, ErrNoHiddenData
+	}
+	if l.keyed() {
+		nonce = readBytes(nonceSize)
+	}
+
+	// 2. Length
+	payload := readBytes(4)
+	lengthBuf := append([]byte(nil), payload...)
+	if l.keyed() {
+		l.whiten(nonce, lengthBuf)
+	}
+	dataLen := binary.BigEndian.Uint32(lengthBuf)
+
+	// Sanity check
+	if dataLen == 0 || uint64(dataLen)*8 > uint64(l.slots-headerBits) {
+		return nil, ErrNoHiddenData
+	}
+
+	// 3. Data, whitened as one stream with the length
+	if load != nil {
+		load()
+	}
+	payload = append(payload, readBytes(int(dataLen))...)
+	if l.keyed() {
+		l.whiten(nonce, payload)
+	}
+	return payload[4:], nil
+}
+
+// whiten XORs buf with the AES-CTR keystream for nonce. It is its own inverse.
+func (l *layout) whiten(nonce, buf []byte) {
+	block, err := aes.NewCipher(l.whiteK)
+	if err != nil {
+		panic(err) // the key is always 32 bytes
+	}
+	cipher.NewCTR(block, nonce).XORKeyStream(buf, buf)
+}
+
+// derived remembers the last passphrase's keys: extraction needs them more
+// than once per image, and bind tries every image with the same passphrase.
+var derived struct {
+	sync.Mutex
+	passphrase string
+	key        []byte
+}
+
+// deriveKeys stretches the passphrase into a permutation key and a
+// whitening key.
+func deriveKeys(passphrase string) (permKey, whiteKey []byte) {
+	derived.Lock()
+	defer derived.Unlock()
+	if derived.key == nil || derived.passphrase != passphrase {
+		key, err := pbkdf2.Key(sha256.New, passphrase, []byte(kdfSalt), kdfIterations, 64)
+		if err != nil {
+			panic(err) // only possible for invalid parameters
+		}
+		derived.passphrase, derived.key = passphrase, key
+	}
+	return derived.key[:32], derived.key[32:]
+}
+
+// permutation is a keyed pseudo-random permutation of [0, n): a balanced
+// Feistel network over the smallest even number of bits covering n, with
+// cycle walking to stay inside the range. It needs no memory per slot, so it
+// works for any image size.
+type permutation struct {
+	n     uint64
+	half  uint
+	mask  uint64
+	block cipher.Block
+}
+
+func newPermutation(key []byte, n uint64) *permutation {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		panic(err) // the key is always 32 bytes
+	}
+	half := uint(bits.Len64(n-1)+1) / 2
+	if half == 0 {
+		half = 1
+	}
+	return &permutation{n: n, half: half, mask: 1<<half - 1, block: block}
+}
+
+// permutationBuffer is scratch space for one goroutine's permutation
+// lookups, which would otherwise allocate on every round.
+type permutationBuffer [2 * aes.BlockSize]byte
+
+// at returns the slot of the i-th payload bit.
+func (p *permutation) at(i uint64, buf *permutationBuffer) uint64 {
+	x := i
+	for {
+		x = p.encrypt(x, buf)
+		if x < p.n {
+			return x
+		}
+	}
+}
+
+func (p *permutation) encrypt(x uint64, buf *permutationBuffer) uint64 {
+	left, right := x>>p.half, x&p.mask
+	in, out := buf[:aes.BlockSize], buf[aes.BlockSize:]
+	clear(in)
+	for round := 0; round < feistelRounds; round++ {
+		in[0] = byte(round)
+		binary.BigEndian.PutUint64(in[8:], right)
+		p.block.Encrypt(out, in)
+		left, right = right, (left^binary.BigEndian.Uint64(out[:8]))&p.mask
+	}
+	return left<<p.half | right
+}
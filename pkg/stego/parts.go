@@ -0,0 +1,105 @@
+package stego
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// partMagic starts a payload that is one part of a larger one. Whole
+// horcruxes start with their preamble or, headerless, with ciphertext.
+const partMagic = "HRXPART1"
+
+// PartOverhead is the number of bytes SplitParts adds to each part:
+// the magic, the set ID, and the part's index and count.
+const PartOverhead = len(partMagic) + 16 + 2 + 2
+
+// MaxParts is the most parts a payload can be split into.
+const MaxParts = 0xFFFF
+
+// Part is one piece of a payload too large for a single carrier.
+type Part struct {
+	Set   [16]byte // random, shared by all parts of the payload
+	Index int      // 0-based
+	Count int
+	Data  []byte
+}
+
+// SplitParts cuts data into consecutive parts of the given sizes, which must
+// add up to len(data), and returns them framed for embedding.
+func SplitParts(data []byte, sizes []int) ([][]byte, error) {
+	if len(sizes) == 0 || len(sizes) > MaxParts {
+		return nil, fmt.Errorf("cannot split into %d parts", len(sizes))
+	}
+	var set [16]byte
+	if _, err := rand.Read(set[:]); err != nil {
+		return nil, fmt.Errorf("failed to generate part set ID: %w", err)
+	}
+
+	payloads := make([][]byte, len(sizes))
+	for i, n := range sizes {
+		if n <= 0 || n > len(data) {
+			return nil, errors.New("part sizes do not add up to the data")
+		}
+		p := make([]byte, 0, PartOverhead+n)
+		p = append(p, partMagic...)
+		p = append(p, set[:]...)
+		p = binary.BigEndian.AppendUint16(p, uint16(i))
+		p = binary.BigEndian.AppendUint16(p, uint16(len(sizes)))
+		payloads[i] = append(p, data[:n]...)
+		data = data[n:]
+	}
+	if len(data) != 0 {
+		return nil, errors.New("part sizes do not add up to the data")
+	}
+	return payloads, nil
+}
+
+// ParsePart reads a payload framed by SplitParts. It reports false for a
+// payload that is not a part.
+func ParsePart(payload []byte) (*Part, bool) {
+	if len(payload) < PartOverhead || !bytes.HasPrefix(payload, []byte(partMagic)) {
+		return nil, false
+	}
+	p := &Part{}
+	rest := payload[len(partMagic):]
+	copy(p.Set[:], rest)
+	p.Index = int(binary.BigEndian.Uint16(rest[16:]))
+	p.Count = int(binary.BigEndian.Uint16(rest[18:]))
+	p.Data = rest[20:]
+	if p.Count == 0 || p.Index >= p.Count {
+		return nil, false
+	}
+	return p, true
+}
+
+// JoinParts reassembles the payload from all parts of one set, in any order.
+func JoinParts(parts []*Part) ([]byte, error) {
+	if len(parts) == 0 {
+		return nil, errors.New("no parts")
+	}
+	count := parts[0].Count
+	ordered := make([]*Part, count)
+	for _, p := range parts {
+		if p.Set != parts[0].Set || p.Count != count {
+			return nil, errors.New("parts belong to different payloads")
+		}
+		ordered[p.Index] = p
+	}
+
+	var out []byte
+	missing := 0
+	for _, p := range ordered {
+		if p == nil {
+			missing++
+			continue
+		}
+		out = append(out, p.Data...)
+	}
+	if missing > 0 {
+		return nil, fmt.Errorf("missing %d of %d parts", missing, count)
+	}
+	return out, nil
+}
@@ -0,0 +1,143 @@
+package stego
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"runtime"
+	"sync"
+)
+
+// Work worth a goroutine of its own: bits to locate, a permutation lookup
+// each, or pixels to convert.
+const (
+	minParallelBits   = 1 << 12
+	minParallelPixels = 1 << 16
+)
+
+// parallel calls fn on consecutive ranges [lo, hi) covering [0, n), split
+// over the CPUs into ranges of at least minRange. Every range starts at a
+// multiple of 8.
+func parallel(n, minRange int, fn func(lo, hi int)) {
+	workers := min(runtime.GOMAXPROCS(0), n/minRange)
+	if workers <= 1 {
+		fn(0, n)
+		return
+	}
+	chunk := (n/workers + 7) &^ 7
+	var wg sync.WaitGroup
+	for lo := 0; lo < n; lo += chunk {
+		wg.Add(1)
+		go func(lo, hi int) {
+			defer wg.Done()
+			fn(lo, hi)
+		}(lo, min(lo+chunk, n))
+	}
+	wg.Wait()
+}
+
+// toNRGBA returns an NRGBA copy of img with its origin at 0,0 and no gaps
+// between rows, so that pixel p starts at Pix[4*p]. Bands of rows are
+// converted in parallel: the images image.Decode returns for PNGs and JPEGs
+// directly, others through draw.Draw, which gives the same values.
+func toNRGBA(img image.Image) *image.NRGBA {
+	b := img.Bounds()
+	width, height := b.Dx(), b.Dy()
+	out := image.NewNRGBA(image.Rect(0, 0, width, height))
+	parallel(height, max(minParallelPixels/max(width, 1), 1), func(lo, hi int) {
+		convertRows(out, img, lo, hi)
+	})
+	return out
+}
+
+// convertRows converts rows [lo, hi) of img into out.
+func convertRows(out *image.NRGBA, img image.Image, lo, hi int) {
+	b := img.Bounds()
+	width := b.Dx()
+	switch src := img.(type) {
+	case *image.NRGBA:
+		for y := lo; y < hi; y++ {
+			i := src.PixOffset(b.Min.X, b.Min.Y+y)
+			copy(out.Pix[y*out.Stride:(y+1)*out.Stride], src.Pix[i:i+4*width])
+		}
+	case *image.RGBA:
+		// Opaque pixels, all of them in a PNG written from an opaque image,
+		// are the same premultiplied or not
+		for y := lo; y < hi; y++ {
+			row := out.Pix[y*out.Stride : (y+1)*out.Stride]
+			i := src.PixOffset(b.Min.X, b.Min.Y+y)
+			copy(row, src.Pix[i:i+4*width])
+			for x := 0; x < len(row); x += 4 {
+				if a := row[x+3]; a != 0xff {
+					c := color.NRGBAModel.Convert(color.RGBA{row[x], row[x+1], row[x+2], a}).(color.NRGBA)
+					row[x], row[x+1], row[x+2] = c.R, c.G, c.B
+				}
+			}
+		}
+	case *image.YCbCr:
+		for y := lo; y < hi; y++ {
+			row := out.Pix[y*out.Stride : (y+1)*out.Stride]
+			for x := 0; x < width; x++ {
+				r, g, bl, _ := src.YCbCrAt(b.Min.X+x, b.Min.Y+y).RGBA()
+				row[4*x], row[4*x+1], row[4*x+2], row[4*x+3] = uint8(r>>8), uint8(g>>8), uint8(bl>>8), 0xff
+			}
+		}
+	default:
+		band := image.Rect(0, lo, width, hi)
+		draw.Draw(out, band, img, b.Min.Add(image.Pt(0, lo)), draw.Src)
+	}
+}
+
+// pixelSource reads the channel values of an image as they would be at an
+// offset in the Pix slice of toNRGBA's copy. Until load makes the copy, each
+// value is converted on its own, which is all ruling out hidden data takes.
+type pixelSource struct {
+	img   image.Image
+	pix   []byte // nil until loaded
+	width int
+}
+
+func newPixelSource(img image.Image) *pixelSource {
+	s := &pixelSource{img: img, width: img.Bounds().Dx()}
+	if n, ok := img.(*image.NRGBA); ok && n.Rect.Min == (image.Point{}) && n.Stride == 4*s.width {
+		s.pix = n.Pix
+	}
+	return s
+}
+
+// pixels is the number of pixels in the image.
+func (s *pixelSource) pixels() int {
+	return s.width * s.img.Bounds().Dy()
+}
+
+func (s *pixelSource) value(off int) byte {
+	if s.pix != nil {
+		return s.pix[off]
+	}
+	b := s.img.Bounds()
+	p := off / 4
+	c := color.NRGBAModel.Convert(s.img.At(b.Min.X+p%s.width, b.Min.Y+p/s.width)).(color.NRGBA)
+	return [4]byte{c.R, c.G, c.B, c.A}[off%4]
+}
+
+// row copies row y as toNRGBA's copy holds it into dst, converting each
+// pixel once.
+func (s *pixelSource) row(y int, dst []byte) {
+	if s.pix != nil {
+		copy(dst, s.pix[4*y*s.width:4*(y+1)*s.width])
+		return
+	}
+	b := s.img.Bounds()
+	for x := 0; x < s.width; x++ {
+		c := color.NRGBAModel.Convert(s.img.At(b.Min.X+x, b.Min.Y+y)).(color.NRGBA)
+		dst[4*x], dst[4*x+1], dst[4*x+2], dst[4*x+3] = c.R, c.G, c.B, c.A
+	}
+}
+
+// load converts the whole image, after which value is safe for concurrent
+// use.
+func (s *pixelSource) load() {
+	if s.pix == nil {
+		s.pix = toNRGBA(s.img).Pix
+	}
+}
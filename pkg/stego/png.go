@@ -0,0 +1,261 @@
+package stego
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"io"
+)
+
+const pngSignature = "\x89PNG\r\n\x1a\n"
+
+// PNG colour types written by EncodePNG.
+const (
+	pngTruecolor      = 2
+	pngTruecolorAlpha = 6
+)
+
+// idatSize is the most pixel data written per IDAT chunk, as libpng does.
+const idatSize = 8192
+
+// pngChunk is one chunk of a PNG file.
+type pngChunk struct {
+	typ  string
+	data []byte
+	off  int // where the chunk starts in the file, when read from one
+}
+
+// copiedChunks are the ancillary chunks kept whatever changes: they describe
+// the image rather than its encoding. Other ancillary chunks are kept when
+// marked safe to copy.
+var copiedChunks = map[string]bool{
+	"tEXt": true, "zTXt": true, "iTXt": true, "eXIf": true, "tIME": true, "pHYs": true,
+	"gAMA": true, "cHRM": true, "sRGB": true, "iCCP": true, "cICP": true, "sPLT": true,
+}
+
+// colourChunks only make sense for the colour type and bit depth they were
+// written for.
+var colourChunks = map[string]bool{
+	"PLTE": true, "tRNS": true, "bKGD": true, "sBIT": true, "hIST": true,
+}
+
+// EncodePNG writes img as a PNG that keeps what it can of carrier, the PNG
+// file it was made from: its text, EXIF, colour profile and other ancillary
+// chunks in their original order, and its colour type and bit depth, so that
+// only the pixel data differs. 8-bit RGBA carriers keep their colour type, as
+// do 8-bit RGB ones while img stays opaque; others (grey, palette, 16-bit)
+// become 8-bit RGB, or RGBA when img has transparency, since the payload
+// changes channels independently.
+//
+// If carrier is not a PNG, for example a JPEG, img is encoded with png.Encode.
+func EncodePNG(w io.Writer, img image.Image, carrier []byte) error {
+	chunks, err := readPNGChunks(carrier)
+	if err != nil {
+		return png.Encode(w, img)
+	}
+
+	nrgba, ok := img.(*image.NRGBA)
+	if !ok {
+		nrgba = toNRGBA(img)
+	}
+
+	// 1. Colour type
+	ihdr := chunks[0].data
+	depth, colourType := ihdr[8], ihdr[9]
+	hasTRNS := false
+	for _, c := range chunks {
+		hasTRNS = hasTRNS || c.typ == "tRNS"
+	}
+	kept := depth == 8 && (colourType == pngTruecolorAlpha || (colourType == pngTruecolor && !hasTRNS && nrgba.Opaque()))
+	outType := colourType
+	if !kept {
+		outType = pngTruecolor
+		if !nrgba.Opaque() {
+			outType = pngTruecolorAlpha
+		}
+	}
+	grey := colourType == 0 || colourType == 4
+
+	// 2. New pixel data
+	idat, err := encodeIDAT(nrgba, outType)
+	if err != nil {
+		return err
+	}
+
+	// 3. The carrier's chunks around it
+	var out bytes.Buffer
+	out.WriteString(pngSignature)
+	newIHDR := make([]byte, 13)
+	binary.BigEndian.PutUint32(newIHDR[0:], uint32(nrgba.Rect.Dx()))
+	binary.BigEndian.PutUint32(newIHDR[4:], uint32(nrgba.Rect.Dy()))
+	newIHDR[8], newIHDR[9] = 8, outType
+	writePNGChunk(&out, "IHDR", newIHDR)
+
+	wroteIDAT := false
+	for _, c := range chunks[1:] {
+		switch {
+		case c.typ == "IDAT":
+			if !wroteIDAT {
+				for len(idat) > 0 {
+					n := min(len(idat), idatSize)
+					writePNGChunk(&out, "IDAT", idat[:n])
+					idat = idat[n:]
+				}
+				wroteIDAT = true
+			}
+		case c.typ == "IEND":
+		case !kept && (colourChunks[c.typ] || (grey && c.typ == "iCCP")):
+			// A grey ICC profile does not fit a colour image either
+		case copiedChunks[c.typ] || colourChunks[c.typ] || (c.typ[0]&0x20 != 0 && c.typ[3]&0x20 != 0):
+			writePNGChunk(&out, c.typ, c.data)
+		}
+	}
+	writePNGChunk(&out, "IEND", nil)
+
+	_, err = w.Write(out.Bytes())
+	return err
+}
+
+// readPNGChunks splits a PNG file into its chunks, up to IEND.
+func readPNGChunks(data []byte) ([]pngChunk, error) {
+	if !bytes.HasPrefix(data, []byte(pngSignature)) {
+		return nil, errors.New("not a PNG file")
+	}
+	var chunks []pngChunk
+	pos := len(pngSignature)
+	for pos+12 <= len(data) {
+		n := int(binary.BigEndian.Uint32(data[pos:]))
+		if n < 0 || pos+12+n > len(data) {
+			break
+		}
+		c := pngChunk{typ: string(data[pos+4 : pos+8]), data: data[pos+8 : pos+8+n], off: pos}
+		chunks = append(chunks, c)
+		pos += 12 + n
+		if c.typ == "IEND" {
+			break
+		}
+	}
+	if len(chunks) == 0 || chunks[0].typ != "IHDR" || len(chunks[0].data) != 13 {
+		return nil, errors.New("corrupt PNG file")
+	}
+	return chunks, nil
+}
+
+func writePNGChunk(w *bytes.Buffer, typ string, data []byte) {
+	var hdr [8]byte
+	binary.BigEndian.PutUint32(hdr[:4], uint32(len(data)))
+	copy(hdr[4:], typ)
+	w.Write(hdr[:])
+	w.Write(data)
+
+	crc := crc32.NewIEEE()
+	crc.Write(hdr[4:])
+	crc.Write(data)
+	var sum [4]byte
+	binary.BigEndian.PutUint32(sum[:], crc.Sum32())
+	w.Write(sum[:])
+}
+
+// encodeIDAT filters and compresses the pixels of img as 8-bit RGB or RGBA,
+// choosing each row's filter by the usual minimum sum of absolute
+// differences.
+func encodeIDAT(img *image.NRGBA, colourType byte) ([]byte, error) {
+	bpp := 3
+	if colourType == pngTruecolorAlpha {
+		bpp = 4
+	}
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+	rowLen := width * bpp
+
+	var buf bytes.Buffer
+	zw, err := zlib.NewWriterLevel(&buf, zlib.DefaultCompression)
+	if err != nil {
+		return nil, err
+	}
+
+	prev := make([]byte, rowLen)
+	cur := make([]byte, rowLen)
+	var filtered [5][]byte
+	for f := range filtered {
+		filtered[f] = make([]byte, rowLen+1)
+		filtered[f][0] = byte(f)
+	}
+
+	for y := 0; y < height; y++ {
+		row := img.Pix[y*img.Stride : y*img.Stride+width*4]
+		if bpp == 4 {
+			copy(cur, row)
+		} else {
+			for x := 0; x < width; x++ {
+				copy(cur[x*3:x*3+3], row[x*4:x*4+3])
+			}
+		}
+
+		best, bestSum := 0, -1
+		for f := range filtered {
+			out := filtered[f][1:]
+			sum := 0
+			for i := 0; i < rowLen; i++ {
+				var a, c byte
+				if i >= bpp {
+					a, c = cur[i-bpp], prev[i-bpp]
+				}
+				b := prev[i]
+				switch f {
+				case 0:
+					out[i] = cur[i]
+				case 1:
+					out[i] = cur[i] - a
+				case 2:
+					out[i] = cur[i] - b
+				case 3:
+					out[i] = cur[i] - byte((int(a)+int(b))/2)
+				case 4:
+					out[i] = cur[i] - paeth(a, b, c)
+				}
+				sum += absByte(out[i])
+			}
+			if bestSum < 0 || sum < bestSum {
+				best, bestSum = f, sum
+			}
+		}
+		if _, err := zw.Write(filtered[best]); err != nil {
+			return nil, err
+		}
+		prev, cur = cur, prev
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	switch {
+	case pa <= pb && pa <= pc:
+		return a
+	case pb <= pc:
+		return b
+	default:
+		return c
+	}
+}
+
+// absByte is the magnitude of a filtered byte read as signed.
+func absByte(b byte) int {
+	return abs(int(int8(b)))
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,320 @@
+package stego
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"image"
+	mrand "math/rand/v2"
+	"sort"
+
+	"github.com/klauspost/reedsolomon"
+)
+
+const (
+	// robustOverhead is the size of a robust block besides its chunk:
+	// [sync (16-bit)] [index] [data chunks] [parity chunks] [chunk size]
+	// (16-bit each) [length (32-bit)] [chunk] [CRC-32 of all but the sync].
+	robustOverhead = 2 + 4*2 + 4 + 4
+
+	// Chunk sizes aim for blocks a quarter of a row long, so that an image
+	// cropped to half its width still holds whole blocks in every row.
+	minRobustChunk = 8
+	maxRobustChunk = 64
+
+	// maxRobustData keeps the erasure code within 256 chunks, counting
+	// parity, so it works over GF(2^8) on chunks of any size.
+	maxRobustData   = 160
+	maxRobustShards = 256
+
+	// robustProbeRows are searched for blocks before the whole image is
+	// converted and scanned.
+	robustProbeRows = 16
+)
+
+var errRobustOptions = errors.New("robust mode hides one bit in each of R, G and B, without alpha")
+
+// robustSync starts every robust block.
+var robustSync = [2]byte{0xb5, 0x1c}
+
+// robustNonce whitens robust blocks. Every block is whitened alike, so that
+// a block can be recognized wherever it turns up.
+var robustNonce = []byte("horcrux-robust-1")
+
+// robustPlan is how a payload is cut into blocks for an image.
+type robustPlan struct {
+	length, chunk int
+	data, parity  int
+	blockPixels   int
+	blocksPerRow  int
+	available     int // blocks in the whole image
+}
+
+func (p robustPlan) blockBytes() int {
+	return robustOverhead + p.chunk
+}
+
+// planRobust cuts n bytes into chunks and adds half as many parity chunks
+// again, then fits the blocks holding them into rows of the image.
+func planRobust(width, height, n int) (robustPlan, error) {
+	p := robustPlan{length: n}
+	p.chunk = min(max((width/4)*channelsPerPixel/8-robustOverhead, minRobustChunk), maxRobustChunk)
+	p.chunk = max(p.chunk, (n+maxRobustData-1)/maxRobustData)
+	p.blockPixels = (p.blockBytes()*8 + channelsPerPixel - 1) / channelsPerPixel
+	p.blocksPerRow = width / p.blockPixels
+	p.available = p.blocksPerRow * height
+	if p.blocksPerRow == 0 {
+		return p, fmt.Errorf("%w: robust blocks of %d pixels do not fit in a row of %d", ErrMessageTooLarge, p.blockPixels, width)
+	}
+
+	// Row after row, blocks take the chunks in turn. With a chunk count
+	// sharing no factor with the blocks in a row, each place in a row holds
+	// every chunk somewhere, so cutting off one side loses none.
+	p.data = max((n+p.chunk-1)/p.chunk, 1)
+	p.parity = max((p.data+1)/2, 1)
+	for gcd(p.blocksPerRow, p.data+p.parity) != 1 {
+		p.parity++
+	}
+	if total := p.data + p.parity; total > maxRobustShards || total > p.available {
+		return p, fmt.Errorf("%w: need %d robust blocks, have %d", ErrMessageTooLarge, total, p.available)
+	}
+	return p, nil
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+// robustCapacity returns the most bytes robust mode can hide in an image of
+// the given size. Less data is repeated more often, and survives more.
+func robustCapacity(width, height int) int {
+	limit := width * height * channelsPerPixel / 8
+	n := sort.Search(limit, func(n int) bool {
+		_, err := planRobust(width, height, n+1)
+		return err != nil
+	})
+	return n
+}
+
+// robustKeystream returns the first n bytes of the keystream robust blocks
+// are whitened with, or nil without a passphrase.
+func robustKeystream(opts Options, n int) []byte {
+	if opts.Passphrase == "" {
+		return nil
+	}
+	_, whiteKey := deriveKeys(opts.Passphrase)
+	ks := make([]byte, n)
+	(&layout{whiteK: whiteKey}).whiten(robustNonce, ks)
+	return ks
+}
+
+// embedRobust hides data in img, a fresh toNRGBA copy, as robust blocks.
+func embedRobust(img *image.NRGBA, data []byte, opts Options, rng *mrand.Rand) error {
+	width, height := img.Rect.Dx(), img.Rect.Dy()
+	p, err := planRobust(width, height, len(data))
+	if err != nil {
+		return err
+	}
+
+	// 1. Erasure code the data
+	enc, err := reedsolomon.New(p.data, p.parity)
+	if err != nil {
+		return err
+	}
+	padded := make([]byte, (p.data+p.parity)*p.chunk)
+	copy(padded, data)
+	chunks := make([][]byte, p.data+p.parity)
+	for i := range chunks {
+		chunks[i] = padded[i*p.chunk : (i+1)*p.chunk]
+	}
+	if err := enc.Encode(chunks); err != nil {
+		return err
+	}
+
+	// 2. Frame each chunk as a block
+	ks := robustKeystream(opts, p.blockBytes())
+	blocks := make([][]byte, len(chunks))
+	for i, chunk := range chunks {
+		b := append([]byte(nil), robustSync[:]...)
+		for _, v := range []int{i, p.data, p.parity, p.chunk} {
+			b = binary.BigEndian.AppendUint16(b, uint16(v))
+		}
+		b = binary.BigEndian.AppendUint32(b, uint32(p.length))
+		b = append(b, chunk...)
+		b = binary.BigEndian.AppendUint32(b, crc32.ChecksumIEEE(b[len(robustSync):]))
+		for j := range ks {
+			b[j] ^= ks[j]
+		}
+		blocks[i] = b
+	}
+
+	// 3. Fill every row with blocks, in R, G and B of consecutive pixels
+	for y := 0; y < height; y++ {
+		for s := 0; s < p.blocksPerRow; s++ {
+			block := blocks[(y*p.blocksPerRow+s)%len(blocks)]
+			start := (y*width + s*p.blockPixels) * 4
+			for j := 0; j < len(block)*8; j++ {
+				off := start + j/channelsPerPixel*4 + j%channelsPerPixel
+				img.Pix[off] = adjust(img.Pix[off], (block[j/8]>>(7-j%8))&1, 1, rng)
+			}
+		}
+	}
+	return nil
+}
+
+// robustScan collects the robust blocks found in the rows of an image.
+type robustScan struct {
+	width    int
+	ks       []byte
+	syncBits [16]byte
+	plan     *robustPlan // from the first block found
+	chunks   [][]byte
+	found    int
+}
+
+func newRobustScan(width int, opts Options) *robustScan {
+	s := &robustScan{width: width, ks: robustKeystream(opts, width*channelsPerPixel/8)}
+	for i := range s.syncBits {
+		b := robustSync[i/8]
+		if s.ks != nil {
+			b ^= s.ks[i/8]
+		}
+		s.syncBits[i] = (b >> (7 - i%8)) & 1
+	}
+	return s
+}
+
+// read packs n bytes from bits, starting at bit from, and removes the
+// whitening.
+func (s *robustScan) read(bits []byte, from, n int) []byte {
+	out := make([]byte, n)
+	for i := 0; i < n*8; i++ {
+		out[i/8] |= bits[from+i] << (7 - i%8)
+	}
+	for i := range out {
+		if s.ks != nil {
+			out[i] ^= s.ks[i]
+		}
+	}
+	return out
+}
+
+// scanRow looks for blocks at every pixel of a row, given as the LSBs of its
+// R, G and B values, and reports whether it found one.
+func (s *robustScan) scanRow(bits []byte) bool {
+	found := false
+	headerBits := (robustOverhead - 4) * 8
+	for x := 0; x*channelsPerPixel+headerBits <= len(bits); x++ {
+		start := x * channelsPerPixel
+		if !s.syncAt(bits, start) {
+			continue
+		}
+		header := s.read(bits, start, robustOverhead-4)
+		index := int(binary.BigEndian.Uint16(header[2:]))
+		p := robustPlan{
+			data:   int(binary.BigEndian.Uint16(header[4:])),
+			parity: int(binary.BigEndian.Uint16(header[6:])),
+			chunk:  int(binary.BigEndian.Uint16(header[8:])),
+			length: int(binary.BigEndian.Uint32(header[10:])),
+		}
+		if p.data == 0 || p.parity == 0 || p.data+p.parity > maxRobustShards || index >= p.data+p.parity ||
+			p.chunk == 0 || p.length == 0 || p.length > p.data*p.chunk || start+p.blockBytes()*8 > len(bits) {
+			continue
+		}
+		block := s.read(bits, start, p.blockBytes())
+		body := block[len(robustSync) : len(block)-4]
+		if crc32.ChecksumIEEE(body) != binary.BigEndian.Uint32(block[len(block)-4:]) {
+			continue
+		}
+		if s.plan == nil {
+			s.plan = &p
+			s.chunks = make([][]byte, p.data+p.parity)
+		} else if *s.plan != p {
+			continue
+		}
+		if s.chunks[index] == nil {
+			s.chunks[index] = body[robustOverhead-6:]
+			s.found++
+		}
+		found = true
+		x += (p.blockBytes()*8+channelsPerPixel-1)/channelsPerPixel - 1
+	}
+	return found
+}
+
+func (s *robustScan) syncAt(bits []byte, start int) bool {
+	for i, b := range s.syncBits {
+		if bits[start+i] != b {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *robustScan) complete() bool {
+	return s.plan != nil && s.found >= s.plan.data
+}
+
+// join decodes the chunks found into the payload.
+func (s *robustScan) join() ([]byte, error) {
+	if s.plan == nil {
+		return nil, ErrNoHiddenData
+	}
+	if !s.complete() {
+		return nil, fmt.Errorf("found %d of the %d intact robust blocks needed", s.found, s.plan.data)
+	}
+	enc, err := reedsolomon.New(s.plan.data, s.plan.parity)
+	if err != nil {
+		return nil, err
+	}
+	if err := enc.ReconstructData(s.chunks); err != nil {
+		return nil, fmt.Errorf("robust blocks do not decode: %w", err)
+	}
+	data := make([]byte, 0, s.plan.data*s.plan.chunk)
+	for _, chunk := range s.chunks[:s.plan.data] {
+		data = append(data, chunk...)
+	}
+	return data[:s.plan.length], nil
+}
+
+// extractRobust finds robust blocks anywhere in the image. It searches a
+// few rows first and gives up if they hold none, before converting the
+// whole image.
+func extractRobust(src *pixelSource, opts Options) ([]byte, error) {
+	width := src.width
+	height := src.img.Bounds().Dy()
+	if width*channelsPerPixel < robustOverhead*8 {
+		return nil, ErrNoHiddenData
+	}
+	s := newRobustScan(width, opts)
+	pix := make([]byte, 4*width)
+	bits := make([]byte, width*channelsPerPixel)
+	row := func(y int) []byte {
+		src.row(y, pix)
+		for i := range bits {
+			bits[i] = pix[i/channelsPerPixel*4+i%channelsPerPixel] & 1
+		}
+		return bits
+	}
+
+	// 1. Probe
+	probes := min(height, robustProbeRows)
+	found := false
+	for i := 0; i < probes && !found; i++ {
+		found = s.scanRow(row(height * i / probes))
+	}
+	if !found {
+		return nil, ErrNoHiddenData
+	}
+
+	// 2. Scan every row until enough chunks turn up
+	src.load()
+	for y := 0; y < height && !s.complete(); y++ {
+		s.scanRow(row(y))
+	}
+	return s.join()
+}
@@ -0,0 +1,71 @@
+package stego
+
+import (
+	"fmt"
+	"image"
+	"math"
+)
+
+// MaxEnlargement is how many times wider and taller Enlarge will make an
+// image. Past that the result is mostly interpolation, which looks nothing
+// like a photo and gives the data little cover.
+const MaxEnlargement = 8
+
+// Enlarge scales img up, keeping its aspect ratio, to the smallest size whose
+// Capacity holds n bytes, with bilinear interpolation. An image that is
+// already large enough is returned as an NRGBA copy.
+func Enlarge(img image.Image, n int, opts Options) (*image.NRGBA, error) {
+	b := img.Bounds()
+	width, height := b.Dx(), b.Dy()
+	if width == 0 || height == 0 {
+		return nil, fmt.Errorf("%w: empty image", ErrMessageTooLarge)
+	}
+
+	// 1. Find the size, from just below the estimate a column at a time
+	w, h := width, height
+	if have := Capacity(b, opts); have < n {
+		scale := math.Sqrt(float64(n) / float64(max(have, 1)))
+		w = max(width, int(float64(width)*scale)-1)
+	}
+	for ; ; w++ {
+		if w > width*MaxEnlargement {
+			return nil, fmt.Errorf("%w: the image would have to be enlarged more than %d times", ErrMessageTooLarge, MaxEnlargement)
+		}
+		h = max(height, int(math.Round(float64(w)*float64(height)/float64(width))))
+		if Capacity(image.Rect(0, 0, w, h), opts) >= n {
+			break
+		}
+	}
+
+	src := toNRGBA(img)
+	if w == width && h == height {
+		return src, nil
+	}
+
+	// 2. Sample each new pixel from the four nearest old ones, aligning pixel
+	// centres
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	sx, sy := float64(width)/float64(w), float64(height)/float64(h)
+	for y := 0; y < h; y++ {
+		fy := math.Max((float64(y)+0.5)*sy-0.5, 0)
+		y0 := int(fy)
+		y1 := min(y0+1, height-1)
+		wy := fy - float64(y0)
+		for x := 0; x < w; x++ {
+			fx := math.Max((float64(x)+0.5)*sx-0.5, 0)
+			x0 := int(fx)
+			x1 := min(x0+1, width-1)
+			wx := fx - float64(x0)
+
+			p00, p01 := src.PixOffset(x0, y0), src.PixOffset(x1, y0)
+			p10, p11 := src.PixOffset(x0, y1), src.PixOffset(x1, y1)
+			out := dst.PixOffset(x, y)
+			for c := 0; c < 4; c++ {
+				top := float64(src.Pix[p00+c])*(1-wx) + float64(src.Pix[p01+c])*wx
+				bottom := float64(src.Pix[p10+c])*(1-wx) + float64(src.Pix[p11+c])*wx
+				dst.Pix[out+c] = uint8(top*(1-wy) + bottom*wy + 0.5)
+			}
+		}
+	}
+	return dst, nil
+}
@@ -1,12 +1,11 @@
 package stego
 
 import (
-	"encoding/binary"
+	"crypto/rand"
 	"errors"
 	"fmt"
 	"image"
-	"image/color"
-	"image/draw"
+	mrand "math/rand/v2"
 )
 
 // ErrMessageTooLarge indicates the carrier image is too small to hold the data.
@@ -15,150 +14,256 @@ var ErrMessageTooLarge = errors.New("message too large for carrier image")
 // ErrNoHiddenData indicates the extraction failed to find a valid length prefix.
 var ErrNoHiddenData = errors.New("could not extract hidden data (invalid length prefix)")
 
-// Embed hides the data byte slice inside the carrier image using LSB encoding.
-// It returns a new image containing the hidden data.
-func Embed(carrier image.Image, data []byte) (image.Image, error) {
-	bounds := carrier.Bounds()
-	width, height := bounds.Dx(), bounds.Dy()
-	numPixels := width * height
+// channelsPerPixel is the number of colour channels carrying data (R, G, B).
+const channelsPerPixel = 3
+
+// MaxBitsPerChannel is the most low bits of a channel Options.BitsPerChannel
+// can use.
+const MaxBitsPerChannel = 4
+
+// Method selects how a channel value is changed to carry a bit. Both leave
+// the bit in the LSB, so Extract reads either.
+type Method int
+
+const (
+	// LSBReplacement overwrites the least significant bit. It is simple, but
+	// evens out the counts of each value pair 2k, 2k+1, which the chi-square
+	// and sample pair detectors in Analyze pick up.
+	LSBReplacement Method = iota
+
+	// LSBMatching adds or subtracts 1 at random when the LSB is wrong, which
+	// keeps the histogram smooth and defeats those detectors.
+	LSBMatching
+)
 
-	// 4 bytes for length + data
-	totalBitsRequired := (4 + len(data)) * 8
-	totalBitsAvailable := numPixels * 3
+// Options tune how Embed and Extract lay out the payload. The zero value is
+// the original layout: sequential from the top-left pixel, in the clear.
+type Options struct {
+	// Passphrase, when set, spreads the payload over the image in a
+	// pseudo-random pixel and channel order and whitens it with a keystream,
+	// both derived from the passphrase. Extract needs the same passphrase.
+	Passphrase string
 
-	if totalBitsRequired > totalBitsAvailable {
-		return nil, fmt.Errorf("%w: need %d pixels, have %d", ErrMessageTooLarge, totalBitsRequired/3, numPixels)
+	// Method is how channel values are changed. The default is LSBReplacement.
+	Method Method
+
+	// BitsPerChannel is how many low bits of each channel carry data, 1 to
+	// MaxBitsPerChannel; 0 means 1. Every extra bit holds as much as the
+	// first, but doubles how far a value can move.
+	BitsPerChannel int
+
+	// Alpha hides data in the alpha channel as well as R, G and B. An opaque
+	// carrier then becomes very slightly transparent.
+	Alpha bool
+
+	// Robust repeats the payload across the image in blocks that can be
+	// found anywhere, each with a checksum, and adds parity blocks, so that
+	// the data survives cropping and painted-over regions. It holds much
+	// less, and uses one bit in each of R, G and B only.
+	Robust bool
+}
+
+// depth is BitsPerChannel with the default filled in.
+func (o Options) depth() int {
+	return max(o.BitsPerChannel, 1)
+}
+
+// channels is the number of channels of a pixel carrying data.
+func (o Options) channels() int {
+	if o.Alpha {
+		return 4
 	}
+	return channelsPerPixel
+}
 
-	// Create a mutable NRGBA copy
-	output := image.NewNRGBA(image.Rect(0, 0, width, height))
-	draw.Draw(output, output.Bounds(), carrier, bounds.Min, draw.Src)
+// prefixed reports whether an embedding with these options starts with a
+// settings prefix. The defaults have none, as before the settings existed.
+func (o Options) prefixed() bool {
+	return o.depth() != 1 || o.Alpha
+}
+
+func (o Options) validate() error {
+	if o.BitsPerChannel < 0 || o.BitsPerChannel > MaxBitsPerChannel {
+		return fmt.Errorf("bits per channel must be 1 to %d, got %d", MaxBitsPerChannel, o.BitsPerChannel)
+	}
+	if o.Robust && o.prefixed() {
+		return errRobustOptions
+	}
+	return nil
+}
 
-	// Prepare payload: [Length (32-bit)] + [Data]
-	lengthBuf := make([]byte, 4)
-	binary.BigEndian.PutUint32(lengthBuf, uint32(len(data)))
-	fullPayload := append(lengthBuf, data...)
+// Embed hides the data byte slice inside the carrier image using LSB encoding.
+// It returns a new image containing the hidden data.
+func Embed(carrier image.Image, data []byte) (image.Image, error) {
+	return EmbedWithOptions(carrier, data, Options{})
+}
 
-	bitIndex := 0
-	payloadBits := len(fullPayload) * 8
+// Extract retrieves the hidden byte slice from a stego image.
+func Extract(stegoImage image.Image) ([]byte, error) {
+	return ExtractWithOptions(stegoImage, Options{})
+}
 
-	for y := 0; y < height; y++ {
-		for x := 0; x < width; x++ {
-			if bitIndex >= payloadBits {
-				return output, nil
-			}
+// EmbedWithOptions is Embed with a choice of layout. When opts uses more
+// than one bit per channel or the alpha channel, the first 8 pixels hold a
+// settings prefix that tells ExtractWithOptions so.
+func EmbedWithOptions(carrier image.Image, data []byte, opts Options) (image.Image, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	// Create a mutable NRGBA copy
+	output := toNRGBA(carrier)
 
-			// Direct access since we created the NRGBA
-			c := output.NRGBAAt(x, y)
+	rng, err := matchingRNG(opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Robust {
+		if err := embedRobust(output, data, opts, rng); err != nil {
+			return nil, err
+		}
+		return output, nil
+	}
 
-			// Helper to set LSB of a channel
-			setLSB := func(val *uint8) {
-				if bitIndex >= payloadBits {
-					return
-				}
-				byteIdx := bitIndex / 8
-				bitPos := 7 - (bitIndex % 8)
-				bit := (fullPayload[byteIdx] >> bitPos) & 1
+	l := newImageLayout(output.Rect.Dx(), output.Rect.Dy(), opts)
+	fullPayload, err := l.frame(data)
+	if err != nil {
+		return nil, err
+	}
 
-				*val = (*val & 0xFE) | bit
-				bitIndex++
-			}
+	totalBitsRequired := len(fullPayload) * 8
+	if totalBitsRequired > l.slots {
+		perPixel := opts.depth() * opts.channels()
+		return nil, fmt.Errorf("%w: need %d pixels, have %d", ErrMessageTooLarge, (totalBitsRequired+perPixel-1)/perPixel, l.slots/perPixel)
+	}
 
-			setLSB(&c.R)
-			setLSB(&c.G)
-			setLSB(&c.B)
+	// 1. Locate every bit, the slow part, in parallel
+	locs := make([]int, 0, settingsBits+totalBitsRequired)
+	bits := make([]byte, 0, cap(locs))
+	if opts.prefixed() {
+		prefix := settingsPrefix(opts)
+		for i := 0; i < settingsBits; i++ {
+			locs = append(locs, settingsLocation(i))
+			bits = append(bits, (prefix[i/8]>>(7-i%8))&1)
+		}
+	}
+	locs = append(locs, l.locations(totalBitsRequired)...)
+	for bitIndex := 0; bitIndex < totalBitsRequired; bitIndex++ {
+		bits = append(bits, (fullPayload[bitIndex/8]>>(7-bitIndex%8))&1)
+	}
 
-			output.SetNRGBA(x, y, c)
+	// 2. Change each value as little as its method allows. A value carries
+	// one bit unless there are more per channel, and then matching has to
+	// see all of its bits at once.
+	if rng == nil || opts.depth() == 1 {
+		for i, loc := range locs {
+			off, plane := splitLocation(loc)
+			output.Pix[off] = adjust(output.Pix[off], bits[i]<<plane, 1<<plane, rng)
+		}
+		return output, nil
+	}
+	want := make([]byte, len(output.Pix))
+	mask := make([]byte, len(output.Pix))
+	for i, loc := range locs {
+		off, plane := splitLocation(loc)
+		want[off] |= bits[i] << plane
+		mask[off] |= 1 << plane
+	}
+	for _, loc := range locs {
+		off, _ := splitLocation(loc)
+		if m := mask[off]; m != 0 {
+			output.Pix[off] = adjust(output.Pix[off], want[off], m, rng)
+			mask[off] = 0
 		}
 	}
 
 	return output, nil
 }
 
-// Extract retrieves the hidden byte slice from a stego image.
-func Extract(stegoImage image.Image) ([]byte, error) {
-	bounds := stegoImage.Bounds()
-	width, height := bounds.Dx(), bounds.Dy()
-
-	// State machine variables
-	var (
-		bitIndex      = 0
-		lengthBits    = make([]uint8, 32)
-		data          []byte
-		dataLen       uint32
-		readingLength = true // First phase: read 32 bits of length
-	)
-
-	// Optimization: check if we can direct-read NRGBA
-	nrgbaImg, isNRGBA := stegoImage.(*image.NRGBA)
-
-	for y := 0; y < height; y++ {
-		for x := 0; x < width; x++ {
-			// Get RGB channels
-			var r, g, b uint8
-
-			if isNRGBA {
-				// Fast path (avoids color conversion loss)
-				// Note: NRGBA struct fields are relative to (0,0), but At expects absolute.
-				// However, nrgbaImg.NRGBAAt takes relative coordinates if we use x,y 0..w,h?
-				// No, image.NRGBA.NRGBAAt(x, y) uses (x-Rect.Min.X). 
-				// Since we iterate 0..width, we must add Min.
-				c := nrgbaImg.NRGBAAt(bounds.Min.X+x, bounds.Min.Y+y)
-				r, g, b = c.R, c.G, c.B
-			} else {
-				// Slow path (fallback for other image types)
-				c := color.NRGBAModel.Convert(stegoImage.At(bounds.Min.X+x, bounds.Min.Y+y)).(color.NRGBA)
-				r, g, b = c.R, c.G, c.B
+// adjust returns v with the bits under mask set to want. LSB replacement
+// overwrites them; LSB matching picks the nearest value that has them,
+// breaking ties at random, which for one bit is a ±1 step.
+func adjust(v, want, mask byte, rng *mrand.Rand) byte {
+	if v&mask == want {
+		// Already right; leave the value alone
+		return v
+	}
+	if rng == nil {
+		return v&^mask | want
+	}
+	for d := 1; ; d++ {
+		down, up := int(v)-d, int(v)+d
+		okDown := down >= 0 && byte(down)&mask == want
+		okUp := up <= 255 && byte(up)&mask == want
+		switch {
+		case okDown && okUp:
+			if rng.IntN(2) == 0 {
+				return byte(down)
 			}
+			return byte(up)
+		case okDown:
+			return byte(down)
+		case okUp:
+			return byte(up)
+		}
+	}
+}
 
-			channels := []uint8{r, g, b}
-
-			for _, val := range channels {
-				bit := val & 1
-
-				if readingLength {
-					lengthBits[bitIndex] = bit
-					bitIndex++
-
-					if bitIndex == 32 {
-						// Finished reading length
-						for i := 0; i < 32; i++ {
-							if lengthBits[i] == 1 {
-								dataLen |= (1 << (31 - i))
-							}
-						}
-
-						// Sanity check
-						if dataLen == 0 || int(dataLen)*8 > (width*height*3)-32 {
-							return nil, ErrNoHiddenData
-						}
-
-						data = make([]byte, dataLen)
-						readingLength = false
-						bitIndex = 0 // Reset for data reading
-					}
-				} else {
-					// Reading Data
-					if bitIndex >= int(dataLen)*8 {
-						return data, nil
-					}
-
-					bytePos := bitIndex / 8
-					bitPos := 7 - (bitIndex % 8)
-
-					if bit == 1 {
-						data[bytePos] |= (1 << bitPos)
-					}
-					bitIndex++
-				}
-			}
+// ExtractWithOptions is Extract for an image embedded with the same
+// passphrase. The bits per channel and alpha use are read from the image's
+// settings prefix, and robust blocks are looked for anyway, so those fields
+// of opts are ignored.
+func ExtractWithOptions(stegoImage image.Image, opts Options) ([]byte, error) {
+	// Pixels are converted one by one until hidden data looks likely
+	src := newPixelSource(stegoImage)
+	opts.BitsPerChannel, opts.Alpha, opts.Robust = 0, false, false
+
+	// 1. The settings in the prefix, if there is one. Data embedded without
+	// one can look like a prefix by chance, so fall through on failure.
+	if settings, ok := readSettings(src.value, src.pixels(), opts); ok {
+		if data, err := extractPixels(src, settings); err == nil {
+			return data, nil
 		}
 	}
 
-	// If we finish the loop but haven't returned, check if we got everything
-	if !readingLength && bitIndex >= int(dataLen)*8 {
-		return data, nil
+	// 2. Robust blocks, anywhere in the image
+	if data, err := extractRobust(src, opts); !errors.Is(err, ErrNoHiddenData) {
+		return data, err
+	}
+
+	// 3. One bit in each of R, G and B
+	return extractPixels(src, opts)
+}
+
+func extractPixels(src *pixelSource, opts Options) ([]byte, error) {
+	l := newImageLayout(src.width, src.img.Bounds().Dy(), opts)
+	return l.unframe(func(loc int) byte {
+		off, plane := splitLocation(loc)
+		return (src.value(off) >> plane) & 1
+	}, src.load)
+}
+
+// Capacity returns the most bytes EmbedWithOptions can hide in an image with
+// the given bounds.
+func Capacity(bounds image.Rectangle, opts Options) int {
+	if opts.Robust {
+		return robustCapacity(bounds.Dx(), bounds.Dy())
+	}
+	pixels := bounds.Dx() * bounds.Dy()
+	if opts.prefixed() {
+		pixels = max(0, pixels-settingsPixels)
 	}
+	return frameCapacity(pixels*opts.depth()*opts.channels(), opts)
+}
 
-	return nil, ErrNoHiddenData
-}
\ No newline at end of file
+// matchingRNG returns the source of the random ±1 steps of LSB matching, or
+// nil for LSB replacement.
+func matchingRNG(opts Options) (*mrand.Rand, error) {
+	if opts.Method != LSBMatching {
+		return nil, nil
+	}
+	var seed [32]byte
+	if _, err := rand.Read(seed[:]); err != nil {
+		return nil, fmt.Errorf("failed to seed LSB matching: %w", err)
+	}
+	return mrand.New(mrand.NewChaCha8(seed)), nil
+}
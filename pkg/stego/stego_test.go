@@ -1,11 +1,20 @@
 package stego
 
 import (
+	"archive/zip"
 	"bytes"
+	"encoding/binary"
 	"errors" // Import required for errors.Is
+	"fmt"
 	"image"
 	"image/color"
 	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"math"
+	"math/rand/v2"
+	"strings"
 	"testing"
 )
 
@@ -52,4 +61,1193 @@ func TestCapacityCheck(t *testing.T) {
 	if !errors.Is(err, ErrMessageTooLarge) {
 		t.Errorf("Expected error wrapping ErrMessageTooLarge, got %v", err)
 	}
-}
\ No newline at end of file
+}
+func TestKeyedEmbedAndExtract(t *testing.T) {
+	carrier := image.NewNRGBA(image.Rect(0, 0, 64, 64))
+	draw.Draw(carrier, carrier.Bounds(), &image.Uniform{color.NRGBA{R: 120, G: 80, B: 200, A: 255}}, image.Point{}, draw.Src)
+
+	secret := []byte("# THIS FILE IS A HORCRUX.\nIt should not be readable in the LSB plane.")
+	opts := Options{Passphrase: "mischief managed"}
+
+	stegoImg, err := EmbedWithOptions(carrier, secret, opts)
+	if err != nil {
+		t.Fatalf("Failed to embed data: %v", err)
+	}
+
+	extracted, err := ExtractWithOptions(stegoImg, opts)
+	if err != nil {
+		t.Fatalf("Failed to extract data: %v", err)
+	}
+	if !bytes.Equal(secret, extracted) {
+		t.Errorf("Extracted data mismatch.\nExpected: %q\nGot: %q", secret, extracted)
+	}
+
+	// Neither the sequential reader nor a wrong passphrase finds the payload.
+	// The flat carrier's LSBs are mostly zero, so the sequential reader may
+	// see a plausible length; it must still not recover the secret.
+	if got, err := Extract(stegoImg); err == nil && bytes.Equal(got, secret) {
+		t.Errorf("Sequential extraction recovered the keyed payload")
+	}
+	if _, err := ExtractWithOptions(stegoImg, Options{Passphrase: "wrong"}); !errors.Is(err, ErrNoHiddenData) {
+		t.Errorf("Expected ErrNoHiddenData with a wrong passphrase, got %v", err)
+	}
+
+	// The top rows of the LSB plane must not spell out the header, at any
+	// bit alignment
+	pix := stegoImg.(*image.NRGBA).Pix
+	var bits []byte
+	for i := 0; i < 4*64*4; i++ {
+		if i%4 != 3 {
+			bits = append(bits, pix[i]&1)
+		}
+	}
+	for shift := 0; shift < 8; shift++ {
+		var lsb bytes.Buffer
+		for i := shift; i+8 <= len(bits); i += 8 {
+			var b byte
+			for k := 0; k < 8; k++ {
+				b = b<<1 | bits[i+k]
+			}
+			lsb.WriteByte(b)
+		}
+		if bytes.Contains(lsb.Bytes(), []byte("HORCRUX")) {
+			t.Error("Payload text is visible in the LSB plane")
+		}
+	}
+
+	// Two embeddings of the same data differ (random nonce)
+	again, err := EmbedWithOptions(carrier, secret, opts)
+	if err != nil {
+		t.Fatalf("Failed to embed data: %v", err)
+	}
+	if bytes.Equal(again.(*image.NRGBA).Pix, pix) {
+		t.Error("Keyed embeddings of the same payload are identical")
+	}
+}
+
+func TestPermutationIsBijective(t *testing.T) {
+	for _, n := range []uint64{1, 2, 3, 17, 300, 1 << 12, 12345} {
+		p := newPermutation(bytes.Repeat([]byte{7}, 32), n)
+		seen := make([]bool, n)
+		var buf permutationBuffer
+		for i := uint64(0); i < n; i++ {
+			s := p.at(i, &buf)
+			if s >= n || seen[s] {
+				t.Fatalf("n=%d: slot %d out of range or repeated", n, s)
+			}
+			seen[s] = true
+		}
+	}
+}
+
+// TestKeyedLayoutIsStable pins where a passphrase puts payload bits, which
+// images hidden in by earlier versions depend on.
+func TestKeyedLayoutIsStable(t *testing.T) {
+	p := newPermutation(bytes.Repeat([]byte{7}, 32), 1000003)
+	var buf permutationBuffer
+	for i, want := range []uint64{299735, 625544, 750382, 319768, 224691} {
+		if got := p.at(uint64(i), &buf); got != want {
+			t.Errorf("permutation at %d = %d, want %d", i, got, want)
+		}
+	}
+
+	l := newImageLayout(40, 30, Options{Passphrase: "x", BitsPerChannel: 2, Alpha: true})
+	locs := l.locations(5 * 97)
+	for i, want := range []int{11469, 17700, 5501, 16717, 14236} {
+		if got := locs[i*97]; got != want {
+			t.Errorf("location of bit %d = %d, want %d", i*97, got, want)
+		}
+	}
+}
+
+// naturalCover returns a smooth, slightly noisy image whose neighbouring
+// pixels correlate like a photo's. With comb set, every value is even, as
+// in an image scaled up from 7 bits; the uneven value pairs make a clean
+// baseline for the chi-square attack.
+func naturalCover(w, h int, comb bool) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	r := rand.New(rand.NewPCG(1, 2))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			for c := 0; c < 3; c++ {
+				v := 110 + 60*math.Sin(float64(x)/17+float64(c))*math.Cos(float64(y)/23) +
+					25*math.Sin(float64(x+2*y)/7) + r.NormFloat64()*2.5
+				v = math.Max(0, math.Min(255, v))
+				if comb {
+					v = 2 * math.Floor(v/2)
+				}
+				img.Pix[(y*w+x)*4+c] = uint8(math.Round(v))
+			}
+			img.Pix[(y*w+x)*4+3] = 255
+		}
+	}
+	return img
+}
+
+// randomPayload fills the given fraction of an image's capacity.
+func randomPayload(img image.Image, fraction float64) []byte {
+	b := img.Bounds()
+	data := make([]byte, int(fraction*float64(b.Dx()*b.Dy()*3)/8)-4)
+	for i := range data {
+		data[i] = byte(rand.IntN(256))
+	}
+	return data
+}
+
+func TestLSBMatching(t *testing.T) {
+	cover := naturalCover(64, 64, false)
+	secret := randomPayload(cover, 0.9)
+
+	stegoImg, err := EmbedWithOptions(cover, secret, Options{Method: LSBMatching})
+	if err != nil {
+		t.Fatalf("Failed to embed data: %v", err)
+	}
+	extracted, err := Extract(stegoImg)
+	if err != nil {
+		t.Fatalf("Failed to extract data: %v", err)
+	}
+	if !bytes.Equal(secret, extracted) {
+		t.Error("Extracted data mismatch")
+	}
+
+	// Every channel moved by at most one
+	out := stegoImg.(*image.NRGBA).Pix
+	for i := range out {
+		if d := int(out[i]) - int(cover.Pix[i]); d < -1 || d > 1 {
+			t.Fatalf("Channel %d changed by %d", i, d)
+		}
+	}
+
+	// The range ends never wrap around
+	edges := image.NewNRGBA(image.Rect(0, 0, 40, 40))
+	for i := range edges.Pix {
+		edges.Pix[i] = uint8(255 * (i / 4 % 2))
+	}
+	stegoImg, err = EmbedWithOptions(edges, randomPayload(edges, 0.9), Options{Method: LSBMatching})
+	if err != nil {
+		t.Fatalf("Failed to embed data: %v", err)
+	}
+	for i, v := range stegoImg.(*image.NRGBA).Pix {
+		if d := int(v) - int(edges.Pix[i]); d < -1 || d > 1 {
+			t.Fatalf("Channel %d wrapped from %d to %d", i, edges.Pix[i], v)
+		}
+	}
+}
+
+func TestSamplePairs(t *testing.T) {
+	cover := naturalCover(256, 256, false)
+	if r := Analyze(cover); r.SamplePairs > SamplePairsThreshold {
+		t.Errorf("Clean cover estimated at %.3f", r.SamplePairs)
+	}
+
+	half := randomPayload(cover, 0.5)
+	replaced, _ := EmbedWithOptions(cover, half, Options{Passphrase: "spread"})
+	if r := Analyze(replaced); math.Abs(r.SamplePairs-0.5) > 0.1 || !r.Suspicious() {
+		t.Errorf("Half-full replacement estimated at %.3f", r.SamplePairs)
+	}
+
+	matched, _ := EmbedWithOptions(cover, half, Options{Passphrase: "spread", Method: LSBMatching})
+	if r := Analyze(matched); r.SamplePairs > SamplePairsThreshold {
+		t.Errorf("LSB matching estimated at %.3f", r.SamplePairs)
+	}
+}
+
+func TestChiSquare(t *testing.T) {
+	cover := naturalCover(128, 128, true)
+	if r := Analyze(cover); r.ChiSquare > 0.05 {
+		t.Errorf("Clean cover: chi-square probability %.3f", r.ChiSquare)
+	}
+
+	// Sequential embedding over the first 30% shows in the profile
+	stegoImg, _ := Embed(cover, randomPayload(cover, 0.3))
+	r := Analyze(stegoImg)
+	if r.ChiSquareProfile[0] < ChiSquareThreshold || r.ChiSquareProfile[1] < ChiSquareThreshold {
+		t.Errorf("Embedded prefix not detected: %v", r.ChiSquareProfile)
+	}
+	if r.ChiSquare > 0.05 {
+		t.Errorf("Whole image: chi-square probability %.3f", r.ChiSquare)
+	}
+
+	full, _ := Embed(cover, randomPayload(cover, 1))
+	if r := Analyze(full); r.ChiSquare < ChiSquareThreshold || !r.Suspicious() {
+		t.Errorf("Full embedding: chi-square probability %.3f", r.ChiSquare)
+	}
+}
+
+// encodeJPEG compresses an image with the standard library, which writes
+// baseline JPEGs: 4:2:0 for colour, one component for grey.
+func encodeJPEG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 90}); err != nil {
+		t.Fatalf("Failed to encode JPEG: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestJPEGCoefficientRoundTrip(t *testing.T) {
+	colour := naturalCover(83, 61, false)
+	grey := image.NewGray(colour.Bounds())
+	draw.Draw(grey, grey.Bounds(), colour, image.Point{}, draw.Src)
+
+	for name, img := range map[string]image.Image{"colour": colour, "grey": grey} {
+		original := encodeJPEG(t, img)
+		f, err := parseJPEG(original)
+		if err != nil {
+			t.Fatalf("%s: failed to parse: %v", name, err)
+		}
+		out, err := f.encode()
+		if err != nil {
+			t.Fatalf("%s: failed to encode: %v", name, err)
+		}
+		if !bytes.Equal(original, out) {
+			t.Errorf("%s: unchanged coefficients did not give back the same file", name)
+		}
+	}
+}
+
+func TestJPEGRestartsAndOptimizedTables(t *testing.T) {
+	original := encodeJPEG(t, naturalCover(83, 61, false))
+	want, err := jpeg.Decode(bytes.NewReader(original))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Insert a restart interval of 5 MCUs and force optimized tables
+	f, err := parseJPEG(original)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, seg := range f.segments {
+		if seg.scan != nil {
+			seg.scan.restart = 5
+			f.segments = append(f.segments[:i], append([]jpegSegment{{marker: markerDRI, data: []byte{0, 5}}}, f.segments[i:]...)...)
+			break
+		}
+	}
+	out, err := f.encodeWith(true)
+	if err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+
+	// The standard decoder sees the same pixels, and so do we
+	got, err := jpeg.Decode(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("Standard decoder rejected the output: %v", err)
+	}
+	if !bytes.Equal(want.(*image.YCbCr).Y, got.(*image.YCbCr).Y) || !bytes.Equal(want.(*image.YCbCr).Cr, got.(*image.YCbCr).Cr) {
+		t.Error("Pixels changed through restart markers and optimized tables")
+	}
+	again, err := parseJPEG(out)
+	if err != nil {
+		t.Fatalf("Failed to parse own output: %v", err)
+	}
+	for i := range f.coefs {
+		if f.coefs[i] != again.coefs[i] {
+			t.Fatalf("Coefficient %d changed: %d != %d", i, f.coefs[i], again.coefs[i])
+		}
+	}
+}
+
+func TestEmbedJPEG(t *testing.T) {
+	carrier := encodeJPEG(t, naturalCover(160, 120, false))
+	secret := []byte("# THIS FILE IS A HORCRUX.\nIt hides in the DCT coefficients.")
+
+	for name, opts := range map[string]Options{
+		"sequential": {},
+		"keyed":      {Passphrase: "mischief managed"},
+		"matching":   {Passphrase: "mischief managed", Method: LSBMatching},
+	} {
+		out, err := EmbedJPEG(carrier, secret, opts)
+		if err != nil {
+			t.Fatalf("%s: failed to embed: %v", name, err)
+		}
+		if _, err := jpeg.Decode(bytes.NewReader(out)); err != nil {
+			t.Errorf("%s: output is not a valid JPEG: %v", name, err)
+		}
+		extracted, err := ExtractJPEG(out, opts)
+		if err != nil {
+			t.Fatalf("%s: failed to extract: %v", name, err)
+		}
+		if !bytes.Equal(secret, extracted) {
+			t.Errorf("%s: extracted data mismatch: %q", name, extracted)
+		}
+	}
+
+	// A clean JPEG is very unlikely to hold a plausible keyed frame
+	if _, err := ExtractJPEG(carrier, Options{Passphrase: "mischief managed"}); !errors.Is(err, ErrNoHiddenData) {
+		t.Errorf("Expected ErrNoHiddenData from the carrier, got %v", err)
+	}
+
+	if _, err := EmbedJPEG(carrier, make([]byte, 100000), Options{}); !errors.Is(err, ErrMessageTooLarge) {
+		t.Errorf("Expected ErrMessageTooLarge, got %v", err)
+	}
+	if _, err := EmbedJPEG([]byte("\x89PNG\r\n"), secret, Options{}); !errors.Is(err, ErrUnsupportedJPEG) {
+		t.Errorf("Expected ErrUnsupportedJPEG for a PNG, got %v", err)
+	}
+}
+
+// withChunks re-writes a PNG with extra chunks before and after its pixel
+// data.
+func withChunks(t *testing.T, file []byte, before, after []pngChunk) []byte {
+	t.Helper()
+	chunks, err := readPNGChunks(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	out.WriteString(pngSignature)
+	writePNGChunk(&out, "IHDR", chunks[0].data)
+	for _, c := range before {
+		writePNGChunk(&out, c.typ, c.data)
+	}
+	for _, c := range chunks[1:] {
+		if c.typ == "IEND" {
+			for _, c := range after {
+				writePNGChunk(&out, c.typ, c.data)
+			}
+		}
+		writePNGChunk(&out, c.typ, c.data)
+	}
+	return out.Bytes()
+}
+
+// chunkTypes lists the chunk types of a PNG, with runs of IDAT as one.
+func chunkTypes(t *testing.T, file []byte) []string {
+	t.Helper()
+	chunks, err := readPNGChunks(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var types []string
+	for _, c := range chunks {
+		if c.typ != "IDAT" || types[len(types)-1] != "IDAT" {
+			types = append(types, c.typ)
+		}
+	}
+	return types
+}
+
+func TestEncodePNGKeepsCarrierChunks(t *testing.T) {
+	secret := []byte("# THIS FILE IS A HORCRUX.\nOnly the pixels change.")
+	text := pngChunk{typ: "tEXt", data: []byte("Software\x00Lightroom")}
+	exif := pngChunk{typ: "eXIf", data: []byte("MM\x00\x2a\x00\x00\x00\x08\x00\x00")}
+
+	// A translucent RGBA carrier keeps its colour type and chunks; an unknown
+	// chunk not marked safe to copy is dropped
+	rgba := naturalCover(40, 30, false)
+	rgba.Pix[3] = 128
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, rgba); err != nil {
+		t.Fatal(err)
+	}
+	carrier := withChunks(t, buf.Bytes(),
+		[]pngChunk{{typ: "gAMA", data: []byte{0, 0, 0xB1, 0x8F}}, exif, {typ: "prVT", data: []byte("x")}, {typ: "prvt", data: []byte("y")}},
+		[]pngChunk{text})
+
+	stegoImg, err := Embed(rgba, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	if err := EncodePNG(&buf, stegoImg, carrier); err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+	out := buf.Bytes()
+
+	want := []string{"IHDR", "gAMA", "eXIf", "prvt", "IDAT", "tEXt", "IEND"}
+	if got := chunkTypes(t, out); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Chunks = %v, want %v", got, want)
+	}
+	if out[25] != pngTruecolorAlpha {
+		t.Errorf("Colour type = %d, want %d", out[25], pngTruecolorAlpha)
+	}
+	if !bytes.Contains(out, text.data) || !bytes.Contains(out, exif.data) {
+		t.Error("Metadata was not copied unchanged")
+	}
+
+	decoded, err := png.Decode(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("Output is not a valid PNG: %v", err)
+	}
+	extracted, err := Extract(decoded)
+	if err != nil || !bytes.Equal(extracted, secret) {
+		t.Errorf("Failed to extract from the re-encoded PNG: %v", err)
+	}
+
+	// A grey carrier becomes RGB and loses its grey colour profile
+	grey := image.NewGray(rgba.Bounds())
+	draw.Draw(grey, grey.Bounds(), rgba, image.Point{}, draw.Src)
+	buf.Reset()
+	if err := png.Encode(&buf, grey); err != nil {
+		t.Fatal(err)
+	}
+	carrier = withChunks(t, buf.Bytes(), []pngChunk{{typ: "iCCP", data: []byte("grey\x00\x00")}, {typ: "sBIT", data: []byte{5}}}, []pngChunk{text})
+
+	stegoImg, err = Embed(grey, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	if err := EncodePNG(&buf, stegoImg, carrier); err != nil {
+		t.Fatalf("Failed to encode: %v", err)
+	}
+	want = []string{"IHDR", "IDAT", "tEXt", "IEND"}
+	if got := chunkTypes(t, buf.Bytes()); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("Chunks = %v, want %v", got, want)
+	}
+	if buf.Bytes()[25] != pngTruecolor {
+		t.Errorf("Colour type = %d, want %d", buf.Bytes()[25], pngTruecolor)
+	}
+	decoded, err = png.Decode(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("Output is not a valid PNG: %v", err)
+	}
+	if extracted, err := Extract(decoded); err != nil || !bytes.Equal(extracted, secret) {
+		t.Errorf("Failed to extract from the re-encoded PNG: %v", err)
+	}
+}
+
+// minimalPDF is a one-page PDF with a classic cross-reference table.
+func minimalPDF() []byte {
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n")
+	offsets := []int{}
+	for _, obj := range []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 200 200] >>",
+	} {
+		offsets = append(offsets, b.Len())
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", len(offsets), obj)
+	}
+	xref := b.Len()
+	b.WriteString("xref\n0 4\n0000000000 65535 f \n")
+	for _, off := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size 4 /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", xref)
+	return b.Bytes()
+}
+
+func TestContainer(t *testing.T) {
+	secret := make([]byte, 200000) // far more than the pixels could hold
+	for i := range secret {
+		secret[i] = byte(rand.IntN(256))
+	}
+	cover := naturalCover(20, 20, false)
+
+	var pngFile, jpegFile, zipFile bytes.Buffer
+	if err := png.Encode(&pngFile, cover); err != nil {
+		t.Fatal(err)
+	}
+	if err := jpeg.Encode(&jpegFile, cover, nil); err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(&zipFile)
+	w, _ := zw.Create("spells.txt")
+	w.Write([]byte("Expelliarmus"))
+	zw.SetComment("keep safe")
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for format, carrier := range map[string][]byte{
+		FormatPNG: pngFile.Bytes(), FormatJPEG: jpegFile.Bytes(), FormatPDF: minimalPDF(), FormatZIP: zipFile.Bytes(),
+	} {
+		if got := ContainerFormat(carrier); got != format {
+			t.Errorf("ContainerFormat = %q, want %q", got, format)
+		}
+		if _, err := ExtractContainer(carrier); !errors.Is(err, ErrNoHiddenData) {
+			t.Errorf("%s: expected ErrNoHiddenData from the carrier, got %v", format, err)
+		}
+
+		out, err := EmbedContainer(carrier, secret)
+		if err != nil {
+			t.Fatalf("%s: failed to embed: %v", format, err)
+		}
+		extracted, err := ExtractContainer(out)
+		if err != nil {
+			t.Fatalf("%s: failed to extract: %v", format, err)
+		}
+		if !bytes.Equal(extracted, secret) {
+			t.Errorf("%s: extracted data mismatch", format)
+		}
+
+		// The file still opens normally
+		switch format {
+		case FormatPNG, FormatJPEG:
+			if _, _, err := image.Decode(bytes.NewReader(out)); err != nil {
+				t.Errorf("%s: no longer decodes: %v", format, err)
+			}
+		case FormatPDF:
+			if !bytes.HasSuffix(out, []byte("startxref\n"+string(startxrefPattern.FindSubmatch(carrier)[1])+"\n%%EOF\n")) {
+				t.Errorf("pdf: does not end with the original startxref")
+			}
+		case FormatZIP:
+			zr, err := zip.NewReader(bytes.NewReader(out), int64(len(out)))
+			if err != nil {
+				t.Fatalf("zip: no longer opens: %v", err)
+			}
+			f, err := zr.File[0].Open()
+			if err != nil {
+				t.Fatalf("zip: entry no longer opens: %v", err)
+			}
+			content, _ := io.ReadAll(f)
+			if string(content) != "Expelliarmus" || zr.Comment != "keep safe" {
+				t.Errorf("zip: content changed: %q, %q", content, zr.Comment)
+			}
+		}
+	}
+
+	if _, err := EmbedContainer([]byte("GIF89a"), secret); !errors.Is(err, ErrUnsupportedContainer) {
+		t.Errorf("Expected ErrUnsupportedContainer, got %v", err)
+	}
+}
+
+func TestParts(t *testing.T) {
+	data := []byte("Seven horcruxes, seven pieces of one soul")
+	payloads, err := SplitParts(data, []int{10, 20, len(data) - 30})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var parts []*Part
+	for i := len(payloads) - 1; i >= 0; i-- {
+		p, ok := ParsePart(payloads[i])
+		if !ok {
+			t.Fatalf("Part %d not recognized", i)
+		}
+		if len(payloads[i]) != PartOverhead+len(p.Data) {
+			t.Errorf("Part %d overhead = %d, want %d", i, len(payloads[i])-len(p.Data), PartOverhead)
+		}
+		parts = append(parts, p)
+	}
+
+	joined, err := JoinParts(parts)
+	if err != nil || !bytes.Equal(joined, data) {
+		t.Errorf("JoinParts = %q, %v", joined, err)
+	}
+	if _, err := JoinParts(parts[1:]); err == nil {
+		t.Error("Expected an error for a missing part")
+	}
+
+	other, _ := SplitParts(data, []int{len(data)})
+	p, _ := ParsePart(other[0])
+	if _, err := JoinParts(append(parts, p)); err == nil {
+		t.Error("Expected an error for parts of different sets")
+	}
+	if _, ok := ParsePart([]byte("# THIS FILE IS A HORCRUX.")); ok {
+		t.Error("A whole horcrux was taken for a part")
+	}
+	if _, err := SplitParts(data, []int{10}); err == nil {
+		t.Error("Expected an error for sizes not covering the data")
+	}
+}
+
+func TestCapacity(t *testing.T) {
+	carrier := naturalCover(40, 30, false)
+	for _, opts := range []Options{{}, {Passphrase: "x"}} {
+		n := Capacity(carrier.Bounds(), opts)
+		if _, err := EmbedWithOptions(carrier, make([]byte, n), opts); err != nil {
+			t.Errorf("Embedding the full capacity %d failed: %v", n, err)
+		}
+		if _, err := EmbedWithOptions(carrier, make([]byte, n+1), opts); !errors.Is(err, ErrMessageTooLarge) {
+			t.Errorf("Embedding past the capacity %d: got %v", n, err)
+		}
+	}
+
+	jpegCarrier := encodeJPEG(t, naturalCover(80, 60, false))
+	n, err := CapacityJPEG(jpegCarrier, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := EmbedJPEG(jpegCarrier, make([]byte, n), Options{}); err != nil {
+		t.Errorf("Embedding the full JPEG capacity %d failed: %v", n, err)
+	}
+	if _, err := EmbedJPEG(jpegCarrier, make([]byte, n+1), Options{}); !errors.Is(err, ErrMessageTooLarge) {
+		t.Errorf("Embedding past the JPEG capacity %d: got %v", n, err)
+	}
+}
+
+func TestEnlarge(t *testing.T) {
+	carrier := naturalCover(40, 30, false)
+	opts := Options{Passphrase: "x"}
+	n := Capacity(carrier.Bounds(), opts) * 3
+
+	big, err := Enlarge(carrier, n, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := big.Bounds()
+	if Capacity(b, opts) < n {
+		t.Fatalf("Enlarged to %v, which holds %d of %d bytes", b, Capacity(b, opts), n)
+	}
+	if w := b.Dx() - 1; Capacity(image.Rect(0, 0, w, int(math.Round(float64(w)*0.75))), opts) >= n {
+		t.Errorf("Enlarged to %v, more than needed", b)
+	}
+	if r := float64(b.Dx()) / float64(b.Dy()); r < 1.3 || r > 1.37 {
+		t.Errorf("Aspect ratio changed to %v", r)
+	}
+	// Corners keep their colour
+	if big.NRGBAAt(0, 0) != carrier.NRGBAAt(0, 0) || big.NRGBAAt(b.Dx()-1, b.Dy()-1) != carrier.NRGBAAt(39, 29) {
+		t.Error("Corners changed colour")
+	}
+
+	data := make([]byte, n)
+	for i := range data {
+		data[i] = byte(rand.IntN(256))
+	}
+	stegoImg, err := EmbedWithOptions(big, data, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	extracted, err := ExtractWithOptions(stegoImg, opts)
+	if err != nil || !bytes.Equal(extracted, data) {
+		t.Fatalf("Round trip through an enlarged carrier failed: %v", err)
+	}
+
+	same, err := Enlarge(carrier, 10, opts)
+	if err != nil || same.Bounds() != carrier.Bounds() {
+		t.Errorf("A large enough carrier was resized to %v (%v)", same.Bounds(), err)
+	}
+	if _, err := Enlarge(carrier, n*100, opts); !errors.Is(err, ErrMessageTooLarge) {
+		t.Errorf("Enlarging past %dx: got %v", MaxEnlargement, err)
+	}
+}
+
+func TestBitsPerChannel(t *testing.T) {
+	carrier := naturalCover(60, 40, false)
+
+	for depth := 1; depth <= MaxBitsPerChannel; depth++ {
+		for _, alpha := range []bool{false, true} {
+			for _, opts := range []Options{{}, {Passphrase: "x", Method: LSBMatching}} {
+				opts.BitsPerChannel, opts.Alpha = depth, alpha
+				name := fmt.Sprintf("%d bits, alpha %v, keyed %v", depth, alpha, opts.Passphrase != "")
+
+				n := Capacity(carrier.Bounds(), opts)
+				base := Capacity(carrier.Bounds(), Options{Passphrase: opts.Passphrase})
+				channels := 3
+				if alpha {
+					channels = 4
+				}
+				if depth > 1 || alpha {
+					if n < base*depth*channels/3-100 {
+						t.Errorf("%s: capacity %d, one bit in RGB holds %d", name, n, base)
+					}
+				} else if n != base {
+					t.Errorf("%s: capacity %d, want %d", name, n, base)
+				}
+
+				data := make([]byte, n)
+				for i := range data {
+					data[i] = byte(rand.IntN(256))
+				}
+				stegoImg, err := EmbedWithOptions(carrier, data, opts)
+				if err != nil {
+					t.Fatalf("%s: %v", name, err)
+				}
+				if _, err := EmbedWithOptions(carrier, make([]byte, n+1), opts); !errors.Is(err, ErrMessageTooLarge) {
+					t.Errorf("%s: embedding past the capacity %d: got %v", name, n, err)
+				}
+
+				// The settings are found without being given
+				extracted, err := ExtractWithOptions(stegoImg, Options{Passphrase: opts.Passphrase})
+				if err != nil || !bytes.Equal(extracted, data) {
+					t.Fatalf("%s: round trip failed: %v", name, err)
+				}
+
+				// Values move by less than 2^depth, and alpha only when used
+				out := stegoImg.(*image.NRGBA)
+				alphaChanged := false
+				for i, v := range out.Pix {
+					if d := abs(int(v) - int(carrier.Pix[i])); d >= 1<<depth {
+						t.Fatalf("%s: value %d moved by %d", name, i, d)
+					}
+					alphaChanged = alphaChanged || (i%4 == 3 && v != carrier.Pix[i])
+				}
+				if alphaChanged != alpha {
+					t.Errorf("%s: alpha changed: %v", name, alphaChanged)
+				}
+			}
+		}
+	}
+
+	if _, err := EmbedWithOptions(carrier, []byte("x"), Options{BitsPerChannel: MaxBitsPerChannel + 1}); err == nil {
+		t.Error("Expected an error for too many bits per channel")
+	}
+	if _, err := EmbedJPEG(encodeJPEG(t, carrier), []byte("x"), Options{BitsPerChannel: 2}); !errors.Is(err, ErrUnsupportedJPEG) {
+		t.Errorf("JPEG embedding with 2 bits per channel: got %v", err)
+	}
+}
+
+func TestRobust(t *testing.T) {
+	carrier := naturalCover(320, 240, false)
+	data := make([]byte, 200)
+	for i := range data {
+		data[i] = byte(rand.IntN(256))
+	}
+
+	// crop keeps part of an image and, as an editor would, saves it anew
+	crop := func(img image.Image, r image.Rectangle) image.Image {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img.(*image.NRGBA).SubImage(r)); err != nil {
+			t.Fatal(err)
+		}
+		out, err := png.Decode(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+
+	for _, opts := range []Options{{Robust: true}, {Robust: true, Passphrase: "x", Method: LSBMatching}} {
+		name := fmt.Sprintf("keyed %v", opts.Passphrase != "")
+		n := Capacity(carrier.Bounds(), opts)
+		if n < len(data) || n >= Capacity(carrier.Bounds(), Options{}) {
+			t.Errorf("%s: capacity %d", name, n)
+		}
+		if _, err := EmbedWithOptions(carrier, make([]byte, n), opts); err != nil {
+			t.Errorf("%s: embedding the capacity %d: %v", name, n, err)
+		}
+		if _, err := EmbedWithOptions(carrier, make([]byte, n+1), opts); !errors.Is(err, ErrMessageTooLarge) {
+			t.Errorf("%s: embedding past the capacity %d: got %v", name, n, err)
+		}
+
+		stegoImg, err := EmbedWithOptions(carrier, data, opts)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		// Painted over, cropped, or both, the data comes back
+		painted := image.NewNRGBA(stegoImg.Bounds())
+		draw.Draw(painted, painted.Rect, stegoImg, image.Point{}, draw.Src)
+		draw.Draw(painted, image.Rect(0, 0, 160, 240), image.NewUniform(color.NRGBA{200, 30, 30, 255}), image.Point{}, draw.Src)
+		for _, c := range []struct {
+			name string
+			img  image.Image
+		}{
+			{"whole", stegoImg},
+			{"cropped", crop(stegoImg, image.Rect(37, 53, 187, 153))},
+			{"painted over", painted},
+			{"painted over and cropped", crop(painted, image.Rect(150, 7, 320, 90))},
+		} {
+			extracted, err := ExtractWithOptions(c.img, Options{Passphrase: opts.Passphrase})
+			if err != nil || !bytes.Equal(extracted, data) {
+				t.Errorf("%s, %s: round trip failed: %v", name, c.name, err)
+			}
+		}
+
+		if opts.Passphrase != "" {
+			if _, err := ExtractWithOptions(stegoImg, Options{Passphrase: "y"}); !errors.Is(err, ErrNoHiddenData) {
+				t.Errorf("%s: wrong passphrase: got %v", name, err)
+			}
+		}
+	}
+
+	// The usual layout does not survive a crop
+	plain, err := EmbedWithOptions(carrier, data, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Extract(crop(plain, image.Rect(37, 53, 187, 153))); err == nil {
+		t.Error("Expected a cropped sequential embedding to be lost")
+	}
+
+	if _, err := EmbedWithOptions(carrier, data, Options{Robust: true, BitsPerChannel: 2}); err == nil {
+		t.Error("Expected an error for robust mode with 2 bits per channel")
+	}
+	if _, err := EmbedJPEG(encodeJPEG(t, carrier), data, Options{Robust: true}); !errors.Is(err, ErrUnsupportedJPEG) {
+		t.Errorf("JPEG embedding in robust mode: got %v", err)
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	// The same seed gives the same image, another seed another
+	if a, b := Generate(80, 60, 7), Generate(80, 60, 7); !bytes.Equal(a.Pix, b.Pix) {
+		t.Error("Generate is not deterministic")
+	}
+	if a, b := Generate(80, 60, 7), Generate(80, 60, 8); bytes.Equal(a.Pix, b.Pix) {
+		t.Error("Different seeds gave the same image")
+	}
+
+	// Clean, or half full with LSB matching, it passes for a photo
+	for seed := uint64(1); seed <= 3; seed++ {
+		cover := Generate(640, 480, seed)
+		if r := Analyze(cover); r.Suspicious() {
+			t.Errorf("seed %d: clean cover flagged: %+v", seed, r)
+		}
+		matched, err := EmbedWithOptions(cover, randomPayload(cover, 0.5), Options{Passphrase: "x", Method: LSBMatching})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r := Analyze(matched); r.Suspicious() {
+			t.Errorf("seed %d: half-full cover flagged: %+v", seed, r)
+		}
+	}
+
+	// Sized to the payload, at most half full
+	for _, c := range []struct {
+		n    int
+		opts Options
+	}{
+		{100, Options{}},
+		{300000, Options{Passphrase: "x"}},
+		{300000, Options{BitsPerChannel: 3}},
+		{5000, Options{Robust: true}},
+	} {
+		img, err := GenerateFor(c.n, c.opts, 1)
+		if err != nil {
+			t.Fatalf("%d bytes, %+v: %v", c.n, c.opts, err)
+		}
+		b := img.Bounds()
+		if capacity := Capacity(b, c.opts); capacity < 2*c.n || b.Dx() < minGeneratedWidth || b.Dy() != b.Dx()*3/4 {
+			t.Errorf("%d bytes, %+v: %dx%d holding %d", c.n, c.opts, b.Dx(), b.Dy(), capacity)
+		}
+		if b.Dx() > minGeneratedWidth && Capacity(image.Rect(0, 0, b.Dx()-1, (b.Dx()-1)*3/4), c.opts) >= 2*c.n {
+			t.Errorf("%d bytes, %+v: %dx%d is larger than needed", c.n, c.opts, b.Dx(), b.Dy())
+		}
+	}
+	if _, err := GenerateFor(1<<30, Options{}, 1); !errors.Is(err, ErrMessageTooLarge) {
+		t.Errorf("Generating for 1 GB: got %v", err)
+	}
+}
+
+// encodeWAV writes samples as a WAV file, with a LIST chunk after the data
+// to check other chunks are kept.
+func encodeWAV(samples []int16, channels, bits int) []byte {
+	var data bytes.Buffer
+	for _, s := range samples {
+		if bits == 8 {
+			data.WriteByte(byte(s>>8) + 128)
+		} else {
+			binary.Write(&data, binary.LittleEndian, s)
+		}
+	}
+	blockAlign := channels * bits / 8
+
+	var f bytes.Buffer
+	f.WriteString("RIFF")
+	binary.Write(&f, binary.LittleEndian, uint32(4+24+8+data.Len()+8+4))
+	f.WriteString("WAVEfmt ")
+	binary.Write(&f, binary.LittleEndian, struct {
+		Size                 uint32
+		Tag, Channels        uint16
+		Rate, ByteRate       uint32
+		BlockAlign, BitDepth uint16
+	}{16, wavePCM, uint16(channels), 44100, uint32(44100 * blockAlign), uint16(blockAlign), uint16(bits)})
+	f.WriteString("data")
+	binary.Write(&f, binary.LittleEndian, uint32(data.Len()))
+	f.Write(data.Bytes())
+	f.WriteString("LIST")
+	binary.Write(&f, binary.LittleEndian, uint32(4))
+	f.WriteString("INFO")
+	return f.Bytes()
+}
+
+func TestWAV(t *testing.T) {
+	samples := make([]int16, 2*4000)
+	for i := range samples {
+		samples[i] = int16(8000*math.Sin(float64(i)/20)) + int16(rand.IntN(64)-32)
+	}
+	samples[0], samples[1] = 32767, -32768
+	carrier := encodeWAV(samples, 2, 16)
+	if !IsWAV(carrier) {
+		t.Fatal("IsWAV rejected a WAV file")
+	}
+
+	for _, opts := range []Options{{}, {Passphrase: "x", Method: LSBMatching}} {
+		n, err := CapacityWAV(carrier, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data := make([]byte, n)
+		for i := range data {
+			data[i] = byte(rand.IntN(256))
+		}
+		out, err := EmbedWAV(carrier, data, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := EmbedWAV(carrier, make([]byte, n+1), opts); !errors.Is(err, ErrMessageTooLarge) {
+			t.Errorf("Embedding past the capacity %d: got %v", n, err)
+		}
+
+		extracted, err := ExtractWAV(out, opts)
+		if err != nil || !bytes.Equal(extracted, data) {
+			t.Fatalf("Round trip failed: %v", err)
+		}
+
+		// Only sample LSBs change
+		if len(out) != len(carrier) || !bytes.Equal(out[:44], carrier[:44]) || !bytes.Equal(out[len(out)-12:], carrier[len(carrier)-12:]) {
+			t.Error("Headers or trailing chunks changed")
+		}
+		for i := range samples {
+			s := int16(binary.LittleEndian.Uint16(out[44+2*i:]))
+			if d := abs(int(s) - int(samples[i])); d > 1 {
+				t.Fatalf("Sample %d moved by %d", i, d)
+			}
+		}
+	}
+
+	if _, err := EmbedWAV(encodeWAV(samples, 2, 8), []byte("x"), Options{}); !errors.Is(err, ErrUnsupportedWAV) {
+		t.Errorf("8-bit WAV: got %v", err)
+	}
+	if _, err := EmbedWAV(carrier, []byte("x"), Options{BitsPerChannel: 2}); !errors.Is(err, ErrUnsupportedWAV) {
+		t.Errorf("2 bits per sample: got %v", err)
+	}
+	if _, err := ExtractWAV(carrier, Options{}); err == nil {
+		t.Error("Expected no hidden data in a clean WAV")
+	}
+}
+
+func TestText(t *testing.T) {
+	letter := []byte("Dear Harry,\r\n\r\nI hope this finds you well. The weather at Hogwarts is lovely.  \r\n" +
+		strings.Repeat("Crookshanks sends his regards.\r\n", 6) + "Yours,\r\nHermione\r\n")
+	data := []byte("-----BEGIN HORCRUX-----\nnot really\n-----END HORCRUX-----\n")
+
+	for _, method := range []TextMethod{ZeroWidth, TrailingWhitespace} {
+		out, err := EmbedText(letter, data, method)
+		if err != nil {
+			t.Fatalf("method %d: %v", method, err)
+		}
+		extracted, err := ExtractText(out)
+		if err != nil || !bytes.Equal(extracted, data) {
+			t.Fatalf("method %d: round trip failed: %v", method, err)
+		}
+
+		// The visible text is unchanged, apart from trailing whitespace
+		visible := strings.Map(func(r rune) rune {
+			if isZeroWidth(r) {
+				return -1
+			}
+			return r
+		}, string(out))
+		if method == TrailingWhitespace {
+			lines := strings.Split(visible, "\r\n")
+			for i := range lines {
+				lines[i] = strings.TrimRight(lines[i], " \t")
+			}
+			visible = strings.Join(lines, "\r\n")
+		}
+		if want := strings.Replace(string(letter), "lovely.  ", "lovely.", 1); method == TrailingWhitespace && visible != want || method == ZeroWidth && visible != string(letter) {
+			t.Errorf("method %d: visible text changed to %q", method, visible)
+		}
+
+		// Embedding again replaces the data
+		again, err := EmbedText(out, []byte("second"), method)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if extracted, err := ExtractText(again); err != nil || string(extracted) != "second" {
+			t.Errorf("method %d: re-embedding gave %q, %v", method, extracted, err)
+		}
+	}
+
+	if n := CapacityText(letter, TrailingWhitespace); n != 11*maxTrailingBits/8-textOverhead {
+		t.Errorf("Trailing whitespace capacity %d", n)
+	}
+	if _, err := EmbedText(letter, make([]byte, 100), TrailingWhitespace); !errors.Is(err, ErrMessageTooLarge) {
+		t.Errorf("Too much data for the lines: got %v", err)
+	}
+	if out, err := EmbedText([]byte("NoSpacesHere\n"), data, ZeroWidth); err != nil || !strings.HasPrefix(string(out), "NoSpacesHere") || !strings.HasSuffix(string(out), "\n") {
+		t.Errorf("Text without spaces: %q, %v", out, err)
+	}
+	if _, err := ExtractText(letter); !errors.Is(err, ErrNoHiddenData) {
+		t.Errorf("Clean text: got %v", err)
+	}
+	if _, err := EmbedText([]byte{0xff, 0xfe}, data, ZeroWidth); err == nil {
+		t.Error("Expected an error for a carrier that is not UTF-8")
+	}
+}
+
+// fakeCarrier stores data after a marker, to check that registered carriers
+// take part in Detect and ExtractAny.
+type fakeCarrier struct{}
+
+func (fakeCarrier) Name() string            { return "fake" }
+func (fakeCarrier) Detect(head []byte) bool { return bytes.HasPrefix(head, []byte("\x00FAKE")) }
+
+func (fakeCarrier) Capacity(file []byte, _ Options) (int, error) { return 1 << 20, nil }
+
+func (fakeCarrier) Embed(file, data []byte, _ Options) ([]byte, string, error) {
+	return append(append([]byte("\x00FAKE"), data...), file...), ".fake", nil
+}
+
+func (fakeCarrier) Extract(file []byte, _ Options) ([]byte, error) {
+	return nil, ErrNoHiddenData
+}
+
+func TestCarriers(t *testing.T) {
+	img := naturalCover(160, 160, false)
+	var pngFile bytes.Buffer
+	if err := png.Encode(&pngFile, img); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string][]byte{
+		"wav":       encodeWAV(make([]int16, 8000), 1, 16),
+		"jpeg":      encodeJPEG(t, img),
+		"image":     pngFile.Bytes(),
+		"text":      []byte("Dear Harry,\nThe Burrow is lovely this time of year.\n"),
+		"container": minimalPDF(),
+	}
+
+	// 1. Each file is sniffed as its format, from its first bytes
+	for name, file := range files {
+		c := Detect(file[:min(len(file), SniffLen)])
+		if c == nil || c.Name() != name {
+			t.Errorf("Detect(%s file) = %v", name, c)
+		}
+	}
+	if c := Detect([]byte{0, 1, 2, 3, 0xff}); c != nil {
+		t.Errorf("Detect(binary junk) = %s, want nil", c.Name())
+	}
+	if !TextCarrier.Detect([]byte("caf\xc3")) {
+		t.Error("text cut off inside a rune was not detected")
+	}
+
+	// 2. ExtractAny finds what any of them hid, with their options
+	secret := []byte("# THIS FILE IS A HORCRUX.\nIt hides anywhere.")
+	opts := Options{Passphrase: "alohomora"}
+	for name, file := range files {
+		c := Detect(file)
+		out, _, err := c.Embed(file, secret, opts)
+		if err != nil {
+			t.Fatalf("%s: Embed failed: %v", name, err)
+		}
+		got, err := ExtractAny(out, opts)
+		if err != nil || !bytes.Equal(got, secret) {
+			t.Errorf("%s: ExtractAny = %q, %v", name, got, err)
+		}
+	}
+	if _, err := ExtractAny(files["image"], Options{}); !errors.Is(err, ErrNoHiddenData) {
+		t.Errorf("ExtractAny(clean image) = %v, want ErrNoHiddenData", err)
+	}
+	trailing := NewTextCarrier(TrailingWhitespace)
+	if trailing.Name() != TextCarrier.Name() {
+		t.Errorf("NewTextCarrier is named %q", trailing.Name())
+	}
+	out, _, err := trailing.Embed(files["text"], []byte("ok"), Options{})
+	if err != nil || bytes.ContainsFunc(out, isZeroWidth) {
+		t.Errorf("NewTextCarrier(TrailingWhitespace) embedded %q, %v", out, err)
+	}
+	if got, err := ExtractAny(out, Options{}); err != nil || string(got) != "ok" {
+		t.Errorf("ExtractAny(trailing whitespace) = %q, %v", got, err)
+	}
+
+	// 3. Registered carriers are tried after the built-in ones
+	Register(fakeCarrier{})
+	if c := Detect([]byte("\x00FAKE and more")); c == nil || c.Name() != "fake" {
+		t.Errorf("Detect did not find the registered carrier, got %v", c)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a name twice did not panic")
+		}
+	}()
+	Register(fakeCarrier{})
+}
+
+// benchmarkPhoto returns a 6 MP photo as image.Decode returns a JPEG, and a
+// stego copy holding 256 KB with a passphrase as it returns a PNG.
+func benchmarkPhoto(b *testing.B) (photo *image.YCbCr, stegoImg *image.RGBA, secret []byte, opts Options) {
+	b.Helper()
+	w, h := 3000, 2000
+	photo = image.NewYCbCr(image.Rect(0, 0, w, h), image.YCbCrSubsampleRatio420)
+	r := rand.New(rand.NewPCG(3, 4))
+	for _, plane := range [][]byte{photo.Y, photo.Cb, photo.Cr} {
+		for i := range plane {
+			plane[i] = byte(r.IntN(256))
+		}
+	}
+	secret = make([]byte, 256<<10)
+	for i := range secret {
+		secret[i] = byte(r.IntN(256))
+	}
+	opts = Options{Passphrase: "mischief managed"}
+
+	embedded, err := EmbedWithOptions(photo, secret, opts)
+	if err != nil {
+		b.Fatal(err)
+	}
+	stegoImg = image.NewRGBA(embedded.Bounds())
+	draw.Draw(stegoImg, stegoImg.Bounds(), embedded, image.Point{}, draw.Src)
+	return photo, stegoImg, secret, opts
+}
+
+func BenchmarkEmbed(b *testing.B) {
+	photo, _, secret, opts := benchmarkPhoto(b)
+	for _, method := range []Method{LSBReplacement, LSBMatching} {
+		opts.Method = method
+		b.Run(fmt.Sprintf("method=%d", method), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := EmbedWithOptions(photo, secret, opts); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkExtract(b *testing.B) {
+	photo, stegoImg, secret, opts := benchmarkPhoto(b)
+	b.Run("hidden", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			data, err := ExtractWithOptions(stegoImg, opts)
+			if err != nil || len(data) != len(secret) {
+				b.Fatal(err)
+			}
+		}
+	})
+	// Bind tries every photo in a directory
+	b.Run("clean", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := ExtractWithOptions(photo, opts); !errors.Is(err, ErrNoHiddenData) {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// TestToNRGBA checks the direct conversions against draw.Draw, and reading
+// single values against both, for images not starting at the origin.
+func TestToNRGBA(t *testing.T) {
+	r := rand.New(rand.NewPCG(5, 6))
+	rect := image.Rect(3, 5, 3+301, 5+217)
+
+	ycbcr := image.NewYCbCr(rect, image.YCbCrSubsampleRatio420)
+	rgba := image.NewRGBA(rect)
+	nrgba := image.NewNRGBA(rect)
+	gray := image.NewGray(rect)
+	for _, plane := range [][]byte{ycbcr.Y, ycbcr.Cb, ycbcr.Cr, gray.Pix, nrgba.Pix} {
+		for i := range plane {
+			plane[i] = byte(r.IntN(256))
+		}
+	}
+	for i := 0; i < len(rgba.Pix); i += 4 {
+		a := byte(r.IntN(256))
+		if i%12 == 0 {
+			a = 255
+		}
+		for c := 0; c < 3; c++ {
+			rgba.Pix[i+c] = byte(r.IntN(int(a) + 1))
+		}
+		rgba.Pix[i+3] = a
+	}
+
+	for _, img := range []image.Image{ycbcr, rgba, nrgba, gray} {
+		want := image.NewNRGBA(image.Rect(0, 0, rect.Dx(), rect.Dy()))
+		draw.Draw(want, want.Bounds(), img, rect.Min, draw.Src)
+		got := toNRGBA(img)
+		if got.Rect != want.Rect || !bytes.Equal(got.Pix, want.Pix) {
+			t.Errorf("%T: toNRGBA differs from draw.Draw", img)
+		}
+		src := newPixelSource(img)
+		for i := 0; i < 1000; i++ {
+			off := r.IntN(len(want.Pix))
+			if v := src.value(off); v != want.Pix[off] {
+				t.Fatalf("%T: value at %d = %d, want %d", img, off, v, want.Pix[off])
+			}
+		}
+	}
+}
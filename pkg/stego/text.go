@@ -0,0 +1,230 @@
+package stego
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// TextMethod selects how EmbedText hides data in text.
+type TextMethod int
+
+const (
+	// ZeroWidth hides data as invisible Unicode characters (U+2061 to
+	// U+2064, two bits each) spread over the spaces between words. The text
+	// looks the same, and copying it keeps the data, but a Unicode-aware
+	// editor or a byte count gives it away.
+	ZeroWidth TextMethod = iota
+
+	// TrailingWhitespace hides data as spaces and tabs at the ends of lines,
+	// one bit each, in the manner of SNOW. It survives plain ASCII channels,
+	// but editors that trim trailing whitespace destroy it.
+	TrailingWhitespace
+)
+
+const (
+	// textMagic starts hidden text frames: [magic] [length (32-bit)] [data].
+	// Trailing whitespace turns up in ordinary text, and the magic tells it
+	// apart.
+	textMagic    = "HRXT"
+	textOverhead = len(textMagic) + 4
+
+	// zeroWidthBase is the first of the four characters carrying two bits.
+	zeroWidthBase = '\u2061'
+
+	// maxTrailingBits is the most whitespace characters added to a line.
+	maxTrailingBits = 64
+)
+
+// EmbedText hides data in a copy of the UTF-8 text carrier and returns it.
+// Any data an earlier embedding left in the carrier is replaced: zero-width
+// characters of the kind ZeroWidth uses are removed, and TrailingWhitespace
+// first trims every line.
+func EmbedText(carrier []byte, data []byte, method TextMethod) ([]byte, error) {
+	if !utf8.Valid(carrier) {
+		return nil, errors.New("carrier is not UTF-8 text")
+	}
+	frame := make([]byte, 0, textOverhead+len(data))
+	frame = append(frame, textMagic...)
+	frame = binary.BigEndian.AppendUint32(frame, uint32(len(data)))
+	frame = append(frame, data...)
+
+	switch method {
+	case ZeroWidth:
+		return embedZeroWidth(stripZeroWidth(carrier), frame), nil
+	case TrailingWhitespace:
+		lines := textLines(carrier)
+		if capacity := CapacityText(carrier, method); len(data) > capacity {
+			return nil, fmt.Errorf("%w: need %d lines, have %d", ErrMessageTooLarge, (len(frame)*8+maxTrailingBits-1)/maxTrailingBits, len(lines))
+		}
+		return embedTrailing(lines, frame), nil
+	}
+	return nil, fmt.Errorf("unknown text method %d", method)
+}
+
+// ExtractText retrieves data hidden by EmbedText with either method.
+func ExtractText(text []byte) ([]byte, error) {
+	if data, ok := unframeText(readZeroWidth(text)); ok {
+		return data, nil
+	}
+	if data, ok := unframeText(readTrailing(text)); ok {
+		return data, nil
+	}
+	return nil, ErrNoHiddenData
+}
+
+// CapacityText returns the most bytes EmbedText can hide in carrier. Zero
+// width characters fit anywhere, as many as needed; trailing whitespace is
+// limited by the number of lines.
+func CapacityText(carrier []byte, method TextMethod) int {
+	if method == TrailingWhitespace {
+		return max(0, len(textLines(carrier))*maxTrailingBits/8-textOverhead)
+	}
+	return 1<<31 - 1 - textOverhead
+}
+
+func unframeText(frame []byte) ([]byte, bool) {
+	if len(frame) < textOverhead || string(frame[:len(textMagic)]) != textMagic {
+		return nil, false
+	}
+	n := binary.BigEndian.Uint32(frame[len(textMagic):])
+	if n == 0 || uint64(n) > uint64(len(frame)-textOverhead) {
+		return nil, false
+	}
+	return frame[textOverhead : textOverhead+int(n)], true
+}
+
+func isZeroWidth(r rune) bool {
+	return r >= zeroWidthBase && r < zeroWidthBase+4
+}
+
+func stripZeroWidth(text []byte) []byte {
+	return bytes.Map(func(r rune) rune {
+		if isZeroWidth(r) {
+			return -1
+		}
+		return r
+	}, text)
+}
+
+// embedZeroWidth spreads the characters for frame evenly over the spaces in
+// text, or puts them at the end of the first line if there are none.
+func embedZeroWidth(text, frame []byte) []byte {
+	symbols := make([]rune, 0, len(frame)*4)
+	for _, b := range frame {
+		for shift := 6; shift >= 0; shift -= 2 {
+			symbols = append(symbols, zeroWidthBase+rune(b>>shift&3))
+		}
+	}
+
+	spaces := bytes.Count(text, []byte(" "))
+	if spaces == 0 {
+		at := bytes.IndexByte(text, '\n')
+		if at < 0 {
+			at = len(text)
+		}
+		at = len(bytes.TrimRight(text[:at], "\r"))
+		out := append([]byte(nil), text[:at]...)
+		out = append(out, string(symbols)...)
+		return append(out, text[at:]...)
+	}
+
+	var out bytes.Buffer
+	out.Grow(len(text) + len(symbols)*3)
+	seen := 0
+	for _, b := range text {
+		out.WriteByte(b)
+		if b == ' ' {
+			// The j-th space is followed by the j-th share of the symbols
+			from, to := len(symbols)*seen/spaces, len(symbols)*(seen+1)/spaces
+			out.WriteString(string(symbols[from:to]))
+			seen++
+		}
+	}
+	return out.Bytes()
+}
+
+func readZeroWidth(text []byte) []byte {
+	var frame []byte
+	var b byte
+	n := 0
+	for _, r := range string(text) {
+		if !isZeroWidth(r) {
+			continue
+		}
+		b = b<<2 | byte(r-zeroWidthBase)
+		if n++; n%4 == 0 {
+			frame = append(frame, b)
+		}
+	}
+	return frame
+}
+
+// textLine is a line of text without its line ending.
+type textLine struct {
+	content, ending string
+}
+
+// textLines splits text into lines, keeping "\n" or "\r\n" endings. A final
+// newline does not start another line.
+func textLines(text []byte) []textLine {
+	var lines []textLine
+	s := string(text)
+	for len(s) > 0 {
+		line, rest, found := strings.Cut(s, "\n")
+		ending := ""
+		if found {
+			ending = "\n"
+			if strings.HasSuffix(line, "\r") {
+				line, ending = line[:len(line)-1], "\r\n"
+			}
+		}
+		lines = append(lines, textLine{line, ending})
+		s = rest
+	}
+	return lines
+}
+
+// embedTrailing trims the lines and writes frame as spaces (0) and tabs (1)
+// after them, the same number of bits on each until it runs out.
+func embedTrailing(lines []textLine, frame []byte) []byte {
+	bits := len(frame) * 8
+	perLine := (bits + len(lines) - 1) / len(lines)
+	var out strings.Builder
+	i := 0
+	for _, line := range lines {
+		out.WriteString(strings.TrimRight(line.content, " \t"))
+		for end := min(i+perLine, bits); i < end; i++ {
+			if frame[i/8]>>(7-i%8)&1 == 1 {
+				out.WriteByte('\t')
+			} else {
+				out.WriteByte(' ')
+			}
+		}
+		out.WriteString(line.ending)
+	}
+	return []byte(out.String())
+}
+
+func readTrailing(text []byte) []byte {
+	var frame []byte
+	var b byte
+	n := 0
+	for _, line := range textLines(text) {
+		trimmed := strings.TrimRight(line.content, " \t")
+		for _, c := range line.content[len(trimmed):] {
+			bit := byte(0)
+			if c == '\t' {
+				bit = 1
+			}
+			b = b<<1 | bit
+			if n++; n%8 == 0 {
+				frame = append(frame, b)
+			}
+		}
+	}
+	return frame
+}
@@ -0,0 +1,177 @@
+package stego
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// ErrUnsupportedWAV indicates a WAV file whose samples cannot carry data:
+// only uncompressed PCM with 16-bit samples can.
+var ErrUnsupportedWAV = errors.New("unsupported WAV (want 16-bit PCM)")
+
+var errWAVOptions = fmt.Errorf("%w: samples carry one bit each", ErrUnsupportedWAV)
+
+var errRobustWAV = fmt.Errorf("%w: robust mode needs pixels", ErrUnsupportedWAV)
+
+// WAVE format tags.
+const (
+	wavePCM        = 1
+	waveExtensible = 0xFFFE
+)
+
+// IsWAV reports whether data starts like a WAV file.
+func IsWAV(data []byte) bool {
+	return len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WAVE"
+}
+
+// EmbedWAV hides data in the least significant bits of the samples of a
+// 16-bit PCM WAV file and returns the new file. The payload is framed as in
+// an image: a 32-bit length, then the data, scattered and whitened with
+// opts.Passphrase. Every other byte of the file is kept. A bit flip in a
+// sample is 1/32768 of full scale, far below the noise of any recording.
+func EmbedWAV(carrier []byte, data []byte, opts Options) ([]byte, error) {
+	if opts.prefixed() {
+		return nil, errWAVOptions
+	}
+	if opts.Robust {
+		return nil, errRobustWAV
+	}
+	start, samples, err := parseWAV(carrier)
+	if err != nil {
+		return nil, err
+	}
+
+	out := bytes.Clone(carrier)
+	l := newWAVLayout(start, samples, opts)
+	fullPayload, err := l.frame(data)
+	if err != nil {
+		return nil, err
+	}
+
+	totalBitsRequired := len(fullPayload) * 8
+	if totalBitsRequired > l.slots {
+		return nil, fmt.Errorf("%w: need %d samples, have %d", ErrMessageTooLarge, totalBitsRequired, l.slots)
+	}
+
+	rng, err := matchingRNG(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	locs := l.locations(totalBitsRequired)
+	for bitIndex := 0; bitIndex < totalBitsRequired; bitIndex++ {
+		bit := int16(fullPayload[bitIndex/8]>>(7-bitIndex%8)) & 1
+		off := locs[bitIndex]
+		s := int16(binary.LittleEndian.Uint16(out[off:]))
+		switch {
+		case s&1 == bit:
+			continue
+		case rng != nil:
+			// Step towards the inside of the range, else at random
+			if s == 32767 || (s != -32768 && rng.IntN(2) == 0) {
+				s--
+			} else {
+				s++
+			}
+		default:
+			s = s&^1 | bit
+		}
+		binary.LittleEndian.PutUint16(out[off:], uint16(s))
+	}
+
+	return out, nil
+}
+
+// ExtractWAV retrieves data hidden by EmbedWAV with the same passphrase.
+func ExtractWAV(wavData []byte, opts Options) ([]byte, error) {
+	start, samples, err := parseWAV(wavData)
+	if err != nil {
+		return nil, err
+	}
+	return newWAVLayout(start, samples, opts).unframe(func(off int) byte {
+		return wavData[off] & 1
+	}, nil)
+}
+
+// CapacityWAV returns the most bytes EmbedWAV can hide in a WAV file.
+func CapacityWAV(carrier []byte, opts Options) (int, error) {
+	if opts.prefixed() {
+		return 0, errWAVOptions
+	}
+	if opts.Robust {
+		return 0, errRobustWAV
+	}
+	_, samples, err := parseWAV(carrier)
+	if err != nil {
+		return 0, err
+	}
+	return frameCapacity(samples, opts), nil
+}
+
+// newWAVLayout numbers the samples of all channels in file order and locates
+// each by its low byte.
+func newWAVLayout(start, samples int, opts Options) *layout {
+	return newLayout(samples, func(slot int) int {
+		return start + 2*slot
+	}, opts)
+}
+
+// parseWAV finds the sample data of a 16-bit PCM WAV file, returning where it
+// starts and how many samples it holds.
+func parseWAV(file []byte) (start, samples int, err error) {
+	if !IsWAV(file) {
+		return 0, 0, errors.New("not a WAV file")
+	}
+
+	var format []byte
+	pos := 12
+	for pos+8 <= len(file) {
+		id := string(file[pos : pos+4])
+		size := int(binary.LittleEndian.Uint32(file[pos+4:]))
+		body := pos + 8
+		if size < 0 || body+size > len(file) {
+			// Streamed files may leave the size unset; take what is there
+			size = len(file) - body
+		}
+
+		switch id {
+		case "fmt ":
+			format = file[body : body+size]
+		case "data":
+			if format == nil {
+				return 0, 0, errors.New("corrupt WAV file: data before fmt")
+			}
+			if err := checkWAVFormat(format); err != nil {
+				return 0, 0, err
+			}
+			blockAlign := int(binary.LittleEndian.Uint16(format[12:]))
+			return body, size / blockAlign * blockAlign / 2, nil
+		}
+		pos = body + size + size&1
+	}
+	return 0, 0, errors.New("corrupt WAV file: no data chunk")
+}
+
+// checkWAVFormat accepts the fmt chunk of 16-bit PCM, plain or extensible.
+func checkWAVFormat(format []byte) error {
+	if len(format) < 16 {
+		return errors.New("corrupt WAV file: short fmt chunk")
+	}
+	tag := binary.LittleEndian.Uint16(format)
+	channels := int(binary.LittleEndian.Uint16(format[2:]))
+	blockAlign := int(binary.LittleEndian.Uint16(format[12:]))
+	bitsPerSample := binary.LittleEndian.Uint16(format[14:])
+	if tag == waveExtensible && len(format) >= 26 {
+		// The sub-format GUID starts with the plain format tag
+		tag = binary.LittleEndian.Uint16(format[24:])
+	}
+	if tag != wavePCM || bitsPerSample != 16 {
+		return fmt.Errorf("%w: format %d, %d-bit", ErrUnsupportedWAV, tag, bitsPerSample)
+	}
+	if channels == 0 || blockAlign != 2*channels {
+		return errors.New("corrupt WAV file: bad block alignment")
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package wordlist
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// RadixSize is the number of words in the list; each word carries 10 bits.
+const RadixSize = 1024
+
+// bitsPerWord is log2(RadixSize).
+const bitsPerWord = 10
+
+// checksumLen is the number of SHA-256 bytes appended before encoding.
+const checksumLen = 2
+
+// ErrChecksum indicates the words decoded but the checksum did not match,
+// which usually means a word was mistyped or the words are out of order.
+var ErrChecksum = errors.New("word list checksum mismatch")
+
+var index = func() map[string]int {
+	m := make(map[string]int, 2*RadixSize)
+	for i, w := range words {
+		m[w] = i
+		m[w[:4]] = i
+	}
+	return m
+}()
+
+// Word returns the word for a 10-bit value.
+func Word(i int) string {
+	return words[i]
+}
+
+// Index returns the 10-bit value of a word. The first four letters are
+// enough, and case is ignored.
+func Index(word string) (int, bool) {
+	i, ok := index[strings.ToLower(strings.TrimSpace(word))]
+	return i, ok
+}
+
+// Encode turns data (at most 255 bytes) into a list of words that a person
+// can write down and type back. The encoding is
+// [length (1 byte) | data | SHA-256 checksum (2 bytes)], packed 10 bits per
+// word and zero-padded.
+func Encode(data []byte) ([]string, error) {
+	return EncodeTagged(data, nil)
+}
+
+// EncodeTagged is Encode with tag mixed into the checksum, which is then taken
+// over tag followed by the rest of the payload. The words only decode with
+// DecodeTagged and the same tag, tying them to whatever the tag identifies.
+func EncodeTagged(data, tag []byte) ([]string, error) {
+	if len(data) > 255 {
+		return nil, fmt.Errorf("data too long for a word list: %d bytes", len(data))
+	}
+
+	payload := append([]byte{byte(len(data))}, data...)
+	sum := checksum(tag, payload)
+	payload = append(payload, sum...)
+
+	totalBits := len(payload) * 8
+	numWords := (totalBits + bitsPerWord - 1) / bitsPerWord
+
+	result := make([]string, numWords)
+	for w := 0; w < numWords; w++ {
+		v := 0
+		for b := 0; b < bitsPerWord; b++ {
+			bit := w*bitsPerWord + b
+			v <<= 1
+			if bit < totalBits && payload[bit/8]&(0x80>>(bit%8)) != 0 {
+				v |= 1
+			}
+		}
+		result[w] = words[v]
+	}
+	return result, nil
+}
+
+// Decode reverses Encode. Words may be abbreviated to four letters.
+func Decode(list []string) ([]byte, error) {
+	return DecodeTagged(list, nil)
+}
+
+// DecodeTagged reverses EncodeTagged. Words encoded with another tag fail
+// with ErrChecksum, as mistyped ones do.
+func DecodeTagged(list []string, tag []byte) ([]byte, error) {
+	if len(list) == 0 {
+		return nil, errors.New("empty word list")
+	}
+
+	totalBits := len(list) * bitsPerWord
+	payload := make([]byte, (totalBits+7)/8)
+	for w, word := range list {
+		v, ok := Index(word)
+		if !ok {
+			return nil, fmt.Errorf("word %d (%q) is not in the word list", w+1, word)
+		}
+		for b := 0; b < bitsPerWord; b++ {
+			if v&(1<<(bitsPerWord-1-b)) != 0 {
+				bit := w*bitsPerWord + b
+				payload[bit/8] |= 0x80 >> (bit % 8)
+			}
+		}
+	}
+
+	n := int(payload[0])
+	end := 1 + n + checksumLen
+	// The padding must be shorter than one word
+	if end > len(payload) || totalBits-end*8 >= bitsPerWord {
+		return nil, fmt.Errorf("word count %d does not match encoded length %d", len(list), n)
+	}
+
+	if !bytes.Equal(checksum(tag, payload[:1+n]), payload[1+n:end]) {
+		return nil, ErrChecksum
+	}
+	return payload[1 : 1+n], nil
+}
+
+// checksum returns the checksum of a payload under tag.
+func checksum(tag, payload []byte) []byte {
+	h := sha256.New()
+	h.Write(tag)
+	h.Write(payload)
+	return h.Sum(nil)[:checksumLen]
+}
+
+// Split breaks a typed phrase into words, accepting spaces, commas, newlines
+// and numbered lists ("1. academic 2. acid").
+func Split(phrase string) []string {
+	return strings.FieldsFunc(phrase, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z')
+	})
+}
@@ -0,0 +1,87 @@
+package wordlist
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestWordList(t *testing.T) {
+	if !sort.StringsAreSorted(words[:]) {
+		t.Error("Word list is not sorted")
+	}
+	prefixes := make(map[string]bool)
+	for _, w := range words {
+		if prefixes[w[:4]] {
+			t.Errorf("Duplicate four-letter prefix %q", w[:4])
+		}
+		prefixes[w[:4]] = true
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	fragment := make([]byte, 33) // AES-256 key share plus x-coordinate
+	rand.Read(fragment)
+
+	list, err := Encode(fragment)
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+
+	// Type it back abbreviated, upper-case and numbered
+	var typed strings.Builder
+	for i, w := range list {
+		typed.WriteString(strings.ToUpper(w[:4]))
+		if i%2 == 0 {
+			typed.WriteString(", ")
+		} else {
+			typed.WriteString("\n3. ")
+		}
+	}
+
+	decoded, err := Decode(Split(typed.String()))
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if !bytes.Equal(decoded, fragment) {
+		t.Error("Decoded data does not match original")
+	}
+}
+
+func TestChecksum(t *testing.T) {
+	list, _ := Encode([]byte("a key fragment"))
+
+	// Swap two words, as a hurried custodian might
+	list[2], list[3] = list[3], list[2]
+	if _, err := Decode(list); !errors.Is(err, ErrChecksum) {
+		t.Errorf("Expected ErrChecksum, got %v", err)
+	}
+
+	// Drop the last word
+	list, _ = Encode([]byte("a key fragment"))
+	if _, err := Decode(list[:len(list)-1]); err == nil {
+		t.Error("Expected an error for a truncated word list")
+	}
+}
+
+func TestTag(t *testing.T) {
+	data := []byte("a key fragment")
+	list, err := EncodeTagged(data, []byte("will.pdf|1700000000"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := DecodeTagged(list, []byte("will.pdf|1700000000"))
+	if err != nil || !bytes.Equal(got, data) {
+		t.Fatalf("DecodeTagged = %q, %v", got, err)
+	}
+	if _, err := DecodeTagged(list, []byte("will.pdf|1700000001")); !errors.Is(err, ErrChecksum) {
+		t.Errorf("Expected ErrChecksum with another tag, got %v", err)
+	}
+	if _, err := Decode(list); !errors.Is(err, ErrChecksum) {
+		t.Errorf("Expected ErrChecksum without the tag, got %v", err)
+	}
+}
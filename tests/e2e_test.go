@@ -1,14 +1,30 @@
 package tests
 
 import (
+	"archive/zip"
 	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
 	"crypto/rand"
 	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/Beastly713/horcrux/cmd"
+	"github.com/Beastly713/horcrux/pkg/shamir"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -157,4 +173,1044 @@ func TestHeaderlessRoundTrip(t *testing.T) {
 	if bytes.Contains(content, []byte("THIS FILE IS A HORCRUX")) {
 		t.Fatal("Headerless mode failed: Found magic header in file")
 	}
-}
\ No newline at end of file
+}
+
+// resetFlags restores every flag of every command to its default value.
+// Cobra keeps flag state in package variables, so without this a flag set by
+// one test would leak into the next Execute call.
+func resetFlags(t *testing.T, c *cobra.Command) {
+	t.Helper()
+	reset := func(f *pflag.Flag) {
+		if sv, ok := f.Value.(pflag.SliceValue); ok {
+			sv.Replace(nil)
+		} else {
+			f.Value.Set(f.DefValue)
+		}
+		f.Changed = false
+	}
+	c.Flags().VisitAll(reset)
+	c.PersistentFlags().VisitAll(reset)
+	for _, sub := range c.Commands() {
+		resetFlags(t, sub)
+	}
+}
+
+// TestArmoredRoundTrip splits with --armor, pastes two shards into a single
+// text file and binds from it, then binds again from stdin.
+func TestArmoredRoundTrip(t *testing.T) {
+	tmpDir := t.TempDir()
+	originalFile := filepath.Join(tmpDir, "letter.txt")
+	originalContent := []byte("Meet me at the Shrieking Shack")
+	require.NoError(t, os.WriteFile(originalFile, originalContent, 0644))
+
+	root := cmd.GetRootCmd()
+	resetFlags(t, root)
+
+	shardDir := filepath.Join(tmpDir, "shards")
+	root.SetArgs([]string{"split", originalFile, "-n", "3", "-t", "2", "-d", shardDir, "--armor"})
+	require.NoError(t, root.Execute())
+
+	matches, err := filepath.Glob(filepath.Join(shardDir, "*.horcrux.asc"))
+	require.NoError(t, err)
+	require.Len(t, matches, 3)
+
+	// Paste two shards into one email-like text file
+	var pasted bytes.Buffer
+	pasted.WriteString("Forwarding the shards as requested:\n\n")
+	for _, m := range matches[:2] {
+		content, err := os.ReadFile(m)
+		require.NoError(t, err)
+		pasted.Write(content)
+		pasted.WriteString("\n-- \nSent from my phone\n\n")
+	}
+
+	mailDir := filepath.Join(tmpDir, "mail")
+	outDir := filepath.Join(tmpDir, "restored")
+	require.NoError(t, os.MkdirAll(mailDir, 0755))
+	require.NoError(t, os.MkdirAll(outDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(mailDir, "email.txt"), pasted.Bytes(), 0644))
+
+	resetFlags(t, root)
+	root.SetArgs([]string{"bind", mailDir, "--destination", outDir})
+	require.NoError(t, root.Execute())
+
+	restored, err := os.ReadFile(filepath.Join(outDir, "letter.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, originalContent, restored)
+
+	// Same shards again, this time from stdin
+	resetFlags(t, root)
+	root.SetIn(bytes.NewReader(pasted.Bytes()))
+	defer root.SetIn(nil)
+	root.SetArgs([]string{"bind", "-", "--destination", outDir, "--overwrite"})
+	require.NoError(t, os.Remove(filepath.Join(outDir, "letter.txt")))
+	require.NoError(t, root.Execute())
+
+	restored, err = os.ReadFile(filepath.Join(outDir, "letter.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, originalContent, restored)
+}
+
+// TestDuplicateArmoredShard binds with one shard found twice, pasted into a
+// text as well as in its own file, which must count once.
+func TestDuplicateArmoredShard(t *testing.T) {
+	tmpDir := t.TempDir()
+	originalFile := filepath.Join(tmpDir, "notes.txt")
+	originalContent := []byte("Mischief managed")
+	require.NoError(t, os.WriteFile(originalFile, originalContent, 0644))
+
+	root := cmd.GetRootCmd()
+	resetFlags(t, root)
+
+	shardDir := filepath.Join(tmpDir, "shards")
+	root.SetArgs([]string{"split", originalFile, "-n", "3", "-t", "2", "-d", shardDir, "--armor"})
+	require.NoError(t, root.Execute())
+
+	matches, err := filepath.Glob(filepath.Join(shardDir, "*.horcrux.asc"))
+	require.NoError(t, err)
+	require.Len(t, matches, 3)
+	require.NoError(t, os.Remove(matches[2]))
+
+	// Shard 1 twice and shard 2 once in the text, shard 2 again beside it
+	var pasted bytes.Buffer
+	for _, m := range []string{matches[0], matches[0], matches[1]} {
+		content, err := os.ReadFile(m)
+		require.NoError(t, err)
+		pasted.Write(content)
+		pasted.WriteString("\n")
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(shardDir, "pasted.txt"), pasted.Bytes(), 0644))
+	require.NoError(t, os.Remove(matches[0]))
+
+	outDir := filepath.Join(tmpDir, "restored")
+	require.NoError(t, os.MkdirAll(outDir, 0755))
+	resetFlags(t, root)
+	root.SetArgs([]string{"bind", shardDir, "--destination", outDir})
+	require.NoError(t, root.Execute())
+
+	restored, err := os.ReadFile(filepath.Join(outDir, "notes.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, originalContent, restored)
+}
+
+// TestDamagedArmoredBlock binds from a text holding three armored shards, one
+// of them damaged in transit, with the other two.
+func TestDamagedArmoredBlock(t *testing.T) {
+	tmpDir := t.TempDir()
+	originalFile := filepath.Join(tmpDir, "notes.txt")
+	originalContent := []byte("I must not tell lies")
+	require.NoError(t, os.WriteFile(originalFile, originalContent, 0644))
+
+	root := cmd.GetRootCmd()
+	resetFlags(t, root)
+
+	shardDir := filepath.Join(tmpDir, "shards")
+	root.SetArgs([]string{"split", originalFile, "-n", "3", "-t", "2", "-d", shardDir, "--armor"})
+	require.NoError(t, root.Execute())
+
+	matches, err := filepath.Glob(filepath.Join(shardDir, "*.horcrux.asc"))
+	require.NoError(t, err)
+	require.Len(t, matches, 3)
+
+	var pasted bytes.Buffer
+	for i, m := range matches {
+		content, err := os.ReadFile(m)
+		require.NoError(t, err)
+		if i == 0 {
+			// Drop a body line, as a careless copy might
+			lines := strings.Split(string(content), "\n")
+			content = []byte(strings.Join(append(lines[:3], lines[4:]...), "\n"))
+		}
+		pasted.Write(content)
+		pasted.WriteString("\n")
+	}
+
+	mailDir := filepath.Join(tmpDir, "mail")
+	outDir := filepath.Join(tmpDir, "restored")
+	require.NoError(t, os.MkdirAll(mailDir, 0755))
+	require.NoError(t, os.MkdirAll(outDir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(mailDir, "email.txt"), pasted.Bytes(), 0644))
+
+	resetFlags(t, root)
+	root.SetArgs([]string{"bind", mailDir, "--destination", outDir})
+	require.NoError(t, root.Execute())
+
+	restored, err := os.ReadFile(filepath.Join(outDir, "notes.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, originalContent, restored)
+}
+
+// TestPaperRoundTrip prints paper backups, "scans" the QR codes of two pages
+// into PNGs and binds them with the typed key words.
+func TestPaperRoundTrip(t *testing.T) {
+	tmpDir := t.TempDir()
+	originalFile := filepath.Join(tmpDir, "wallet.txt")
+	originalContent := []byte("correct horse battery staple")
+	require.NoError(t, os.WriteFile(originalFile, originalContent, 0644))
+
+	root := cmd.GetRootCmd()
+	resetFlags(t, root)
+
+	pageDir := filepath.Join(tmpDir, "pages")
+	root.SetArgs([]string{"split", originalFile, "-n", "3", "-t", "2", "-d", pageDir, "--paper"})
+	require.NoError(t, root.Execute())
+
+	pages, err := filepath.Glob(filepath.Join(pageDir, "*.html"))
+	require.NoError(t, err)
+	require.Len(t, pages, 3)
+
+	// Rasterize the SVG codes of two pages, and collect their key words
+	svgPath := regexp.MustCompile(`<svg[^>]*viewBox="0 0 (\d+) \d+".*?<path d="([^"]*)"`)
+	module := regexp.MustCompile(`M(\d+),(\d+)h1v1h-1z`)
+	listItem := regexp.MustCompile(`<li>(\w+)</li>`)
+
+	scanDir := filepath.Join(tmpDir, "scans")
+	require.NoError(t, os.MkdirAll(scanDir, 0755))
+	args := []string{"bind", "--scan", scanDir, "--destination", tmpDir}
+
+	for i, page := range pages[1:] {
+		html, err := os.ReadFile(page)
+		require.NoError(t, err)
+
+		for j, m := range svgPath.FindAllSubmatch(html, -1) {
+			side, _ := strconv.Atoi(string(m[1]))
+			const scale = 4
+			img := image.NewGray(image.Rect(0, 0, side*scale, side*scale))
+			draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+			for _, mod := range module.FindAllSubmatch(m[2], -1) {
+				x, _ := strconv.Atoi(string(mod[1]))
+				y, _ := strconv.Atoi(string(mod[2]))
+				draw.Draw(img, image.Rect(x*scale, y*scale, (x+1)*scale, (y+1)*scale), image.Black, image.Point{}, draw.Src)
+			}
+
+			f, err := os.Create(filepath.Join(scanDir, fmt.Sprintf("page%d_code%d.png", i, j)))
+			require.NoError(t, err)
+			require.NoError(t, png.Encode(f, img))
+			f.Close()
+		}
+
+		var words []string
+		for _, m := range listItem.FindAllSubmatch(html, -1) {
+			words = append(words, string(m[1]))
+		}
+		require.NotEmpty(t, words)
+		args = append(args, "--words", strings.Join(words, " "))
+	}
+
+	require.NoError(t, os.Remove(originalFile))
+
+	root.SetArgs(args)
+	require.NoError(t, root.Execute())
+
+	restored, err := os.ReadFile(originalFile)
+	require.NoError(t, err)
+	assert.Equal(t, originalContent, restored)
+}
+
+func TestSlip39SharesRoundTrip(t *testing.T) {
+	tmpDir := t.TempDir()
+	originalFile := filepath.Join(tmpDir, "vault.txt")
+	originalContent := []byte("Seven hundred and thirteen")
+	require.NoError(t, os.WriteFile(originalFile, originalContent, 0644))
+
+	root := cmd.GetRootCmd()
+	resetFlags(t, root)
+
+	shardDir := filepath.Join(tmpDir, "shards")
+	root.SetArgs([]string{"split", originalFile, "-n", "3", "-t", "2", "-d", shardDir})
+	require.NoError(t, root.Execute())
+
+	// Export the key as two SLIP-39 groups, either of which recovers it
+	sharesFile := filepath.Join(tmpDir, "shares.txt")
+	resetFlags(t, root)
+	root.SetArgs([]string{"shares", "export", shardDir, "--groups", "2of3,1of1", "--passphrase", "griphook", "-o", sharesFile})
+	require.NoError(t, root.Execute())
+
+	content, err := os.ReadFile(sharesFile)
+	require.NoError(t, err)
+	var mnemonics []string
+	for _, line := range strings.Split(string(content), "\n") {
+		if line != "" && !strings.HasPrefix(line, "#") {
+			mnemonics = append(mnemonics, line)
+		}
+	}
+	require.Len(t, mnemonics, 4)
+	assert.Len(t, strings.Fields(mnemonics[0]), 33, "a 256-bit key takes 33 words")
+
+	// Import two members of the first group from stdin
+	outDir := filepath.Join(tmpDir, "restored")
+	require.NoError(t, os.MkdirAll(outDir, 0755))
+	resetFlags(t, root)
+	root.SetIn(strings.NewReader("# from the safe\n" + mnemonics[2] + "\n\n" + mnemonics[0] + "\n"))
+	defer root.SetIn(nil)
+	root.SetArgs([]string{"shares", "import", shardDir, "--passphrase", "griphook", "-d", outDir})
+	require.NoError(t, root.Execute())
+
+	restored, err := os.ReadFile(filepath.Join(outDir, "vault.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, originalContent, restored)
+
+	// A single member of a 2-of-3 group is not enough
+	resetFlags(t, root)
+	root.SetIn(strings.NewReader(mnemonics[1]))
+	root.SetArgs([]string{"shares", "import", shardDir, "--passphrase", "griphook", "-d", outDir, "--overwrite"})
+	assert.Error(t, root.Execute())
+}
+
+func TestVaultAndSsssSharesRoundTrip(t *testing.T) {
+	tmpDir := t.TempDir()
+	originalFile := filepath.Join(tmpDir, "unseal.txt")
+	originalContent := []byte("Alohomora")
+	require.NoError(t, os.WriteFile(originalFile, originalContent, 0644))
+
+	root := cmd.GetRootCmd()
+	resetFlags(t, root)
+
+	shardDir := filepath.Join(tmpDir, "shards")
+	root.SetArgs([]string{"split", originalFile, "-n", "4", "-t", "3", "-d", shardDir})
+	require.NoError(t, root.Execute())
+
+	for _, tc := range []struct {
+		format string
+		prefix *regexp.Regexp
+	}{
+		{"vault", regexp.MustCompile(`^Unseal Key [1-4]: [A-Za-z0-9+/=]{44}$`)},
+		{"ssss", regexp.MustCompile(`^safe-[1-4]-[0-9a-f]{64}$`)},
+	} {
+		t.Run(tc.format, func(t *testing.T) {
+			sharesFile := filepath.Join(tmpDir, tc.format+".txt")
+			resetFlags(t, root)
+			root.SetArgs([]string{"shares", "export", shardDir, "--format", tc.format, "--token", "safe", "-o", sharesFile})
+			require.NoError(t, root.Execute())
+
+			content, err := os.ReadFile(sharesFile)
+			require.NoError(t, err)
+			var shares []string
+			for _, line := range strings.Split(string(content), "\n") {
+				if line != "" && !strings.HasPrefix(line, "#") {
+					assert.Regexp(t, tc.prefix, line)
+					shares = append(shares, line)
+				}
+			}
+			require.Len(t, shares, 4)
+
+			outDir := filepath.Join(tmpDir, tc.format)
+			require.NoError(t, os.MkdirAll(outDir, 0755))
+			resetFlags(t, root)
+			root.SetIn(strings.NewReader(strings.Join([]string{shares[3], shares[0], shares[2]}, "\n")))
+			defer root.SetIn(nil)
+			root.SetArgs([]string{"shares", "import", shardDir, "--format", tc.format, "-d", outDir})
+			require.NoError(t, root.Execute())
+
+			restored, err := os.ReadFile(filepath.Join(outDir, "unseal.txt"))
+			require.NoError(t, err)
+			assert.Equal(t, originalContent, restored)
+		})
+	}
+}
+
+// writeLegacyHorcruxes splits data the way the original jesseduffield/horcrux
+// tool did: AES-OFB with a zero IV, whole ciphertext per horcrux unless the
+// threshold equals the total, in which case bytes are dealt out round-robin.
+func writeLegacyHorcruxes(t *testing.T, dir, name string, data []byte, total, threshold int) {
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	require.NoError(t, err)
+	fragments, err := shamir.Split(key, total, threshold)
+	require.NoError(t, err)
+
+	block, err := aes.NewCipher(key)
+	require.NoError(t, err)
+	cipherText := make([]byte, len(data))
+	cipher.NewOFB(block, make([]byte, aes.BlockSize)).XORKeyStream(cipherText, data)
+
+	require.NoError(t, os.MkdirAll(dir, 0755))
+	for i := 0; i < total; i++ {
+		header, err := json.Marshal(map[string]any{
+			"originalFilename": name,
+			"timestamp":        1582384634,
+			"index":            i + 1,
+			"total":            total,
+			"threshold":        threshold,
+			"keyFragment":      fragments[i],
+		})
+		require.NoError(t, err)
+
+		body := cipherText
+		if threshold == total {
+			body = nil
+			for pos := i; pos < len(cipherText); pos += total {
+				body = append(body, cipherText[pos])
+			}
+		}
+
+		var buf bytes.Buffer
+		fmt.Fprintf(&buf, `# THIS FILE IS A HORCRUX.
+# IT IS ONE OF %d HORCRUXES THAT EACH CONTAIN PART OF AN ORIGINAL FILE.
+# THIS IS HORCRUX NUMBER %d.
+# IN ORDER TO RESURRECT THIS ORIGINAL FILE YOU MUST FIND THE OTHER %d HORCRUX(ES) AND THEN BIND THEM USING THE PROGRAM FOUND AT THE FOLLOWING URL
+# https://github.com/jesseduffield/horcrux
+
+-- HEADER --
+%s
+-- BODY --
+`, total, i+1, total-1, header)
+		buf.Write(body)
+		file := fmt.Sprintf("%s_%d_of_%d.horcrux", strings.TrimSuffix(name, filepath.Ext(name)), i+1, total)
+		require.NoError(t, os.WriteFile(filepath.Join(dir, file), buf.Bytes(), 0644))
+	}
+}
+
+func TestLegacyBindAndUpgrade(t *testing.T) {
+	tmpDir := t.TempDir()
+	legacyDir := filepath.Join(tmpDir, "legacy")
+	diary := []byte("Tom Marvolo Riddle")
+	locket := bytes.Repeat([]byte("Salazar Slytherin "), 100)
+	writeLegacyHorcruxes(t, legacyDir, "diary.txt", diary, 3, 3)
+	writeLegacyHorcruxes(t, legacyDir, "locket.bin", locket, 4, 2)
+	require.NoError(t, os.Remove(filepath.Join(legacyDir, "locket_1_of_4.horcrux")))
+
+	root := cmd.GetRootCmd()
+
+	// Bind reads both legacy splits transparently
+	outDir := filepath.Join(tmpDir, "restored")
+	require.NoError(t, os.MkdirAll(outDir, 0755))
+	resetFlags(t, root)
+	root.SetArgs([]string{"bind", legacyDir, "-d", outDir})
+	require.NoError(t, root.Execute())
+
+	restored, err := os.ReadFile(filepath.Join(outDir, "diary.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, diary, restored)
+	restored, err = os.ReadFile(filepath.Join(outDir, "locket.bin"))
+	require.NoError(t, err)
+	assert.Equal(t, locket, restored)
+
+	// Upgrade re-splits them in the current format
+	resetFlags(t, root)
+	root.SetArgs([]string{"upgrade", legacyDir})
+	require.NoError(t, root.Execute())
+
+	upgradedDir := filepath.Join(legacyDir, "upgraded")
+	matches, err := filepath.Glob(filepath.Join(upgradedDir, "*.horcrux"))
+	require.NoError(t, err)
+	assert.Len(t, matches, 7)
+
+	content, err := os.ReadFile(filepath.Join(upgradedDir, "diary_1_of_3.horcrux"))
+	require.NoError(t, err)
+	assert.NotContains(t, string(content), "jesseduffield")
+
+	// Upgrading again does not replace the new horcruxes
+	resetFlags(t, root)
+	root.SetArgs([]string{"upgrade", legacyDir})
+	err = root.Execute()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "already exists")
+	again, err := os.ReadFile(filepath.Join(upgradedDir, "diary_1_of_3.horcrux"))
+	require.NoError(t, err)
+	assert.Equal(t, content, again)
+
+	// Two of the upgraded diary horcruxes are not enough...
+	require.NoError(t, os.Remove(filepath.Join(upgradedDir, "diary_2_of_3.horcrux")))
+	require.NoError(t, os.Remove(filepath.Join(upgradedDir, "locket_1_of_4.horcrux")))
+	newOut := filepath.Join(tmpDir, "upgraded-restored")
+	require.NoError(t, os.MkdirAll(newOut, 0755))
+	resetFlags(t, root)
+	root.SetArgs([]string{"bind", upgradedDir, "-d", newOut})
+	require.NoError(t, root.Execute())
+
+	_, err = os.Stat(filepath.Join(newOut, "diary.txt"))
+	assert.True(t, os.IsNotExist(err))
+
+	// ...but any two of the locket's four are, like before
+	restored, err = os.ReadFile(filepath.Join(newOut, "locket.bin"))
+	require.NoError(t, err)
+	assert.Equal(t, locket, restored)
+}
+
+// writeCarrier saves a noisy PNG to hide horcruxes in.
+func writeCarrier(t *testing.T, path string, w, h int) {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	_, err := rand.Read(img.Pix)
+	require.NoError(t, err)
+	for i := 3; i < len(img.Pix); i += 4 {
+		img.Pix[i] = 255
+	}
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	defer f.Close()
+	require.NoError(t, png.Encode(f, img))
+}
+
+func decodeImage(t *testing.T, path string) image.Image {
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	require.NoError(t, err)
+	return img
+}
+
+func TestStegoPassphraseRoundTrip(t *testing.T) {
+	tmpDir := t.TempDir()
+	originalFile := filepath.Join(tmpDir, "map.txt")
+	originalContent := []byte("I solemnly swear that I am up to no good")
+	require.NoError(t, os.WriteFile(originalFile, originalContent, 0644))
+	carrier := filepath.Join(tmpDir, "owl.png")
+	writeCarrier(t, carrier, 120, 120)
+
+	root := cmd.GetRootCmd()
+	resetFlags(t, root)
+
+	shardDir := filepath.Join(tmpDir, "shards")
+	root.SetArgs([]string{"split", originalFile, "-n", "3", "-t", "2", "-d", shardDir, "-i", carrier, "--stego-passphrase", "nox"})
+	require.NoError(t, root.Execute())
+
+	// Without the passphrase nothing is found
+	outDir := filepath.Join(tmpDir, "restored")
+	require.NoError(t, os.MkdirAll(outDir, 0755))
+	resetFlags(t, root)
+	root.SetArgs([]string{"bind", shardDir, "-d", outDir})
+	assert.Error(t, root.Execute())
+
+	resetFlags(t, root)
+	root.SetArgs([]string{"bind", shardDir, "-d", outDir, "--stego-passphrase", "nox"})
+	require.NoError(t, root.Execute())
+
+	restored, err := os.ReadFile(filepath.Join(outDir, "map.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, originalContent, restored)
+}
+
+func TestStegoAnalyze(t *testing.T) {
+	tmpDir := t.TempDir()
+	originalFile := filepath.Join(tmpDir, "prophecy.txt")
+	require.NoError(t, os.WriteFile(originalFile, bytes.Repeat([]byte("Neither can live while the other survives. "), 40), 0644))
+	carrier := filepath.Join(tmpDir, "orb.png")
+	writeCarrier(t, carrier, 64, 64)
+
+	root := cmd.GetRootCmd()
+	resetFlags(t, root)
+	shardDir := filepath.Join(tmpDir, "shards")
+	root.SetArgs([]string{"split", originalFile, "-n", "2", "-t", "2", "-d", shardDir, "-i", carrier, "--lsb-matching"})
+	require.NoError(t, root.Execute())
+
+	// Matching still binds like replacement
+	resetFlags(t, root)
+	root.SetArgs([]string{"bind", shardDir, "-d", tmpDir, "--overwrite"})
+	require.NoError(t, root.Execute())
+
+	var out bytes.Buffer
+	resetFlags(t, root)
+	root.SetOut(&out)
+	defer root.SetOut(nil)
+	root.SetArgs([]string{"stego", "analyze", carrier, filepath.Join(shardDir, "prophecy_1_of_2.png")})
+	require.NoError(t, root.Execute())
+
+	report := out.String()
+	assert.Contains(t, report, "chi-square:")
+	assert.Contains(t, report, "sample pairs:")
+	assert.Contains(t, report, "of 2 images flagged")
+}
+
+// TestStegoBitsAndAlpha fits shards into a carrier too small for one bit per
+// channel, and binds without being told the settings.
+func TestStegoBitsAndAlpha(t *testing.T) {
+	tmpDir := t.TempDir()
+	originalFile := filepath.Join(tmpDir, "prophecy.txt")
+	originalContent := make([]byte, 1500)
+	_, err := rand.Read(originalContent)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(originalFile, originalContent, 0644))
+	carrier := filepath.Join(tmpDir, "orb.png")
+	writeCarrier(t, carrier, 40, 40)
+
+	root := cmd.GetRootCmd()
+	shardDir := filepath.Join(tmpDir, "shards")
+	split := []string{"split", originalFile, "-n", "2", "-t", "2", "-d", shardDir, "-i", carrier, "--stego-passphrase", "nox"}
+	resetFlags(t, root)
+	root.SetArgs(split)
+	require.Error(t, root.Execute())
+
+	resetFlags(t, root)
+	root.SetArgs(append(split, "--stego-bits", "3", "--stego-alpha"))
+	require.NoError(t, root.Execute())
+
+	outDir := filepath.Join(tmpDir, "restored")
+	require.NoError(t, os.MkdirAll(outDir, 0755))
+	resetFlags(t, root)
+	root.SetArgs([]string{"bind", shardDir, "-d", outDir, "--stego-passphrase", "nox"})
+	require.NoError(t, root.Execute())
+	restored, err := os.ReadFile(filepath.Join(outDir, "prophecy.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, originalContent, restored)
+
+	var out bytes.Buffer
+	resetFlags(t, root)
+	root.SetOut(&out)
+	defer root.SetOut(nil)
+	root.SetArgs([]string{"stego", "capacity", carrier})
+	require.NoError(t, root.Execute())
+	assert.Contains(t, out.String(), "(40x40)")
+	assert.Regexp(t, `(?m)^\s+1\s+596\s+792$`, out.String())
+	assert.Regexp(t, `(?m)^\s+4\s+2384\s+3180$`, out.String())
+
+	resetFlags(t, root)
+	root.SetArgs(append(split, "--stego-bits", "5"))
+	assert.Error(t, root.Execute())
+}
+
+// TestStegoRobustSurvivesCropping crops every horcrux image, as a photo
+// editor would, before binding.
+func TestStegoRobustSurvivesCropping(t *testing.T) {
+	tmpDir := t.TempDir()
+	originalFile := filepath.Join(tmpDir, "locket.txt")
+	originalContent := []byte("Salazar Slytherin's locket, hidden in the cave by the sea")
+	require.NoError(t, os.WriteFile(originalFile, originalContent, 0644))
+	carriers := make([]string, 3)
+	for i := range carriers {
+		carriers[i] = filepath.Join(tmpDir, fmt.Sprintf("cave_%d.png", i))
+		writeCarrier(t, carriers[i], 400, 300)
+	}
+
+	root := cmd.GetRootCmd()
+	resetFlags(t, root)
+	shardDir := filepath.Join(tmpDir, "shards")
+	root.SetArgs([]string{"split", originalFile, "-n", "3", "-t", "2", "-d", shardDir, "-i", strings.Join(carriers, ","), "--stego-passphrase", "nox", "--stego-robust"})
+	require.NoError(t, root.Execute())
+
+	for i := 1; i <= 3; i++ {
+		path := filepath.Join(shardDir, fmt.Sprintf("locket_%d_of_3.png", i))
+		img := decodeImage(t, path).(interface {
+			SubImage(image.Rectangle) image.Image
+		})
+		f, err := os.Create(path)
+		require.NoError(t, err)
+		require.NoError(t, png.Encode(f, img.SubImage(image.Rect(60+10*i, 40, 300, 260))))
+		require.NoError(t, f.Close())
+	}
+
+	outDir := filepath.Join(tmpDir, "restored")
+	require.NoError(t, os.MkdirAll(outDir, 0755))
+	resetFlags(t, root)
+	root.SetArgs([]string{"bind", shardDir, "-d", outDir, "--stego-passphrase", "nox"})
+	require.NoError(t, root.Execute())
+	restored, err := os.ReadFile(filepath.Join(outDir, "locket.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, originalContent, restored)
+
+	resetFlags(t, root)
+	root.SetArgs([]string{"split", originalFile, "-n", "3", "-t", "2", "-d", shardDir, "-i", carriers[0], "--stego-robust", "--stego-bits", "2"})
+	assert.Error(t, root.Execute())
+}
+
+func TestStegoGenerateRoundTrip(t *testing.T) {
+	tmpDir := t.TempDir()
+	originalFile := filepath.Join(tmpDir, "diary.txt")
+	originalContent := make([]byte, 40000)
+	_, err := rand.Read(originalContent)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(originalFile, originalContent, 0644))
+
+	root := cmd.GetRootCmd()
+	resetFlags(t, root)
+	shardDir := filepath.Join(tmpDir, "shards")
+	root.SetArgs([]string{"split", originalFile, "-n", "3", "-t", "2", "-d", shardDir, "--stego-generate", "--stego-seed", "42", "--lsb-matching"})
+	require.NoError(t, root.Execute())
+
+	// Each shard has an image of its own, large enough to be half empty
+	var first image.Image
+	for i := 1; i <= 3; i++ {
+		img := decodeImage(t, filepath.Join(shardDir, fmt.Sprintf("diary_%d_of_3.png", i)))
+		b := img.Bounds()
+		assert.GreaterOrEqual(t, b.Dx()*b.Dy()*3/8, 2*20000)
+		assert.Equal(t, b.Dx()*3/4, b.Dy())
+		if first == nil {
+			first = img
+		} else {
+			assert.NotEqual(t, first.At(b.Dx()/2, b.Dy()/2), img.At(b.Dx()/2, b.Dy()/2))
+		}
+	}
+
+	outDir := filepath.Join(tmpDir, "restored")
+	require.NoError(t, os.MkdirAll(outDir, 0755))
+	resetFlags(t, root)
+	root.SetArgs([]string{"bind", shardDir, "-d", outDir})
+	require.NoError(t, root.Execute())
+	restored, err := os.ReadFile(filepath.Join(outDir, "diary.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, originalContent, restored)
+
+	resetFlags(t, root)
+	root.SetArgs([]string{"split", originalFile, "-n", "3", "-t", "2", "-d", shardDir, "--stego-seed", "42"})
+	assert.Error(t, root.Execute())
+}
+
+func TestJPEGCarrierRoundTrip(t *testing.T) {
+	tmpDir := t.TempDir()
+	originalFile := filepath.Join(tmpDir, "diary.txt")
+	originalContent := []byte("Tom Riddle's diary, page one")
+	require.NoError(t, os.WriteFile(originalFile, originalContent, 0644))
+
+	// A noisy photo stand-in, saved as a baseline JPEG
+	noise := filepath.Join(tmpDir, "noise.png")
+	writeCarrier(t, noise, 160, 160)
+	f, err := os.Open(noise)
+	require.NoError(t, err)
+	img, err := png.Decode(f)
+	f.Close()
+	require.NoError(t, err)
+	carrier := filepath.Join(tmpDir, "vacation.jpg")
+	out, err := os.Create(carrier)
+	require.NoError(t, err)
+	require.NoError(t, jpeg.Encode(out, img, &jpeg.Options{Quality: 90}))
+	out.Close()
+
+	root := cmd.GetRootCmd()
+	resetFlags(t, root)
+	shardDir := filepath.Join(tmpDir, "shards")
+	root.SetArgs([]string{"split", originalFile, "-n", "3", "-t", "2", "-d", shardDir, "-i", carrier})
+	require.NoError(t, root.Execute())
+
+	// The carriers stay JPEGs
+	for i := 1; i <= 3; i++ {
+		data, err := os.ReadFile(filepath.Join(shardDir, fmt.Sprintf("diary_%d_of_3.jpg", i)))
+		require.NoError(t, err)
+		_, err = jpeg.Decode(bytes.NewReader(data))
+		assert.NoError(t, err)
+	}
+	_, err = os.Stat(filepath.Join(shardDir, "diary_1_of_3.png"))
+	assert.True(t, os.IsNotExist(err))
+
+	// An ordinary photo among them is ignored
+	photo, err := os.ReadFile(carrier)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(shardDir, "holiday.jpg"), photo, 0644))
+	require.NoError(t, os.Remove(filepath.Join(shardDir, "diary_2_of_3.jpg")))
+
+	outDir := filepath.Join(tmpDir, "restored")
+	require.NoError(t, os.MkdirAll(outDir, 0755))
+	resetFlags(t, root)
+	root.SetArgs([]string{"bind", shardDir, "-d", outDir})
+	require.NoError(t, root.Execute())
+
+	restored, err := os.ReadFile(filepath.Join(outDir, "diary.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, originalContent, restored)
+}
+
+func TestContainerRoundTrip(t *testing.T) {
+	tmpDir := t.TempDir()
+	originalFile := filepath.Join(tmpDir, "book.bin")
+	originalContent := make([]byte, 300000) // far too big for a small carrier's pixels
+	_, err := rand.Read(originalContent)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(originalFile, originalContent, 0644))
+
+	// A tiny PNG and a ZIP archive as containers
+	pngCarrier := filepath.Join(tmpDir, "owl.png")
+	writeCarrier(t, pngCarrier, 16, 16)
+	var zipBuf bytes.Buffer
+	zw := zip.NewWriter(&zipBuf)
+	w, err := zw.Create("notes.txt")
+	require.NoError(t, err)
+	w.Write([]byte("Potions homework"))
+	require.NoError(t, zw.Close())
+	zipCarrier := filepath.Join(tmpDir, "homework.zip")
+	require.NoError(t, os.WriteFile(zipCarrier, zipBuf.Bytes(), 0644))
+
+	root := cmd.GetRootCmd()
+	for _, carrier := range []string{pngCarrier, zipCarrier} {
+		ext := filepath.Ext(carrier)
+		shardDir := filepath.Join(tmpDir, "shards"+ext)
+
+		resetFlags(t, root)
+		root.SetArgs([]string{"split", originalFile, "-n", "3", "-t", "2", "-d", shardDir, "--container", carrier})
+		require.NoError(t, root.Execute())
+
+		// The copies still open as what they were
+		first := filepath.Join(shardDir, "book_1_of_3"+ext)
+		if ext == ".png" {
+			f, err := os.Open(first)
+			require.NoError(t, err)
+			_, err = png.Decode(f)
+			f.Close()
+			assert.NoError(t, err)
+		} else {
+			zr, err := zip.OpenReader(first)
+			require.NoError(t, err)
+			assert.Equal(t, "notes.txt", zr.File[0].Name)
+			zr.Close()
+		}
+
+		require.NoError(t, os.Remove(filepath.Join(shardDir, "book_3_of_3"+ext)))
+		outDir := filepath.Join(tmpDir, "restored"+ext)
+		require.NoError(t, os.MkdirAll(outDir, 0755))
+		resetFlags(t, root)
+		root.SetArgs([]string{"bind", shardDir, "-d", outDir})
+		require.NoError(t, root.Execute())
+
+		restored, err := os.ReadFile(filepath.Join(outDir, "book.bin"))
+		require.NoError(t, err)
+		assert.Equal(t, originalContent, restored)
+	}
+
+	// Container mode is exclusive with pixel stego
+	resetFlags(t, root)
+	root.SetArgs([]string{"split", originalFile, "-n", "2", "-t", "2", "--container", zipCarrier, "-i", pngCarrier})
+	assert.Error(t, root.Execute())
+}
+
+func TestCarrierDirSpreadsShards(t *testing.T) {
+	tmpDir := t.TempDir()
+	originalFile := filepath.Join(tmpDir, "locket.bin")
+	originalContent := make([]byte, 2000)
+	_, err := rand.Read(originalContent)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(originalFile, originalContent, 0644))
+
+	// An album of photos each far too small for a whole shard
+	album := filepath.Join(tmpDir, "album")
+	require.NoError(t, os.MkdirAll(album, 0755))
+	for i := 0; i < 10; i++ {
+		writeCarrier(t, filepath.Join(album, fmt.Sprintf("IMG_%04d.png", i)), 40, 40)
+	}
+
+	root := cmd.GetRootCmd()
+	resetFlags(t, root)
+	shardDir := filepath.Join(tmpDir, "shards")
+	root.SetArgs([]string{"split", originalFile, "-n", "3", "-t", "2", "-d", shardDir, "--carrier-dir", album, "--stego-passphrase", "nox"})
+	require.NoError(t, root.Execute())
+
+	parts, err := filepath.Glob(filepath.Join(shardDir, "locket_1_of_3_part*.png"))
+	require.NoError(t, err)
+	require.Greater(t, len(parts), 1, "shard 1 should be spread over several images")
+
+	// Losing one image loses that shard, but the other two still bind
+	require.NoError(t, os.Remove(parts[0]))
+	outDir := filepath.Join(tmpDir, "restored")
+	require.NoError(t, os.MkdirAll(outDir, 0755))
+	resetFlags(t, root)
+	root.SetArgs([]string{"bind", shardDir, "-d", outDir, "--stego-passphrase", "nox"})
+	require.NoError(t, root.Execute())
+
+	restored, err := os.ReadFile(filepath.Join(outDir, "locket.bin"))
+	require.NoError(t, err)
+	assert.Equal(t, originalContent, restored)
+
+	// An album too small for a shard fails cleanly
+	tiny := filepath.Join(tmpDir, "tiny")
+	require.NoError(t, os.MkdirAll(tiny, 0755))
+	writeCarrier(t, filepath.Join(tiny, "IMG_0001.png"), 8, 8)
+	resetFlags(t, root)
+	root.SetArgs([]string{"split", originalFile, "-n", "3", "-t", "2", "-d", filepath.Join(tmpDir, "nope"), "--carrier-dir", tiny})
+	assert.Error(t, root.Execute())
+}
+
+// TestCarrierPerShard gives each shard its own image, one of them too small
+// until --auto-scale enlarges it.
+func TestCarrierPerShard(t *testing.T) {
+	tmpDir := t.TempDir()
+	originalFile := filepath.Join(tmpDir, "prophecy.txt")
+	originalContent := []byte(strings.Repeat("Neither can live while the other survives. ", 20))
+	require.NoError(t, os.WriteFile(originalFile, originalContent, 0644))
+
+	var carriers []string
+	for i, size := range []int{100, 110, 20} {
+		path := filepath.Join(tmpDir, fmt.Sprintf("photo%d.png", i))
+		writeCarrier(t, path, size, size)
+		carriers = append(carriers, path)
+	}
+
+	root := cmd.GetRootCmd()
+	shardDir := filepath.Join(tmpDir, "shards")
+	split := []string{"split", originalFile, "-n", "3", "-t", "2", "-d", shardDir, "-i", strings.Join(carriers, ",")}
+
+	// The small image is caught before anything is written
+	resetFlags(t, root)
+	root.SetArgs(split)
+	err := root.Execute()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "photo2.png")
+	entries, _ := os.ReadDir(shardDir)
+	assert.Empty(t, entries)
+
+	resetFlags(t, root)
+	root.SetArgs(append(split, "--auto-scale"))
+	require.NoError(t, root.Execute())
+
+	// Each horcrux has the size of its own carrier, the last one enlarged
+	for i, size := range []int{100, 110} {
+		img := decodeImage(t, filepath.Join(shardDir, fmt.Sprintf("prophecy_%d_of_3.png", i+1)))
+		assert.Equal(t, size, img.Bounds().Dx())
+	}
+	img := decodeImage(t, filepath.Join(shardDir, "prophecy_3_of_3.png"))
+	assert.Greater(t, img.Bounds().Dx(), 20)
+
+	// Any two bind
+	require.NoError(t, os.Remove(filepath.Join(shardDir, "prophecy_1_of_3.png")))
+	outDir := filepath.Join(tmpDir, "restored")
+	require.NoError(t, os.MkdirAll(outDir, 0755))
+	resetFlags(t, root)
+	root.SetArgs([]string{"bind", shardDir, "-d", outDir})
+	require.NoError(t, root.Execute())
+	restored, err := os.ReadFile(filepath.Join(outDir, "prophecy.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, originalContent, restored)
+
+	// Two images for three shards is neither one nor one each
+	resetFlags(t, root)
+	root.SetArgs([]string{"split", originalFile, "-n", "3", "-t", "2", "-d", shardDir, "-i", carriers[0], "-i", carriers[1]})
+	assert.Error(t, root.Execute())
+}
+
+// writeWAV writes a second of 16-bit stereo noise.
+func writeWAV(t *testing.T, path string) {
+	samples := make([]byte, 44100*4)
+	_, err := rand.Read(samples)
+	require.NoError(t, err)
+
+	var f bytes.Buffer
+	f.WriteString("RIFF")
+	binary.Write(&f, binary.LittleEndian, uint32(36+len(samples)))
+	f.WriteString("WAVEfmt ")
+	binary.Write(&f, binary.LittleEndian, []uint32{16, 2<<16 | 1, 44100, 44100 * 4, 16<<16 | 4})
+	f.WriteString("data")
+	binary.Write(&f, binary.LittleEndian, uint32(len(samples)))
+	f.Write(samples)
+	require.NoError(t, os.WriteFile(path, f.Bytes(), 0644))
+}
+
+func TestAudioCarrierRoundTrip(t *testing.T) {
+	tmpDir := t.TempDir()
+	originalFile := filepath.Join(tmpDir, "memo.txt")
+	originalContent := []byte("Remember to feed Fawkes")
+	require.NoError(t, os.WriteFile(originalFile, originalContent, 0644))
+
+	var memos []string
+	for i := 1; i <= 3; i++ {
+		memos = append(memos, filepath.Join(tmpDir, fmt.Sprintf("memo%d.wav", i)))
+		writeWAV(t, memos[i-1])
+	}
+
+	root := cmd.GetRootCmd()
+	resetFlags(t, root)
+	shardDir := filepath.Join(tmpDir, "shards")
+	root.SetArgs([]string{"split", originalFile, "-n", "3", "-t", "2", "-d", shardDir, "--carrier-audio", strings.Join(memos, ","), "--stego-passphrase", "nox", "--lsb-matching"})
+	require.NoError(t, root.Execute())
+
+	// Only the samples' lowest bits differ from the recording
+	original, err := os.ReadFile(memos[0])
+	require.NoError(t, err)
+	shard, err := os.ReadFile(filepath.Join(shardDir, "memo_1_of_3.wav"))
+	require.NoError(t, err)
+	require.Equal(t, len(original), len(shard))
+	assert.Equal(t, original[:44], shard[:44])
+
+	outDir := filepath.Join(tmpDir, "restored")
+	require.NoError(t, os.MkdirAll(outDir, 0755))
+	resetFlags(t, root)
+	root.SetArgs([]string{"bind", shardDir, "-d", outDir, "--stego-passphrase", "nox"})
+	require.NoError(t, root.Execute())
+	restored, err := os.ReadFile(filepath.Join(outDir, "memo.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, originalContent, restored)
+
+	// Images are not recordings
+	carrier := filepath.Join(tmpDir, "owl.png")
+	writeCarrier(t, carrier, 50, 50)
+	resetFlags(t, root)
+	root.SetArgs([]string{"split", originalFile, "-n", "2", "-t", "2", "-d", shardDir, "--carrier-audio", carrier})
+	assert.Error(t, root.Execute())
+}
+
+func TestTextCarrierRoundTrip(t *testing.T) {
+	tmpDir := t.TempDir()
+	originalFile := filepath.Join(tmpDir, "spell.txt")
+	originalContent := []byte("Expecto Patronum")
+	require.NoError(t, os.WriteFile(originalFile, originalContent, 0644))
+
+	var body strings.Builder
+	for i := 0; i < 120; i++ {
+		fmt.Fprintf(&body, "Line %d of a perfectly ordinary wiki page about owls.\n", i)
+	}
+	letter := filepath.Join(tmpDir, "letter.md")
+	require.NoError(t, os.WriteFile(letter, []byte(body.String()), 0644))
+
+	root := cmd.GetRootCmd()
+	for _, encoding := range []string{"zero-width", "whitespace"} {
+		shardDir := filepath.Join(tmpDir, encoding)
+		resetFlags(t, root)
+		root.SetArgs([]string{"split", originalFile, "-n", "3", "-t", "2", "-d", shardDir, "--carrier-text", letter, "--text-encoding", encoding})
+		require.NoError(t, root.Execute())
+
+		// The words are all still there
+		shard, err := os.ReadFile(filepath.Join(shardDir, "spell_2_of_3.md"))
+		require.NoError(t, err)
+		visible := strings.Map(func(r rune) rune {
+			if r >= '\u2061' && r <= '\u2064' {
+				return -1
+			}
+			return r
+		}, string(shard))
+		assert.Equal(t, strings.Fields(body.String()), strings.Fields(visible), encoding)
+		assert.NotContains(t, string(shard), "BEGIN", encoding)
+
+		outDir := filepath.Join(tmpDir, encoding+"-restored")
+		require.NoError(t, os.MkdirAll(outDir, 0755))
+		resetFlags(t, root)
+		root.SetArgs([]string{"bind", shardDir, "-d", outDir})
+		require.NoError(t, root.Execute())
+		restored, err := os.ReadFile(filepath.Join(outDir, "spell.txt"))
+		require.NoError(t, err)
+		assert.Equal(t, originalContent, restored, encoding)
+	}
+
+	// A short note has too few lines for whitespace
+	note := filepath.Join(tmpDir, "note.txt")
+	require.NoError(t, os.WriteFile(note, []byte("Back soon.\n"), 0644))
+	resetFlags(t, root)
+	root.SetArgs([]string{"split", originalFile, "-n", "2", "-t", "2", "-d", filepath.Join(tmpDir, "nope"), "--carrier-text", note, "--text-encoding", "whitespace"})
+	assert.Error(t, root.Execute())
+}
+
+// TestRenamedFilesBind checks that bind tells horcruxes and carriers by their
+// contents, not their names.
+func TestRenamedFilesBind(t *testing.T) {
+	tmpDir := t.TempDir()
+	originalFile := filepath.Join(tmpDir, "map.txt")
+	originalContent := []byte("I solemnly swear that I am up to no good.")
+	require.NoError(t, os.WriteFile(originalFile, originalContent, 0644))
+	carrier := filepath.Join(tmpDir, "owl.png")
+	writeCarrier(t, carrier, 100, 100)
+
+	root := cmd.GetRootCmd()
+	for _, mode := range []string{"plain", "carrier"} {
+		shardDir := filepath.Join(tmpDir, mode)
+		split := []string{"split", originalFile, "-n", "3", "-t", "2", "-d", shardDir}
+		if mode == "carrier" {
+			split = append(split, "-i", carrier)
+		}
+		resetFlags(t, root)
+		root.SetArgs(split)
+		require.NoError(t, root.Execute())
+
+		// Names that say nothing, next to a file that is nothing
+		entries, err := os.ReadDir(shardDir)
+		require.NoError(t, err)
+		require.Len(t, entries, 3)
+		for i, e := range entries {
+			require.NoError(t, os.Rename(filepath.Join(shardDir, e.Name()), filepath.Join(shardDir, fmt.Sprintf("IMG_%04d.dat", i))))
+		}
+		require.NoError(t, os.WriteFile(filepath.Join(shardDir, "notes.png"), []byte("not an image"), 0644))
+
+		outDir := filepath.Join(tmpDir, mode+"-restored")
+		require.NoError(t, os.MkdirAll(outDir, 0755))
+		resetFlags(t, root)
+		root.SetArgs([]string{"bind", shardDir, "-d", outDir})
+		require.NoError(t, root.Execute(), mode)
+		restored, err := os.ReadFile(filepath.Join(outDir, "map.txt"))
+		require.NoError(t, err, mode)
+		assert.Equal(t, originalContent, restored, mode)
+	}
+}